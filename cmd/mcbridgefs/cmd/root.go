@@ -125,7 +125,7 @@ type Server struct {
 	c          chan os.Signal
 }
 
-func mustStartFuseFileServer(mountPoint string, root *mcbridgefs.Node) *Server {
+func mustStartFuseFileServer(mountPoint string, root fs.InodeEmbedder) *Server {
 	opts := &fs.Options{
 		AttrTimeout:  &timeout,
 		EntryTimeout: &timeout,
@@ -4,7 +4,8 @@ go 1.15
 
 require (
 	github.com/apex/log v1.9.0
-	github.com/go-resty/resty/v2 v2.5.0 // indirect
+	github.com/go-resty/resty/v2 v2.5.0
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/hanwen/go-fuse/v2 v2.0.3
 	github.com/hashicorp/go-uuid v1.0.1
 	github.com/labstack/echo/v4 v4.2.1
@@ -14,8 +15,9 @@ require (
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.6.1
-	github.com/subosito/gotenv v1.2.0 // indirect
+	github.com/subosito/gotenv v1.2.0
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
+	golang.org/x/text v0.3.5
 	gorm.io/driver/mysql v1.0.3
 	gorm.io/gorm v1.20.11
 )
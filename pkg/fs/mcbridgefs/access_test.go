@@ -0,0 +1,77 @@
+package mcbridgefs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestNodeAccess(t *testing.T) {
+	savedTransferRequest, savedReadOnly := transferRequest, readOnly
+	defer func() { transferRequest, readOnly = savedTransferRequest, savedReadOnly }()
+
+	tests := []struct {
+		name     string
+		request  mcmodel.TransferRequest
+		readOnly bool
+		read     syscall.Errno
+		write    syscall.Errno
+	}{
+		{name: "project", request: mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}, read: 0, write: 0},
+		{name: "read only project", request: mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}, readOnly: true, read: 0, write: syscall.EROFS},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transferRequest, readOnly = test.request, test.readOnly
+			n := newRootNode()
+
+			require.Equal(t, test.read, n.Access(context.Background(), unix.R_OK))
+			require.Equal(t, test.read, n.Access(context.Background(), unix.R_OK|unix.X_OK))
+			require.Equal(t, test.read, n.Access(context.Background(), unix.F_OK))
+			require.Equal(t, test.write, n.Access(context.Background(), unix.W_OK))
+			require.Equal(t, test.write, n.Access(context.Background(), unix.R_OK|unix.W_OK))
+		})
+	}
+}
+
+func TestNodeAccessForAnotherUser(t *testing.T) {
+	savedTransferRequest := transferRequest
+	defer func() { transferRequest = savedTransferRequest }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	n := newRootNode()
+	require.Equal(t, syscall.Errno(0), n.Access(callerContext(uid), unix.W_OK))
+
+	// Another user on the host can read the files but not write them.
+	require.Equal(t, syscall.Errno(0), n.Access(callerContext(uid+1), unix.R_OK))
+	require.Equal(t, syscall.EACCES, n.Access(callerContext(uid+1), unix.W_OK))
+}
+
+func TestTransferTypesAccess(t *testing.T) {
+	savedTransferTypes, savedTransferRequest := transferTypes, transferRequest
+	defer func() { transferTypes, transferRequest = savedTransferTypes, savedTransferRequest }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	root, _ := newTransferTypesRoot(WithTransferTypes("archive"))
+	archive, ok := root.GetChild(mcpath.ArchiveTransferType).Operations().(fs.NodeAccesser)
+	require.True(t, ok)
+	project, ok := root.GetChild(mcpath.GlobusTransferType).Operations().(fs.NodeAccesser)
+	require.True(t, ok)
+
+	// The mount root and the other transfer types can be read but not written to.
+	for _, n := range []fs.NodeAccesser{root, archive} {
+		require.Equal(t, syscall.Errno(0), n.Access(callerContext(uid), unix.R_OK|unix.X_OK))
+		require.Equal(t, syscall.EACCES, n.Access(callerContext(uid), unix.W_OK))
+		require.Equal(t, syscall.EACCES, n.Access(callerContext(uid), unix.R_OK|unix.W_OK))
+	}
+
+	// The project under its transfer type can still be written to.
+	require.Equal(t, syscall.Errno(0), project.Access(callerContext(uid), unix.W_OK))
+}
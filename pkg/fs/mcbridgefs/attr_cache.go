@@ -0,0 +1,85 @@
+package mcbridgefs
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+)
+
+// defaultAttrCacheTTL is kept short, since other bridges and the web UI can change a project
+// without this file system knowing.
+const defaultAttrCacheTTL = time.Second
+
+// attrCache holds the files that Getattr and Lookup looked up in the database, so that the
+// repeated calls FUSE clients make for the same paths don't each go to the database. Entries
+// expire after the TTL, and are invalidated when this file system changes the path. A TTL of 0
+// turns off caching.
+type attrCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[mcpath.TransferPathContext]attrCacheEntry
+}
+
+type attrCacheEntry struct {
+	file    *mcmodel.File
+	expires time.Time
+}
+
+func newAttrCache(ttl time.Duration) *attrCache {
+	return &attrCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[mcpath.TransferPathContext]attrCacheEntry),
+	}
+}
+
+// get returns the cached file for the path, if there is one and it hasn't expired.
+func (c *attrCache) get(pathContext *mcpath.TransferPathContext) (*mcmodel.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[*pathContext]
+	if !ok {
+		return nil, false
+	}
+
+	if c.now().After(entry.expires) {
+		delete(c.entries, *pathContext)
+		return nil, false
+	}
+
+	return entry.file, true
+}
+
+func (c *attrCache) put(pathContext *mcpath.TransferPathContext, file *mcmodel.File) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[*pathContext] = attrCacheEntry{file: file, expires: c.now().Add(c.ttl)}
+}
+
+// invalidate removes the cached entries for the path and, since it may be a directory that was
+// renamed or removed, for everything below it.
+func (c *attrCache) invalidate(pathContext *mcpath.TransferPathContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := pathContext.Path.String() + "/"
+	for key := range c.entries {
+		if key.ProjectID != pathContext.ProjectID || key.TransferType != pathContext.TransferType || key.UserID != pathContext.UserID {
+			continue
+		}
+
+		if key.Path == pathContext.Path || pathContext.Path.IsRoot() || strings.HasPrefix(key.Path.String(), prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package mcbridgefs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttrCache(t *testing.T) {
+	now := time.Now()
+	cache := newAttrCache(time.Second)
+	cache.now = func() time.Time { return now }
+
+	dir := &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d1"}
+	file := &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d1/file.txt"}
+	sibling := &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d10"}
+	otherProject := &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 3, Path: "d1"}
+
+	for _, p := range []*mcpath.TransferPathContext{dir, file, sibling, otherProject} {
+		cache.put(p, &mcmodel.File{Name: p.Path.String()})
+	}
+
+	f, ok := cache.get(file)
+	require.True(t, ok)
+	require.Equal(t, "d1/file.txt", f.Name)
+
+	// Invalidating a directory removes everything below it, but not paths that only share a prefix
+	// or that are in another project.
+	cache.invalidate(dir)
+	_, ok = cache.get(dir)
+	require.False(t, ok)
+	_, ok = cache.get(file)
+	require.False(t, ok)
+	_, ok = cache.get(sibling)
+	require.True(t, ok)
+	_, ok = cache.get(otherProject)
+	require.True(t, ok)
+
+	now = now.Add(2 * time.Second)
+	_, ok = cache.get(sibling)
+	require.False(t, ok)
+}
+
+func TestAttrCacheDisabled(t *testing.T) {
+	cache := newAttrCache(0)
+	p := &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "file.txt"}
+	cache.put(p, &mcmodel.File{})
+	_, ok := cache.get(p)
+	require.False(t, ok)
+}
+
+func TestAttrCacheInvalidatedOnWrite(t *testing.T) {
+	savedTransferRequest, savedAttrs := transferRequest, attrs
+	defer func() { transferRequest, attrs = savedTransferRequest, savedAttrs }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	attrs = newAttrCache(time.Minute)
+
+	n := newRootNode()
+	attrs.put(n.ToTransferPathContext(), &mcmodel.File{Size: 10})
+	_, ok := attrs.get(n.ToTransferPathContext())
+	require.True(t, ok)
+
+	tmp, err := ioutil.TempFile("", "attr-cache")
+	require.NoError(t, err)
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	in := &fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_SIZE
+	fh := NewFileHandle(int(tmp.Fd()), 0, tmp.Name())
+	require.Equal(t, 0, int(n.Setattr(context.Background(), fh, in, &fuse.AttrOut{})))
+
+	_, ok = attrs.get(n.ToTransferPathContext())
+	require.False(t, ok)
+}
+
+func BenchmarkAttrCache(b *testing.B) {
+	cache := newAttrCache(time.Minute)
+	paths := make([]*mcpath.TransferPathContext, 1000)
+	for i := range paths {
+		paths[i] = &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: mcpath.NewRelPath(fmt.Sprintf("d1/file-%d.txt", i))}
+		cache.put(paths[i], &mcmodel.File{})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = cache.get(paths[i%len(paths)])
+	}
+}
@@ -1,17 +1,19 @@
 package mcbridgefs
 
 import (
-	"bytes"
 	"context"
-	"io"
 	"syscall"
 
+	"github.com/apex/log"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/materials-commons/mcbridgefs/pkg/fs/bridgefs"
 )
 
 // Code based on loopback file system from github.com/hanwen/go-fuse/v2/fs/file.go
 
+// FileHandle is a file opened for writing. Writes go to a local file, see openForWrite. Reads are
+// passed through to BridgeFileHandle, which only reads the offset and size FUSE asks for. Files
+// opened read only get an ObjectFileHandle instead.
 type FileHandle struct {
 	*bridgefs.BridgeFileHandle
 	Flags uint32
@@ -41,24 +43,117 @@ func NewFileHandle(fd int, flags uint32, path string) fs.FileHandle {
 }
 
 // Write overrides the BridgeFileHandle write to incorporate updating the checksum as bytes
-// are written to the file.
+// are written to the file, see OpenFile.addToChecksum. When the handle was opened with O_APPEND
+// the data is always written to the end of the file. The underlying file isn't opened with
+// O_APPEND, because pwrite on a file opened with O_APPEND ignores the offset on Linux, so the end
+// is found here instead. A write that would take the file past maxFileSize fails with EFBIG.
 func (f *FileHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
 	f.Mu.Lock()
 	defer f.Mu.Unlock()
 
+	if f.Flags&syscall.O_APPEND != 0 {
+		st := syscall.Stat_t{}
+		if err := syscall.Fstat(f.Fd, &st); err != nil {
+			return 0, fs.ToErrno(err)
+		}
+		off = st.Size
+	}
+
+	file := openedFilesTracker.Get(f.Path)
+	if exceedsMaxFileSize(off + int64(len(data))) {
+		if file != nil {
+			file.markTooLarge()
+		}
+		return 0, syscall.EFBIG
+	}
+
 	n, err := syscall.Pwrite(f.Fd, data, off)
-	if err != fs.OK {
+	if err != nil {
 		return uint32(n), fs.ToErrno(err)
 	}
 
-	file := openedFilesTracker.Get(f.Path)
 	if file != nil && n > 0 {
-		_, _ = io.Copy(file.hasher, bytes.NewBuffer(data[:n]))
+		file.addToChecksum(data[:n], off)
 	}
 
 	return uint32(n), fs.OK
 }
 
+// Truncate sets the size of the file. Growing the file fills it with zeros and shrinking it drops
+// the bytes past size. Either way the checksum built up from the writes no longer matches the file,
+// so it is worked out from the file when the file is released. Truncating to 0 starts the checksum
+// over instead, since the writes that follow describe the whole file.
+func (f *FileHandle) Truncate(size int64) syscall.Errno {
+	if exceedsMaxFileSize(size) {
+		return syscall.EFBIG
+	}
+
+	f.Mu.Lock()
+	defer f.Mu.Unlock()
+
+	if err := syscall.Ftruncate(f.Fd, size); err != nil {
+		return fs.ToErrno(err)
+	}
+
+	if file := openedFilesTracker.Get(f.Path); file != nil {
+		if size == 0 {
+			file.resetChecksum()
+		} else {
+			file.markChecksumStale()
+		}
+	}
+
+	return fs.OK
+}
+
+// Allocate preallocates space for the file. Unless mode has FALLOC_FL_KEEP_SIZE set the file grows
+// to cover the allocated range, which is checked against maxFileSize and, like Truncate, means the
+// checksum has to be worked out from the file when it is released.
+func (f *FileHandle) Allocate(ctx context.Context, off uint64, size uint64, mode uint32) syscall.Errno {
+	keepSize := mode&fallocKeepSize != 0
+	if !keepSize && exceedsMaxFileSize(int64(off+size)) {
+		return syscall.EFBIG
+	}
+
+	if errno := f.BridgeFileHandle.Allocate(ctx, off, size, mode); errno != fs.OK {
+		return errno
+	}
+
+	if file := openedFilesTracker.Get(f.Path); file != nil && !keepSize {
+		file.markChecksumStale()
+	}
+
+	return fs.OK
+}
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE, which allocates space without changing the file size.
+const fallocKeepSize = 0x01
+
+// exceedsMaxFileSize returns true if a file of size bytes would be larger than maxFileSize.
+func exceedsMaxFileSize(size int64) bool {
+	return maxFileSize > 0 && size > maxFileSize
+}
+
+// Release closes the file, and when the file was staged for writing puts what was written back into
+// objectStore, see openForWrite.
+func (f *FileHandle) Release(ctx context.Context) syscall.Errno {
+	if errno := f.BridgeFileHandle.Release(ctx); errno != fs.OK {
+		return errno
+	}
+
+	file := openedFilesTracker.Get(f.Path)
+	if file == nil || file.File == nil {
+		return fs.OK
+	}
+
+	if err := file.releaseStaged(underlyingFilePath(file.File)); err != nil {
+		log.Errorf("Release: unable to store %s: %s", f.Path, err)
+		return syscall.EIO
+	}
+
+	return fs.OK
+}
+
 func (f *FileHandle) Flush(ctx context.Context) syscall.Errno {
 	return fs.OK
 }
@@ -0,0 +1,202 @@
+package mcbridgefs
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHandleReadsRanges(t *testing.T) {
+	// A file large enough that reading all of it for each range would be noticeable, filled with
+	// a pattern so every offset has a known value.
+	contents := make([]byte, 8*1024*1024)
+	for i := range contents {
+		contents[i] = byte(i % 251)
+	}
+
+	f, err := ioutil.TempFile("", "mcbridgefs-read")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(contents)
+	require.NoError(t, err)
+
+	fh := NewFileHandle(int(f.Fd()), 0, f.Name()).(*FileHandle)
+	ranges := []struct {
+		off  int64
+		size int
+	}{
+		{off: 7 * 1024 * 1024, size: 4096},
+		{off: 13, size: 100},
+		{off: 3*1024*1024 + 5, size: 64 * 1024},
+	}
+
+	for _, r := range ranges {
+		res, errno := fh.Read(context.Background(), make([]byte, r.size), r.off)
+		require.Equal(t, 0, int(errno))
+		data, status := res.Bytes(make([]byte, r.size))
+		require.Equal(t, 0, int(status))
+		require.Equal(t, contents[r.off:r.off+int64(r.size)], data)
+	}
+
+	// A range that runs past the end of the file returns what is there.
+	res, _ := fh.Read(context.Background(), make([]byte, 100), int64(len(contents)-10))
+	data, _ := res.Bytes(make([]byte, 100))
+	require.Equal(t, contents[len(contents)-10:], data)
+}
+
+func TestWriteMaxFileSize(t *testing.T) {
+	savedMaxFileSize, savedTracker := maxFileSize, openedFilesTracker
+	defer func() { maxFileSize, openedFilesTracker = savedMaxFileSize, savedTracker }()
+	maxFileSize = 10
+	openedFilesTracker = NewOpenFilesTracker()
+
+	f, err := ioutil.TempFile("", "mcbridgefs-max")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	openedFilesTracker.Store("/file.txt", &mcmodel.File{})
+	openFile := openedFilesTracker.Get("/file.txt")
+	fh := NewFileHandle(int(f.Fd()), syscall.O_WRONLY, "/file.txt").(*FileHandle)
+
+	// Streamed writes are checked as they come in, so writes up to the limit succeed...
+	n, errno := fh.Write(context.Background(), []byte("12345"), 0)
+	require.Equal(t, syscall.Errno(0), errno)
+	require.Equal(t, uint32(5), n)
+	n, errno = fh.Write(context.Background(), []byte("6789"), 5)
+	require.Equal(t, syscall.Errno(0), errno)
+	require.Equal(t, uint32(4), n)
+	require.False(t, openFile.isTooLarge())
+
+	// ...and the first one that would go past it fails, leaving the file as it was.
+	n, errno = fh.Write(context.Background(), []byte("ab"), 9)
+	require.Equal(t, syscall.EFBIG, errno)
+	require.Equal(t, uint32(0), n)
+	require.True(t, openFile.isTooLarge())
+
+	contents, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, "123456789", string(contents))
+}
+
+// openTrackedFile opens a temporary file containing contents for writing, tracked as /file.txt.
+func openTrackedFile(t *testing.T, contents string) (*FileHandle, *OpenFile, string) {
+	savedTracker := openedFilesTracker
+	t.Cleanup(func() { openedFilesTracker = savedTracker })
+	openedFilesTracker = NewOpenFilesTracker()
+
+	f, err := ioutil.TempFile("", "mcbridgefs-truncate")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Remove(f.Name()) })
+	_, err = f.WriteString(contents)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+
+	openedFilesTracker.Store("/file.txt", &mcmodel.File{})
+	openFile := openedFilesTracker.Get("/file.txt")
+	openFile.addToChecksum([]byte(contents), 0)
+	return NewFileHandle(int(f.Fd()), syscall.O_WRONLY, "/file.txt").(*FileHandle), openFile, f.Name()
+}
+
+func TestTruncateGrow(t *testing.T) {
+	fh, openFile, path := openTrackedFile(t, "abc")
+	require.Equal(t, syscall.Errno(0), fh.Truncate(6))
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []byte("abc\x00\x00\x00"), contents)
+
+	checksum, err := openFile.checksum(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum(contents)), checksum)
+}
+
+func TestTruncateShrink(t *testing.T) {
+	fh, openFile, path := openTrackedFile(t, "abcdef")
+	require.Equal(t, syscall.Errno(0), fh.Truncate(2))
+
+	// Writes after the truncate still end up in the checksum, which is worked out from the file.
+	_, errno := fh.Write(context.Background(), []byte("XY"), 2)
+	require.Equal(t, syscall.Errno(0), errno)
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "abXY", string(contents))
+
+	checksum, err := openFile.checksum(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("abXY"))), checksum)
+}
+
+func TestTruncateToZeroStartsChecksumOver(t *testing.T) {
+	fh, openFile, path := openTrackedFile(t, "abcdef")
+	require.Equal(t, syscall.Errno(0), fh.Truncate(0))
+	_, errno := fh.Write(context.Background(), []byte("new"), 0)
+	require.Equal(t, syscall.Errno(0), errno)
+
+	// The checksum comes from the writes, so removing the file shows it isn't read.
+	require.NoError(t, os.Remove(path))
+	checksum, err := openFile.checksum(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("new"))), checksum)
+}
+
+func TestWritesInOrderBuildUpChecksum(t *testing.T) {
+	fh, openFile, path := openTrackedFile(t, "abc")
+	for _, data := range []string{"def", "ghi"} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		_, errno := fh.Write(context.Background(), []byte(data), info.Size())
+		require.Equal(t, syscall.Errno(0), errno)
+	}
+
+	require.False(t, openFile.checksumStale)
+	checksum, err := openFile.checksum(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("abcdefghi"))), checksum)
+}
+
+func TestOverwriteRecomputesChecksum(t *testing.T) {
+	fh, openFile, path := openTrackedFile(t, "abcdef")
+	_, errno := fh.Write(context.Background(), []byte("XY"), 1)
+	require.Equal(t, syscall.Errno(0), errno)
+
+	require.True(t, openFile.checksumStale)
+	checksum, err := openFile.checksum(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("aXYdef"))), checksum)
+}
+
+func TestOutOfOrderWritesRecomputeChecksum(t *testing.T) {
+	fh, openFile, path := openTrackedFile(t, "")
+	_, errno := fh.Write(context.Background(), []byte("world"), 6)
+	require.Equal(t, syscall.Errno(0), errno)
+	_, errno = fh.Write(context.Background(), []byte("hello "), 0)
+	require.Equal(t, syscall.Errno(0), errno)
+
+	checksum, err := openFile.checksum(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("hello world"))), checksum)
+}
+
+func TestTruncateMaxFileSize(t *testing.T) {
+	savedMaxFileSize := maxFileSize
+	defer func() { maxFileSize = savedMaxFileSize }()
+	maxFileSize = 10
+
+	fh, _, path := openTrackedFile(t, "abc")
+	require.Equal(t, syscall.EFBIG, fh.Truncate(11))
+	require.Equal(t, syscall.EFBIG, fh.Allocate(context.Background(), 5, 6, 0))
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(contents))
+}
@@ -1,30 +1,34 @@
 package mcbridgefs
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/apex/log"
 	"github.com/hashicorp/go-uuid"
 	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
 	"gorm.io/gorm"
 )
 
 type FileStore struct {
 	db              *gorm.DB
-	mcfsRoot        string
+	storage         StorageRouter
 	transferRequest *mcmodel.TransferRequest
 }
 
-func NewFileStore(db *gorm.DB, mcfsRoot string, transferRequest *mcmodel.TransferRequest) *FileStore {
-	return &FileStore{db: db, mcfsRoot: mcfsRoot, transferRequest: transferRequest}
+func NewFileStore(db *gorm.DB, storage StorageRouter, transferRequest *mcmodel.TransferRequest) *FileStore {
+	return &FileStore{db: db, storage: storage, transferRequest: transferRequest}
 }
 
 func (s *FileStore) MarkFileReleased(file *mcmodel.File, checksum string) error {
-	finfo, err := os.Stat(file.ToUnderlyingFilePath(s.mcfsRoot))
+	filePath := file.ToUnderlyingFilePath(storageRoot(s.storage, file))
+	finfo, err := objectStore.Stat(context.Background(), filePath)
 	if err != nil {
-		log.Errorf("MarkFileReleased Stat %s failed: %s", file.ToUnderlyingFilePath(s.mcfsRoot), err)
+		log.Errorf("MarkFileReleased Stat %s failed: %s", filePath, err)
 		return err
 	}
 
@@ -51,7 +55,7 @@ func (s *FileStore) MarkFileReleased(file *mcmodel.File, checksum string) error
 		// a new computed checksum, also update the checksum field.
 		if checksum != "" {
 			return tx.Model(file).Updates(mcmodel.File{
-				Size:     uint64(finfo.Size()),
+				Size:     uint64(finfo.Size),
 				Current:  true,
 				Checksum: checksum,
 			}).Error
@@ -59,7 +63,7 @@ func (s *FileStore) MarkFileReleased(file *mcmodel.File, checksum string) error
 
 		// If we are here then the file was opened for read/write but it was never written to. In this situation there
 		// is no checksum that has been computed, so don't update the field.
-		return tx.Model(file).Updates(mcmodel.File{Size: uint64(finfo.Size()), Current: true}).Error
+		return tx.Model(file).Updates(mcmodel.File{Size: uint64(finfo.Size), Current: true}).Error
 	}, s.db, txRetryCount)
 }
 
@@ -69,10 +73,15 @@ func (s *FileStore) CreateNewFile(file, dir *mcmodel.File) (*mcmodel.File, error
 		return file, err
 	}
 
-	if err := os.MkdirAll(file.ToUnderlyingDirPath(s.mcfsRoot), 0755); err != nil {
-		// TODO: If this fails then we should remove the created file from the database
-		log.Errorf("os.MkdirAll failed (%s): %s\n", file.ToUnderlyingDirPath(s.mcfsRoot), err)
-		return nil, err
+	// Files in a local store are opened for writing in place, see openForWrite, so their directory
+	// has to exist.
+	if local, ok := objectStore.(objectstore.LocalFiler); ok {
+		dirPath := local.LocalPath(file.ToUnderlyingDirPath(storageRoot(s.storage, file)))
+		if err := os.MkdirAll(dirPath, 0755); err != nil {
+			// TODO: If this fails then we should remove the created file from the database
+			log.Errorf("os.MkdirAll failed (%s): %s\n", dirPath, err)
+			return nil, err
+		}
 	}
 
 	file.Directory = dir
@@ -184,16 +193,26 @@ func (s *FileStore) ListDirectory(dir *mcmodel.File) ([]mcmodel.File, error) {
 	results := s.db.Where("directory_id = ?", dir.ID).
 		Where("transfer_request_id = ?", s.transferRequest.ID).
 		Find(&uploadedFiles)
+	if results.Error != nil {
+		uploadedFiles = nil
+	}
+
+	return mergeDirectoryFiles(files, uploadedFiles), nil
+}
+
+// mergeDirectoryFiles adds the files uploaded by the transfer request that aren't already in files,
+// and sorts the result by name. Neither the database nor the map used to merge the two lists keep
+// entries in a stable order, so without sorting the same directory could be listed differently
+// each time.
+func mergeDirectoryFiles(files []mcmodel.File, uploadedFiles []mcmodel.TransferRequestFile) []mcmodel.File {
+	// Convert the files into a hashtable by name. Since we don't have the underlying mcmodel.File
+	// we create one on the fly only filling in the entries that will be needed to return the
+	// data about the directory. In this case all that is needed are the Name, the ID (for the inode
+	// number) and the Directory (only Path off the directory). So for directory we use the single
+	// entry dirToUse. See comment at start of Readdir that explains this.
 	uploadedFilesByName := make(map[string]mcmodel.File)
-	if results.Error == nil && len(uploadedFiles) != 0 {
-		// Convert the files into a hashtable by name. Since we don't have the underlying mcmodel.File
-		// we create one on the fly only filling in the entries that will be needed to return the
-		// data about the directory. In this case all that is needed are the Name and the Directory (only
-		// Path off the directory). So for directory we use the single entry dirToUse. See comment at
-		// start of Readdir that explains this.
-		for _, requestFile := range uploadedFiles {
-			uploadedFilesByName[requestFile.Name] = mcmodel.File{Name: requestFile.Name}
-		}
+	for _, requestFile := range uploadedFiles {
+		uploadedFilesByName[requestFile.Name] = mcmodel.File{ID: requestFile.FileID, Name: requestFile.Name}
 	}
 
 	for _, fileEntry := range files {
@@ -209,7 +228,11 @@ func (s *FileStore) ListDirectory(dir *mcmodel.File) ([]mcmodel.File, error) {
 		files = append(files, fileEntry)
 	}
 
-	return files, nil
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	return files
 }
 
 func (s *FileStore) GetFileByPath(path string) (*mcmodel.File, error) {
@@ -0,0 +1,33 @@
+package mcbridgefs
+
+import (
+	"testing"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDirectoryFilesIsSorted(t *testing.T) {
+	// Files from the database come back in whatever order the query returns them, and the uploaded
+	// files go through a map, so list the same directory with the entries in different orders.
+	projectFiles := func() []mcmodel.File {
+		return []mcmodel.File{{ID: 3, Name: "c.txt"}, {ID: 1, Name: "a.txt"}, {ID: 2, Name: "B.txt"}}
+	}
+	uploaded := []mcmodel.TransferRequestFile{{Name: "e.txt"}, {Name: "a.txt"}, {Name: "d.txt"}}
+	reversed := []mcmodel.TransferRequestFile{uploaded[2], uploaded[1], uploaded[0]}
+
+	var names []string
+	for _, f := range mergeDirectoryFiles(projectFiles(), uploaded) {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{"B.txt", "a.txt", "c.txt", "d.txt", "e.txt"}, names)
+
+	for i := 0; i < 10; i++ {
+		files := projectFiles()
+		files[0], files[2] = files[2], files[0]
+		require.Equal(t, mergeDirectoryFiles(projectFiles(), uploaded), mergeDirectoryFiles(files, reversed))
+	}
+
+	// The project's own entry for a.txt is kept over the uploaded one.
+	require.Equal(t, 1, mergeDirectoryFiles(projectFiles(), uploaded)[1].ID)
+}
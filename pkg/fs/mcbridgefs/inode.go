@@ -0,0 +1,48 @@
+package mcbridgefs
+
+import (
+	"hash/fnv"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+)
+
+// Inode numbers are split into two namespaces by their top bit so that they can't collide. Entries
+// that resolve to a row in the database use their id, which is stable for as long as the row
+// exists. Synthetic entries, such as the directories above a project that only exist in the
+// transfer layout, have no id, so they use a hash of their path with the top bit set.
+const (
+	// rootInode is the inode number go-fuse reserves for the root of the file system.
+	rootInode uint64 = 1
+
+	// syntheticInodeBit is set on inode numbers for entries that aren't in the database.
+	syntheticInodeBit uint64 = 1 << 63
+)
+
+// inodeNumber returns the inode number for entry. The same entry always gets the same number, so
+// that clients relying on inode numbers for caching or hardlink detection see a stable value
+// across lookups. Each version of a file is its own row, so writing a new version of a file gives
+// it a new inode number, as replacing a file would.
+func inodeNumber(entry *mcmodel.File) uint64 {
+	if entry == nil {
+		return rootInode
+	}
+
+	if entry.ID > 0 {
+		return dbInode(entry.ID)
+	}
+
+	return syntheticInode(entry.FullPath())
+}
+
+// dbInode returns the inode number for the database row with the given id. Ids start at 1, which
+// is the root's inode number, so they're offset by one.
+func dbInode(id int) uint64 {
+	return (uint64(id) + 1) &^ syntheticInodeBit
+}
+
+// syntheticInode returns the inode number for the entry at path that isn't in the database.
+func syntheticInode(path string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(path))
+	return h.Sum64() | syntheticInodeBit
+}
@@ -0,0 +1,61 @@
+package mcbridgefs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/fs/bridgefs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInodeNumber(t *testing.T) {
+	require.Equal(t, rootInode, inodeNumber(nil))
+
+	// Entries in the database are numbered by their id, whatever their path.
+	f := &mcmodel.File{ID: 5, Name: "a.txt", Directory: &mcmodel.File{Path: "/d1"}}
+	require.Equal(t, dbInode(5), inodeNumber(f))
+	require.Equal(t, inodeNumber(f), inodeNumber(&mcmodel.File{ID: 5, Name: "b.txt", Directory: &mcmodel.File{Path: "/d2"}}))
+	require.NotEqual(t, rootInode, inodeNumber(&mcmodel.File{ID: 1, Name: "a.txt"}))
+	require.Zero(t, inodeNumber(f)&syntheticInodeBit)
+
+	// Entries without an id are numbered by their path, in their own namespace.
+	synthetic := &mcmodel.File{Path: "/globus", MimeType: "directory"}
+	require.Equal(t, inodeNumber(synthetic), inodeNumber(&mcmodel.File{Path: "/globus", MimeType: "directory"}))
+	require.NotEqual(t, inodeNumber(synthetic), inodeNumber(&mcmodel.File{Path: "/globus/1", MimeType: "directory"}))
+	require.NotZero(t, inodeNumber(synthetic)&syntheticInodeBit)
+}
+
+func TestLookupReturnsStableInodes(t *testing.T) {
+	savedTransferRequest, savedAttrs := transferRequest, attrs
+	defer func() { transferRequest, attrs = savedTransferRequest, savedAttrs }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	attrs = newAttrCache(time.Minute)
+
+	bridgeRoot, err := bridgefs.NewBridgeRoot(os.TempDir(), nil, nil)
+	require.NoError(t, err)
+	root := &Node{BridgeNode: bridgeRoot.(*bridgefs.BridgeNode)}
+	_ = fs.NewNodeFS(root, &fs.Options{})
+
+	dir := &mcmodel.File{ID: 10, Name: "d1", Path: "/d1", MimeType: "directory"}
+	attrs.put(root.childPathContext("d1"), dir)
+	attrs.put(root.childPathContext("a.txt"), &mcmodel.File{ID: 11, Name: "a.txt", Directory: &mcmodel.File{Path: "/"}})
+	attrs.put(root.childPathContext("b.txt"), &mcmodel.File{ID: 12, Name: "b.txt", Directory: &mcmodel.File{Path: "/"}})
+
+	lookup := func(name string) uint64 {
+		inode, errno := root.Lookup(context.Background(), name, &fuse.EntryOut{})
+		require.Equal(t, 0, int(errno))
+		return inode.StableAttr().Ino
+	}
+
+	require.Equal(t, lookup("a.txt"), lookup("a.txt"))
+	require.Equal(t, lookup("d1"), lookup("d1"))
+	require.NotEqual(t, lookup("a.txt"), lookup("b.txt"))
+	require.NotEqual(t, lookup("a.txt"), lookup("d1"))
+	require.Equal(t, dbInode(11), lookup("a.txt"))
+}
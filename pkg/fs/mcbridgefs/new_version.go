@@ -0,0 +1,51 @@
+package mcbridgefs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// initNewVersion sets up the contents of a newly created version of a file. When the file is opened
+// with O_TRUNC the new version is left empty. Otherwise the contents of the current version are copied
+// into it, so writes and appends apply to the existing data. The copied data is added to the checksum
+// for the new version.
+func initNewVersion(current, newVersion *mcmodel.File, openFile *OpenFile, flags uint32) error {
+	if flags&syscall.O_TRUNC != 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	src, err := objectStore.Get(ctx, underlyingFilePath(current), 0, -1)
+	switch {
+	case errors.Is(err, objectstore.ErrNotFound):
+		// Nothing was ever written to the current version, so there is nothing to copy.
+		return nil
+	case err != nil:
+		return err
+	}
+	defer src.Close()
+
+	_, err = objectStore.Put(ctx, underlyingFilePath(newVersion), &checksumReader{r: src, openFile: openFile})
+	return err
+}
+
+// checksumReader adds everything read from r to the checksum of openFile.
+type checksumReader struct {
+	r        io.Reader
+	openFile *OpenFile
+	off      int64
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.openFile.addToChecksum(p[:n], c.off)
+		c.off += int64(n)
+	}
+	return n, err
+}
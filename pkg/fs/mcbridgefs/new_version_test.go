@@ -0,0 +1,88 @@
+package mcbridgefs
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/stretchr/testify/require"
+)
+
+// setupVersions creates a current version of a file containing contents, and an empty new version
+// of it, under a temporary storage directory.
+func setupVersions(t *testing.T, contents string) (current, newVersion *mcmodel.File) {
+	dir, err := ioutil.TempDir("", "mcbridgefs")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	storage = SingleStorageRouter(dir)
+
+	current = &mcmodel.File{UUID: "d5ab2ff0-4cd1-4ab8-a3b2-3b5bd1e2b7b1"}
+	newVersion = &mcmodel.File{UUID: "0e6e1d6c-8c56-4f9b-9d5c-1c8c8d8e1f2a"}
+	for _, f := range []*mcmodel.File{current, newVersion} {
+		require.NoError(t, os.MkdirAll(f.ToUnderlyingDirPath(dir), 0755))
+	}
+
+	require.NoError(t, ioutil.WriteFile(underlyingFilePath(current), []byte(contents), 0644))
+	require.NoError(t, ioutil.WriteFile(underlyingFilePath(newVersion), nil, 0644))
+	return current, newVersion
+}
+
+func TestInitNewVersionWithTruncate(t *testing.T) {
+	current, newVersion := setupVersions(t, "existing data")
+	tracker := NewOpenFilesTracker()
+	tracker.Store("/file.txt", newVersion)
+	openFile := tracker.Get("/file.txt")
+
+	require.NoError(t, initNewVersion(current, newVersion, openFile, syscall.O_WRONLY|syscall.O_TRUNC))
+
+	contents, err := ioutil.ReadFile(underlyingFilePath(newVersion))
+	require.NoError(t, err)
+	require.Empty(t, contents)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum(nil)), fmt.Sprintf("%x", openFile.hasher.Sum(nil)))
+}
+
+func TestInitNewVersionAndAppend(t *testing.T) {
+	current, newVersion := setupVersions(t, "existing data")
+	tracker := NewOpenFilesTracker()
+	tracker.Store("/file.txt", newVersion)
+	openFile := tracker.Get("/file.txt")
+
+	flags := uint32(syscall.O_WRONLY | syscall.O_APPEND)
+	require.NoError(t, initNewVersion(current, newVersion, openFile, flags))
+
+	fd, err := syscall.Open(underlyingFilePath(newVersion), syscall.O_WRONLY, 0)
+	require.NoError(t, err)
+	fh := NewFileHandle(fd, flags, "/file.txt").(*FileHandle)
+
+	// The offset is ignored for handles opened with O_APPEND.
+	n, errno := fh.Write(context.Background(), []byte(" and more"), 0)
+	require.Equal(t, syscall.Errno(0), errno)
+	require.Equal(t, uint32(9), n)
+	require.NoError(t, syscall.Close(fd))
+
+	contents, err := ioutil.ReadFile(underlyingFilePath(newVersion))
+	require.NoError(t, err)
+	require.Equal(t, "existing data and more", string(contents))
+
+	// The copied data is part of the checksum along with the appended data. The handle isn't in the
+	// package level tracker, so only the copied data made it into this one.
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("existing data"))), fmt.Sprintf("%x", openFile.hasher.Sum(nil)))
+}
+
+func TestResetChecksum(t *testing.T) {
+	tracker := NewOpenFilesTracker()
+	tracker.Store("/file.txt", &mcmodel.File{})
+	openFile := tracker.Get("/file.txt")
+	openFile.addToChecksum([]byte("data"), 0)
+	openFile.markChecksumStale()
+
+	openFile.resetChecksum()
+	require.False(t, openFile.checksumStale)
+	require.Equal(t, int64(0), openFile.hashed)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum(nil)), fmt.Sprintf("%x", openFile.hasher.Sum(nil)))
+}
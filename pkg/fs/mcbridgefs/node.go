@@ -1,9 +1,10 @@
 package mcbridgefs
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"hash/fnv"
 	"mime"
 	"os"
 	"os/user"
@@ -18,22 +19,36 @@ import (
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/materials-commons/gomcdb/mcmodel"
 	"github.com/materials-commons/mcbridgefs/pkg/fs/bridgefs"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+	"golang.org/x/sys/unix"
 	"gorm.io/gorm"
 )
 
 type Node struct {
-	file *mcmodel.File
+	file        *mcmodel.File
+	pathContext pathContextCache
 	*bridgefs.BridgeNode
 }
 
+var _ = (fs.NodeAccesser)((*Node)(nil))
+
 var (
 	uid, gid           uint32
-	mcfsRoot           string
+	storage            StorageRouter
+	objectStore        objectstore.ObjectStore = objectstore.NewLocalStore("")
 	db                 *gorm.DB
 	transferRequest    mcmodel.TransferRequest
 	openedFilesTracker *OpenFilesTracker
 	txRetryCount       int
 	fileStore          *FileStore
+	projectQuota       uint64
+	projectQuotas      ProjectQuotaStore
+	attrs              = newAttrCache(defaultAttrCacheTTL)
+	maxFileSize        int64
+	readOnly           bool
+	readCompression    bool
+	transferTypes      []string
 )
 
 func init() {
@@ -57,16 +72,40 @@ func init() {
 
 	txRetryCount = int(txRetryCount64)
 
+	// The quota (in bytes) reported by Statfs for each project. When it isn't set Statfs reports
+	// the space on the underlying file system.
+	projectQuota, _ = strconv.ParseUint(os.Getenv("MCFS_PROJECT_QUOTA"), 10, 64)
+
 	// Track any files that this instance writes to/create, so that if another instance does the same
 	// each of them will see their versions of the file, rather than intermixing them.
 	openedFilesTracker = NewOpenFilesTracker()
 }
 
-func CreateFS(fsRoot string, dB *gorm.DB, tr mcmodel.TransferRequest) *Node {
-	mcfsRoot = fsRoot
+func CreateFS(fsRoot string, dB *gorm.DB, tr mcmodel.TransferRequest, opts ...Option) fs.InodeEmbedder {
+	config := Config{
+		AttrCacheTTL:  defaultAttrCacheTTL,
+		StorageRouter: SingleStorageRouter(fsRoot),
+		ObjectStore:   objectstore.NewLocalStore(""),
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	attrs = newAttrCache(config.AttrCacheTTL)
+	maxFileSize = config.MaxFileSize
+	readOnly = config.ReadOnly
+	readCompression = config.ReadCompression
+	storage = config.StorageRouter
+	objectStore = config.ObjectStore
 	db = dB
 	transferRequest = tr
-	fileStore = NewFileStore(dB, fsRoot, &transferRequest)
+	fileStore = NewFileStore(dB, storage, &transferRequest)
+	projectQuotas = newDBProjectQuotaStore(dB, projectQuota)
+	transferTypes = config.TransferTypes
+	if len(transferTypes) != 0 {
+		return newTransferTypesNode(transferTypes, rootNode())
+	}
+
 	return rootNode()
 }
 
@@ -86,17 +125,69 @@ func (n *Node) newNode() *Node {
 	}
 }
 
-// Readdir reads the corresponding directory and returns its entries
+// ToTransferPathContext returns the TransferPathContext for the node. The mount holds the project of
+// the transfer request, so the node's path is relative to that project. The context is cached on the
+// node until a rename invalidates it.
+func (n *Node) ToTransferPathContext() *mcpath.TransferPathContext {
+	return n.pathContext.get(func() *mcpath.TransferPathContext {
+		return &mcpath.TransferPathContext{
+			TransferType: mcpath.GlobusTransferType,
+			UserID:       transferRequest.OwnerID,
+			ProjectID:    transferRequest.ProjectID,
+			Path:         mcpath.NewRelPath(relPath(n.EmbeddedInode())),
+		}
+	})
+}
+
+// relPath returns the path of inode within the project of the transfer request. A mount created
+// WithTransferTypes has the project in the directory for its transfer type, which is left off.
+func relPath(inode *fs.Inode) string {
+	p := inode.Path(inode.Root())
+	if len(transferTypes) == 0 {
+		return p
+	}
+
+	if i := strings.Index(p, "/"); i != -1 {
+		return p[i+1:]
+	}
+
+	return ""
+}
+
+// Statfs reports the space on the file system. When the node resolves to a project with a quota,
+// the capacity and free space come from the project's quota and usage rather than the disk.
+func (n *Node) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	if errno := n.BridgeNode.Statfs(ctx, out); errno != fs.OK {
+		return errno
+	}
+
+	pathContext := n.ToTransferPathContext()
+	if !pathContext.IsProject() {
+		return fs.OK
+	}
+
+	quota, err := projectQuotas.GetProjectQuota(pathContext.ProjectID)
+	if err != nil {
+		log.Errorf("Statfs: GetProjectQuota failed for project %d: %s", pathContext.ProjectID, err)
+		return fs.OK
+	}
+
+	quota.fillStatfs(out)
+	return fs.OK
+}
+
+// Readdir reads the corresponding directory and returns its entries sorted by name, so listing the
+// same directory twice gives the same order.
 func (n *Node) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	// Directories can have a large amount of files. To speed up processing
 	// Readdir uses queries that don't retrieve either the underlying directory
 	// for a mcmodel.File, or the underlying file for a mcmodel.TransferRequestFile.
 	// However, the path for the directory is still needed. This is accessed
 	// off of the underlying mcmodel.File by the FullPath() routine which is
-	// used the inodeHash() and getMode() methods. To work around this we
+	// used by inodeNumber() and the getMode() method. To work around this we
 	// create a single directory (see dirToUse below), and assign this as the
 	// directory for all mcmodel.File entries.
-	dirPath := filepath.Join("/", n.Path(n.Root()))
+	dirPath := filepath.Join("/", relPath(n.EmbeddedInode()))
 	dirToUse := &mcmodel.File{Path: dirPath}
 
 	dir, err := n.getMCDir("")
@@ -115,7 +206,7 @@ func (n *Node) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 		entry := fuse.DirEntry{
 			Mode: n.getMode(&f),
 			Name: f.Name,
-			Ino:  n.inodeHash(&f),
+			Ino:  inodeNumber(&f),
 		}
 
 		filesList = append(filesList, entry)
@@ -138,7 +229,7 @@ func (n *Node) Getxattr(ctx context.Context, attr string, dest []byte) (uint32,
 
 // Getattr gets attributes about the file
 func (n *Node) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	//fmt.Println("Getattr:", n.Path(n.Root()), n.IsDir())
+	//fmt.Println("Getattr:", relPath(n.EmbeddedInode()), n.IsDir())
 
 	// Owner is always the process the bridge is running as
 	out.Uid = uid
@@ -150,27 +241,34 @@ func (n *Node) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut)
 		return fs.OK
 	}
 
-	file, err := fileStore.GetFileByPath(filepath.Join("/", n.Path(n.Root())))
+	file, err := n.lookupFile(n.ToTransferPathContext())
 	if err != nil {
-		log.Errorf("Getattr: GetFileByPath failed (%s): %s\n", filepath.Join("/", n.Path(n.Root())), err)
+		log.Errorf("Getattr: GetFileByPath failed (%s): %s\n", filepath.Join("/", relPath(n.EmbeddedInode())), err)
 		return syscall.ENOENT
 	}
 
-	st := syscall.Stat_t{}
-	if err := syscall.Lstat(file.ToUnderlyingFilePath(mcfsRoot), &st); err != nil {
-		log.Errorf("Getattr: Lstat failed (%s): %s\n", file.ToUnderlyingFilePath(mcfsRoot), err)
-		return fs.ToErrno(err)
+	info, err := objectStore.Stat(ctx, underlyingFilePath(file))
+	switch {
+	case errors.Is(err, objectstore.ErrNotFound):
+		return syscall.ENOENT
+	case err != nil:
+		log.Errorf("Getattr: Stat failed (%s): %s\n", underlyingFilePath(file), err)
+		return syscall.EIO
 	}
 
-	out.FromStat(&st)
+	out.Ino = inodeNumber(file)
+	out.Mode = n.getMode(file)
+	out.Nlink = 1
+	out.Size = uint64(info.Size)
+	out.Blocks = (out.Size + 511) / 512
+	out.SetTimes(&info.ModTime, &info.ModTime, &info.ModTime)
 
 	return fs.OK
 }
 
 // Lookup will return information about the current entry.
 func (n *Node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	path := filepath.Join("/", n.Path(n.Root()), name)
-	f, err := fileStore.GetFileByPath(path)
+	f, err := n.lookupFile(n.childPathContext(name))
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
@@ -186,25 +284,83 @@ func (n *Node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs
 
 	node := n.newNode()
 	node.file = f
-	return n.NewInode(ctx, node, fs.StableAttr{Mode: n.getMode(f), Ino: n.inodeHash(f)}), fs.OK
+	return n.NewInode(ctx, node, fs.StableAttr{Mode: n.getMode(f), Ino: inodeNumber(f)}), fs.OK
+}
+
+// checkOwner returns EROFS when the file system is read only, and EACCES when the process asking for
+// the change, the FUSE caller in ctx, isn't the user the mount serves the transfer request's files
+// as. Every file is reported as owned by that user, so another user on the host can read them but
+// can't change one user's transfer tree through the mount. A call that didn't come through FUSE has
+// no caller and is made by the file system itself. It is checked before any change.
+func (n *Node) checkOwner(ctx context.Context, op string) syscall.Errno {
+	if readOnly {
+		log.Errorf("%s: file system is read only", op)
+		return syscall.EROFS
+	}
+
+	if caller, ok := fuse.FromContext(ctx); ok && caller.Uid != uid {
+		log.Errorf("%s: user %d doesn't own %s", op, caller.Uid, n.ToTransferPathContext().ToFSPath(""))
+		return syscall.EACCES
+	}
+
+	return fs.OK
+}
+
+// Access reports whether the operations in mask are allowed on the node, so that tools checking
+// first get the same answer the operation would. Reading is always allowed, writing only where
+// checkOwner allows changes.
+func (n *Node) Access(ctx context.Context, mask uint32) syscall.Errno {
+	if mask&unix.W_OK == 0 {
+		return fs.OK
+	}
+
+	return n.checkOwner(ctx, "Access")
+}
+
+// childPathContext returns the TransferPathContext for the entry name in this directory.
+func (n *Node) childPathContext(name string) *mcpath.TransferPathContext {
+	pathContext := n.ToTransferPathContext()
+	pathContext.Path = pathContext.Path.Join(name)
+	return pathContext
+}
+
+// lookupFile looks up the file at pathContext, going to the database only when it isn't in the
+// attribute cache.
+func (n *Node) lookupFile(pathContext *mcpath.TransferPathContext) (*mcmodel.File, error) {
+	if f, ok := attrs.get(pathContext); ok {
+		return f, nil
+	}
+
+	f, err := fileStore.GetFileByPath(pathContext.ToFilePath(""))
+	if err != nil {
+		return nil, err
+	}
+
+	attrs.put(pathContext, f)
+	return f, nil
 }
 
 // getMCDir looks a directory up in the database.
 func (n *Node) getMCDir(name string) (*mcmodel.File, error) {
-	path := filepath.Join("/", n.Path(n.Root()), name)
+	path := filepath.Join("/", relPath(n.EmbeddedInode()), name)
 	return fileStore.FindDirByPath(transferRequest.ProjectID, path)
 }
 
 // Mkdir will create a new directory. If an attempt is made to create an existing directory then it will return
 // the existing directory rather than returning an error.
 func (n *Node) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	path := filepath.Join("/", n.Path(n.Root()), name)
+	if errno := n.checkOwner(ctx, "Mkdir"); errno != fs.OK {
+		return nil, errno
+	}
+
+	path := filepath.Join("/", relPath(n.EmbeddedInode()), name)
 	parent, err := n.getMCDir("")
 	if err != nil {
 		return nil, syscall.EINVAL
 	}
 
 	dir, err := fileStore.CreateDirectory(parent.ID, path, name)
+	attrs.invalidate(n.childPathContext(name))
 
 	if err != nil {
 		return nil, syscall.EINVAL
@@ -218,30 +374,38 @@ func (n *Node) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.En
 
 	node := n.newNode()
 	node.file = dir
-	return n.NewInode(ctx, node, fs.StableAttr{Mode: n.getMode(dir), Ino: n.inodeHash(dir)}), fs.OK
+	return n.NewInode(ctx, node, fs.StableAttr{Mode: n.getMode(dir), Ino: inodeNumber(dir)}), fs.OK
 }
 
 func (n *Node) Rmdir(ctx context.Context, name string) syscall.Errno {
-	fmt.Printf("Rmdir %s/%s\n", n.Path(n.Root()), name)
+	attrs.invalidate(n.childPathContext(name))
+	fmt.Printf("Rmdir %s/%s\n", relPath(n.EmbeddedInode()), name)
 	return syscall.EIO
 }
 
 // Create will create a new file. At this point the file shouldn't exist. However, because multiple users could be
 // uploading files, there is a chance it does exist. If that happens then a new version of the file is created instead.
 func (n *Node) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (inode *fs.Inode, fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	if errno := n.checkOwner(ctx, "Create"); errno != fs.OK {
+		return nil, nil, 0, errno
+	}
+
 	f, err := n.createNewMCFile(name)
 	if err != nil {
 		log.Errorf("Create - failed creating new file (%s): %s", name, err)
 		return nil, nil, 0, syscall.EIO
 	}
 
-	path := filepath.Join("/", n.Path(n.Root()), name)
+	path := filepath.Join("/", relPath(n.EmbeddedInode()), name)
 	openedFilesTracker.Store(path, f)
+	attrs.invalidate(n.childPathContext(name))
 
+	// O_APPEND is handled by the FileHandle rather than the underlying file, see FileHandle.Write.
+	appendFlag := flags & syscall.O_APPEND
 	flags = flags &^ syscall.O_APPEND
-	fd, err := syscall.Open(f.ToUnderlyingFilePath(mcfsRoot), int(flags)|os.O_CREATE, mode)
+	fd, err := openForWrite(underlyingFilePath(f), openedFilesTracker.Get(path), int(flags)|os.O_CREATE, mode)
 	if err != nil {
-		log.Errorf("    Create - syscall.Open failed:", err)
+		log.Errorf("    Create - open failed: %s", err)
 		return nil, nil, 0, syscall.EIO
 	}
 
@@ -255,23 +419,34 @@ func (n *Node) Create(ctx context.Context, name string, flags uint32, mode uint3
 	node := n.newNode()
 	node.file = f
 	out.FromStat(&statInfo)
-	return n.NewInode(ctx, node, fs.StableAttr{Mode: n.getMode(f), Ino: n.inodeHash(f)}), NewFileHandle(fd, flags, path), 0, fs.OK
+	return n.NewInode(ctx, node, fs.StableAttr{Mode: n.getMode(f), Ino: inodeNumber(f)}), NewFileHandle(fd, flags|appendFlag, path), 0, fs.OK
 }
 
-// Open will open an existing file.
+// Open will open an existing file. Opening a file for write creates a new version of it. The new
+// version starts out with the contents of the current version, unless O_TRUNC was given in which
+// case it starts out empty. Since the size stored in the database is taken from the new version when
+// it is released, this also gives truncated and appended files the right size.
 func (n *Node) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
 	var (
 		err     error
 		newFile *mcmodel.File
 	)
-	path := filepath.Join("/", n.Path(n.Root()))
+	path := filepath.Join("/", relPath(n.EmbeddedInode()))
+
+	// O_APPEND is handled by the FileHandle rather than the underlying file, see FileHandle.Write.
+	appendFlag := flags & syscall.O_APPEND
 
 	switch flags & syscall.O_ACCMODE {
 	case syscall.O_RDONLY:
 		newFile = getFromOpenedFiles(path)
-	case syscall.O_WRONLY:
+	case syscall.O_WRONLY, syscall.O_RDWR:
+		if errno := n.checkOwner(ctx, "Open"); errno != fs.OK {
+			return nil, 0, errno
+		}
+
 		newFile = getFromOpenedFiles(path)
-		if newFile == nil {
+		switch {
+		case newFile == nil:
 			newFile, err = n.createNewMCFileVersion()
 			if err != nil {
 				// TODO: What error should be returned?
@@ -279,18 +454,14 @@ func (n *Node) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFl
 			}
 
 			openedFilesTracker.Store(path, newFile)
-		}
-		flags = flags &^ syscall.O_CREAT
-		flags = flags &^ syscall.O_APPEND
-	case syscall.O_RDWR:
-		newFile = getFromOpenedFiles(path)
-		if newFile == nil {
-			newFile, err = n.createNewMCFileVersion()
-			if err != nil {
-				// TODO: What error should be returned?
+			attrs.invalidate(n.ToTransferPathContext())
+			if err := initNewVersion(n.file, newFile, openedFilesTracker.Get(path), flags); err != nil {
+				log.Errorf("Open - failed setting up new version of %s: %s", path, err)
 				return nil, 0, syscall.EIO
 			}
-			openedFilesTracker.Store(path, newFile)
+		case flags&syscall.O_TRUNC != 0:
+			// The existing version is about to be truncated, so its checksum starts over.
+			openedFilesTracker.Get(path).resetChecksum()
 		}
 		flags = flags &^ syscall.O_CREAT
 		flags = flags &^ syscall.O_APPEND
@@ -298,30 +469,73 @@ func (n *Node) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFl
 		return
 	}
 
-	filePath := n.file.ToUnderlyingFilePath(mcfsRoot)
+	filePath := underlyingFilePath(n.file)
 	if newFile != nil {
-		filePath = newFile.ToUnderlyingFilePath(mcfsRoot)
+		filePath = underlyingFilePath(newFile)
 	}
-	fd, err := syscall.Open(filePath, int(flags), 0)
+
+	if flags&syscall.O_ACCMODE == syscall.O_RDONLY {
+		return NewObjectFileHandle(filePath), 0, fs.OK
+	}
+
+	fd, err := openForWrite(filePath, openedFilesTracker.Get(path), int(flags), 0)
 	if err != nil {
 		return nil, 0, fs.ToErrno(err)
 	}
 
-	fhandle := NewFileHandle(fd, flags, path)
+	fhandle := NewFileHandle(fd, flags|appendFlag, path)
 	return fhandle, 0, fs.OK
 }
 
-// Setattr will set attributes on a file. Currently the only attribute supported is setting the size. This is
-// done by calling Ftruncate.
+// Setattr will set attributes on a file. Currently the only attribute supported is setting the size. When
+// the file is open the size is set through its handle, otherwise see Truncate.
 func (n *Node) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if errno := n.checkOwner(ctx, "Setattr"); errno != fs.OK {
+		return errno
+	}
+
 	if sz, ok := in.GetSize(); ok {
-		fh := f.(*FileHandle)
-		return fs.ToErrno(syscall.Ftruncate(fh.Fd, int64(sz)))
+		if f == nil {
+			return n.Truncate(ctx, sz)
+		}
+
+		fh, ok := f.(*FileHandle)
+		if !ok {
+			return syscall.EINVAL
+		}
+
+		attrs.invalidate(n.ToTransferPathContext())
+		return fh.Truncate(int64(sz))
 	}
 
 	return fs.OK
 }
 
+// Truncate sets the size of a file that isn't open, as truncate(2) does. Like any other change it is
+// made to a new version of the file, which is released straight away so the database has the new size.
+func (n *Node) Truncate(ctx context.Context, size uint64) syscall.Errno {
+	if exceedsMaxFileSize(int64(size)) {
+		return syscall.EFBIG
+	}
+
+	flags := uint32(syscall.O_WRONLY)
+	if size == 0 {
+		flags |= syscall.O_TRUNC
+	}
+
+	f, _, errno := n.Open(ctx, flags)
+	if errno != fs.OK {
+		return errno
+	}
+
+	if errno := f.(*FileHandle).Truncate(int64(size)); errno != fs.OK {
+		_ = f.(*FileHandle).Release(ctx)
+		return errno
+	}
+
+	return n.Release(ctx, f)
+}
+
 // Release will close the file handle and update meta data about the file in the database
 func (n *Node) Release(ctx context.Context, f fs.FileHandle) syscall.Errno {
 	bridgeFH, ok := f.(fs.FileReleaser)
@@ -335,8 +549,8 @@ func (n *Node) Release(ctx context.Context, f fs.FileHandle) syscall.Errno {
 	}
 
 	// If the file was opened only for read then there is no meta data that needs to be updated.
-	fh := bridgeFH.(*FileHandle)
-	if fh.Flags&syscall.O_ACCMODE == syscall.O_RDONLY {
+	fh, ok := bridgeFH.(*FileHandle)
+	if !ok || fh.Flags&syscall.O_ACCMODE == syscall.O_RDONLY {
 		return fs.OK
 	}
 
@@ -344,7 +558,7 @@ func (n *Node) Release(ctx context.Context, f fs.FileHandle) syscall.Errno {
 	// file size, set this as the current file, and if a new checksum was computed, set the checksum.
 	// TODO: is n.file even valid anymore?
 	fileToUpdate := n.file
-	fpath := filepath.Join("/", n.Path(n.Root()))
+	fpath := filepath.Join("/", relPath(n.EmbeddedInode()))
 	nf := openedFilesTracker.Get(fpath)
 	if nf != nil && nf.File != nil {
 		fileToUpdate = nf.File
@@ -352,9 +566,20 @@ func (n *Node) Release(ctx context.Context, f fs.FileHandle) syscall.Errno {
 
 	var checksum string
 	if nf != nil {
-		checksum = fmt.Sprintf("%x", nf.hasher.Sum(nil))
+		if nf.isTooLarge() {
+			// The writes past the limit failed, so what was written isn't the whole file.
+			log.Errorf("Release: not releasing %s, a write went past the maximum file size of %d bytes", fpath, maxFileSize)
+			return syscall.EFBIG
+		}
+
+		var err error
+		if checksum, err = nf.checksum(underlyingFilePath(fileToUpdate)); err != nil {
+			log.Errorf("Release: unable to compute checksum for %s: %s", fpath, err)
+			return fs.ToErrno(err)
+		}
 	}
 
+	defer attrs.invalidate(n.ToTransferPathContext())
 	return fs.ToErrno(fileStore.MarkFileReleased(fileToUpdate, checksum))
 }
 
@@ -366,7 +591,7 @@ func (n *Node) Release(ctx context.Context, f fs.FileHandle) syscall.Errno {
 // file is written to it.
 func (n *Node) createNewMCFileVersion() (*mcmodel.File, error) {
 	// First check if there is already a version of this file being written to for this upload context.
-	existing := getFromOpenedFiles(filepath.Join("/", n.Path(n.Root()), n.file.Name))
+	existing := getFromOpenedFiles(filepath.Join("/", relPath(n.EmbeddedInode()), n.file.Name))
 	if existing != nil {
 		return existing, nil
 	}
@@ -391,13 +616,10 @@ func (n *Node) createNewMCFileVersion() (*mcmodel.File, error) {
 	}
 
 	// Create the empty file for new version
-	f, err := os.OpenFile(newFile.ToUnderlyingFilePath(mcfsRoot), os.O_RDWR|os.O_CREATE, 0755)
-
-	if err != nil {
-		log.Errorf("os.OpenFile failed (%s): %s\n", newFile.ToUnderlyingFilePath(mcfsRoot), err)
+	if _, err := objectStore.Put(context.Background(), underlyingFilePath(newFile), bytes.NewReader(nil)); err != nil {
+		log.Errorf("Put failed (%s): %s\n", underlyingFilePath(newFile), err)
 		return nil, err
 	}
-	defer f.Close()
 
 	return newFile, nil
 }
@@ -442,9 +664,33 @@ func getMimeType(name string) string {
 }
 
 func (n *Node) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
-	fmt.Printf("Rename: %s/%s to %s/%s\n", n.Path(n.Root()), name, newParent.EmbeddedInode().Path(n.Root()), newName)
-	fromPath := filepath.Join("/", n.Path(n.Root()))
-	toPath := filepath.Join("/", newParent.EmbeddedInode().Path(n.Root()))
+	if errno := n.checkOwner(ctx, "Rename"); errno != fs.OK {
+		return errno
+	}
+
+	if newParentNode, ok := newParent.(*Node); ok {
+		if errno := newParentNode.checkOwner(ctx, "Rename"); errno != fs.OK {
+			return errno
+		}
+	}
+
+	// A directory can't be moved inside itself.
+	if newParentNode, ok := newParent.(*Node); ok {
+		if newParentNode.childPathContext(newName).IsDescendantOf(n.childPathContext(name)) {
+			return syscall.EINVAL
+		}
+	}
+
+	// A rename changes the path of the renamed entry and everything below it.
+	defer invalidatePathContexts()
+	attrs.invalidate(n.childPathContext(name))
+	if newParentNode, ok := newParent.(*Node); ok {
+		attrs.invalidate(newParentNode.childPathContext(newName))
+	}
+
+	fmt.Printf("Rename: %s/%s to %s/%s\n", relPath(n.EmbeddedInode()), name, relPath(newParent.EmbeddedInode()), newName)
+	fromPath := filepath.Join("/", relPath(n.EmbeddedInode()))
+	toPath := filepath.Join("/", relPath(newParent.EmbeddedInode()))
 
 	dir, err := n.getMCDir("")
 	if err != nil {
@@ -486,7 +732,8 @@ func (n *Node) renameFile(fromPath, toPath, name, toName string, f mcmodel.File)
 }
 
 func (n *Node) Unlink(ctx context.Context, name string) syscall.Errno {
-	fmt.Printf("Unlink: %s/%s\n", n.Path(n.Root()), name)
+	attrs.invalidate(n.childPathContext(name))
+	fmt.Printf("Unlink: %s/%s\n", relPath(n.EmbeddedInode()), name)
 	return syscall.EPERM
 }
 
@@ -504,17 +751,6 @@ func (n *Node) getMode(entry *mcmodel.File) uint32 {
 	return 0644 | uint32(syscall.S_IFREG)
 }
 
-// inodeHash creates a new inode id from the the file path.
-func (n *Node) inodeHash(entry *mcmodel.File) uint64 {
-	if entry == nil {
-		return 1
-	}
-
-	h := fnv.New64a()
-	_, _ = h.Write([]byte(entry.FullPath()))
-	return h.Sum64()
-}
-
 // getFromOpenedFiles returns the mcmodel.File from the openedFilesTracker. It handles
 // the case where the path wasn't found.
 func getFromOpenedFiles(path string) *mcmodel.File {
@@ -0,0 +1,54 @@
+package mcbridgefs
+
+import (
+	"context"
+	"errors"
+	"syscall"
+
+	"github.com/apex/log"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// ObjectFileHandle is a file opened read only. Each read asks objectStore for just the offset and
+// size FUSE asks for, so scattered reads of a large file don't read the whole file, and nothing is
+// held open between reads.
+type ObjectFileHandle struct {
+	// Key is the key of the file's contents in objectStore.
+	Key string
+}
+
+var _ = (fs.FileHandle)((*ObjectFileHandle)(nil))
+var _ = (fs.FileReader)((*ObjectFileHandle)(nil))
+var _ = (fs.FileReleaser)((*ObjectFileHandle)(nil))
+var _ = (fs.FileFlusher)((*ObjectFileHandle)(nil))
+
+func NewObjectFileHandle(key string) fs.FileHandle {
+	return &ObjectFileHandle{Key: key}
+}
+
+// Read fills dest with the bytes of the file starting at off, fetched compressed with
+// WithReadCompression. A new version is given an empty object when it is created, so a file without
+// one has lost its contents, and reading it fails with ENOENT as Getattr does.
+func (f *ObjectFileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := objectstore.ReadRange(ctx, objectStore, f.Key, dest, off, readCompression)
+	switch {
+	case errors.Is(err, objectstore.ErrNotFound):
+		log.Errorf("Read: no object for %s", f.Key)
+		return nil, syscall.ENOENT
+	case err != nil:
+		log.Errorf("Read: unable to read %s: %s", f.Key, err)
+		return nil, syscall.EIO
+	}
+
+	return fuse.ReadResultData(dest[:n]), fs.OK
+}
+
+func (f *ObjectFileHandle) Release(ctx context.Context) syscall.Errno {
+	return fs.OK
+}
+
+func (f *ObjectFileHandle) Flush(ctx context.Context) syscall.Errno {
+	return fs.OK
+}
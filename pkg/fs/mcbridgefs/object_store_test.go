@@ -0,0 +1,223 @@
+package mcbridgefs
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+	"github.com/stretchr/testify/require"
+)
+
+// useMemoryStore replaces the object store with a MemoryStore for the rest of the test.
+func useMemoryStore(t *testing.T) *objectstore.MemoryStore {
+	savedStorage, savedObjectStore := storage, objectStore
+	t.Cleanup(func() { storage, objectStore = savedStorage, savedObjectStore })
+
+	store := objectstore.NewMemoryStore()
+	storage = SingleStorageRouter("/mcfs")
+	objectStore = store
+	return store
+}
+
+func TestInitNewVersionFromObjectStore(t *testing.T) {
+	store := useMemoryStore(t)
+	ctx := context.Background()
+	current := &mcmodel.File{UUID: "d5ab2ff0-4cd1-4ab8-a3b2-3b5bd1e2b7b1"}
+	newVersion := &mcmodel.File{UUID: "0e6e1d6c-8c56-4f9b-9d5c-1c8c8d8e1f2a"}
+	_, err := store.Put(ctx, underlyingFilePath(current), strings.NewReader("existing data"))
+	require.NoError(t, err)
+
+	tracker := NewOpenFilesTracker()
+	tracker.Store("/file.txt", newVersion)
+	openFile := tracker.Get("/file.txt")
+	require.NoError(t, initNewVersion(current, newVersion, openFile, syscall.O_WRONLY))
+
+	r, err := store.Get(ctx, underlyingFilePath(newVersion), 0, -1)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "existing data", string(contents))
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum(contents)), fmt.Sprintf("%x", openFile.hasher.Sum(nil)))
+
+	// The checksum of a file whose checksum went stale is read back from the store.
+	_, err = store.Put(ctx, underlyingFilePath(newVersion), strings.NewReader("rewritten"))
+	require.NoError(t, err)
+	openFile.markChecksumStale()
+	checksum, err := openFile.checksum(underlyingFilePath(newVersion))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", md5.Sum([]byte("rewritten"))), checksum)
+}
+
+func TestInitNewVersionWithoutCurrentContents(t *testing.T) {
+	store := useMemoryStore(t)
+	current := &mcmodel.File{UUID: "d5ab2ff0-4cd1-4ab8-a3b2-3b5bd1e2b7b1"}
+	newVersion := &mcmodel.File{UUID: "0e6e1d6c-8c56-4f9b-9d5c-1c8c8d8e1f2a"}
+
+	tracker := NewOpenFilesTracker()
+	tracker.Store("/file.txt", newVersion)
+	require.NoError(t, initNewVersion(current, newVersion, tracker.Get("/file.txt"), syscall.O_WRONLY))
+	require.Empty(t, store.Keys())
+}
+
+func TestChecksumOfMissingObject(t *testing.T) {
+	useMemoryStore(t)
+	tracker := NewOpenFilesTracker()
+	tracker.Store("/file.txt", &mcmodel.File{})
+	openFile := tracker.Get("/file.txt")
+	openFile.markChecksumStale()
+
+	_, err := openFile.checksum("/mcfs/missing")
+	require.True(t, errors.Is(err, objectstore.ErrNotFound))
+}
+
+// recordingStore records the ranges asked for by Get.
+type recordingStore struct {
+	objectstore.ObjectStore
+	ranges [][2]int64
+}
+
+func (s *recordingStore) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s.ranges = append(s.ranges, [2]int64{offset, length})
+	return s.ObjectStore.Get(ctx, key, offset, length)
+}
+
+func TestObjectFileHandleReadsRanges(t *testing.T) {
+	store := useMemoryStore(t)
+	recording := &recordingStore{ObjectStore: store}
+	objectStore = recording
+
+	contents := make([]byte, 1024*1024)
+	for i := range contents {
+		contents[i] = byte(i % 251)
+	}
+	_, err := store.Put(context.Background(), "file.txt", bytes.NewReader(contents))
+	require.NoError(t, err)
+
+	fh := NewObjectFileHandle("file.txt").(*ObjectFileHandle)
+	res, errno := fh.Read(context.Background(), make([]byte, 100), 5000)
+	require.Equal(t, syscall.Errno(0), errno)
+	data, _ := res.Bytes(nil)
+	require.Equal(t, contents[5000:5100], data)
+	require.Equal(t, [][2]int64{{5000, 100}}, recording.ranges)
+
+	// A range that runs past the end of the file returns what is there.
+	res, errno = fh.Read(context.Background(), make([]byte, 100), int64(len(contents)-10))
+	require.Equal(t, syscall.Errno(0), errno)
+	data, _ = res.Bytes(nil)
+	require.Equal(t, contents[len(contents)-10:], data)
+
+	// A file whose object is missing can't be read, rather than reading as empty.
+	_, errno = NewObjectFileHandle("missing.txt").(*ObjectFileHandle).Read(context.Background(), make([]byte, 100), 0)
+	require.Equal(t, syscall.ENOENT, errno)
+}
+
+func TestObjectFileHandleReadsCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mcbridgefs")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	savedObjectStore, savedReadCompression := objectStore, readCompression
+	t.Cleanup(func() { objectStore, readCompression = savedObjectStore, savedReadCompression })
+	objectStore = objectstore.NewLocalStore(dir)
+
+	contents := []byte(strings.Repeat("sample,temperature,pressure\n", 10000))
+	_, err = objectStore.Put(context.Background(), "data.csv", bytes.NewReader(contents))
+	require.NoError(t, err)
+
+	// The bytes read are the same with and without compression.
+	fh := NewObjectFileHandle("data.csv").(*ObjectFileHandle)
+	for _, compressed := range []bool{false, true} {
+		readCompression = compressed
+		res, errno := fh.Read(context.Background(), make([]byte, 64*1024), 5000)
+		require.Equal(t, syscall.Errno(0), errno)
+		data, _ := res.Bytes(nil)
+		require.Equal(t, contents[5000:5000+64*1024], data, "compressed %t", compressed)
+
+		res, errno = fh.Read(context.Background(), make([]byte, 100), int64(len(contents)-10))
+		require.Equal(t, syscall.Errno(0), errno)
+		data, _ = res.Bytes(nil)
+		require.Equal(t, contents[len(contents)-10:], data, "compressed %t", compressed)
+
+		_, errno = NewObjectFileHandle("missing.csv").(*ObjectFileHandle).Read(context.Background(), make([]byte, 100), 0)
+		require.Equal(t, syscall.ENOENT, errno, "compressed %t", compressed)
+	}
+}
+
+func TestWriteThroughStagedFile(t *testing.T) {
+	store := useMemoryStore(t)
+	savedTracker := openedFilesTracker
+	t.Cleanup(func() { openedFilesTracker = savedTracker })
+	openedFilesTracker = NewOpenFilesTracker()
+
+	ctx := context.Background()
+	file := &mcmodel.File{UUID: "d5ab2ff0-4cd1-4ab8-a3b2-3b5bd1e2b7b1"}
+	key := underlyingFilePath(file)
+	_, err := store.Put(ctx, key, strings.NewReader("existing"))
+	require.NoError(t, err)
+	openedFilesTracker.Store("/file.txt", file)
+	openFile := openedFilesTracker.Get("/file.txt")
+
+	// Two handles on the file share the staged copy.
+	fd1, err := openForWrite(key, openFile, syscall.O_RDWR, 0)
+	require.NoError(t, err)
+	fd2, err := openForWrite(key, openFile, syscall.O_RDWR, 0)
+	require.NoError(t, err)
+	staged := openFile.stagedPath
+	fh1 := NewFileHandle(fd1, syscall.O_RDWR, "/file.txt").(*FileHandle)
+	fh2 := NewFileHandle(fd2, syscall.O_RDWR, "/file.txt").(*FileHandle)
+
+	_, errno := fh1.Write(ctx, []byte(" data"), 8)
+	require.Equal(t, syscall.Errno(0), errno)
+	require.Equal(t, syscall.Errno(0), fh1.Release(ctx))
+	require.Equal(t, "existing data", readStoreObject(t, store, key))
+	require.FileExists(t, staged)
+
+	_, errno = fh2.Write(ctx, []byte("!"), 13)
+	require.Equal(t, syscall.Errno(0), errno)
+	require.Equal(t, syscall.Errno(0), fh2.Release(ctx))
+	require.Equal(t, "existing data!", readStoreObject(t, store, key))
+
+	// The staged copy is removed with the last handle.
+	require.Empty(t, openFile.stagedPath)
+	_, err = os.Stat(staged)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestStagingCreatesMissingObject(t *testing.T) {
+	store := useMemoryStore(t)
+	file := &mcmodel.File{UUID: "0e6e1d6c-8c56-4f9b-9d5c-1c8c8d8e1f2a"}
+	key := underlyingFilePath(file)
+	openFile := &OpenFile{File: file, hasher: md5.New()}
+
+	fd, err := openForWrite(key, openFile, syscall.O_RDWR|syscall.O_CREAT, 0644)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = syscall.Close(fd)
+		_ = openFile.releaseStaged(key)
+	})
+
+	// The file can be looked up while it is being written.
+	info, err := store.Stat(context.Background(), key)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), info.Size)
+}
+
+// readStoreObject returns the contents of key in store.
+func readStoreObject(t *testing.T, store objectstore.ObjectStore, key string) string {
+	r, err := store.Get(context.Background(), key, 0, -1)
+	require.NoError(t, err)
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
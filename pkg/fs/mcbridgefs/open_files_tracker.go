@@ -1,9 +1,13 @@
 package mcbridgefs
 
 import (
+	"context"
 	"crypto/md5"
+	"fmt"
 	"hash"
+	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/materials-commons/gomcdb/mcmodel"
 )
@@ -15,7 +19,23 @@ type OpenFilesTracker struct {
 type OpenFile struct {
 	File     *mcmodel.File
 	Checksum string
-	hasher   hash.Hash
+
+	// tooLarge is set (to 1) once a write to the file fails for going past maxFileSize.
+	tooLarge int32
+
+	// mu guards the checksum being built up from the writes. hasher has seen the first hashed bytes
+	// of the file. checksumStale is set once the file is changed in a way that can't be added to
+	// hasher, so hasher no longer matches its contents.
+	mu            sync.Mutex
+	hasher        hash.Hash
+	hashed        int64
+	checksumStale bool
+
+	// stagedPath is the temporary file writes go to when objectStore doesn't keep its objects in local
+	// files, and stagedHandles the number of handles open on it. See openForWrite.
+	stageMu       sync.Mutex
+	stagedPath    string
+	stagedHandles int
 }
 
 func NewOpenFilesTracker() *OpenFilesTracker {
@@ -42,3 +62,71 @@ func (t *OpenFilesTracker) Get(path string) *OpenFile {
 func (t *OpenFilesTracker) Delete(path string) {
 	t.m.Delete(path)
 }
+
+// resetChecksum starts the checksum over, for when the file is truncated.
+func (o *OpenFile) resetChecksum() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hasher.Reset()
+	o.hashed = 0
+	o.checksumStale = false
+}
+
+// markChecksumStale records that the file was changed in a way the checksum built up from the writes
+// doesn't cover, such as being truncated to a non zero size.
+func (o *OpenFile) markChecksumStale() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.checksumStale = true
+}
+
+// addToChecksum adds data, which was written to the file at off, to the checksum. The checksum can
+// only be built up from writes that carry on from where the bytes already hashed end. Any other
+// write, such as one that overwrites earlier data or leaves a hole, marks the checksum stale.
+func (o *OpenFile) addToChecksum(data []byte, off int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch {
+	case o.checksumStale:
+	case off != o.hashed:
+		o.checksumStale = true
+	default:
+		_, _ = o.hasher.Write(data)
+		o.hashed += int64(len(data))
+	}
+}
+
+// checksum returns the checksum of the file, whose contents are in objectStore under path. It is
+// the checksum built up from the writes, unless that went stale, in which case the contents are
+// read to work it out.
+func (o *OpenFile) checksum(path string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.checksumStale {
+		return fmt.Sprintf("%x", o.hasher.Sum(nil)), nil
+	}
+
+	f, err := objectStore.Get(context.Background(), path, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// markTooLarge records that a write to the file was refused for going past maxFileSize.
+func (o *OpenFile) markTooLarge() {
+	atomic.StoreInt32(&o.tooLarge, 1)
+}
+
+func (o *OpenFile) isTooLarge() bool {
+	return atomic.LoadInt32(&o.tooLarge) == 1
+}
@@ -0,0 +1,114 @@
+package mcbridgefs
+
+import (
+	"sort"
+	"time"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// Config holds the file system settings that can be changed with Options passed to CreateFS.
+type Config struct {
+	// AttrCacheTTL is how long the files looked up by Getattr and Lookup are cached. A value of 0
+	// turns off the cache.
+	AttrCacheTTL time.Duration
+
+	// MaxFileSize is the largest a file written through the mount can be. A value of 0 means there
+	// is no limit.
+	MaxFileSize int64
+
+	// StorageRouter decides where the contents of files are stored. It defaults to storing
+	// everything under the directory passed to CreateFS.
+	StorageRouter StorageRouter
+
+	// ObjectStore holds the contents of files, with the path StorageRouter gives a file as its key.
+	// It defaults to the local file system.
+	ObjectStore objectstore.ObjectStore
+
+	// ReadOnly turns down every change made through the mount with EROFS.
+	ReadOnly bool
+
+	// ReadCompression has reads fetch the contents of files from ObjectStore compressed, when it is
+	// an objectstore.CompressedGetter.
+	ReadCompression bool
+
+	// TransferTypes are the transfer types the root of the mount lists a directory for. The project
+	// is in the directory for mcpath.GlobusTransferType, and the others are empty. When it is empty
+	// the mount is rooted at the project.
+	TransferTypes []string
+}
+
+// Option configures the file system created by CreateFS.
+type Option func(c *Config)
+
+// WithAttrCacheTTL sets how long the files looked up by Getattr and Lookup are cached.
+func WithAttrCacheTTL(d time.Duration) Option {
+	return func(c *Config) {
+		c.AttrCacheTTL = d
+	}
+}
+
+// WithMaxFileSize sets the largest a file written through the mount can be. Writes that would make a
+// file larger fail with EFBIG, and the file's new version isn't released.
+func WithMaxFileSize(bytes int64) Option {
+	return func(c *Config) {
+		c.MaxFileSize = bytes
+	}
+}
+
+// WithStorageRouter sets the StorageRouter that decides where the contents of files are stored.
+func WithStorageRouter(router StorageRouter) Option {
+	return func(c *Config) {
+		c.StorageRouter = router
+	}
+}
+
+// WithReadOnly makes the mount read only. Files can still be looked up and read, but creating,
+// writing, renaming and changing them fails with EROFS.
+func WithReadOnly() Option {
+	return func(c *Config) {
+		c.ReadOnly = true
+	}
+}
+
+// WithReadCompression has files opened read only fetch their contents from the ObjectStore gzip
+// compressed, which cuts the time large reads of text files take when the store is remote. FUSE is
+// still handed the bytes of the file. A fetch that can't be compressed is made again uncompressed.
+func WithReadCompression() Option {
+	return func(c *Config) {
+		c.ReadCompression = true
+	}
+}
+
+// WithObjectStore sets the ObjectStore the contents of files are read, written, checksummed and
+// deleted through. Writes go to a local file, which is the object's own file when the store is an
+// objectstore.LocalFiler, and otherwise a copy that is put back into the store as each handle is
+// released.
+func WithObjectStore(store objectstore.ObjectStore) Option {
+	return func(c *Config) {
+		c.ObjectStore = store
+	}
+}
+
+// WithTransferTypes puts a level above the project, the way the transfers root is laid out, with a
+// directory for each of types. Without types it lists every type in mcpath.TransferTypes. The project
+// is always in the directory for mcpath.GlobusTransferType, which is added when types doesn't have it.
+func WithTransferTypes(types ...string) Option {
+	return func(c *Config) {
+		if len(types) == 0 {
+			types = mcpath.TransferTypes()
+		}
+
+		seen := map[string]bool{mcpath.GlobusTransferType: true}
+		c.TransferTypes = []string{mcpath.GlobusTransferType}
+		for _, transferType := range types {
+			if !seen[transferType] {
+				seen[transferType] = true
+				c.TransferTypes = append(c.TransferTypes, transferType)
+			}
+		}
+
+		sort.Strings(c.TransferTypes)
+	}
+}
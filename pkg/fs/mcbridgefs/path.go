@@ -35,7 +35,7 @@ func (p *Path) IsProject() bool {
 }
 
 func (n *Node) ToPath() *Path {
-	basePath := n.Path(n.Root())
+	basePath := relPath(n.EmbeddedInode())
 	return ToPath(filepath.Join("/", basePath))
 }
 
@@ -0,0 +1,44 @@
+package mcbridgefs
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+)
+
+// pathContextGeneration is bumped whenever a rename may have changed the path of existing nodes.
+// Every cached TransferPathContext from an earlier generation is stale. A rename can move a whole
+// subtree, so rather than finding all the nodes under it, the caches are invalidated all at once.
+var pathContextGeneration uint64
+
+// invalidatePathContexts marks all cached TransferPathContexts as stale.
+func invalidatePathContexts() {
+	atomic.AddUint64(&pathContextGeneration, 1)
+}
+
+// pathContextCache memoizes a Node's TransferPathContext. Working out the context walks the node's
+// parents to build its path and then parses it, and Lookup and Readdir heavy workloads ask for the
+// context of the same nodes over and over. It is safe for use by concurrent FUSE callbacks.
+type pathContextCache struct {
+	mu          sync.Mutex
+	generation  uint64
+	pathContext *mcpath.TransferPathContext
+}
+
+// get returns a copy of the cached context, calling compute to fill the cache if it is empty or
+// stale. A copy is returned so callers can't change the cached context.
+func (c *pathContextCache) get(compute func() *mcpath.TransferPathContext) *mcpath.TransferPathContext {
+	generation := atomic.LoadUint64(&pathContextGeneration)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pathContext == nil || c.generation != generation {
+		c.pathContext = compute()
+		c.generation = generation
+	}
+
+	pathContext := *c.pathContext
+	return &pathContext
+}
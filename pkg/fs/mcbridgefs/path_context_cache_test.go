@@ -0,0 +1,133 @@
+package mcbridgefs
+
+import (
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/fs/bridgefs"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+// newRootNode returns a Node set up as the root of a file system that isn't mounted, so that its
+// path can be worked out.
+func newRootNode() *Node {
+	n := &Node{BridgeNode: &bridgefs.BridgeNode{}}
+	_ = fs.NewNodeFS(n, &fs.Options{})
+	return n
+}
+
+// addChildDir adds a directory called name to parent, and returns its Node.
+func addChildDir(parent *Node, name string) *Node {
+	child := &Node{BridgeNode: &bridgefs.BridgeNode{}}
+	inode := parent.NewPersistentInode(context.Background(), child, fs.StableAttr{Mode: fuse.S_IFDIR})
+	parent.AddChild(name, inode, false)
+	return child
+}
+
+func TestPathContextCache(t *testing.T) {
+	var cache pathContextCache
+	computed := 0
+	compute := func() *mcpath.TransferPathContext {
+		computed++
+		return &mcpath.TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: computed}
+	}
+
+	require.Equal(t, 1, cache.get(compute).ProjectID)
+	require.Equal(t, 1, cache.get(compute).ProjectID)
+	require.Equal(t, 1, computed)
+
+	// Changing the returned copy doesn't change what is cached.
+	cache.get(compute).ProjectID = 100
+	require.Equal(t, 1, cache.get(compute).ProjectID)
+
+	invalidatePathContexts()
+	require.Equal(t, 2, cache.get(compute).ProjectID)
+	require.Equal(t, 2, computed)
+}
+
+func TestNodePathContextInvalidatedOnRename(t *testing.T) {
+	savedTransferRequest := transferRequest
+	defer func() { transferRequest = savedTransferRequest }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	n := newRootNode()
+	require.Equal(t, 2, n.ToTransferPathContext().ProjectID)
+
+	// The node keeps using its cached context until a rename invalidates it.
+	transferRequest.ProjectID = 3
+	require.Equal(t, 2, n.ToTransferPathContext().ProjectID)
+
+	invalidatePathContexts()
+	require.Equal(t, 3, n.ToTransferPathContext().ProjectID)
+}
+
+func BenchmarkPathContext(b *testing.B) {
+	deepPath := strings.Repeat("dir/", 30) + "file.txt"
+	parse := func(parses *int) func() *mcpath.TransferPathContext {
+		return func() *mcpath.TransferPathContext {
+			*parses++
+			return mcpath.ToTransferPathContext("/__transfers/globus/1/2/" + deepPath)
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		parses := 0
+		compute := parse(&parses)
+		for i := 0; i < b.N; i++ {
+			_ = compute()
+		}
+		b.ReportMetric(float64(parses)/float64(b.N), "parses/op")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		parses := 0
+		compute := parse(&parses)
+		var cache pathContextCache
+		for i := 0; i < b.N; i++ {
+			_ = cache.get(compute)
+		}
+		b.ReportMetric(float64(parses)/float64(b.N), "parses/op")
+	})
+}
+
+// callerContext returns a context for a FUSE call made by the user with callerUID.
+func callerContext(callerUID uint32) context.Context {
+	return &fuse.Context{Caller: fuse.Caller{Owner: fuse.Owner{Uid: callerUID, Gid: gid}}}
+}
+
+func TestNodeChecksOwnerBeforeChanges(t *testing.T) {
+	savedTransferRequest := transferRequest
+	defer func() { transferRequest = savedTransferRequest }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	n := newRootNode()
+	dir := addChildDir(n, "d1")
+	require.Equal(t, 0, int(dir.checkOwner(callerContext(uid), "test")))
+	require.Equal(t, 0, int(dir.checkOwner(context.Background(), "test")))
+
+	// The files belong to the user the mount serves them as, so another user can't change them.
+	other := callerContext(uid + 1)
+	require.Equal(t, syscall.EACCES, dir.checkOwner(other, "test"))
+	require.Equal(t, syscall.EACCES, dir.Setattr(other, nil, &fuse.SetAttrIn{}, &fuse.AttrOut{}))
+	_, _, _, errno := n.Create(other, "file.txt", 0, 0644, &fuse.EntryOut{})
+	require.Equal(t, syscall.EACCES, errno)
+}
+
+func TestRenameIntoItselfIsRejected(t *testing.T) {
+	savedTransferRequest := transferRequest
+	defer func() { transferRequest = savedTransferRequest }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	root := newRootNode()
+	d1 := addChildDir(root, "d1")
+	d2 := addChildDir(d1, "d2")
+
+	require.Equal(t, syscall.EINVAL, root.Rename(context.Background(), "d1", d1, "d1", 0))
+	require.Equal(t, syscall.EINVAL, root.Rename(context.Background(), "d1", d2, "moved", 0))
+}
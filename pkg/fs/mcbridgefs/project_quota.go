@@ -0,0 +1,73 @@
+package mcbridgefs
+
+import (
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"gorm.io/gorm"
+)
+
+// ProjectQuota is how many bytes a project is allowed to use, and how many it is using. A Quota of 0
+// means the project doesn't have a quota.
+type ProjectQuota struct {
+	Quota uint64
+	Used  uint64
+}
+
+// ProjectQuotaStore looks up the ProjectQuota for a project.
+type ProjectQuotaStore interface {
+	GetProjectQuota(projectID int) (*ProjectQuota, error)
+}
+
+// dbProjectQuotaStore takes the usage from the project's size and gives every project the same quota.
+type dbProjectQuotaStore struct {
+	db    *gorm.DB
+	quota uint64
+}
+
+func newDBProjectQuotaStore(db *gorm.DB, quota uint64) *dbProjectQuotaStore {
+	return &dbProjectQuotaStore{db: db, quota: quota}
+}
+
+func (s *dbProjectQuotaStore) GetProjectQuota(projectID int) (*ProjectQuota, error) {
+	var project struct {
+		Size int64
+	}
+	if err := s.db.Table("projects").Select("size").Where("id = ?", projectID).Take(&project).Error; err != nil {
+		return nil, err
+	}
+
+	used := uint64(0)
+	if project.Size > 0 {
+		used = uint64(project.Size)
+	}
+
+	return &ProjectQuota{Quota: s.quota, Used: used}, nil
+}
+
+// fillStatfs replaces the capacity in out with the quota. The block size already in out is kept so
+// the numbers line up with the underlying file system. Usage over the quota reports as no space free.
+func (q *ProjectQuota) fillStatfs(out *fuse.StatfsOut) {
+	if q.Quota == 0 {
+		return
+	}
+
+	// df counts blocks in units of the fragment size when it is set, and the block size otherwise.
+	blockSize := uint64(out.Frsize)
+	if blockSize == 0 {
+		blockSize = uint64(out.Bsize)
+	}
+
+	if blockSize == 0 {
+		blockSize = 4096
+		out.Bsize = 4096
+		out.Frsize = 4096
+	}
+
+	free := uint64(0)
+	if q.Used < q.Quota {
+		free = q.Quota - q.Used
+	}
+
+	out.Blocks = q.Quota / blockSize
+	out.Bfree = free / blockSize
+	out.Bavail = out.Bfree
+}
@@ -0,0 +1,58 @@
+package mcbridgefs
+
+import (
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectQuotaStatfs(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+
+	tests := []struct {
+		name           string
+		quota          ProjectQuota
+		in             fuse.StatfsOut
+		expectedBlocks uint64
+		expectedFree   uint64
+	}{
+		{
+			name:           "usage under quota",
+			quota:          ProjectQuota{Quota: 10 * gb, Used: 4 * gb},
+			in:             fuse.StatfsOut{Blocks: 1000, Bfree: 1000, Bavail: 1000, Bsize: 4096, Frsize: 4096},
+			expectedBlocks: 10 * gb / 4096,
+			expectedFree:   6 * gb / 4096,
+		},
+		{
+			name:           "usage over quota",
+			quota:          ProjectQuota{Quota: 1 * gb, Used: 2 * gb},
+			in:             fuse.StatfsOut{Bsize: 4096, Frsize: 4096},
+			expectedBlocks: 1 * gb / 4096,
+			expectedFree:   0,
+		},
+		{
+			name:           "no block size uses 4k blocks",
+			quota:          ProjectQuota{Quota: 1 * gb, Used: 0},
+			expectedBlocks: 1 * gb / 4096,
+			expectedFree:   1 * gb / 4096,
+		},
+		{
+			name:           "no quota keeps file system values",
+			quota:          ProjectQuota{Used: 2 * gb},
+			in:             fuse.StatfsOut{Blocks: 1000, Bfree: 500, Bavail: 500, Bsize: 4096, Frsize: 4096},
+			expectedBlocks: 1000,
+			expectedFree:   500,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := test.in
+			test.quota.fillStatfs(&out)
+			require.Equal(t, test.expectedBlocks, out.Blocks)
+			require.Equal(t, test.expectedFree, out.Bfree)
+			require.Equal(t, test.expectedFree, out.Bavail)
+		})
+	}
+}
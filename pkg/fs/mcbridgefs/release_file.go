@@ -1,8 +1,8 @@
 package mcbridgefs
 
 import (
+	"context"
 	"crypto/tls"
-	"os"
 
 	"github.com/apex/log"
 	"github.com/go-resty/resty/v2"
@@ -58,8 +58,8 @@ func updateForExistingFile(file *mcmodel.File, uuid string, fileID int) error {
 	}
 
 	// Delete the uploaded file
-	filePath := file.ToUnderlyingFilePath(mcfsRoot)
-	if err := os.Remove(filePath); err != nil {
+	filePath := underlyingFilePath(file)
+	if err := objectStore.Delete(context.Background(), filePath); err != nil {
 		log.Errorf("Failed to delete file (%): %s", filePath, err)
 		// TODO: Return err here?
 	}
@@ -0,0 +1,113 @@
+package mcbridgefs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"syscall"
+
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// openForWrite opens the local file that writes to key go to, see FileHandle. When objectStore keeps
+// its objects in local files that is the object's own file. Otherwise the object is staged in a
+// temporary file, which is put back into objectStore each time a handle on it is released, see
+// OpenFile.releaseStaged.
+func openForWrite(key string, openFile *OpenFile, flags int, mode uint32) (int, error) {
+	if local, ok := objectStore.(objectstore.LocalFiler); ok {
+		return syscall.Open(local.LocalPath(key), flags, mode)
+	}
+
+	path, err := openFile.stage(key)
+	if err != nil {
+		return -1, err
+	}
+
+	fd, err := syscall.Open(path, flags, mode)
+	if err != nil {
+		_ = openFile.releaseStaged(key)
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+// stage returns the temporary file holding a copy of key for writing, copying it out of objectStore
+// for the first handle. An object that doesn't exist yet is created empty, so the file can be looked
+// up while it is being written.
+func (o *OpenFile) stage(key string) (string, error) {
+	o.stageMu.Lock()
+	defer o.stageMu.Unlock()
+
+	if o.stagedPath == "" {
+		path, err := copyToTempFile(key)
+		if err != nil {
+			return "", err
+		}
+		o.stagedPath = path
+	}
+
+	o.stagedHandles++
+	return o.stagedPath, nil
+}
+
+// releaseStaged puts the staged copy of key back into objectStore when a handle on it is released,
+// and removes the copy once the last handle is released. It does nothing for a file that isn't staged.
+func (o *OpenFile) releaseStaged(key string) error {
+	o.stageMu.Lock()
+	defer o.stageMu.Unlock()
+
+	if o.stagedPath == "" {
+		return nil
+	}
+
+	f, err := os.Open(o.stagedPath)
+	if err != nil {
+		return err
+	}
+	_, err = objectStore.Put(context.Background(), key, f)
+	_ = f.Close()
+
+	if o.stagedHandles--; o.stagedHandles == 0 {
+		_ = os.Remove(o.stagedPath)
+		o.stagedPath = ""
+	}
+
+	return err
+}
+
+// copyToTempFile copies key from objectStore into a new temporary file and returns its path.
+func copyToTempFile(key string) (string, error) {
+	ctx := context.Background()
+	src, err := objectStore.Get(ctx, key, 0, -1)
+	switch {
+	case errors.Is(err, objectstore.ErrNotFound):
+		if _, err := objectStore.Put(ctx, key, bytes.NewReader(nil)); err != nil {
+			return "", err
+		}
+		src = ioutil.NopCloser(bytes.NewReader(nil))
+	case err != nil:
+		return "", err
+	}
+	defer src.Close()
+
+	f, err := ioutil.TempFile("", "mcbridgefs-staged")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,49 @@
+package mcbridgefs
+
+import (
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+)
+
+// StorageRouter decides where the contents of files are stored. The path of a file within a project
+// doesn't say anything about where its contents live, so a router can spread projects across more
+// than one storage backend.
+type StorageRouter interface {
+	// StorageRoot returns the directory the contents of files in pathContext are stored under.
+	StorageRoot(pathContext *mcpath.TransferPathContext) string
+}
+
+// SingleStorageRouter stores the contents of every file under one directory. It is the router
+// CreateFS uses when it isn't given one.
+type SingleStorageRouter string
+
+// StorageRoot returns the router's directory, whatever the context.
+func (r SingleStorageRouter) StorageRoot(_ *mcpath.TransferPathContext) string {
+	return string(r)
+}
+
+// fileContext returns the TransferPathContext for a file in the database. Files written through the
+// mount always belong to the owner of the transfer request.
+func fileContext(file *mcmodel.File) *mcpath.TransferPathContext {
+	path := file.Name
+	if file.IsDir() || file.Directory != nil {
+		path = file.FullPath()
+	}
+
+	return &mcpath.TransferPathContext{
+		TransferType: mcpath.GlobusTransferType,
+		UserID:       transferRequest.OwnerID,
+		ProjectID:    file.ProjectID,
+		Path:         mcpath.NewRelPath(path),
+	}
+}
+
+// storageRoot returns the directory router stores the contents of file under.
+func storageRoot(router StorageRouter, file *mcmodel.File) string {
+	return router.StorageRoot(fileContext(file))
+}
+
+// underlyingFilePath returns where the contents of file are stored.
+func underlyingFilePath(file *mcmodel.File) string {
+	return file.ToUnderlyingFilePath(storageRoot(storage, file))
+}
@@ -0,0 +1,66 @@
+package mcbridgefs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+// projectStorageRouter stores project 1 on the fast backend and everything else on the slow one.
+type projectStorageRouter struct {
+	fast, slow string
+}
+
+func (r projectStorageRouter) StorageRoot(pathContext *mcpath.TransferPathContext) string {
+	if pathContext.ProjectID == 1 {
+		return r.fast
+	}
+
+	return r.slow
+}
+
+func TestStorageRouterRoutesByProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mcbridgefs")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	router := projectStorageRouter{fast: filepath.Join(dir, "fast"), slow: filepath.Join(dir, "slow")}
+	storage = router
+	t.Cleanup(func() { storage = nil })
+
+	dirs := map[int]*mcmodel.File{
+		1: {ProjectID: 1, Path: "/d1", MimeType: "directory"},
+		2: {ProjectID: 2, Path: "/d1", MimeType: "directory"},
+	}
+	for projectID, backend := range map[int]string{1: router.fast, 2: router.slow} {
+		current := &mcmodel.File{UUID: "11111111-aaaa-1111-1111-111111111111", ProjectID: projectID, Name: "file.txt", Directory: dirs[projectID]}
+		newVersion := &mcmodel.File{UUID: "22222222-bbbb-2222-2222-222222222222", ProjectID: projectID, Name: "file.txt", Directory: dirs[projectID]}
+		for _, f := range []*mcmodel.File{current, newVersion} {
+			require.NoError(t, os.MkdirAll(f.ToUnderlyingDirPath(backend), 0755))
+		}
+
+		require.NoError(t, ioutil.WriteFile(current.ToUnderlyingFilePath(backend), []byte("data"), 0644))
+		require.NoError(t, ioutil.WriteFile(newVersion.ToUnderlyingFilePath(backend), nil, 0644))
+
+		tracker := NewOpenFilesTracker()
+		tracker.Store("/d1/file.txt", newVersion)
+		require.NoError(t, initNewVersion(current, newVersion, tracker.Get("/d1/file.txt"), syscall.O_WRONLY))
+
+		require.Equal(t, newVersion.ToUnderlyingFilePath(backend), underlyingFilePath(newVersion))
+		contents, err := ioutil.ReadFile(newVersion.ToUnderlyingFilePath(backend))
+		require.NoError(t, err)
+		require.Equal(t, "data", string(contents))
+	}
+}
+
+func TestSingleStorageRouter(t *testing.T) {
+	router := SingleStorageRouter("/mcfs/data")
+	require.Equal(t, "/mcfs/data", router.StorageRoot(&mcpath.TransferPathContext{ProjectID: 1}))
+	require.Equal(t, "/mcfs/data", router.StorageRoot(&mcpath.TransferPathContext{ProjectID: 2}))
+}
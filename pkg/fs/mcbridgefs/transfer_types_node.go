@@ -0,0 +1,98 @@
+package mcbridgefs
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"golang.org/x/sys/unix"
+)
+
+// TransferTypesNode is the root of a mount created WithTransferTypes. It has a directory for each of
+// the configured transfer types, as the transfers root does. The one for mcpath.GlobusTransferType
+// holds the project of the transfer request, and the others are empty.
+type TransferTypesNode struct {
+	fs.Inode
+	types   []string
+	project *Node
+}
+
+var _ = (fs.NodeOnAdder)((*TransferTypesNode)(nil))
+var _ = (fs.NodeReaddirer)((*TransferTypesNode)(nil))
+var _ = (fs.NodeGetattrer)((*TransferTypesNode)(nil))
+var _ = (fs.NodeAccesser)((*TransferTypesNode)(nil))
+
+func newTransferTypesNode(types []string, project *Node) *TransferTypesNode {
+	return &TransferTypesNode{types: types, project: project}
+}
+
+// OnAdd adds the directories for the transfer types once the node is in the tree.
+func (n *TransferTypesNode) OnAdd(ctx context.Context) {
+	for _, transferType := range n.types {
+		var child fs.InodeEmbedder = &emptyDirNode{}
+		if transferType == mcpath.GlobusTransferType {
+			child = n.project
+		}
+
+		n.AddChild(transferType, n.NewPersistentInode(ctx, child, fs.StableAttr{Mode: fuse.S_IFDIR}), false)
+	}
+}
+
+// Readdir lists the transfer types, sorted by name.
+func (n *TransferTypesNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := make([]fuse.DirEntry, 0, len(n.types))
+	for _, transferType := range n.types {
+		entries = append(entries, fuse.DirEntry{Mode: fuse.S_IFDIR, Name: transferType})
+	}
+
+	return fs.NewListDirStream(entries), fs.OK
+}
+
+// Getattr reports the node as a directory owned by the process the bridge is running as.
+func (n *TransferTypesNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return dirAttr(out)
+}
+
+// Access allows reading the node but not writing to it, as only the project can be written to.
+func (n *TransferTypesNode) Access(ctx context.Context, mask uint32) syscall.Errno {
+	return dirAccess(mask)
+}
+
+// emptyDirNode is the directory for a transfer type other than the transfer request's. Nothing can
+// be created in it.
+type emptyDirNode struct {
+	fs.Inode
+}
+
+var _ = (fs.NodeGetattrer)((*emptyDirNode)(nil))
+var _ = (fs.NodeAccesser)((*emptyDirNode)(nil))
+
+// Getattr reports the node as a directory owned by the process the bridge is running as.
+func (n *emptyDirNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return dirAttr(out)
+}
+
+// Access allows reading the node but not writing to it.
+func (n *emptyDirNode) Access(ctx context.Context, mask uint32) syscall.Errno {
+	return dirAccess(mask)
+}
+
+func dirAttr(out *fuse.AttrOut) syscall.Errno {
+	out.Uid = uid
+	out.Gid = gid
+	out.Mode = fuse.S_IFDIR | 0755
+	now := time.Now()
+	out.SetTimes(&now, &now, &now)
+	return fs.OK
+}
+
+func dirAccess(mask uint32) syscall.Errno {
+	if mask&unix.W_OK != 0 {
+		return syscall.EACCES
+	}
+
+	return fs.OK
+}
@@ -0,0 +1,69 @@
+package mcbridgefs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/fs/bridgefs"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+// newTransferTypesRoot returns the root of a file system that isn't mounted, configured with opts,
+// along with the project node under it.
+func newTransferTypesRoot(opts ...Option) (*TransferTypesNode, *Node) {
+	var config Config
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	transferTypes = config.TransferTypes
+	project := &Node{BridgeNode: &bridgefs.BridgeNode{}}
+	root := newTransferTypesNode(config.TransferTypes, project)
+	_ = fs.NewNodeFS(root, &fs.Options{})
+	return root, project
+}
+
+func readdirNames(t *testing.T, n fs.NodeReaddirer) []string {
+	stream, errno := n.Readdir(context.Background())
+	require.Equal(t, fs.OK, errno)
+
+	var names []string
+	for stream.HasNext() {
+		entry, errno := stream.Next()
+		require.Equal(t, fs.OK, errno)
+		names = append(names, entry.Name)
+	}
+
+	return names
+}
+
+func TestTransferTypesListedAtRoot(t *testing.T) {
+	savedTransferTypes, savedTransferRequest := transferTypes, transferRequest
+	defer func() { transferTypes, transferRequest = savedTransferTypes, savedTransferRequest }()
+
+	root, _ := newTransferTypesRoot(WithTransferTypes("s3", "archive", "s3"))
+	require.Equal(t, []string{"archive", "globus", "s3"}, readdirNames(t, root))
+
+	// Without types every type with a layout is listed.
+	root, _ = newTransferTypesRoot(WithTransferTypes())
+	require.Equal(t, mcpath.TransferTypes(), readdirNames(t, root))
+}
+
+func TestProjectUnderItsTransferType(t *testing.T) {
+	savedTransferTypes, savedTransferRequest := transferTypes, transferRequest
+	defer func() { transferTypes, transferRequest = savedTransferTypes, savedTransferRequest }()
+
+	transferRequest = mcmodel.TransferRequest{OwnerID: 1, ProjectID: 2}
+	root, project := newTransferTypesRoot(WithTransferTypes("archive"))
+	require.Equal(t, project.EmbeddedInode(), root.GetChild(mcpath.GlobusTransferType))
+	require.NotNil(t, root.GetChild(mcpath.ArchiveTransferType))
+
+	// The transfer type directory isn't part of the paths within the project.
+	dir := addChildDir(project, "d1")
+	require.Equal(t, mcpath.RelPath("d1"), dir.ToTransferPathContext().Path)
+	require.Equal(t, mcpath.RelPath(""), project.ToTransferPathContext().Path)
+	require.Equal(t, 0, int(dir.checkOwner(context.Background(), "test")))
+}
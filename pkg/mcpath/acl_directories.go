@@ -0,0 +1,38 @@
+package mcpath
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ACLDirectories returns the endpoint directories that need an ACL rule granting write access so
+// that files can be uploaded for contexts. Access is granted a project at a time, so each context is
+// cut off at the project level with ProjectPathContext, and every project is returned once however
+// many contexts fall in it. Globus requires the path of an ACL rule on a directory to end with a
+// slash, so each one does. The result is sorted so that a setup step creating the rules in bulk
+// works through them in the same order each time.
+//
+// Every context must be at or below the project level. ErrInvalidID is returned for the first one
+// that isn't, and the error from Validate for the first one that is invalid.
+func ACLDirectories(contexts []*TransferPathContext) ([]string, error) {
+	seen := make(map[string]bool)
+	dirs := make([]string, 0, len(contexts))
+	for _, pathContext := range contexts {
+		if err := pathContext.Validate(); err != nil {
+			return nil, err
+		}
+
+		if !pathContext.IsUser() || !pathContext.IsProject() {
+			return nil, fmt.Errorf("%w: user %d, project %d", ErrInvalidID, pathContext.UserID, pathContext.ProjectID)
+		}
+
+		dir := pathContext.ProjectPathContext().ToFSPath("") + "/"
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
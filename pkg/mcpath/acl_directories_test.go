@@ -0,0 +1,44 @@
+package mcpath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestACLDirectories(t *testing.T) {
+	contexts := []*TransferPathContext{
+		ToTransferPathContext("/__transfers/globus/1/2/d1/file.txt"),
+		ToTransferPathContext("/__transfers/globus/1/2/d1/d2/other.txt"),
+		ToTransferPathContext("/__transfers/globus/1/2"),
+		ToTransferPathContext("/__transfers/globus/3/2/file.txt"),
+		ToTransferPathContext("/__transfers/archive/1/2/c1/file.txt"),
+		ToTransferPathContext("/__transfers/archive/1/2/c2/file.txt"),
+		ToTransferPathContext("/__transfers/globus/1/10/file.txt"),
+	}
+
+	dirs, err := ACLDirectories(contexts)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"/__transfers/archive/1/2/",
+		"/__transfers/globus/1/10/",
+		"/__transfers/globus/1/2/",
+		"/__transfers/globus/3/2/",
+	}, dirs)
+
+	dirs, err = ACLDirectories(nil)
+	require.NoError(t, err)
+	require.Empty(t, dirs)
+}
+
+func TestACLDirectoriesRequiresProject(t *testing.T) {
+	_, err := ACLDirectories([]*TransferPathContext{
+		ToTransferPathContext("/__transfers/globus/1/2/file.txt"),
+		ToTransferPathContext("/__transfers/globus/1"),
+	})
+	require.True(t, errors.Is(err, ErrInvalidID))
+
+	_, err = ACLDirectories([]*TransferPathContext{{UserID: 1, ProjectID: 2}})
+	require.True(t, errors.Is(err, ErrMissingTransferType))
+}
@@ -0,0 +1,91 @@
+package mcpath
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMissingLevel is returned by ContextBuilder.Build when a level of the transfer tree is set but a
+// level above it isn't, for example a project without a user.
+var ErrMissingLevel = errors.New("a level requires the levels above it")
+
+// ErrUnexpectedCohort is returned by ContextBuilder.Build when a cohort is set for a transfer type
+// that doesn't have cohorts.
+var ErrUnexpectedCohort = errors.New("transfer type doesn't have cohorts")
+
+// ContextBuilder builds a TransferPathContext a level at a time, checking the result in Build rather
+// than leaving callers to populate the struct directly:
+//
+//	p, err := NewContextBuilder().TransferType(GlobusTransferType).User(1).Project(2).Path("a/b").Build()
+//
+// Levels that aren't set are left out, so a builder can stop at any level, the same as a path can.
+type ContextBuilder struct {
+	p          TransferPathContext
+	userSet    bool
+	projectSet bool
+}
+
+// NewContextBuilder returns a builder for a context at the root of the transfer tree.
+func NewContextBuilder() *ContextBuilder {
+	return &ContextBuilder{}
+}
+
+// TransferType sets the transfer type.
+func (b *ContextBuilder) TransferType(transferType string) *ContextBuilder {
+	b.p.TransferType = transferType
+	return b
+}
+
+// User sets the user id.
+func (b *ContextBuilder) User(userID int) *ContextBuilder {
+	b.p.UserID = userID
+	b.userSet = true
+	return b
+}
+
+// Project sets the project id.
+func (b *ContextBuilder) Project(projectID int) *ContextBuilder {
+	b.p.ProjectID = projectID
+	b.projectSet = true
+	return b
+}
+
+// Cohort sets the cohort, for transfer types that have one.
+func (b *ContextBuilder) Cohort(cohort string) *ContextBuilder {
+	b.p.Cohort = cohort
+	return b
+}
+
+// Path sets the path within the project (or cohort). It is cleaned with NewRelPath.
+func (b *ContextBuilder) Path(path string) *ContextBuilder {
+	b.p.Path = NewRelPath(path)
+	return b
+}
+
+// Build returns the context, or an error if it doesn't describe a place in the transfer tree. Along
+// with the checks Validate makes, it returns ErrInvalidID for an id that was set but isn't positive,
+// ErrMissingLevel for a level that is set without the ones above it, and ErrUnexpectedCohort for a
+// cohort on a transfer type that doesn't have them. Each call returns a new context.
+func (b *ContextBuilder) Build() (*TransferPathContext, error) {
+	p := b.p
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case (b.userSet && !p.IsUser()) || (b.projectSet && !p.IsProject()):
+		return nil, fmt.Errorf("%w: user %d, project %d", ErrInvalidID, p.UserID, p.ProjectID)
+	case p.IsProject() && !p.IsUser():
+		return nil, fmt.Errorf("%w: project %d has no user", ErrMissingLevel, p.ProjectID)
+	case p.Cohort != "" && !p.hasCohort():
+		return nil, fmt.Errorf("%w: transfer type %s, cohort %q", ErrUnexpectedCohort, p.TransferType, p.Cohort)
+	case p.Cohort != "" && !p.IsProject():
+		return nil, fmt.Errorf("%w: cohort %q has no project", ErrMissingLevel, p.Cohort)
+	case !p.Path.IsRoot() && !p.IsProject():
+		return nil, fmt.Errorf("%w: path %q has no project", ErrMissingLevel, p.Path)
+	case !p.Path.IsRoot() && p.hasCohort() && p.Cohort == "":
+		return nil, fmt.Errorf("%w: path %q has no cohort", ErrMissingLevel, p.Path)
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,78 @@
+package mcpath
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *ContextBuilder
+		expected TransferPathContext
+	}{
+		{name: "root", builder: NewContextBuilder(), expected: TransferPathContext{}},
+		{name: "transfer type", builder: NewContextBuilder().TransferType("globus"), expected: TransferPathContext{TransferType: "globus"}},
+		{name: "user", builder: NewContextBuilder().TransferType("globus").User(1), expected: TransferPathContext{TransferType: "globus", UserID: 1}},
+		{name: "project", builder: NewContextBuilder().TransferType("globus").User(1).Project(2), expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}},
+		{name: "path", builder: NewContextBuilder().TransferType("globus").User(1).Project(2).Path("/a//b/"), expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "a/b"}},
+		{name: "archive", builder: NewContextBuilder().TransferType("archive").User(1).Project(2).Cohort("c1").Path("a"), expected: TransferPathContext{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", Path: "a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := test.builder.Build()
+			require.NoError(t, err)
+			require.Equal(t, test.expected, *p)
+			require.Equal(t, p, ToTransferPathContext(p.String()))
+		})
+	}
+}
+
+func TestContextBuilderMatchesBuildTransferDestination(t *testing.T) {
+	p, err := NewContextBuilder().TransferType("globus").User(1).Project(2).Path("d1").Build()
+	require.NoError(t, err)
+
+	dest, err := BuildTransferDestination("globus", 1, 2, "d1/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, dest, p.ToFSPath("file.txt"))
+}
+
+func TestContextBuilderFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *ContextBuilder
+		expected error
+	}{
+		{name: "project without user", builder: NewContextBuilder().TransferType("globus").Project(2), expected: ErrMissingLevel},
+		{name: "path without project", builder: NewContextBuilder().TransferType("globus").User(1).Path("a"), expected: ErrMissingLevel},
+		{name: "cohort without project", builder: NewContextBuilder().TransferType("archive").User(1).Cohort("c1"), expected: ErrMissingLevel},
+		{name: "archive path without cohort", builder: NewContextBuilder().TransferType("archive").User(1).Project(2).Path("a"), expected: ErrMissingLevel},
+		{name: "cohort on globus", builder: NewContextBuilder().TransferType("globus").User(1).Project(2).Cohort("c1"), expected: ErrUnexpectedCohort},
+		{name: "zero user", builder: NewContextBuilder().TransferType("globus").User(0), expected: ErrInvalidID},
+		{name: "negative project", builder: NewContextBuilder().TransferType("globus").User(1).Project(-2), expected: ErrInvalidID},
+		{name: "no transfer type", builder: NewContextBuilder().User(1).Project(2), expected: ErrMissingTransferType},
+		{name: "dot transfer type", builder: NewContextBuilder().TransferType(".."), expected: ErrDotEntry},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := test.builder.Build()
+			require.Nil(t, p)
+			require.True(t, errors.Is(err, test.expected), "expected %v, got %v", test.expected, err)
+		})
+	}
+}
+
+func TestContextBuilderBuildReturnsNewContexts(t *testing.T) {
+	b := NewContextBuilder().TransferType("globus").User(1).Project(2)
+	p1, err := b.Build()
+	require.NoError(t, err)
+
+	p2, err := b.Path("a").Build()
+	require.NoError(t, err)
+	require.Equal(t, RelPath(""), p1.Path)
+	require.Equal(t, RelPath("a"), p2.Path)
+}
@@ -0,0 +1,78 @@
+package mcpath
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// PathNormalizer rewrites a path before it is turned into a RelPath. Clients on different
+// operating systems can send the same logical path with different separators or Unicode forms,
+// and a normalizer maps those onto a single form so they aren't treated as different files.
+type PathNormalizer func(p string) string
+
+// IdentityNormalizer returns p unchanged.
+func IdentityNormalizer(p string) string {
+	return p
+}
+
+// CanonicalNormalizer turns backslash separators into slashes and puts the path into Unicode
+// NFC form. Backslashes are allowed in file names on most file systems, so this should only be
+// used when every client is known to not rely on them.
+func CanonicalNormalizer(p string) string {
+	return norm.NFC.String(strings.ReplaceAll(p, `\`, "/"))
+}
+
+// PercentDecodeNormalizer decodes percent-escaped characters, such as %20 for a space, in each
+// segment of p. Escaped slashes (%2F) and NULs (%00) are left escaped, since decoding them would
+// turn a single file name into more than one path segment or into a name no file system accepts.
+// Malformed escapes are left as they are. Names can legitimately contain a %, so paths are only
+// decoded when this normalizer is chosen.
+func PercentDecodeNormalizer(p string) string {
+	if !strings.Contains(p, "%") {
+		return p
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '%' && i+2 < len(p) {
+			if c, ok := unhex(p[i+1], p[i+2]); ok && c != '/' && c != 0 {
+				b.WriteByte(c)
+				i += 2
+				continue
+			}
+		}
+
+		b.WriteByte(p[i])
+	}
+
+	return b.String()
+}
+
+// unhex returns the byte encoded by the two hex digits h and l.
+func unhex(h, l byte) (byte, bool) {
+	hv, ok := hexValue(h)
+	if !ok {
+		return 0, false
+	}
+
+	lv, ok := hexValue(l)
+	if !ok {
+		return 0, false
+	}
+
+	return hv<<4 | lv, true
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,55 @@
+package mcpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalNormalizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected RelPath
+	}{
+		{name: "slashes", path: "d1/d2/file.txt", expected: "d1/d2/file.txt"},
+		{name: "backslashes", path: `d1\d2\file.txt`, expected: "d1/d2/file.txt"},
+		{name: "mixed", path: `d1\d2/file.txt`, expected: "d1/d2/file.txt"},
+		{name: "nfd", path: "re\u0301sume\u0301.txt", expected: "r\u00e9sum\u00e9.txt"},
+		{name: "nfc", path: "r\u00e9sum\u00e9.txt", expected: "r\u00e9sum\u00e9.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, NewRelPath(CanonicalNormalizer(test.path)))
+		})
+	}
+}
+
+func TestIdentityNormalizer(t *testing.T) {
+	require.Equal(t, `d1\file.txt`, IdentityNormalizer(`d1\file.txt`))
+	require.Equal(t, "re\u0301sume\u0301.txt", IdentityNormalizer("re\u0301sume\u0301.txt"))
+}
+
+func TestPercentDecodeNormalizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "plain", path: "d1/file.txt", expected: "d1/file.txt"},
+		{name: "spaces", path: "run%201/my%20file.txt", expected: "run 1/my file.txt"},
+		{name: "slash", path: "d1/a%2Fb.txt", expected: "d1/a%2Fb.txt"},
+		{name: "lower case slash", path: "d1/a%2fb.txt", expected: "d1/a%2fb.txt"},
+		{name: "nul", path: "a%00b.txt", expected: "a%00b.txt"},
+		{name: "unicode", path: "r%C3%A9sum%C3%A9.txt", expected: "r\u00e9sum\u00e9.txt"},
+		{name: "percent", path: "100%25.txt", expected: "100%.txt"},
+		{name: "malformed", path: "100%.txt/%zz/%4", expected: "100%.txt/%zz/%4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, PercentDecodeNormalizer(test.path))
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package mcpath
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RelPath is a path relative to a project. It never has a leading or trailing slash, and is blank
+// for the project root. Keeping all the joining and splitting here means callers don't each have
+// their own idea of where the slashes go.
+type RelPath string
+
+// NewRelPath cleans p into a RelPath. Leading, trailing and repeated slashes are removed.
+func NewRelPath(p string) RelPath {
+	return RelPath(strings.Trim(filepath.Clean("/"+p), "/"))
+}
+
+// Join returns the RelPath for name within r.
+func (r RelPath) Join(name string) RelPath {
+	return NewRelPath(string(r) + "/" + name)
+}
+
+// IsRoot returns true if r is the project root.
+func (r RelPath) IsRoot() bool {
+	return r == ""
+}
+
+// Segments returns the entries that make up r. The project root has no segments.
+func (r RelPath) Segments() []string {
+	if r.IsRoot() {
+		return nil
+	}
+
+	return strings.Split(string(r), "/")
+}
+
+func (r RelPath) String() string {
+	return string(r)
+}
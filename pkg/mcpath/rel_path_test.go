@@ -0,0 +1,33 @@
+package mcpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRelPath(t *testing.T) {
+	require.Equal(t, RelPath(""), NewRelPath(""))
+	require.Equal(t, RelPath(""), NewRelPath("/"))
+	require.Equal(t, RelPath("a/b"), NewRelPath("/a//b/"))
+	require.Equal(t, RelPath("a/b"), NewRelPath("a/b"))
+}
+
+func TestRelPathJoin(t *testing.T) {
+	require.Equal(t, RelPath("file.txt"), RelPath("").Join("file.txt"))
+	require.Equal(t, RelPath("a/b/file.txt"), RelPath("a/b").Join("file.txt"))
+	require.Equal(t, RelPath("a/b/c/file.txt"), RelPath("a").Join("/b/c/file.txt"))
+	require.Equal(t, RelPath("a"), RelPath("a").Join(""))
+	require.Equal(t, RelPath(""), RelPath("").Join(""))
+}
+
+func TestRelPathIsRoot(t *testing.T) {
+	require.True(t, RelPath("").IsRoot())
+	require.False(t, RelPath("a").IsRoot())
+}
+
+func TestRelPathSegments(t *testing.T) {
+	require.Nil(t, RelPath("").Segments())
+	require.Equal(t, []string{"a"}, RelPath("a").Segments())
+	require.Equal(t, []string{"a", "b", "file.txt"}, RelPath("a/b/file.txt").Segments())
+}
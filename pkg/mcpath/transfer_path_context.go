@@ -0,0 +1,414 @@
+package mcpath
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TransfersRoot is the top level directory that all upload (destination) paths live under.
+	TransfersRoot = "__transfers"
+
+	// DownloadsRoot is the top level directory that all download (source) paths live under.
+	DownloadsRoot = "__downloads"
+
+	// GlobusTransferType is the transfer type for files moved through Globus.
+	GlobusTransferType = "globus"
+
+	// ArchiveTransferType is the transfer type for files being archived. Archived files are grouped
+	// into cohorts within a project, so their paths have an extra cohort entry.
+	ArchiveTransferType = "archive"
+)
+
+// segment is one of the entries that sits between the transfer type and the path within the project.
+type segment int
+
+const (
+	userSegment segment = iota
+	projectSegment
+	cohortSegment
+)
+
+// transferLayouts has the segments each known transfer type uses. Unknown transfer types are parsed
+// with the Globus layout.
+var transferLayouts = map[string][]segment{
+	GlobusTransferType:  {userSegment, projectSegment},
+	ArchiveTransferType: {userSegment, projectSegment, cohortSegment},
+}
+
+// TransferTypes returns the transfer types that have a layout, sorted by name. These are the entries
+// that belong directly under TransfersRoot, so a listing of it should come from here rather than
+// naming the types itself, and picks up a new type as soon as its layout is added.
+func TransferTypes() []string {
+	return transferTypes(transferLayouts)
+}
+
+// transferTypes returns the transfer types in layouts, sorted by name.
+func transferTypes(layouts map[string][]segment) []string {
+	types := make([]string, 0, len(layouts))
+	for transferType := range layouts {
+		types = append(types, transferType)
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+func layoutFor(transferType string) []segment {
+	if layout, ok := transferLayouts[transferType]; ok {
+		return layout
+	}
+
+	return transferLayouts[GlobusTransferType]
+}
+
+// ErrInvalidID is returned when a context that must be at the project level has a user or project id
+// that isn't positive.
+var ErrInvalidID = errors.New("user and project ids must be positive")
+
+// ErrMissingCohort is returned when a context for a transfer type that has cohorts doesn't have one.
+var ErrMissingCohort = errors.New("cohort must be set")
+
+// ErrMissingTransferType is returned when a context has ids, a cohort or a path but no transfer type.
+// Everything below the transfer type is laid out according to it, so without one there is no way to
+// route the rest of the path.
+var ErrMissingTransferType = errors.New("ids require a transfer type")
+
+// ErrDotEntry is returned when a context's transfer type or cohort is . or .., which would name the
+// directory above it, or the directory itself, rather than an entry within it.
+var ErrDotEntry = errors.New("transfer type and cohort can't be . or ..")
+
+// TransferPathContext describes where a path falls in the transfer tree. Uploads through Globus
+// are written to destination paths that have the following layout:
+//
+//	/__transfers/<transfer type>/<user id>/<project id>/...rest of path...
+//
+// The archive transfer type has an extra entry for the cohort the files belong to:
+//
+//	/__transfers/archive/<user id>/<project id>/<cohort>/...rest of path...
+//
+// A path can stop at any level. For example /__transfers/globus/1 has a TransferType and a
+// UserID, but no ProjectID or Path. Path is relative to the project (or to the cohort for
+// archives) and is blank when the path is at or above that level. Cohort is only set for
+// transfer types that have one. Ids require a transfer type: a context with ids, a cohort or a
+// path but no TransferType is invalid (see Validate).
+type TransferPathContext struct {
+	TransferType string
+	UserID       int
+	ProjectID    int
+	Cohort       string
+	Path         RelPath
+}
+
+// ToTransferPathContext parses a destination path (see TransferPathContext for the layout). It
+// is lenient: the leading __transfers entry isn't checked and ids that don't parse are left as 0.
+// Percent-escapes are taken literally, use ToTransferPathContextDecoded for paths that may have
+// them.
+func ToTransferPathContext(p string) *TransferPathContext {
+	// Split will return ["", "__transfers", "<transfer type>", "...rest of path..."]
+	// Any of the entries after "__transfers" may be missing.
+	pathParts := strings.SplitN(p, "/", 4)
+
+	var transferType, rest string
+	if len(pathParts) > 2 {
+		transferType = pathParts[2]
+	}
+
+	if len(pathParts) > 3 {
+		rest = pathParts[3]
+	}
+
+	return toTransferPathContext(transferType, rest)
+}
+
+// ParseTransferPath is the strict version of ToTransferPathContext. Rather than leaving ids that
+// don't parse as 0 it returns ErrInvalidID, and it returns ErrMissingTransferType for paths such as
+// ///1/2 that have ids but no transfer type. Like ToTransferPathContext the leading __transfers entry
+// isn't checked.
+func ParseTransferPath(p string) (*TransferPathContext, error) {
+	pathParts := strings.SplitN(p, "/", 4)
+
+	var transferType, rest string
+	if len(pathParts) > 2 {
+		transferType = pathParts[2]
+	}
+
+	if len(pathParts) > 3 {
+		rest = pathParts[3]
+	}
+
+	pathContext, err := parseTransferPathContext(transferType, rest)
+	if err == nil {
+		err = pathContext.Validate()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid transfer path %s: %w", p, err)
+	}
+
+	return pathContext, nil
+}
+
+// ToTransferPathContextDecoded is ToTransferPathContext for destination paths that Globus may have
+// percent-escaped. The path is decoded with PercentDecodeNormalizer before it is parsed, so an
+// escaped slash stays part of the file name rather than becoming a separator.
+func ToTransferPathContextDecoded(p string) *TransferPathContext {
+	return ToTransferPathContext(PercentDecodeNormalizer(p))
+}
+
+// ToTransferPathContextFromSource parses the SourcePath of a download. Globus downloads have a
+// blank DestinationPath, so the source is the only place the user and project can be found.
+// Downloads are served out of a tree that is related to, but not the same as, the upload tree:
+//
+//	/__downloads/<user id>/<project id>/...rest of path...
+//
+// There is no transfer type entry because downloads only happen through Globus, so the returned
+// context always has a TransferType of GlobusTransferType. Like ToTransferPathContext it is lenient
+// about the leading __downloads entry and about ids that don't parse.
+func ToTransferPathContextFromSource(p string) *TransferPathContext {
+	// Split will return ["", "__downloads", "...rest of path..."]
+	pathParts := strings.SplitN(p, "/", 3)
+
+	var rest string
+	if len(pathParts) > 2 {
+		rest = pathParts[2]
+	}
+
+	return toTransferPathContext(GlobusTransferType, rest)
+}
+
+// toTransferPathContext fills out a context from the part of the path that follows the transfer type.
+// The layout for the transfer type says which segments come before the path within the project; any
+// of them may be missing.
+func toTransferPathContext(transferType string, rest string) *TransferPathContext {
+	p, _ := parseTransferPathContext(transferType, rest)
+	return p
+}
+
+// parseTransferPathContext is toTransferPathContext, but also returns ErrInvalidID if a user or project
+// segment isn't a positive number. The context is filled out either way, with the bad id left as 0.
+// An empty segment, as left by a trailing slash, is treated as missing rather than invalid.
+func parseTransferPathContext(transferType string, rest string) (*TransferPathContext, error) {
+	p := &TransferPathContext{TransferType: transferType}
+	layout := layoutFor(transferType)
+
+	// Split will return ["<first segment>", ..., "<last segment>", "...rest of path..."]
+	var err error
+	parts := strings.SplitN(rest, "/", len(layout)+1)
+	for i, s := range layout {
+		if i >= len(parts) {
+			break
+		}
+
+		switch s {
+		case userSegment:
+			p.UserID = parseID(parts[i], &err)
+		case projectSegment:
+			p.ProjectID = parseID(parts[i], &err)
+		case cohortSegment:
+			p.Cohort = parts[i]
+		}
+	}
+
+	if len(parts) > len(layout) {
+		p.Path = NewRelPath(parts[len(layout)])
+	}
+
+	return p, err
+}
+
+// parseID parses an id segment. If the segment isn't empty and isn't a positive number it returns 0,
+// and sets *err to ErrInvalidID unless an earlier segment already set it.
+func parseID(segment string, err *error) int {
+	id, parseErr := strconv.Atoi(segment)
+	if segment != "" && (parseErr != nil || id <= 0) {
+		if *err == nil {
+			*err = fmt.Errorf("%w: %q", ErrInvalidID, segment)
+		}
+		return 0
+	}
+
+	return id
+}
+
+// Validate checks the invariant that ids require a transfer type: a context with a user or project id,
+// a cohort, or a path must also have a TransferType. The lenient parsers can produce contexts that
+// break it, for example from ///1/2, and those can't be turned back into a path that means the same
+// thing. It returns ErrMissingTransferType when the invariant doesn't hold, and ErrDotEntry for a
+// transfer type or cohort of . or .., which can't be turned back into a path either.
+func (p *TransferPathContext) Validate() error {
+	switch {
+	case p.TransferType == "" && (p.UserID != 0 || p.ProjectID != 0 || p.Cohort != "" || p.Path != ""):
+		return fmt.Errorf("%w: user %d, project %d", ErrMissingTransferType, p.UserID, p.ProjectID)
+	case isDotEntry(p.TransferType) || isDotEntry(p.Cohort):
+		return fmt.Errorf("%w: transfer type %q, cohort %q", ErrDotEntry, p.TransferType, p.Cohort)
+	}
+
+	return nil
+}
+
+func isDotEntry(entry string) bool {
+	return entry == "." || entry == ".."
+}
+
+// IsValid returns true if the context passes Validate.
+func (p *TransferPathContext) IsValid() bool {
+	return p.Validate() == nil
+}
+
+// IsRoot returns true if the path is /__transfers itself.
+func (p *TransferPathContext) IsRoot() bool {
+	return p.TransferType == ""
+}
+
+// IsUser returns true if the path is at or below the user level. Ids are always positive, so a
+// UserID of 0 or less means the user level isn't set.
+func (p *TransferPathContext) IsUser() bool {
+	return p.UserID > 0
+}
+
+// IsProject returns true if the path is at or below the project level. Ids are always positive, so
+// a ProjectID of 0 or less means the project level isn't set.
+func (p *TransferPathContext) IsProject() bool {
+	return p.ProjectID > 0
+}
+
+// OwnedBy returns true if the path is in userID's part of the transfer tree. Paths above the user
+// level aren't owned by anyone, so they are never owned by userID.
+func (p *TransferPathContext) OwnedBy(userID int) bool {
+	return p.IsUser() && p.UserID == userID
+}
+
+// InUserScope returns true if the path is at or below userID's level of the transfer tree. It is
+// OwnedBy for authorization checks: a context that doesn't pass Validate isn't in anyone's scope.
+func (p *TransferPathContext) InUserScope(userID int) bool {
+	return p.IsValid() && p.OwnedBy(userID)
+}
+
+// InProject returns true if the path is at or below the level of the project with projectID. Paths
+// above the project level, like the root or a user's directory, aren't in any project. Neither is a
+// context that doesn't pass Validate, or one with a project but no user, as a path whose user id
+// didn't parse has.
+func (p *TransferPathContext) InProject(projectID int) bool {
+	return p.IsValid() && p.IsUser() && p.IsProject() && p.ProjectID == projectID
+}
+
+// IsDescendantOf returns true if p is below other in the transfer tree, at any depth. A context
+// isn't a descendant of itself, so two contexts naming the same place, siblings, and contexts in
+// different parts of the tree all return false. The levels are compared the way ToFSPath writes
+// them, so ids that aren't positive count as not set. Contexts that don't pass Validate aren't
+// descendants of anything, and nothing is a descendant of them.
+func (p *TransferPathContext) IsDescendantOf(other *TransferPathContext) bool {
+	if !p.IsValid() || !other.IsValid() {
+		return false
+	}
+
+	return strings.HasPrefix(p.ToFSPath(""), other.ToFSPath("")+"/")
+}
+
+// ProjectPathContext returns a copy of the context cut off at the project level.
+func (p *TransferPathContext) ProjectPathContext() *TransferPathContext {
+	return &TransferPathContext{TransferType: p.TransferType, UserID: p.UserID, ProjectID: p.ProjectID}
+}
+
+// ToFilePath returns the path of name within the project. This is the path that is stored for
+// directories in the database, so it always starts with a slash. Neither Path nor name are decoded
+// again: a context from ToTransferPathContextDecoded already holds the decoded path, and decoding
+// twice would turn a name containing a literal %25 into a different name.
+func (p *TransferPathContext) ToFilePath(name string) string {
+	return "/" + p.Path.Join(name).String()
+}
+
+// ToFSPath returns the full destination path for name under this context. It is lenient about ids:
+// a UserID or ProjectID that isn't positive is treated as not set, and the path stops at the first
+// level that isn't set. So a hand built context never produces a path containing a negative id, or
+// a project id sitting where the user id belongs. Use ToProjectFSPath when the context is expected
+// to be at the project level.
+func (p *TransferPathContext) ToFSPath(name string) string {
+	parts := []string{"/", TransfersRoot, p.TransferType}
+	if p.IsUser() {
+		parts = append(parts, fmt.Sprintf("%d", p.UserID))
+		if p.IsProject() {
+			parts = append(parts, fmt.Sprintf("%d", p.ProjectID))
+			if p.hasCohort() && p.Cohort != "" {
+				parts = append(parts, p.Cohort)
+			}
+		}
+	}
+
+	parts = append(parts, p.Path.Join(name).String())
+	return filepath.Join(parts...)
+}
+
+// ToProjectFSPath is ToFSPath for contexts that must be at or below the project level. Rather than
+// leaving out ids that aren't positive it returns ErrInvalidID, and for transfer types that have
+// cohorts it returns ErrMissingCohort when the cohort isn't set. A context without a transfer type
+// returns ErrMissingTransferType, see Validate.
+func (p *TransferPathContext) ToProjectFSPath(name string) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	if !p.IsUser() || !p.IsProject() {
+		return "", fmt.Errorf("%w: user %d, project %d", ErrInvalidID, p.UserID, p.ProjectID)
+	}
+
+	if p.hasCohort() && p.Cohort == "" {
+		return "", fmt.Errorf("%w: transfer type %s", ErrMissingCohort, p.TransferType)
+	}
+
+	return p.ToFSPath(name), nil
+}
+
+// String returns the destination path for the context, which is ToFSPath for no name.
+func (p *TransferPathContext) String() string {
+	return p.ToFSPath("")
+}
+
+// hasCohort returns true if the layout for the context's transfer type has a cohort entry.
+func (p *TransferPathContext) hasCohort() bool {
+	for _, s := range layoutFor(p.TransferType) {
+		if s == cohortSegment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BuildTransferDestination returns the destination path a transfer of type transferType should write
+// path to for the given user and project. Transfer types that have cohorts can't be built this way,
+// use ToProjectFSPath on a context with the Cohort set instead.
+func BuildTransferDestination(transferType string, userID, projectID int, path string) (string, error) {
+	p := &TransferPathContext{TransferType: transferType, UserID: userID, ProjectID: projectID}
+	return p.ToProjectFSPath(path)
+}
+
+// SplitProjectPath splits a destination path into the directory of the project it is in,
+// /__transfers/<transfer type>/<user id>/<project id>, and the rest of the path below that. The rest
+// is cleaned like a RelPath, and is blank for the project directory itself. For transfer types with
+// cohorts the cohort is the first entry in the rest. It returns false when the path stops above the
+// project level. Like ToTransferPathContext the leading __transfers entry and the ids aren't checked.
+func SplitProjectPath(p string) (root string, rel string, ok bool) {
+	// Split will return ["", "__transfers", "<transfer type>", "<user id>", "<project id>", "...rest of path..."]
+	parts := strings.SplitN(p, "/", 6)
+	if len(parts) < 5 || parts[0] != "" {
+		return "", "", false
+	}
+
+	for _, part := range parts[1:5] {
+		if part == "" {
+			return "", "", false
+		}
+	}
+
+	if len(parts) == 6 {
+		rel = NewRelPath(parts[5]).String()
+	}
+
+	return strings.Join(parts[:5], "/"), rel, true
+}
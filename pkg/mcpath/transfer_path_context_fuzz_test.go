@@ -0,0 +1,68 @@
+//go:build go1.18
+// +build go1.18
+
+package mcpath
+
+import (
+	"testing"
+)
+
+// complete returns true if every level above the deepest one set in p is also set. The lenient
+// parser can skip a level, for example /__transfers/globus//2 has a project but no user, and a context
+// like that can't be written back out as a path.
+func complete(p *TransferPathContext) bool {
+	switch {
+	case p.ProjectID != 0 && p.UserID == 0:
+		return false
+	case (p.Cohort != "" || p.Path != "") && p.ProjectID == 0:
+		return false
+	case p.hasCohort() && p.Path != "" && p.Cohort == "":
+		return false
+	}
+
+	return true
+}
+
+func FuzzToTransferPathContext(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"/",
+		"/__transfers",
+		"/__transfers/",
+		"/__transfers/globus/1/2/d1/file.txt",
+		"/__transfers/globus/1/2/",
+		"/__transfers/globus//2/file.txt",
+		"/__transfers/globus/-1/2/file.txt",
+		"/__transfers/globus/+1/02/file.txt",
+		"/__transfers/globus/abc/2/file.txt",
+		"/__transfers/globus/99999999999999999999/2",
+		"/__transfers/globus/1/2/../../x",
+		"/__transfers/globus/1/2/d1//d2/",
+		"/__transfers/archive/1/2/c1/d1/file.txt",
+		"/__transfers/archive/1/2//file.txt",
+		"/__transfers/archive/1/2/../file.txt",
+		"/__transfers//1/2",
+		"///1/2",
+		"/__transfers/../1/2",
+		"/__transfers/./1/2",
+		"/__transfers/globus/1/2/run%201/a%2Fb.txt",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		p := ToTransferPathContext(path)
+		if p.UserID < 0 || p.ProjectID < 0 {
+			t.Fatalf("%q parsed to negative ids: %+v", path, *p)
+		}
+
+		if !p.IsValid() || !complete(p) {
+			return
+		}
+
+		again := ToTransferPathContext(p.String())
+		if *again != *p {
+			t.Fatalf("%q parsed to %+v, which round trips through %q to %+v", path, *p, p.String(), *again)
+		}
+	})
+}
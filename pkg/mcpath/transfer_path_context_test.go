@@ -0,0 +1,363 @@
+package mcpath
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToTransferPathContext(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected TransferPathContext
+	}{
+		{path: "/__transfers", expected: TransferPathContext{}},
+		{path: "/__transfers/globus", expected: TransferPathContext{TransferType: "globus"}},
+		{path: "/__transfers/globus/1", expected: TransferPathContext{TransferType: "globus", UserID: 1}},
+		{path: "/__transfers/globus/1/2", expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}},
+		{path: "/__transfers/globus/1/2/", expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}},
+		{path: "/__transfers/globus/1/2/file.txt", expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "file.txt"}},
+		{path: "/__transfers/globus/1/2/d1/d2/file.txt", expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d1/d2/file.txt"}},
+		{path: "/__transfers/globus/abc/2/file.txt", expected: TransferPathContext{TransferType: "globus", ProjectID: 2, Path: "file.txt"}},
+		{path: "/__transfers/other/1/2/file.txt", expected: TransferPathContext{TransferType: "other", UserID: 1, ProjectID: 2, Path: "file.txt"}},
+		{path: "/__transfers/archive/1/2", expected: TransferPathContext{TransferType: "archive", UserID: 1, ProjectID: 2}},
+		{path: "/__transfers/archive/1/2/c1", expected: TransferPathContext{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1"}},
+		{path: "/__transfers/archive/1/2/c1/d1/file.txt", expected: TransferPathContext{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", Path: "d1/file.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			require.Equal(t, test.expected, *ToTransferPathContext(test.path))
+		})
+	}
+}
+
+func TestTransferTypes(t *testing.T) {
+	require.Equal(t, []string{ArchiveTransferType, GlobusTransferType}, TransferTypes())
+
+	// A new transfer type is listed once it has a layout.
+	layouts := map[string][]segment{"s3": {userSegment, projectSegment}}
+	for transferType, layout := range transferLayouts {
+		layouts[transferType] = layout
+	}
+	require.Equal(t, []string{ArchiveTransferType, GlobusTransferType, "s3"}, transferTypes(layouts))
+}
+
+func TestToTransferPathContextDecoded(t *testing.T) {
+	p := ToTransferPathContextDecoded("/__transfers/globus/1/2/run%201/a%2Fb%20c.txt")
+	require.Equal(t, TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "run 1/a%2Fb c.txt"}, *p)
+	require.Equal(t, "/run 1/a%2Fb c.txt", p.ToFilePath(""))
+
+	p = ToTransferPathContextDecoded("/__transfers/globus/1/2/r%C3%A9sum%C3%A9.txt")
+	require.Equal(t, RelPath("r\u00e9sum\u00e9.txt"), p.Path)
+
+	// Without decoding the escapes are part of the name.
+	p = ToTransferPathContext("/__transfers/globus/1/2/run%201/file.txt")
+	require.Equal(t, "/run%201/file.txt", p.ToFilePath(""))
+}
+
+func TestToTransferPathContextFromSource(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected TransferPathContext
+	}{
+		{path: "/__downloads", expected: TransferPathContext{TransferType: "globus"}},
+		{path: "/__downloads/1", expected: TransferPathContext{TransferType: "globus", UserID: 1}},
+		{path: "/__downloads/1/2", expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}},
+		{path: "/__downloads/1/2/results.csv", expected: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "results.csv"}},
+		{path: "/__downloads/10/22/run 1/images/img001.tif", expected: TransferPathContext{TransferType: "globus", UserID: 10, ProjectID: 22, Path: "run 1/images/img001.tif"}},
+		{path: "/__downloads/10/22/d1//d2/", expected: TransferPathContext{TransferType: "globus", UserID: 10, ProjectID: 22, Path: "d1/d2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			require.Equal(t, test.expected, *ToTransferPathContextFromSource(test.path))
+		})
+	}
+}
+
+func TestToFSPath(t *testing.T) {
+	p := ToTransferPathContext("/__transfers/globus/1/2/d1")
+	require.Equal(t, "/__transfers/globus/1/2/d1/file.txt", p.ToFSPath("file.txt"))
+	require.Equal(t, "/d1/file.txt", p.ToFilePath("file.txt"))
+
+	p = ToTransferPathContextFromSource("/__downloads/1/2/d1")
+	require.Equal(t, "/__transfers/globus/1/2/d1/file.txt", p.ToFSPath("file.txt"))
+}
+
+func TestNonPositiveIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    int
+		projectID int
+		lenient   string
+	}{
+		{name: "zero user", userID: 0, projectID: 2, lenient: "/__transfers/globus/file.txt"},
+		{name: "zero project", userID: 1, projectID: 0, lenient: "/__transfers/globus/1/file.txt"},
+		{name: "negative user", userID: -1, projectID: 2, lenient: "/__transfers/globus/file.txt"},
+		{name: "negative project", userID: 1, projectID: -2, lenient: "/__transfers/globus/1/file.txt"},
+		{name: "both negative", userID: -1, projectID: -2, lenient: "/__transfers/globus/file.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &TransferPathContext{TransferType: "globus", UserID: test.userID, ProjectID: test.projectID}
+			require.Equal(t, test.lenient, p.ToFSPath("file.txt"))
+
+			_, err := p.ToProjectFSPath("file.txt")
+			require.True(t, errors.Is(err, ErrInvalidID))
+
+			_, err = BuildTransferDestination("globus", test.userID, test.projectID, "file.txt")
+			require.True(t, errors.Is(err, ErrInvalidID))
+		})
+	}
+}
+
+func TestBuildTransferDestination(t *testing.T) {
+	dest, err := BuildTransferDestination("globus", 1, 2, "d1/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "/__transfers/globus/1/2/d1/file.txt", dest)
+	require.Equal(t, TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d1/file.txt"}, *ToTransferPathContext(dest))
+}
+
+func TestProjectPathContext(t *testing.T) {
+	p := ToTransferPathContext("/__transfers/globus/1/2/d1/file.txt")
+	require.Equal(t, TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}, *p.ProjectPathContext())
+	require.Equal(t, RelPath("d1/file.txt"), p.Path)
+}
+
+func TestArchiveFSPath(t *testing.T) {
+	p := ToTransferPathContext("/__transfers/archive/1/2/c1/d1")
+	require.Equal(t, "/__transfers/archive/1/2/c1/d1/file.txt", p.ToFSPath("file.txt"))
+	require.Equal(t, "/d1/file.txt", p.ToFilePath("file.txt"))
+
+	fsPath, err := p.ToProjectFSPath("file.txt")
+	require.NoError(t, err)
+	require.Equal(t, "/__transfers/archive/1/2/c1/d1/file.txt", fsPath)
+
+	_, err = BuildTransferDestination("archive", 1, 2, "file.txt")
+	require.True(t, errors.Is(err, ErrMissingCohort))
+}
+
+func TestToFSPathProjectRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		context  TransferPathContext
+		file     string
+		expected string
+	}{
+		{name: "project root", context: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}, file: "file.txt", expected: "/__transfers/globus/1/2/file.txt"},
+		{name: "project root no name", context: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}, file: "", expected: "/__transfers/globus/1/2"},
+		{name: "project root slashed name", context: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2}, file: "/file.txt", expected: "/__transfers/globus/1/2/file.txt"},
+		{name: "with path", context: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d1"}, file: "file.txt", expected: "/__transfers/globus/1/2/d1/file.txt"},
+		{name: "with path no name", context: TransferPathContext{TransferType: "globus", UserID: 1, ProjectID: 2, Path: "d1"}, file: "", expected: "/__transfers/globus/1/2/d1"},
+		{name: "cohort root", context: TransferPathContext{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1"}, file: "file.txt", expected: "/__transfers/archive/1/2/c1/file.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, test.context.ToFSPath(test.file))
+		})
+	}
+
+	// A project root context parsed from a path with a trailing slash gives the same result as one
+	// built by hand.
+	parsed := ToTransferPathContext("/__transfers/globus/1/2/")
+	require.Equal(t, "/__transfers/globus/1/2/file.txt", parsed.ToFSPath("file.txt"))
+}
+
+func TestOwnedBy(t *testing.T) {
+	tests := []struct {
+		path     string
+		userID   int
+		expected bool
+	}{
+		{path: "/__transfers/globus/1/2/file.txt", userID: 1, expected: true},
+		{path: "/__transfers/globus/1", userID: 1, expected: true},
+		{path: "/__transfers/globus/1/2/file.txt", userID: 2, expected: false},
+		{path: "/__transfers/archive/3/2/c1/file.txt", userID: 3, expected: true},
+		{path: "/__transfers/globus", userID: 1, expected: false},
+		{path: "/__transfers/globus/abc/2/file.txt", userID: 0, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-%d", test.path, test.userID), func(t *testing.T) {
+			require.Equal(t, test.expected, ToTransferPathContext(test.path).OwnedBy(test.userID))
+		})
+	}
+}
+
+func TestInUserScope(t *testing.T) {
+	tests := []struct {
+		path     string
+		userID   int
+		expected bool
+	}{
+		{path: "/__transfers/globus/1/2/file.txt", userID: 1, expected: true},
+		{path: "/__transfers/globus/1/2", userID: 1, expected: true},
+		{path: "/__transfers/globus/1", userID: 1, expected: true},
+		{path: "/__transfers/archive/3/2/c1/file.txt", userID: 3, expected: true},
+		{path: "/__transfers/globus/1/2/file.txt", userID: 2, expected: false},
+		{path: "/__transfers/globus", userID: 1, expected: false},
+		{path: "/__transfers", userID: 1, expected: false},
+		{path: "/__transfers/globus/abc/2/file.txt", userID: 0, expected: false},
+		{path: "/__transfers/../1/2/file.txt", userID: 1, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-%d", test.path, test.userID), func(t *testing.T) {
+			require.Equal(t, test.expected, ToTransferPathContext(test.path).InUserScope(test.userID))
+		})
+	}
+}
+
+func TestInProject(t *testing.T) {
+	tests := []struct {
+		path      string
+		projectID int
+		expected  bool
+	}{
+		{path: "/__transfers/globus/1/2/d1/file.txt", projectID: 2, expected: true},
+		{path: "/__transfers/globus/1/2", projectID: 2, expected: true},
+		{path: "/__transfers/archive/1/2/c1/file.txt", projectID: 2, expected: true},
+		{path: "/__transfers/archive/1/2", projectID: 2, expected: true},
+		{path: "/__transfers/globus/1/2/d1/file.txt", projectID: 3, expected: false},
+		{path: "/__transfers/globus/1/20", projectID: 2, expected: false},
+		{path: "/__transfers/globus/1", projectID: 1, expected: false},
+		{path: "/__transfers/globus", projectID: 2, expected: false},
+		{path: "/__transfers", projectID: 0, expected: false},
+		{path: "/__transfers/globus/abc/2/file.txt", projectID: 2, expected: false},
+		{path: "/__transfers/../1/2/file.txt", projectID: 2, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-%d", test.path, test.projectID), func(t *testing.T) {
+			require.Equal(t, test.expected, ToTransferPathContext(test.path).InProject(test.projectID))
+		})
+	}
+}
+
+func TestIsDescendantOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		other    string
+		expected bool
+	}{
+		{name: "file below project", path: "/__transfers/globus/1/2/d1/file.txt", other: "/__transfers/globus/1/2", expected: true},
+		{name: "file below directory", path: "/__transfers/globus/1/2/d1/d2/file.txt", other: "/__transfers/globus/1/2/d1", expected: true},
+		{name: "project below transfer type", path: "/__transfers/globus/1/2", other: "/__transfers/globus", expected: true},
+		{name: "everything below root", path: "/__transfers/globus/1", other: "/__transfers", expected: true},
+		{name: "archive below cohort", path: "/__transfers/archive/1/2/c1/file.txt", other: "/__transfers/archive/1/2/c1", expected: true},
+		{name: "ancestor", path: "/__transfers/globus/1/2", other: "/__transfers/globus/1/2/d1/file.txt", expected: false},
+		{name: "same", path: "/__transfers/globus/1/2/d1", other: "/__transfers/globus/1/2/d1", expected: false},
+		{name: "sibling file", path: "/__transfers/globus/1/2/d1/a.txt", other: "/__transfers/globus/1/2/d1/b.txt", expected: false},
+		{name: "sibling with shared prefix", path: "/__transfers/globus/1/2/d10/file.txt", other: "/__transfers/globus/1/2/d1", expected: false},
+		{name: "other project", path: "/__transfers/globus/1/20/d1", other: "/__transfers/globus/1/2", expected: false},
+		{name: "other user", path: "/__transfers/globus/2/2/d1", other: "/__transfers/globus/1/2", expected: false},
+		{name: "other transfer type", path: "/__transfers/archive/1/2/c1/d1", other: "/__transfers/globus/1/2", expected: false},
+		{name: "other cohort", path: "/__transfers/archive/1/2/c2/d1", other: "/__transfers/archive/1/2/c1", expected: false},
+		{name: "invalid", path: "///1/2/d1", other: "///1/2", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, ToTransferPathContext(test.path).IsDescendantOf(ToTransferPathContext(test.other)))
+		})
+	}
+}
+
+func TestIDsRequireTransferType(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected TransferPathContext
+	}{
+		{path: "///1/2", expected: TransferPathContext{UserID: 1, ProjectID: 2}},
+		{path: "/__transfers//1", expected: TransferPathContext{UserID: 1}},
+		{path: "/__transfers//1/2/d1/file.txt", expected: TransferPathContext{UserID: 1, ProjectID: 2, Path: "d1/file.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			// The lenient parser fills out the ids, but the result isn't valid.
+			p := ToTransferPathContext(test.path)
+			require.Equal(t, test.expected, *p)
+			require.False(t, p.IsValid())
+			require.True(t, errors.Is(p.Validate(), ErrMissingTransferType))
+
+			_, err := p.ToProjectFSPath("file.txt")
+			require.True(t, errors.Is(err, ErrMissingTransferType))
+
+			_, err = ParseTransferPath(test.path)
+			require.True(t, errors.Is(err, ErrMissingTransferType))
+		})
+	}
+
+	// Contexts without a transfer type are fine as long as nothing below it is set.
+	require.True(t, (&TransferPathContext{}).IsValid())
+	require.True(t, ToTransferPathContext("/__transfers").IsValid())
+}
+
+func TestParseTransferPath(t *testing.T) {
+	p, err := ParseTransferPath("/__transfers/archive/1/2/c1/d1/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, TransferPathContext{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", Path: "d1/file.txt"}, *p)
+
+	p, err = ParseTransferPath("/__transfers/globus/1/")
+	require.NoError(t, err)
+	require.Equal(t, TransferPathContext{TransferType: "globus", UserID: 1}, *p)
+
+	for _, path := range []string{"/__transfers/globus/abc/2/file.txt", "/__transfers/globus/1/-2", "/__transfers/globus/0/2"} {
+		_, err = ParseTransferPath(path)
+		require.True(t, errors.Is(err, ErrInvalidID), path)
+	}
+
+	for _, path := range []string{"/__transfers/./1/2", "/__transfers/../1/2/file.txt", "/__transfers/archive/1/2/../file.txt"} {
+		_, err = ParseTransferPath(path)
+		require.True(t, errors.Is(err, ErrDotEntry), path)
+	}
+}
+
+func TestSplitProjectPath(t *testing.T) {
+	tests := []struct {
+		path string
+		root string
+		rel  string
+		ok   bool
+	}{
+		{path: "/__transfers/globus/1/2", root: "/__transfers/globus/1/2", rel: "", ok: true},
+		{path: "/__transfers/globus/1/2/", root: "/__transfers/globus/1/2", rel: "", ok: true},
+		{path: "/__transfers/globus/1/2/file.txt", root: "/__transfers/globus/1/2", rel: "file.txt", ok: true},
+		{path: "/__transfers/globus/1/2/d1//d2/file.txt", root: "/__transfers/globus/1/2", rel: "d1/d2/file.txt", ok: true},
+		{path: "/__transfers/archive/1/2/c1/d1/file.txt", root: "/__transfers/archive/1/2", rel: "c1/d1/file.txt", ok: true},
+		{path: "/__transfers/globus/1", ok: false},
+		{path: "/__transfers/globus/1/", ok: false},
+		{path: "/__transfers/globus", ok: false},
+		{path: "/__transfers", ok: false},
+		{path: "", ok: false},
+		{path: "/__transfers//1/2/file.txt", ok: false},
+		{path: "__transfers/globus/1/2/file.txt", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			root, rel, ok := SplitProjectPath(test.path)
+			require.Equal(t, test.ok, ok)
+			require.Equal(t, test.root, root)
+			require.Equal(t, test.rel, rel)
+		})
+	}
+}
+
+func TestSplitProjectPathMatchesContext(t *testing.T) {
+	dest, err := BuildTransferDestination("globus", 1, 2, "d1/file.txt")
+	require.NoError(t, err)
+
+	root, rel, ok := SplitProjectPath(dest)
+	require.True(t, ok)
+	p := ToTransferPathContext(dest)
+	require.Equal(t, p.ProjectPathContext().ToFSPath(""), root)
+	require.Equal(t, p.Path.String(), rel)
+}
@@ -0,0 +1,19 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// ActiveUsers returns the ids, in ascending order, of the users that have had an upload processed
+// within window of now. It is answered from the processed upload audit table, so it only looks as
+// far back as Config.AuditRetention keeps entries, and uploads whose owner wasn't known aren't
+// counted.
+func (m *GlobusTaskMonitor) ActiveUsers(window time.Duration) ([]int, error) {
+	ownerIDs, err := m.processedUploads.OwnersProcessedSince(m.now().Add(-window))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list active users: %w", err)
+	}
+
+	return ownerIDs, nil
+}
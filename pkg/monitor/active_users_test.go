@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveUsers(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	store := newFakeProcessedUploadStore()
+	add := func(id string, ownerID int, age time.Duration) {
+		upload := &ProcessedGlobusUpload{GlobusUploadID: id, OwnerID: ownerID, ProcessedAt: now.Add(-age)}
+		require.NoError(t, store.AddProcessedUpload(upload))
+	}
+
+	add("1", 3, time.Minute)
+	add("2", 1, 30*time.Minute)
+	add("3", 3, 2*time.Hour)
+	add("4", 2, 3*time.Hour)
+	add("5", 0, time.Minute)
+	add("6", 4, 48*time.Hour)
+
+	m := newTestMonitor(NewFakeGlobusClient())
+	m.processedUploads = store
+	m.now = func() time.Time { return now }
+
+	tests := []struct {
+		window   time.Duration
+		expected []int
+	}{
+		{window: 10 * time.Minute, expected: []int{3}},
+		{window: time.Hour, expected: []int{1, 3}},
+		{window: 24 * time.Hour, expected: []int{1, 2, 3}},
+		{window: time.Second, expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.window.String(), func(t *testing.T) {
+			userIDs, err := m.ActiveUsers(test.window)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, userIDs)
+		})
+	}
+}
+
+func TestProcessedUploadsRecordOwner(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+
+	m := newTestMonitor(client)
+	store := newFakeProcessedUploadStore()
+	m.processedUploads = store
+	seedGlobusUploads(m, 1)
+
+	m.retrieveAndProcessUploads(context.Background())
+
+	// Upload 2 has no globus_uploads entry, so its owner isn't known.
+	require.Len(t, store.uploads, 2)
+	owners := map[string]int{}
+	for _, upload := range store.uploads {
+		owners[upload.GlobusUploadID] = upload.OwnerID
+	}
+	require.Equal(t, map[string]int{"1": 1, "2": 0}, owners)
+
+	userIDs, err := m.ActiveUsers(time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, userIDs)
+}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"errors"
+
+	"github.com/apex/log"
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"gorm.io/gorm"
+)
+
+// archiveTaskPrefix is put in front of the task id of an archive task to give the id it is recorded
+// as processed under.
+const archiveTaskPrefix = "archive:"
+
+// ArchiveProcessor handles tasks that transferred files into the archive layout (see
+// mcpath.ArchiveTransferType). Files holds a context for each file the task transferred. A task
+// that returns an error is retried on the next pass.
+type ArchiveProcessor interface {
+	ProcessArchiveTask(task globus.Task, files []*mcpath.TransferPathContext) error
+}
+
+type logArchiveProcessor struct{}
+
+func (logArchiveProcessor) ProcessArchiveTask(task globus.Task, files []*mcpath.TransferPathContext) error {
+	log.Infof("Globus task %s archived %d files", task.TaskID, len(files))
+	return nil
+}
+
+// processArchiveTransfers hands the transfers for an archive task to the ArchiveProcessor. Archive
+// tasks have no globus_uploads entry, so they are recorded as processed under their task id. Only the
+// files in the archive tree of the user the task acts for, see archiveOwner, are handed on. The
+// projects the files were archived into are added to touched. Tasks that have already been processed
+// are skipped unless reprocess is true.
+func (m *GlobusTaskMonitor) processArchiveTransfers(task globus.Task, transfers *globus.TransferItems, touched projectRefs, skipped *skippedFiles, reprocess bool) taskOutcome {
+	id := archiveTaskPrefix + task.TaskID
+	if !reprocess && m.isFinished(id) {
+		return taskSkipped
+	}
+
+	var files []*mcpath.TransferPathContext
+	for _, transfer := range transfers.Transfers {
+		pathContext := mcpath.ToTransferPathContext(transfer.DestinationPath)
+		if pathContext.TransferType != mcpath.ArchiveTransferType || pathContext.Path.IsRoot() {
+			continue
+		}
+
+		files = append(files, pathContext)
+	}
+
+	ownerID, ok := m.archiveOwner(task, files)
+	if !ok {
+		return taskRetry
+	}
+	files = m.dropOtherOwners(task, ownerID, files, skipped)
+
+	files, ok = m.dropDeletedProjects(task, files, skipped)
+	if !ok {
+		return taskRetry
+	}
+
+	if err := m.archiveProcessor.ProcessArchiveTask(task, files); err != nil {
+		m.logger.Errorf("Unable to process archive task %s: %s", task.TaskID, err)
+		return taskRetry
+	}
+
+	m.markFinished(id, task, ownerID)
+	for _, file := range files {
+		touched.add(file.UserID, file.ProjectID)
+	}
+
+	return taskProcessed
+}
+
+// archiveOwner returns the user an archive task acts for. That is the user the globus uploads of the
+// task's submitter were processed for, when the ProcessedUploadStore is a SubmitterOwnerFinder and
+// there are any. Otherwise it is the user of the first file, so a task can still only change a single
+// user's archive tree. It returns false if the submitter's uploads couldn't be looked up.
+func (m *GlobusTaskMonitor) archiveOwner(task globus.Task, files []*mcpath.TransferPathContext) (int, bool) {
+	if finder, ok := m.processedUploads.(SubmitterOwnerFinder); ok && task.OwnerID != "" {
+		ownerID, err := finder.OwnerForSubmitter(task.OwnerID)
+		switch {
+		case err == nil:
+			return ownerID, true
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			m.logger.Errorf("Unable to look up the owner for the submitter of archive task %s: %s", task.TaskID, err)
+			return 0, false
+		}
+	}
+
+	if len(files) == 0 {
+		return 0, true
+	}
+
+	return files[0].UserID, true
+}
+
+// dropOtherOwners removes the files that aren't in ownerID's archive tree, skipping them as
+// not_owner, so an archive task never hands on files in another user's tree.
+func (m *GlobusTaskMonitor) dropOtherOwners(task globus.Task, ownerID int, files []*mcpath.TransferPathContext, skipped *skippedFiles) []*mcpath.TransferPathContext {
+	var kept []*mcpath.TransferPathContext
+	for _, file := range files {
+		if !file.OwnedBy(ownerID) {
+			m.logger.Warnf("Archive task %s wrote %s, which user %d doesn't own", task.TaskID, file.ToFSPath(""), ownerID)
+			m.skipFile(skipped, SkippedFile{TaskID: task.TaskID, ProjectID: file.ProjectID, Path: file.Path.String(), Reason: "not_owner"})
+			continue
+		}
+
+		kept = append(kept, file)
+	}
+
+	return kept
+}
+
+// dropDeletedProjects removes the files that were archived into projects that have since been deleted.
+// It returns false if the projects couldn't be checked.
+func (m *GlobusTaskMonitor) dropDeletedProjects(task globus.Task, files []*mcpath.TransferPathContext, skipped *skippedFiles) ([]*mcpath.TransferPathContext, bool) {
+	exists := make(map[int]bool)
+	var kept []*mcpath.TransferPathContext
+	for _, file := range files {
+		projectExists, seen := exists[file.ProjectID]
+		if !seen {
+			var checked bool
+			if projectExists, checked = m.checkProjectExists(task, file.ProjectID); !checked {
+				return nil, false
+			}
+			exists[file.ProjectID] = projectExists
+		}
+
+		if !projectExists {
+			m.skipFile(skipped, SkippedFile{TaskID: task.TaskID, ProjectID: file.ProjectID, Path: file.Path.String(), Reason: "project_deleted"})
+			continue
+		}
+
+		kept = append(kept, file)
+	}
+
+	return kept, true
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeArchiveProcessor records the archive tasks it is given.
+type fakeArchiveProcessor struct {
+	files map[string][]*mcpath.TransferPathContext
+}
+
+func (p *fakeArchiveProcessor) ProcessArchiveTask(task globus.Task, files []*mcpath.TransferPathContext) error {
+	p.files[task.TaskID] = files
+	return nil
+}
+
+func TestArchiveTasksAreRouted(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__transfers/archive/1/2/c1/d1/file.txt", "/__transfers/archive/1/2/c1/other.txt")
+	processor := &fakeArchiveProcessor{files: make(map[string][]*mcpath.TransferPathContext)}
+	m := newTestMonitor(client, WithArchiveProcessor(processor))
+	seedGlobusUploads(m, 1)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 2, result.TasksProcessed)
+	require.True(t, m.finishedGlobusTasks["1"])
+
+	expected := []*mcpath.TransferPathContext{
+		{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", Path: "d1/file.txt"},
+		{TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", Path: "other.txt"},
+	}
+	require.Equal(t, map[string][]*mcpath.TransferPathContext{"task-2": expected}, processor.files)
+
+	// The archive task is only processed once.
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 0, result.TasksProcessed)
+}
+
+func TestArchiveFilesOfAnotherUserAreSkipped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/archive/1/2/c1/mine.txt", "/__transfers/archive/3/4/c1/theirs.txt")
+	client.Tasks[0].OwnerID = "identity-1"
+	processor := &fakeArchiveProcessor{files: make(map[string][]*mcpath.TransferPathContext)}
+	m := newTestMonitor(client, WithArchiveProcessor(processor))
+
+	// The submitter has had an upload processed for user 3, so the task acts for user 3 whatever the
+	// path of its first file says.
+	processedUploads := m.processedUploads.(*fakeProcessedUploadStore)
+	require.NoError(t, processedUploads.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "9", OwnerID: 3, SubmitterIdentity: "identity-1"}))
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Equal(t, []*mcpath.TransferPathContext{{TransferType: "archive", UserID: 3, ProjectID: 4, Cohort: "c1", Path: "theirs.txt"}}, processor.files["task-1"])
+	require.Len(t, result.SkippedFiles, 1)
+	require.Equal(t, "not_owner", result.SkippedFiles[0].Reason)
+	require.Equal(t, 2, result.SkippedFiles[0].ProjectID)
+}
+
+func TestArchiveTaskWithoutKnownSubmitterActsForOneUser(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/archive/1/2/c1/mine.txt", "/__transfers/archive/3/4/c1/theirs.txt")
+	processor := &fakeArchiveProcessor{files: make(map[string][]*mcpath.TransferPathContext)}
+	m := newTestMonitor(client, WithArchiveProcessor(processor))
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Len(t, processor.files["task-1"], 1)
+	require.Equal(t, 1, processor.files["task-1"][0].UserID)
+	require.Equal(t, "not_owner", result.SkippedFiles[0].Reason)
+}
@@ -0,0 +1,306 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"gorm.io/gorm"
+)
+
+// AuditReport describes what the monitor would process for the tasks that completed in a window
+// of time. See AuditRange.
+type AuditReport struct {
+	From time.Time
+	To   time.Time
+
+	// TasksSeen is the number of tasks Globus returned for the window.
+	TasksSeen int
+
+	// Items are the tasks that would be processed.
+	Items []AuditItem
+
+	// TotalBytes is the sum of the bytes transferred by the tasks in Items.
+	TotalBytes int64
+
+	// Skipped counts the tasks that wouldn't be processed, by the reason they would be skipped.
+	Skipped map[string]int
+}
+
+// AuditItem is a task that would be processed. GlobusUploadID is blank for archive tasks.
+type AuditItem struct {
+	TaskID         string
+	TransferType   string
+	GlobusUploadID string
+	UserID         int
+	ProjectID      int
+	Paths          []mcpath.RelPath
+	Bytes          int64
+}
+
+// AuditRange reports what the monitor would process for the tasks that completed between from and
+// to. It makes the same decisions as a pass, but without any side effects: nothing is processed or
+// recorded, and uploads that appear in more than one task are only reported once by keeping track
+// of them in the report rather than in the monitor. Uploads that have already been processed are
+// looked up, but not changed, and all of a task's transfers are fetched whatever MaxTransferPages
+// is. It returns an error if Globus can't be queried, since a report with tasks missing from it
+// would be misleading.
+func (m *GlobusTaskMonitor) AuditRange(ctx context.Context, from, to time.Time) (AuditReport, error) {
+	report := AuditReport{From: from, To: to, Skipped: make(map[string]int)}
+
+	tasks, err := m.listTasks(m.completionRangeFilter(from, to))
+	if err != nil {
+		return report, fmt.Errorf("unable to get task list: %w", m.client.ExtractError(err))
+	}
+
+	report.TasksSeen = len(tasks)
+	seen := m.finishedIDs()
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		task, ok := m.checkCompletionTime(task)
+		if !ok {
+			report.Skipped["missing_completion_time"]++
+			continue
+		}
+
+		if !m.passesTaskFilters(task) {
+			report.Skipped["task_filter"]++
+			continue
+		}
+
+		transfers, _, err := m.fetchTransferPages(task.TaskID, 0, 0)
+		if err != nil {
+			return report, fmt.Errorf("unable to get transfers for task %s: %w", task.TaskID, m.client.ExtractError(err))
+		}
+
+		if err := m.auditTask(task, transfers, seen, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// add adds item to the report, or counts it as skipped when reason isn't blank.
+func (r *AuditReport) add(item AuditItem, reason string) {
+	if reason != "" {
+		r.Skipped[reason]++
+		return
+	}
+
+	r.Items = append(r.Items, item)
+	r.TotalBytes += item.Bytes
+}
+
+// finishedIDs returns a copy of the ids of the uploads, and archive tasks, the monitor knows to have
+// been processed. It waits for a running pass to finish.
+func (m *GlobusTaskMonitor) finishedIDs() map[string]bool {
+	m.passMu.Lock()
+	defer m.passMu.Unlock()
+
+	finished := make(map[string]bool, len(m.finishedGlobusTasks))
+	for id := range m.finishedGlobusTasks {
+		finished[id] = true
+	}
+
+	return finished
+}
+
+// auditTask works out what a pass would do with a task, without doing it, and adds that to report.
+// A task split across uploads under MixedUploadsSplit has an item for each upload, with the task's
+// bytes counted against the first of them.
+func (m *GlobusTaskMonitor) auditTask(task globus.Task, transfers []globus.Transfer, seen map[string]bool, report *AuditReport) error {
+	item := AuditItem{TaskID: task.TaskID, Bytes: int64(task.BytesTransferred)}
+
+	switch {
+	case len(transfers) == 0:
+		report.add(item, "empty")
+		return nil
+	case transfers[0].DestinationPath == "":
+		report.add(item, "download")
+		return nil
+	case task.DestinationEndpointID != m.endpointID:
+		report.add(item, "destination_endpoint_mismatch")
+		return nil
+	case isLoopbackTransfer(task, transfers):
+		report.add(item, "loopback")
+		return nil
+	}
+
+	if isArchiveDestination(transfers[0].DestinationPath) {
+		item, reason, err := m.auditArchive(item, task, transfers, seen)
+		if err != nil {
+			return err
+		}
+		report.add(item, reason)
+		return nil
+	}
+
+	files, _ := splitDirectoryOnlyTransfers(transfers)
+	if len(files) == 0 {
+		report.add(item, "directory_only")
+		return nil
+	}
+
+	groups, err := groupTransfersByUpload(files)
+	if err != nil {
+		report.add(item, "invalid_path")
+		return nil
+	}
+
+	if len(groups) > 1 && m.config.MixedUploadPolicy == MixedUploadsReject {
+		reason, err := m.auditRejectedUploads(groups, seen)
+		if err != nil {
+			return err
+		}
+		report.add(item, reason)
+		return nil
+	}
+
+	for _, group := range groups {
+		uploadItem, reason, err := m.auditUpload(item, group.id, group.transfers, seen)
+		if err != nil {
+			return err
+		}
+		report.add(uploadItem, reason)
+		if reason == "" {
+			item.Bytes = 0
+		}
+	}
+
+	return nil
+}
+
+// auditArchive works out whether an archive task would be processed. It returns the AuditItem for
+// the task, or the reason it would be skipped. Like the pass, only the files in the archive tree of
+// the user the task acts for are listed.
+func (m *GlobusTaskMonitor) auditArchive(item AuditItem, task globus.Task, transfers []globus.Transfer, seen map[string]bool) (AuditItem, string, error) {
+	processed, err := m.auditSeen(archiveTaskPrefix+item.TaskID, seen)
+	if err != nil || processed {
+		return item, "already_processed", err
+	}
+
+	var files []*mcpath.TransferPathContext
+	for _, transfer := range transfers {
+		pathContext := mcpath.ToTransferPathContext(transfer.DestinationPath)
+		if pathContext.TransferType != mcpath.ArchiveTransferType || pathContext.Path.IsRoot() {
+			continue
+		}
+
+		files = append(files, pathContext)
+	}
+
+	ownerID, ok := m.archiveOwner(task, files)
+	if !ok {
+		return item, "", fmt.Errorf("unable to look up the owner of archive task %s", task.TaskID)
+	}
+
+	item.TransferType = mcpath.ArchiveTransferType
+	item.UserID = ownerID
+	for _, file := range files {
+		if file.OwnedBy(ownerID) {
+			item.ProjectID = file.ProjectID
+			item.Paths = append(item.Paths, file.Path)
+		}
+	}
+
+	return item, "", nil
+}
+
+// auditRejectedUploads returns the reason a task that wrote into more than one upload would be
+// skipped under MixedUploadsReject. Like the pass it is only rejected when one of the uploads hasn't
+// been dealt with yet.
+func (m *GlobusTaskMonitor) auditRejectedUploads(groups []uploadTransfers, seen map[string]bool) (string, error) {
+	finished := 0
+	for _, group := range groups {
+		processed, err := m.auditSeen(group.id, seen)
+		if err != nil {
+			return "", err
+		}
+		if processed {
+			finished++
+		}
+	}
+
+	if finished == len(groups) {
+		return "already_processed", nil
+	}
+
+	return "mixed_upload_ids", nil
+}
+
+// auditUpload works out whether the transfers a task made into the globus upload with id would be
+// processed. It returns the AuditItem for the upload, or the reason it would be skipped.
+func (m *GlobusTaskMonitor) auditUpload(item AuditItem, id string, transfers []globus.Transfer, seen map[string]bool) (AuditItem, string, error) {
+	processed, err := m.auditSeen(id, seen)
+	if err != nil || processed {
+		return item, "already_processed", err
+	}
+
+	globusUpload, err := m.globusUploads.GetGlobusUpload(id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return item, "missing_upload", nil
+	case err != nil:
+		return item, "", fmt.Errorf("unable to look up globus upload %s: %w", id, err)
+	}
+
+	exists, err := m.projects.ProjectExists(globusUpload.ProjectID)
+	switch {
+	case err != nil:
+		return item, "", fmt.Errorf("unable to check if project %d exists: %w", globusUpload.ProjectID, err)
+	case !exists:
+		return item, "project_deleted", nil
+	}
+
+	files, err := resolveUploadFiles(transfers, m.config.DuplicateNamePolicy, m.config.PathNormalizer, m.config.CaseInsensitivePaths)
+	if err != nil {
+		return item, "duplicate_file_name", nil
+	}
+
+	var kept []UploadFile
+	for _, file := range files {
+		if !isExcludedFile(m.config.ExcludeGlobs, file.Path) {
+			kept = append(kept, file)
+		}
+	}
+	if len(files) != 0 && len(kept) == 0 {
+		return item, "excluded", nil
+	}
+	files = kept
+
+	if m.config.ZeroByteFilePolicy == ZeroByteFileSkip {
+		_, files = zeroByteFiles(m.objectStore, globusUpload.Path, files)
+	}
+
+	item.TransferType = mcpath.GlobusTransferType
+	item.GlobusUploadID = id
+	item.UserID, item.ProjectID = globusUpload.OwnerID, globusUpload.ProjectID
+	for _, file := range files {
+		item.Paths = append(item.Paths, file.Path)
+	}
+
+	return item, "", nil
+}
+
+// auditSeen returns true if the upload has already been processed, or was already reported by this
+// audit. seen starts out as a copy of the monitor's finished uploads, so the audit doesn't touch them.
+func (m *GlobusTaskMonitor) auditSeen(id string, seen map[string]bool) (bool, error) {
+	if seen[id] {
+		return true, nil
+	}
+
+	seen[id] = true
+	processed, err := m.processedUploads.IsProcessed(id)
+	if err != nil {
+		return false, fmt.Errorf("unable to check if %s was processed: %w", id, err)
+	}
+
+	return processed, nil
+}
@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// defaultTaskLookback is how far back the monitor asks Globus for completed tasks when
+	// Config.TaskLookback isn't set.
+	defaultTaskLookback = 7 * 24 * time.Hour
+
+	// auditCompactionInterval is how often old processed upload entries are purged. It doesn't
+	// need to run anywhere near as often as the poll for new tasks.
+	auditCompactionInterval = time.Hour
+)
+
+// compactProcessedUploads periodically purges processed upload entries older than the configured
+// retention until ctx is cancelled.
+func (m *GlobusTaskMonitor) compactProcessedUploads(ctx context.Context) {
+	for {
+		m.compactProcessedUploadsOnce()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(auditCompactionInterval):
+		}
+	}
+}
+
+// compactProcessedUploadsOnce deletes the processed upload entries older than the retention. The
+// entries are also what keep an upload from being processed twice, so an entry is never deleted while
+// its task can still be returned by Globus. An upload is processed after its task completes, so any
+// entry older than the task lookback belongs to a task that has fallen out of the window we ask for.
+func (m *GlobusTaskMonitor) compactProcessedUploadsOnce() {
+	retention := m.config.AuditRetention
+	if retention < m.config.TaskLookback {
+		retention = m.config.TaskLookback
+	}
+
+	cutoff := m.now().Add(-retention)
+	count, err := m.processedUploads.DeleteProcessedUploadsBefore(cutoff)
+	if err != nil {
+		m.logger.Errorf("Unable to delete processed globus uploads before %s: %s", cutoff, err)
+		return
+	}
+
+	if count != 0 {
+		m.logger.Infof("Deleted %d processed globus uploads before %s", count, cutoff)
+	}
+}
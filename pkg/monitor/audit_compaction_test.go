@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactProcessedUploads(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.Add(-time.Duration(n) * 24 * time.Hour) }
+
+	tests := []struct {
+		name      string
+		retention time.Duration
+		expected  []string
+	}{
+		{name: "retention longer than lookback", retention: 30 * 24 * time.Hour, expected: []string{"1-day", "10-days"}},
+		{name: "retention shorter than lookback keeps in-window entries", retention: 24 * time.Hour, expected: []string{"1-day"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := newFakeProcessedUploadStore()
+			require.NoError(t, store.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "1-day", ProcessedAt: days(1)}))
+			require.NoError(t, store.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "10-days", ProcessedAt: days(10)}))
+			require.NoError(t, store.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "40-days", ProcessedAt: days(40)}))
+
+			m := newTestMonitor(NewFakeGlobusClient(), WithAuditRetention(test.retention))
+			m.processedUploads = store
+			m.now = func() time.Time { return now }
+
+			m.compactProcessedUploadsOnce()
+			require.Equal(t, test.expected, store.uploadIDs())
+		})
+	}
+}
+
+func TestProcessedUploadsAreRecordedAndSkipped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	store := newFakeProcessedUploadStore()
+	require.NoError(t, store.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "2", TaskID: "task-2"}))
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+
+	m := newTestMonitor(client)
+	m.processedUploads = store
+	seedGlobusUploads(m, 1, 2)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Equal(t, []string{"2", "1"}, store.uploadIDs())
+}
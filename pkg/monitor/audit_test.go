@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRange(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/file.txt", "/__globus_uploads/1/other.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	client.AddUpload("task-4", "/__transfers/archive/5/50/c1/file.txt")
+	client.AddUpload("task-5")
+	client.AddUpload("task-6", "/__globus_uploads/1/again.txt")
+	client.Tasks[0].BytesTransferred = 100
+	client.Tasks[3].BytesTransferred = 50
+
+	processed := newFakeProcessedUploadStore()
+	require.NoError(t, processed.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "2", TaskID: "task-2"}))
+	archiver := &fakeArchiveProcessor{files: make(map[string][]*mcpath.TransferPathContext)}
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithArchiveProcessor(archiver), WithErrorSink(sink))
+	m.processedUploads = processed
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	uploads.add(&GlobusUpload{ID: 1, OwnerID: 10, ProjectID: 100})
+	uploads.add(&GlobusUpload{ID: 2, OwnerID: 10, ProjectID: 100})
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	report, err := m.AuditRange(context.Background(), from, to)
+	require.NoError(t, err)
+
+	require.Equal(t, "2021-03-01T00:00:00,2021-03-08T00:00:00", client.TaskListFilters[0]["filter_completion_time"])
+	expected := AuditReport{
+		From:      from,
+		To:        to,
+		TasksSeen: 6,
+		Items: []AuditItem{
+			{TaskID: "task-1", TransferType: "globus", GlobusUploadID: "1", UserID: 10, ProjectID: 100, Paths: []mcpath.RelPath{"d1/file.txt", "other.txt"}, Bytes: 100},
+			{TaskID: "task-4", TransferType: "archive", UserID: 5, ProjectID: 50, Paths: []mcpath.RelPath{"file.txt"}, Bytes: 50},
+		},
+		TotalBytes: 150,
+		Skipped: map[string]int{
+			// task-2 was processed before, and task-6 is a second task for upload 1.
+			"already_processed": 2,
+			"missing_upload":    1,
+			"empty":             1,
+		},
+	}
+	require.Equal(t, expected, report)
+
+	// Nothing was processed or recorded.
+	require.Equal(t, []string{"2"}, processed.uploadIDs())
+	require.Empty(t, m.finishedGlobusTasks)
+	require.Empty(t, archiver.files)
+	require.Empty(t, sink.events)
+
+	// So running it again gives the same report.
+	again, err := m.AuditRange(context.Background(), from, to)
+	require.NoError(t, err)
+	require.Equal(t, report, again)
+}
+
+func TestAuditRangeMakesThePassDecisions(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/.DS_Store")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/a.txt", "/__globus_uploads/4/b.txt")
+	client.AddUpload("task-4", "/__transfers/globus/1/2")
+	client.AddUpload("task-5", "/__globus_uploads/5/c.txt", "/__globus_uploads/6/d.txt")
+	client.AddUpload("task-6", "/__transfers/globus/1/2/file.txt")
+	client.Tasks[4].BytesTransferred = 10
+
+	m := newTestMonitor(client, WithExcludeGlobs([]string{".DS_Store"}))
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	for id := 1; id <= 6; id++ {
+		uploads.add(&GlobusUpload{ID: id, OwnerID: 10, ProjectID: 100 + id})
+	}
+	m.projects.(*fakeProjectStore).deleted[102] = true
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	t.Run("split", func(t *testing.T) {
+		report, err := m.AuditRange(context.Background(), from, to)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"excluded": 1, "project_deleted": 1, "directory_only": 1, "invalid_path": 1}, report.Skipped)
+
+		var ids []string
+		for _, item := range report.Items {
+			ids = append(ids, item.GlobusUploadID)
+		}
+		require.Equal(t, []string{"3", "4", "5", "6"}, ids)
+
+		// The bytes of a task split across uploads are only counted once.
+		require.Equal(t, int64(10), report.TotalBytes)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		m.config.MixedUploadPolicy = MixedUploadsReject
+		report, err := m.AuditRange(context.Background(), from, to)
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Skipped["mixed_upload_ids"])
+		require.Empty(t, report.Items)
+	})
+}
+
+func TestAuditRangeLeavesTransferPagesAlone(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt", "/__globus_uploads/1/b.txt", "/__globus_uploads/1/c.txt")
+	client.TransferPageSize = 1
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMaxTransferPages(1), WithMetrics(metrics))
+	seedGlobusUploads(m, 1)
+
+	report, err := m.AuditRange(context.Background(), time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, report.Items, 1)
+	require.Len(t, report.Items[0].Paths, 3)
+
+	require.Empty(t, m.deferredPages)
+	require.Empty(t, metrics.counters)
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// BackfillProgress is called as Backfill works through the tasks it found, with how many of the total
+// have been handled so far.
+type BackfillProgress func(done, total int)
+
+// Backfill processes the tasks that completed between from and to, for catching up on a window the
+// monitor missed, such as one older than the lookback. It is the processing counterpart to AuditRange:
+// uploads that have already been processed are skipped, and the task filters apply, but the watermark
+// isn't moved. progress, when not nil, is called once before the first task and then after each task
+// is handled, so an operator can follow a backfill that takes hours. It returns an error if Globus
+// can't be queried for the tasks.
+func (m *GlobusTaskMonitor) Backfill(ctx context.Context, from, to time.Time, progress BackfillProgress) (PassResult, error) {
+	m.passMu.Lock()
+	defer m.passMu.Unlock()
+
+	if progress == nil {
+		progress = func(done, total int) {}
+	}
+
+	var result PassResult
+	tasks, err := m.listTasks(m.completionRangeFilter(from, to))
+	if err != nil {
+		return result, fmt.Errorf("unable to get task list: %w", m.client.ExtractError(err))
+	}
+
+	result.TasksSeen = len(tasks)
+	var toFetch []globus.Task
+	for _, task := range tasks {
+		task, ok := m.checkCompletionTime(task)
+		if !ok {
+			m.incCounter("tasks_skipped", Labels{"reason": "missing_completion_time"})
+			continue
+		}
+
+		if !m.passesTaskFilters(task) {
+			m.incCounter("tasks_skipped", Labels{"reason": "task_filter"})
+			continue
+		}
+
+		toFetch = append(toFetch, task)
+	}
+
+	total := len(toFetch)
+	progress(0, total)
+
+	touched := make(projectRefs)
+	fetches := m.fetchTransfers(ctx, toFetch)
+	for i := range toFetch {
+		if err := ctx.Err(); err != nil {
+			result.TouchedProjects = touched.sorted()
+			return result, err
+		}
+
+		m.processFetchedTask(ctx, fetches.get(i), touched, &result, false)
+		progress(i+1, total)
+	}
+
+	result.TouchedProjects = touched.sorted()
+	return result, nil
+}
+
+// completionRangeFilter returns the task list filters for the tasks that succeeded between from and to.
+func (m *GlobusTaskMonitor) completionRangeFilter(from, to time.Time) map[string]string {
+	const layout = "2006-01-02T15:04:05"
+	return map[string]string{
+		"filter_completion_time": m.inFilterTimeZone(from).Format(layout) + "," + m.inFilterTimeZone(to).Format(layout),
+		"filter_status":          "SUCCEEDED",
+	}
+}
@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillProgress(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	client.AddUpload("task-4", "/__globus_uploads/4/file.txt")
+	client.Tasks[3].OwnerID = "maintenance"
+	m := newTestMonitor(client, WithTaskFilter(func(task globus.Task) bool { return task.OwnerID != "maintenance" }))
+	seedGlobusUploads(m, 1, 2, 3, 4)
+	watermark := m.lastProcessedTime
+
+	type step struct{ done, total int }
+	var steps []step
+	result, err := m.Backfill(context.Background(), from, to, func(done, total int) {
+		steps = append(steps, step{done, total})
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, result.TasksSeen)
+	require.Equal(t, 3, result.TasksProcessed)
+	require.Equal(t, "2021-01-01T00:00:00,2021-01-31T00:00:00", client.TaskListFilters[0]["filter_completion_time"])
+
+	// Progress goes up by one for each task that passed the filters, and ends at the total.
+	require.Equal(t, []step{{0, 3}, {1, 3}, {2, 3}, {3, 3}}, steps)
+	require.Equal(t, watermark, m.lastProcessedTime)
+
+	// Running it again skips the uploads that were processed.
+	result, err = m.Backfill(context.Background(), from, to, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.TasksProcessed)
+}
+
+func TestBackfillTaskListError(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TaskListErr = errors.New("globus down")
+	m := newTestMonitor(client)
+
+	called := false
+	_, err := m.Backfill(context.Background(), time.Now().Add(-time.Hour), time.Now(), func(done, total int) { called = true })
+	require.Error(t, err)
+	require.False(t, called)
+}
@@ -0,0 +1,36 @@
+package monitor
+
+import "context"
+
+// CancelUpload aborts the processing of the globus upload with the given id, if it is being processed.
+// This is for uploads found to be bad while they are being processed. Other uploads aren't affected.
+// A cancelled upload isn't marked as processed, so once it has been dealt with it is evaluated again
+// on a later pass. It returns true if the upload was being processed.
+func (m *GlobusTaskMonitor) CancelUpload(id string) bool {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+
+	upload, ok := m.inFlight[id]
+	if ok {
+		upload.cancel()
+	}
+
+	return ok
+}
+
+// startUpload registers an upload as being processed and returns the context to process it with.
+// The returned function must be called once processing is finished.
+func (m *GlobusTaskMonitor) startUpload(ctx context.Context, id string) (context.Context, func()) {
+	uploadCtx, cancel := context.WithCancel(ctx)
+
+	m.inFlightMu.Lock()
+	m.inFlight[id] = &inFlightUpload{cancel: cancel, started: m.now()}
+	m.inFlightMu.Unlock()
+
+	return uploadCtx, func() {
+		m.inFlightMu.Lock()
+		delete(m.inFlight, id)
+		m.inFlightMu.Unlock()
+		cancel()
+	}
+}
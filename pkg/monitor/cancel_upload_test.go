@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelUpload(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1, 2)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.block = 1
+	fileLoads.started = make(chan struct{})
+
+	require.False(t, m.CancelUpload("1"))
+
+	done := make(chan PassResult)
+	go func() {
+		done <- m.retrieveAndProcessUploads(context.Background())
+	}()
+
+	select {
+	case <-fileLoads.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("upload 1 was never processed")
+	}
+	require.True(t, m.CancelUpload("1"))
+
+	var result PassResult
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pass did not finish after upload 1 was cancelled")
+	}
+
+	// Upload 1 was aborted and left unprocessed, upload 2 carried on as usual.
+	require.Equal(t, 1, result.TasksProcessed)
+	require.False(t, m.finishedGlobusTasks["1"])
+	require.True(t, m.finishedGlobusTasks["2"])
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.Equal(t, 2, fileLoads.fileLoads[0].GlobusUploadID)
+	require.False(t, m.CancelUpload("1"))
+
+	// Once it has been dealt with, upload 1 is processed on a later pass.
+	fileLoads.block = 0
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.True(t, m.finishedGlobusTasks["1"])
+}
+
+func TestCancelledPassStopsProcessing(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1, 2, 3)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.block = 1
+	fileLoads.started = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan PassResult)
+	go func() {
+		done <- m.retrieveAndProcessUploads(ctx)
+	}()
+
+	select {
+	case <-fileLoads.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("upload 1 was never processed")
+	}
+	cancel()
+
+	var result PassResult
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pass did not finish after it was cancelled")
+	}
+
+	// The tasks after the one that was running when the pass was cancelled aren't started.
+	require.Equal(t, 0, result.TasksProcessed)
+	require.Empty(t, fileLoads.fileLoads)
+	require.False(t, m.finishedGlobusTasks["2"])
+	require.False(t, m.finishedGlobusTasks["3"])
+}
@@ -0,0 +1,32 @@
+package monitor
+
+import "time"
+
+// defaultCaughtUpLag is how close the watermark has to get to now for the monitor to count as caught
+// up when Config.CaughtUpLag isn't changed.
+const defaultCaughtUpLag = time.Minute
+
+// checkCaughtUp calls the WithOnCaughtUp callback the first time a pass that handled every task,
+// clean, leaves the watermark within Config.CaughtUpLag of now. A pass that failed, or left tasks to
+// be retried, hasn't caught up whatever the watermark says. Nor has a monitor whose watermark is
+// still only when it started, before a pass or ImportState has set it. It is called at the end of
+// each pass, so the callback runs on the goroutine running the passes and the next pass waits for it
+// to return.
+func (m *GlobusTaskMonitor) checkCaughtUp(clean bool) {
+	if m.onCaughtUp == nil || m.caughtUp || !clean {
+		return
+	}
+
+	m.statusMu.Lock()
+	known, lastProcessed := m.watermarkKnown, m.lastProcessedTime
+	m.statusMu.Unlock()
+
+	lag := m.now().Sub(lastProcessed)
+	if !known || lag > m.config.CaughtUpLag {
+		return
+	}
+
+	m.caughtUp = true
+	m.logger.Infof("Globus task monitor for endpoint %s has caught up (%s behind)", m.endpointID, lag.Round(time.Second))
+	m.onCaughtUp()
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnCaughtUpFiresOnce(t *testing.T) {
+	// The monitor's watermark starts at when it was created, so the clock carries on from there.
+	now := time.Now().UTC()
+	client := NewFakeGlobusClient()
+	calls := 0
+	m := newTestMonitor(client, WithOnCaughtUp(func() { calls++ }), WithCaughtUpLag(time.Minute))
+	m.now = func() time.Time { return now }
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+	// A backlog of uploads for a monitor that has just started. Its watermark is within the lag of
+	// now, but only because it is when the monitor started.
+	for i := 1; i <= 5; i++ {
+		client.AddUpload(fmt.Sprintf("task-%d", i), fmt.Sprintf("/__globus_uploads/%d/file.txt", i))
+		seedGlobusUploads(m, i)
+	}
+
+	// While Globus can't be reached the backlog isn't worked through.
+	client.TaskListErr = errors.New("globus down")
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 0, calls)
+
+	// Nor has it been when an upload is left to be retried.
+	client.TaskListErr = nil
+	fileLoads.err = errors.New("insert failed")
+	fileLoads.failures = 1
+	now = now.Add(10 * time.Second)
+	require.Equal(t, 4, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 0, calls)
+
+	now = now.Add(10 * time.Second)
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 1, calls)
+
+	// Idle passes, and falling behind and catching up again, don't fire it again.
+	for i := 0; i < 3; i++ {
+		now = now.Add(10 * time.Second)
+		m.retrieveAndProcessUploads(context.Background())
+	}
+	client.TaskListErr = errors.New("globus down")
+	now = now.Add(time.Hour)
+	m.retrieveAndProcessUploads(context.Background())
+	client.TaskListErr = nil
+	now = now.Add(10 * time.Second)
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, calls)
+}
+
+func TestOnCaughtUpWaitsForTheLagThreshold(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	m := newTestMonitor(NewFakeGlobusClient(), WithOnCaughtUp(func() { calls++ }), WithCaughtUpLag(time.Minute))
+	m.now = func() time.Time { return now }
+	m.advanceWatermark(now.Add(-2 * time.Minute))
+
+	m.checkCaughtUp(true)
+	require.Equal(t, 0, calls)
+
+	m.advanceWatermark(now.Add(-30 * time.Second))
+	m.checkCaughtUp(false)
+	require.Equal(t, 0, calls)
+
+	m.checkCaughtUp(true)
+	require.Equal(t, 1, calls)
+}
@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// defaultClockSkewWarning is how far the host clock can be behind Globus before the monitor warns
+// about it.
+const defaultClockSkewWarning = time.Minute
+
+// globusTimeLayouts are the formats Globus has used for task timestamps.
+var globusTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+
+// epochMillisThreshold separates epoch timestamps in seconds from those in milliseconds. As seconds
+// it is in the year 5138, and as milliseconds it is in 1973, well before any Globus task.
+const epochMillisThreshold = 100000000000
+
+// parseGlobusTime parses a timestamp from Globus, returning it in UTC whatever offset it was given
+// with. Timestamps without a zone are in UTC. Some endpoints give timestamps as Unix epoch times
+// instead, so a timestamp that is all digits is read as seconds since the epoch, or as milliseconds
+// when it is too large to be seconds.
+func parseGlobusTime(s string) (time.Time, bool) {
+	if t, ok := parseEpochTime(s); ok {
+		return t, true
+	}
+
+	for _, layout := range globusTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseEpochTime parses an all digit timestamp as a Unix epoch time in seconds or milliseconds.
+func parseEpochTime(s string) (time.Time, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return time.Time{}, false
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	switch {
+	case err != nil:
+		return time.Time{}, false
+	case n >= epochMillisThreshold:
+		return time.Unix(0, n*int64(time.Millisecond)).UTC(), true
+	}
+
+	return time.Unix(n, 0).UTC(), true
+}
+
+// measureClockSkew estimates how far the host clock is behind Globus from the completion times of
+// tasks, which Globus assigns. A task can't complete in the future, so a completion time after now
+// means the host clock is behind by at least that much. A host clock that is ahead can't be detected
+// this way, and is harmless: the watermark and lookback window only ever reach further back. The
+// estimate is kept until a later pass sees a newer completion time.
+func (m *GlobusTaskMonitor) measureClockSkew(tasks []globus.Task, now time.Time) {
+	var newest time.Time
+	for _, task := range tasks {
+		if completed, ok := parseGlobusTime(task.CompletionTime); ok && completed.After(newest) {
+			newest = completed
+		}
+	}
+
+	if newest.IsZero() {
+		return
+	}
+
+	skew := newest.Sub(now)
+	if skew < 0 {
+		skew = 0
+	}
+
+	m.clockSkew = skew
+	m.setGauge("clock_skew_seconds", skew.Seconds(), nil)
+
+	if m.config.ClockSkewWarning > 0 && skew > m.config.ClockSkewWarning {
+		m.incCounter("clock_skew_warnings", nil)
+		m.logger.Warnf("Clock for globus task monitor for endpoint %s is at least %s behind Globus (task completed at %s)",
+			m.endpointID, skew.Round(time.Second), newest.Format(time.RFC3339))
+	}
+}
+
+// compensateClockSkew moves t forward by the measured clock skew when Config.CompensateClockSkew is
+// set, putting it in Globus' time.
+func (m *GlobusTaskMonitor) compensateClockSkew(t time.Time) time.Time {
+	if !m.config.CompensateClockSkew {
+		return t
+	}
+
+	return t.Add(m.clockSkew)
+}
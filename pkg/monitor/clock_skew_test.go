@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGlobusTime(t *testing.T) {
+	expected := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	for _, s := range []string{"2021-03-15T12:00:00+00:00", "2021-03-15T12:00:00", "2021-03-15 12:00:00", "2021-03-15T07:00:00-05:00"} {
+		parsed, ok := parseGlobusTime(s)
+		require.True(t, ok, s)
+		require.True(t, expected.Equal(parsed), s)
+		require.Equal(t, time.UTC, parsed.Location(), s)
+	}
+
+	_, ok := parseGlobusTime("")
+	require.False(t, ok)
+}
+
+func TestParseGlobusEpochTime(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected time.Time
+	}{
+		{s: "1615809600", expected: time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{s: "1615809600000", expected: time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{s: "1615809600123", expected: time.Date(2021, 3, 15, 12, 0, 0, 123000000, time.UTC)},
+		{s: "99999999999", expected: time.Date(5138, 11, 16, 9, 46, 39, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			parsed, ok := parseGlobusTime(test.s)
+			require.True(t, ok)
+			require.True(t, test.expected.Equal(parsed), "expected %s, got %s", test.expected, parsed)
+			require.Equal(t, time.UTC, parsed.Location())
+		})
+	}
+
+	for _, s := range []string{"-1615809600", "1615809600.5", "16158096OO", "99999999999999999999"} {
+		_, ok := parseGlobusTime(s)
+		require.False(t, ok, s)
+	}
+}
+
+func TestEpochCompletionTimesAdvanceWatermark(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.Tasks[0].CompletionTime = "1615806000"
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+	m.now = func() time.Time { return now }
+	m.lastProcessedTime = now.Add(-2 * time.Hour)
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.True(t, now.Equal(m.lastProcessedTime))
+	uploads := m.processedUploads.(*fakeProcessedUploadStore).uploads
+	require.True(t, now.Add(-time.Hour).Equal(*uploads[0].CompletionTime))
+	require.Equal(t, time.Hour.Seconds(), uploads[0].LatencySeconds)
+}
+
+func TestClockSkewWarning(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.Tasks[0].CompletionTime = now.Add(-time.Hour).Format("2006-01-02T15:04:05")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+	seedGlobusUploads(m, 1)
+	m.now = func() time.Time { return now }
+	m.lastProcessedTime = now.Add(-time.Hour)
+	labels := Labels{"endpoint": testEndpointID}
+
+	// Tasks that completed in the past say nothing about the clock.
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 0.0, metrics.gauge("clock_skew_seconds", labels))
+	require.Equal(t, 0.0, metrics.counter("clock_skew_warnings", labels))
+
+	// A task that completed 10 minutes from now means the clock is at least 10 minutes behind.
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.Tasks[1].CompletionTime = now.Add(10 * time.Minute).Format("2006-01-02T15:04:05")
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 600.0, metrics.gauge("clock_skew_seconds", labels))
+	require.Equal(t, 1.0, metrics.counter("clock_skew_warnings", labels))
+
+	// Without compensation the watermark stays in the host's time.
+	require.Equal(t, now, m.lastProcessedTime)
+}
+
+func TestClockSkewCompensation(t *testing.T) {
+	now := time.Date(2021, 3, 15, 23, 55, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.Tasks[0].CompletionTime = now.Add(10 * time.Minute).Format(time.RFC3339)
+	m := newTestMonitor(client, WithClockSkewCompensation(), WithTaskLookback(time.Minute), WithClockSkewWarning(time.Hour))
+	seedGlobusUploads(m, 1)
+	m.now = func() time.Time { return now }
+	m.lastProcessedTime = now.Add(-time.Hour)
+
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, now.Add(10*time.Minute), m.lastProcessedTime)
+
+	// The next pass asks for tasks using Globus' time, which is already the next day.
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, "2021-03-15", client.TaskListFilters[0]["filter_completion_time"])
+	require.Equal(t, "2021-03-16", client.TaskListFilters[1]["filter_completion_time"])
+}
@@ -0,0 +1,58 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// pingDB returns a check that db can be reached. A nil db, as the tests use, can always be reached.
+func pingDB(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return nil
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// dbAvailable returns true if the database can be reached, or WithPauseWhenDBUnavailable wasn't given.
+// The first time it can't be reached is logged and sent to the ErrorSink, and the monitor reports
+// itself unhealthy until it can be reached again.
+func (m *GlobusTaskMonitor) dbAvailable(ctx context.Context) bool {
+	if !m.config.PauseWhenDBUnavailable {
+		return true
+	}
+
+	err := m.dbCheck(ctx)
+
+	m.statusMu.Lock()
+	wasUnavailable := m.dbUnavailable
+	m.dbUnavailable = err != nil
+	m.statusMu.Unlock()
+
+	switch {
+	case err != nil && !wasUnavailable:
+		m.notifyError(ErrorEvent{
+			Kind: "db_unavailable",
+			Err:  fmt.Errorf("database unavailable, pausing processing of globus uploads: %w", err),
+		})
+	case err != nil:
+		m.logger.Infof("Database still unavailable, processing of globus uploads remains paused: %s", err)
+	case wasUnavailable:
+		m.logger.Infof("Database available again, resuming processing of globus uploads")
+	}
+
+	if err != nil {
+		m.incCounter("passes_paused", Labels{"reason": "db_unavailable"})
+	}
+
+	return err == nil
+}
@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB is a database check that fails while the database is down.
+type fakeDB struct {
+	mu   sync.Mutex
+	down bool
+}
+
+func (db *fakeDB) check(_ context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.down {
+		return errors.New("connection refused")
+	}
+
+	return nil
+}
+
+func (db *fakeDB) setDown(down bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.down = down
+}
+
+func TestPauseWhenDBUnavailable(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithPauseWhenDBUnavailable(), WithErrorSink(sink))
+	seedGlobusUploads(m, 1, 2)
+	db := &fakeDB{}
+	m.dbCheck = db.check
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+	// While the database is down Globus is still polled, but nothing is processed or marked as done.
+	db.setDown(true)
+	watermark := m.HealthStatus().Watermark
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.True(t, result.Paused)
+	require.Equal(t, 1, result.TasksSeen)
+	require.Equal(t, 0, result.TasksProcessed)
+	require.True(t, m.HealthStatus().DBUnavailable)
+	require.Equal(t, watermark, m.HealthStatus().Watermark)
+
+	// An upload that completes during the outage waits along with the first.
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.True(t, result.Paused)
+	require.Equal(t, 2, result.TasksSeen)
+	require.Empty(t, fileLoads.fileLoads)
+	require.False(t, m.isFinished("1"))
+	require.False(t, m.isFinished("2"))
+
+	// The outage is only reported when it starts.
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "db_unavailable", sink.events[0].Kind)
+
+	// Once the database is back both uploads are processed.
+	db.setDown(false)
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.False(t, result.Paused)
+	require.Equal(t, 2, result.TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 2)
+	require.True(t, m.isFinished("1"))
+	require.True(t, m.isFinished("2"))
+	require.False(t, m.HealthStatus().DBUnavailable)
+}
+
+func TestPausedPassesArentIdle(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client, WithPauseWhenDBUnavailable(), WithStopAfterIdlePasses(2))
+	seedGlobusUploads(m, 1)
+
+	// The database comes back after a few passes, and the monitor is still running to process the
+	// upload before stopping once it is idle.
+	down := 5
+	m.dbCheck = func(_ context.Context) error {
+		if down > 0 {
+			down--
+			return errors.New("connection refused")
+		}
+
+		return nil
+	}
+
+	runUntilStopped(t, m)
+	require.True(t, m.isFinished("1"))
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// defaultDBRetries is how many times an operation that failed with a transient database error
+	// is retried when Config.DBRetries isn't changed.
+	defaultDBRetries = 3
+
+	// defaultDBRetryBackoff is how long to wait before the first retry. The wait doubles for each
+	// retry after it.
+	defaultDBRetryBackoff = 100 * time.Millisecond
+)
+
+// MySQL error numbers for failures that are expected to go away when the statement is run again.
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// isTransientDBError returns true if err is a database failure that is likely to clear up by itself,
+// such as a deadlock or a dropped connection. Other errors, for example a constraint violation, will
+// fail the same way every time.
+func isTransientDBError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDeadlock || mysqlErr.Number == mysqlLockWaitTimeout
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withDBRetry runs fn, running it again up to Config.DBRetries more times while it fails with a
+// transient database error. It waits Config.DBRetryBackoff before the first retry and twice as long
+// before each one after that. It returns the last error when fn doesn't succeed, or when ctx is
+// cancelled while waiting.
+func (m *GlobusTaskMonitor) withDBRetry(ctx context.Context, op string, fn func() error) error {
+	err := fn()
+	backoff := m.config.DBRetryBackoff
+	for retry := 1; err != nil && retry <= m.config.DBRetries && isTransientDBError(err); retry++ {
+		m.logger.Warnf("Retrying %s after transient database error (retry %d of %d): %s", op, retry, m.config.DBRetries, err)
+		m.incCounter("db_retries", Labels{"op": op})
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		err = fn()
+	}
+
+	return err
+}
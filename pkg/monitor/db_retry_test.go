@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "deadlock", err: &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, transient: true},
+		{name: "lock wait timeout", err: &mysql.MySQLError{Number: 1205}, transient: true},
+		{name: "wrapped deadlock", err: fmt.Errorf("create: %w", &mysql.MySQLError{Number: 1213}), transient: true},
+		{name: "bad connection", err: driver.ErrBadConn, transient: true},
+		{name: "invalid connection", err: mysql.ErrInvalidConn, transient: true},
+		{name: "duplicate entry", err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, transient: false},
+		{name: "cancelled", err: context.Canceled, transient: false},
+		{name: "other", err: errors.New("database down"), transient: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.transient, isTransientDBError(test.err))
+		})
+	}
+}
+
+func TestTransientFileLoadFailuresAreRetried(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	metrics := newMemoryMetrics()
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithDBRetries(3, time.Millisecond), WithMetrics(metrics), WithErrorSink(sink))
+	seedGlobusUploads(m, 1)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.err = &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	fileLoads.failures = 2
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 3, fileLoads.calls)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.True(t, m.finishedGlobusTasks["1"])
+	require.Empty(t, sink.events)
+	require.Equal(t, 2.0, metrics.counter("db_retries", Labels{"endpoint": testEndpointID, "op": "add_file_load"}))
+}
+
+func TestFileLoadFailingAfterRetriesIsReported(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithDBRetries(2, time.Millisecond), WithErrorSink(sink))
+	seedGlobusUploads(m, 1)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.err = driver.ErrBadConn
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 3, fileLoads.calls)
+	require.False(t, m.finishedGlobusTasks["1"])
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "file_load_failed", sink.events[0].Kind)
+	require.True(t, errors.Is(sink.events[0].Err, driver.ErrBadConn))
+
+	// The database is back by the next pass.
+	fileLoads.err = nil
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+}
+
+func TestPermanentFileLoadFailuresAreNotRetried(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithDBRetries(3, time.Millisecond), WithErrorSink(sink))
+	seedGlobusUploads(m, 1)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.err = &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 1, fileLoads.calls)
+	require.Len(t, sink.events, 1)
+}
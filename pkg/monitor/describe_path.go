@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+)
+
+// The layouts DescribePath recognises.
+const (
+	// PathLayoutUpload is /__globus_uploads/<id>/...rest of path..., where the files for a globus upload
+	// are written. Any destination path outside of the transfers tree is read with this layout.
+	PathLayoutUpload = "upload"
+
+	// PathLayoutArchive is the archive layout of the transfers tree, see mcpath.ArchiveTransferType.
+	PathLayoutArchive = "archive"
+
+	// PathLayoutProjectDirectory is /__transfers/<transfer type>/<user id>/<project id>, the directory
+	// of a project in the transfers tree with nothing below it. It isn't a file, so it is never loaded.
+	PathLayoutProjectDirectory = "project_directory"
+
+	// PathLayoutTransfers is a file in the transfers tree other than an archive file. These are
+	// written by the bridge for a transfer request, not into a globus upload, so they are never loaded.
+	PathLayoutTransfers = "transfers"
+
+	// PathLayoutDownload is the /__downloads/<user id>/<project id>/... layout of the source paths of
+	// downloads. Downloads have a blank destination path.
+	PathLayoutDownload = "download"
+)
+
+// uploadsRoot is the top level directory of the upload layout.
+const uploadsRoot = "__globus_uploads"
+
+// PathDescription is how the monitor interprets a transfer path, see DescribePath. The ids and
+// relative path are those found in the path, and are left blank when the layout doesn't have them;
+// for uploads the user and project come from the globus_uploads entry rather than the path.
+type PathDescription struct {
+	Path           string
+	Layout         string
+	TransferType   string
+	UserID         int
+	ProjectID      int
+	Cohort         string
+	RelPath        string
+	GlobusUploadID string
+
+	// Valid is false when the path doesn't fit its layout, and Error says why.
+	Valid bool
+	Error string
+
+	// Skip is the reason the monitor would pass over the path, using the reason labels of the
+	// tasks_skipped counter where there is one, and is blank when it would be loaded.
+	// Only the reasons that follow from the path alone are given: a task can still be skipped for
+	// other reasons, such as its destination endpoint or its project having been deleted.
+	Skip string
+}
+
+// DescribePath returns how the monitor interprets p, the destination path of a transfer, or its source
+// path for a download. It is meant for tracking down why a transfer was or wasn't loaded, and only
+// looks at the path, so it doesn't go to Globus or the database.
+func DescribePath(p string) PathDescription {
+	d := PathDescription{Path: p, Valid: true}
+	switch {
+	case p == "" || strings.HasPrefix(p, "/"+mcpath.DownloadsRoot+"/"):
+		describeDownload(&d)
+	case isArchiveDestination(p):
+		describeArchive(&d)
+	case isDirectoryOnlyDestination(p):
+		describeProjectDirectory(&d)
+	case strings.HasPrefix(p, "/"+mcpath.TransfersRoot+"/"):
+		describeTransfers(&d)
+	default:
+		describeUpload(&d)
+	}
+
+	return d
+}
+
+// describeDownload fills in d for a download. Downloads are never loaded.
+func describeDownload(d *PathDescription) {
+	d.Layout = PathLayoutDownload
+	d.Skip = "download"
+	if d.Path == "" {
+		return
+	}
+
+	pathContext := mcpath.ToTransferPathContextFromSource(d.Path)
+	describeContext(d, pathContext)
+	if !pathContext.IsUser() || !pathContext.IsProject() {
+		d.Valid, d.Error = false, fmt.Sprintf("expected /%s/<user id>/<project id>/...rest of path...", mcpath.DownloadsRoot)
+	}
+}
+
+// describeArchive fills in d for an archive path. Entries above the path within the cohort aren't
+// files, so they are left out of the archive task.
+func describeArchive(d *PathDescription) {
+	d.Layout = PathLayoutArchive
+	pathContext := mcpath.ToTransferPathContext(d.Path)
+	describeContext(d, pathContext)
+	if _, err := mcpath.ParseTransferPath(d.Path); err != nil {
+		d.Valid, d.Error = false, err.Error()
+	}
+
+	if pathContext.Path.IsRoot() {
+		d.Skip = "archive_not_a_file"
+	}
+}
+
+// describeProjectDirectory fills in d for the directory of a project in the transfers tree.
+func describeProjectDirectory(d *PathDescription) {
+	d.Layout = PathLayoutProjectDirectory
+	describeContext(d, mcpath.ToTransferPathContext(d.Path))
+	d.Skip = "directory_only"
+}
+
+// describeTransfers fills in d for a file in the transfers tree that isn't an archive file.
+func describeTransfers(d *PathDescription) {
+	d.Layout = PathLayoutTransfers
+	describeContext(d, mcpath.ToTransferPathContext(d.Path))
+	d.Valid, d.Error = false, fmt.Sprintf("files in /%s aren't written into a globus upload", mcpath.TransfersRoot)
+	d.Skip = "invalid_path"
+}
+
+// describeUpload fills in d for an upload, the layout used for anything that isn't an archive.
+func describeUpload(d *PathDescription) {
+	d.Layout = PathLayoutUpload
+	d.TransferType = mcpath.GlobusTransferType
+
+	id, ok := uploadIDFromDestination(d.Path)
+	if !ok {
+		d.Valid, d.Error = false, fmt.Sprintf("expected /%s/<id>/...rest of path...", uploadsRoot)
+		d.Skip = "invalid_path"
+		return
+	}
+
+	d.GlobusUploadID = id
+	d.RelPath = mcpath.NewRelPath(strings.SplitN(d.Path, "/", 4)[3]).String()
+
+	// The upload id is read from the path wherever it is, so a path outside of the uploads tree, or
+	// one without a numeric id, is looked up and never found.
+	if n, err := strconv.Atoi(id); err != nil || n <= 0 || !strings.HasPrefix(d.Path, "/"+uploadsRoot+"/") {
+		d.Valid, d.Error = false, fmt.Sprintf("%q isn't a globus upload id under /%s", id, uploadsRoot)
+		d.Skip = "missing_upload"
+	}
+}
+
+// describeContext copies the parts of pathContext into d.
+func describeContext(d *PathDescription, pathContext *mcpath.TransferPathContext) {
+	d.TransferType = pathContext.TransferType
+	d.UserID = pathContext.UserID
+	d.ProjectID = pathContext.ProjectID
+	d.Cohort = pathContext.Cohort
+	d.RelPath = pathContext.Path.String()
+}
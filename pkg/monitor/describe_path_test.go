@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected PathDescription
+	}{
+		{
+			path: "/__globus_uploads/12/d1/file.txt",
+			expected: PathDescription{
+				Layout: PathLayoutUpload, TransferType: "globus", GlobusUploadID: "12", RelPath: "d1/file.txt", Valid: true,
+			},
+		},
+		{
+			path: "/__transfers/archive/1/2/c1/d1/file.txt",
+			expected: PathDescription{
+				Layout: PathLayoutArchive, TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", RelPath: "d1/file.txt", Valid: true,
+			},
+		},
+		{
+			path: "/__transfers/archive/1/2/c1",
+			expected: PathDescription{
+				Layout: PathLayoutArchive, TransferType: "archive", UserID: 1, ProjectID: 2, Cohort: "c1", Valid: true, Skip: "archive_not_a_file",
+			},
+		},
+		{
+			path: "/__transfers/globus/1/2",
+			expected: PathDescription{
+				Layout: PathLayoutProjectDirectory, TransferType: "globus", UserID: 1, ProjectID: 2, Valid: true, Skip: "directory_only",
+			},
+		},
+		{
+			path: "/__downloads/1/2/d1/file.txt",
+			expected: PathDescription{
+				Layout: PathLayoutDownload, TransferType: "globus", UserID: 1, ProjectID: 2, RelPath: "d1/file.txt", Valid: true, Skip: "download",
+			},
+		},
+		{
+			path:     "",
+			expected: PathDescription{Layout: PathLayoutDownload, Valid: true, Skip: "download"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			test.expected.Path = test.path
+			require.Equal(t, test.expected, DescribePath(test.path))
+		})
+	}
+}
+
+func TestDescribeMalformedPath(t *testing.T) {
+	tests := []struct {
+		path           string
+		skip           string
+		globusUploadID string
+	}{
+		{path: "/__globus_uploads/12", skip: "invalid_path"},
+		{path: "/file.txt", skip: "invalid_path"},
+		{path: "/__globus_uploads/abc/file.txt", skip: "missing_upload", globusUploadID: "abc"},
+		{path: "/__transfers/globus/1/2/file.txt", skip: "invalid_path"},
+		{path: "/__transfers/globus/1", skip: "invalid_path"},
+		{path: "/__transfers/archive/abc/2/c1/file.txt"},
+		{path: "/__downloads/abc/file.txt", skip: "download"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			d := DescribePath(test.path)
+			require.False(t, d.Valid)
+			require.NotEmpty(t, d.Error)
+			require.Equal(t, test.skip, d.Skip)
+			require.Equal(t, test.globusUploadID, d.GlobusUploadID)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package monitor
+
+// EffectiveConfig returns a copy of the settings the monitor is running with, once the defaults and
+// the options it was created with have been applied, so operators can see which settings took
+// effect. Config holds no credentials, so it is safe to log. Changing the copy doesn't change the
+// monitor.
+func (m *GlobusTaskMonitor) EffectiveConfig() Config {
+	config := m.config
+	config.ExcludeGlobs = append([]string(nil), m.config.ExcludeGlobs...)
+	config.MoveDetectionProjects = append([]int(nil), m.config.MoveDetectionProjects...)
+	return config
+}
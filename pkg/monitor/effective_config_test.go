@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	// Defaults.
+	config := NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID).EffectiveConfig()
+	require.Equal(t, defaultPollInterval, config.PollInterval)
+	require.Equal(t, defaultTaskLookback, config.TaskLookback)
+	require.Equal(t, defaultTransferFetchConcurrency, config.TransferFetchConcurrency)
+	require.Equal(t, defaultDBRetries, config.DBRetries)
+	require.Equal(t, time.UTC, config.FilterTimeZone)
+	require.False(t, config.SkipVerifyFileLoad)
+	require.Zero(t, config.MaxTransferPages)
+	require.Empty(t, config.ExcludeGlobs)
+
+	// Options.
+	m := NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID, WithTaskLookback(2*time.Hour),
+		WithExcludeGlobs([]string{".DS_Store"}), WithMaxTransferPages(10), WithVerifyFileLoadCreated(false))
+	config = m.EffectiveConfig()
+	require.Equal(t, defaultPollInterval, config.PollInterval)
+	require.Equal(t, 2*time.Hour, config.TaskLookback)
+	require.Equal(t, []string{".DS_Store"}, config.ExcludeGlobs)
+	require.Equal(t, 10, config.MaxTransferPages)
+	require.True(t, config.SkipVerifyFileLoad)
+
+	// Changing the copy doesn't change the monitor.
+	config.ExcludeGlobs[0] = "*"
+	config.TaskLookback = time.Minute
+	require.Equal(t, []string{".DS_Store"}, m.EffectiveConfig().ExcludeGlobs)
+	require.Equal(t, 2*time.Hour, m.EffectiveConfig().TaskLookback)
+
+	// A Config given to Run, as built from the environment, keeps the defaults for what it leaves out.
+	expected := NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID).EffectiveConfig()
+	expected.PollInterval = time.Minute
+	config = NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID, withConfig(Config{PollInterval: time.Minute})).EffectiveConfig()
+	require.NotNil(t, config.PathNormalizer)
+	expected.PathNormalizer, config.PathNormalizer = nil, nil
+	require.Equal(t, expected, config)
+	require.Equal(t, defaultDBRetries, config.DBRetries)
+	require.Equal(t, defaultDBRetryBackoff, config.DBRetryBackoff)
+	require.Equal(t, defaultCaughtUpLag, config.CaughtUpLag)
+	require.False(t, config.SkipVerifyFileLoad)
+
+	// Verification and retries can be turned off from a Config.
+	config = NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID, withConfig(Config{SkipVerifyFileLoad: true, DBRetries: -1})).EffectiveConfig()
+	require.True(t, config.SkipVerifyFileLoad)
+	require.Equal(t, -1, config.DBRetries)
+}
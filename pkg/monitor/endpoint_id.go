@@ -0,0 +1,34 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidEndpointID is returned by VerifyEndpoint when the endpoint id can't be a Globus endpoint
+// id. Globus endpoint ids are UUIDs.
+var ErrInvalidEndpointID = errors.New("invalid globus endpoint id")
+
+var endpointIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// normalizeEndpointID returns id without surrounding white space and in lower case, which is how
+// Globus writes endpoint ids. Ids copied from a config file or the web app often pick up a trailing
+// newline or space.
+func normalizeEndpointID(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// validateEndpointID returns an error wrapping ErrInvalidEndpointID when id, which should already be
+// normalized, is empty or isn't a UUID.
+func validateEndpointID(id string) error {
+	switch {
+	case id == "":
+		return fmt.Errorf("%w: no endpoint id given", ErrInvalidEndpointID)
+	case !endpointIDPattern.MatchString(id):
+		return fmt.Errorf("%w %q: endpoint ids are UUIDs", ErrInvalidEndpointID, id)
+	default:
+		return nil
+	}
+}
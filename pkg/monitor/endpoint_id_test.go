@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointIDValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpointID string
+		valid      bool
+	}{
+		{name: "uuid", endpointID: testEndpointID, valid: true},
+		{name: "padded", endpointID: "  " + testEndpointID + "\n", valid: true},
+		{name: "upper case", endpointID: strings.ToUpper(testEndpointID), valid: true},
+		{name: "empty", endpointID: "", valid: false},
+		{name: "white space", endpointID: " \t", valid: false},
+		{name: "not a uuid", endpointID: "my-endpoint", valid: false},
+		{name: "truncated uuid", endpointID: testEndpointID[:30], valid: false},
+		{name: "uuid with extra", endpointID: testEndpointID + "x", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			m := NewGlobusTaskMonitor(client, nil, test.endpointID)
+
+			err := m.VerifyEndpoint(context.Background())
+			if test.valid {
+				require.NoError(t, err)
+				require.Equal(t, testEndpointID, m.endpointID)
+				require.Equal(t, 1, client.TaskListCalls)
+				return
+			}
+
+			require.True(t, errors.Is(err, ErrInvalidEndpointID))
+			require.Equal(t, 0, client.TaskListCalls)
+		})
+	}
+}
+
+func TestRunFailsForInvalidEndpointID(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := NewGlobusTaskMonitor(client, nil, "my-endpoint", WithStopAfterIdlePasses(1))
+	require.True(t, errors.Is(m.Run(context.Background()), ErrInvalidEndpointID))
+	require.Equal(t, 0, client.TaskListCalls)
+}
+
+func TestMultiEndpointMonitorNormalizesEndpointIDs(t *testing.T) {
+	mm := NewMultiEndpointMonitor(NewFakeGlobusClient(), nil, []string{" " + strings.ToUpper(testEndpointID) + "\n"}, Config{}, nil)
+	require.Equal(t, []string{testEndpointID}, mm.EndpointIDs())
+	require.NotNil(t, mm.Monitor(testEndpointID))
+	require.NotNil(t, mm.Monitor(strings.ToUpper(testEndpointID)))
+}
@@ -0,0 +1,23 @@
+package monitor
+
+import "github.com/apex/log"
+
+// ErrorEvent describes a problem the monitor ran into that someone may want to look at. Kind is a
+// short machine friendly name for the problem, and Fields holds whatever identifies what it happened to.
+type ErrorEvent struct {
+	Kind   string
+	Err    error
+	Fields log.Fields
+}
+
+// ErrorSink receives the ErrorEvents from the monitor. The default implementation logs them;
+// WithErrorSink can send them somewhere more likely to get noticed.
+type ErrorSink interface {
+	Notify(event ErrorEvent)
+}
+
+type logErrorSink struct{}
+
+func (logErrorSink) Notify(event ErrorEvent) {
+	log.WithFields(event.Fields).Errorf("%s: %s", event.Kind, event.Err)
+}
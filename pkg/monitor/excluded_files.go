@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+)
+
+// isExcludedFile returns true if p, the path of a file within its project, matches one of globs. A
+// glob without a slash is matched against the name of the file, wherever it is, and one with a slash
+// against the whole path. Globs use the syntax of path.Match, and a glob that isn't valid never matches.
+func isExcludedFile(globs []string, p mcpath.RelPath) bool {
+	for _, glob := range globs {
+		name := p.String()
+		if !strings.Contains(glob, "/") {
+			name = path.Base(name)
+		}
+
+		if matched, _ := path.Match(glob, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excludeFiles removes the files of an upload that match the globs given to WithExcludeGlobs from the
+// upload directory, so the file loader doesn't turn them into project files, and returns the rest.
+func (m *GlobusTaskMonitor) excludeFiles(id string, task globus.Task, upload *GlobusUpload, files []UploadFile, skipped *skippedFiles) []UploadFile {
+	if len(m.config.ExcludeGlobs) == 0 {
+		return files
+	}
+
+	var kept []UploadFile
+	excluded := 0
+	for _, file := range files {
+		if !isExcludedFile(m.config.ExcludeGlobs, file.Path) {
+			kept = append(kept, file)
+			continue
+		}
+
+		local := filepath.Join(upload.Path, file.Source.String())
+		if err := m.objectStore.Delete(context.Background(), local); err != nil {
+			// The file is still there, so the file loader will load it.
+			m.logger.Errorf("Unable to remove excluded file %s from globus upload %s: %s", file.Source, id, err)
+			kept = append(kept, file)
+			continue
+		}
+
+		excluded++
+		m.skipFile(skipped, uploadSkippedFile(id, task, upload, file, "excluded"))
+	}
+
+	if excluded != 0 {
+		m.logger.Infof("Excluded %d files from globus upload %s (task %s)", excluded, id, task.TaskID)
+	}
+
+	return kept
+}
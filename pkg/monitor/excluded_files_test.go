@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsExcludedFile(t *testing.T) {
+	globs := []string{".DS_Store", "~$*", "*.part", "tmp/*.txt", "["}
+	require.True(t, isExcludedFile(globs, mcpath.NewRelPath(".DS_Store")))
+	require.True(t, isExcludedFile(globs, mcpath.NewRelPath("d1/d2/.DS_Store")))
+	require.True(t, isExcludedFile(globs, mcpath.NewRelPath("d1/~$report.docx")))
+	require.True(t, isExcludedFile(globs, mcpath.NewRelPath("d1/data.csv.part")))
+	require.True(t, isExcludedFile(globs, mcpath.NewRelPath("tmp/a.txt")))
+	require.False(t, isExcludedFile(globs, mcpath.NewRelPath("d1/tmp/a.txt")))
+	require.False(t, isExcludedFile(globs, mcpath.NewRelPath("d1/report.docx")))
+	require.False(t, isExcludedFile(nil, mcpath.NewRelPath(".DS_Store")))
+}
+
+func TestExcludeGlobs(t *testing.T) {
+	client := NewFakeGlobusClient()
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithExcludeGlobs([]string{".DS_Store", "~$*", "*.part"}), WithMetrics(metrics))
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+	// The temporary files are removed from the upload, and the real ones are loaded.
+	dir1 := writeUploadDir(t, map[string]string{"d1/a.txt": "a", "d1/.DS_Store": "x", "~$b.docx": "x", "c.csv.part": "x"})
+	uploads.add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir1})
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/a.txt", "/__globus_uploads/1/d1/.DS_Store",
+		"/__globus_uploads/1/~$b.docx", "/__globus_uploads/1/c.csv.part")
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.Equal(t, 1, fileLoads.fileLoads[0].GlobusUploadID)
+	require.FileExists(t, filepath.Join(dir1, "d1/a.txt"))
+	for _, name := range []string{"d1/.DS_Store", "~$b.docx", "c.csv.part"} {
+		_, err := os.Stat(filepath.Join(dir1, name))
+		require.True(t, os.IsNotExist(err), name)
+	}
+	require.Equal(t, float64(3), metrics.counter("files_skipped", Labels{"endpoint": testEndpointID, "reason": "excluded"}))
+
+	// An upload of nothing but temporary files doesn't create a file load.
+	dir2 := writeUploadDir(t, map[string]string{".DS_Store": "x"})
+	uploads.add(&GlobusUpload{ID: 2, ProjectID: 1, OwnerID: 1, Path: dir2})
+	client.AddUpload("task-2", "/__globus_uploads/2/.DS_Store")
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.True(t, m.isFinished("2"))
+	require.Equal(t, float64(1), metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "excluded"}))
+}
@@ -0,0 +1,348 @@
+package monitor
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// FakeGlobusClient is an in memory GlobusClient. Tests fill in Tasks and Transfers (keyed by task id)
+// and can inspect the recorded calls afterwards.
+type FakeGlobusClient struct {
+	mu sync.Mutex
+
+	Tasks     []globus.Task
+	Transfers map[string][]globus.Transfer
+
+	TaskListErr      error
+	TaskListResponse *globus.TaskList
+	TransfersErr     error
+	GlobusError      *GlobusError
+	TaskListCalls    int
+	TransferCalls    int
+	TaskListFilters  []map[string]string
+	TransfersFetched []string
+
+	// BeforeTaskListPage, when set, is called with the call number before each task list page is put
+	// together, so tests can change Tasks or TaskListResponse between pages. The client is locked while
+	// it runs, so it must change them directly rather than through the client's methods.
+	BeforeTaskListPage func(call int)
+
+	// taskKeys is the order tasks were first seen in, which the last_key of a page refers to.
+	taskKeys    map[string]int
+	nextTaskKey int
+
+	// TransferDelay is how long each GetTaskSuccessfulTransfers call takes. MaxTransferCallsActive
+	// records the most calls that were running at the same time.
+	TransferDelay          time.Duration
+	transferCallsActive    int
+	MaxTransferCallsActive int
+
+	// TransferPageSize, when set, splits the transfers for a task into pages of that size, with the
+	// marker of a page being the index of its first transfer.
+	TransferPageSize int
+
+	// ACLErr is returned by DeleteEndpointACLRule. DeletedACLs records the ACLs it was asked to delete.
+	ACLErr      error
+	DeletedACLs []string
+
+	// scripts holds the FakeCalls queued up by Script, by method name.
+	scripts map[string][]FakeCall
+}
+
+// FakeCall scripts how a single call to a FakeGlobusClient method behaves: the call takes Latency and
+// then fails with Err, or carries on as usual when Err is nil.
+type FakeCall struct {
+	Latency time.Duration
+	Err     error
+}
+
+// FailCalls returns n FakeCalls that fail with err, for scripting a run of failures.
+func FailCalls(n int, err error) []FakeCall {
+	calls := make([]FakeCall, n)
+	for i := range calls {
+		calls[i] = FakeCall{Err: err}
+	}
+
+	return calls
+}
+
+// Script queues up how the next calls to method, for example "GetEndpointTaskList", behave, one
+// FakeCall per call. For example, to fail the next 2 calls and then succeed after 300ms:
+//
+//	client.Script("GetEndpointTaskList", append(FailCalls(2, err), FakeCall{Latency: 300 * time.Millisecond})...)
+//
+// Once the script runs out calls behave as set up by the other fields. A scripted error takes the
+// place of TaskListErr, TransfersErr or ACLErr for that call, but the call is still recorded.
+func (c *FakeGlobusClient) Script(method string, calls ...FakeCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scripts == nil {
+		c.scripts = make(map[string][]FakeCall)
+	}
+
+	c.scripts[method] = append(c.scripts[method], calls...)
+}
+
+// runScript takes the next FakeCall scripted for method, waits for its latency and returns its error.
+// It must be called without holding mu, so calls can wait at the same time.
+func (c *FakeGlobusClient) runScript(method string) error {
+	c.mu.Lock()
+	var call FakeCall
+	if calls := c.scripts[method]; len(calls) != 0 {
+		call, c.scripts[method] = calls[0], calls[1:]
+	}
+	c.mu.Unlock()
+
+	time.Sleep(call.Latency)
+	return call.Err
+}
+
+var (
+	_ GlobusClient = (*FakeGlobusClient)(nil)
+	_ ACLClient    = (*FakeGlobusClient)(nil)
+)
+
+func NewFakeGlobusClient() *FakeGlobusClient {
+	return &FakeGlobusClient{Transfers: make(map[string][]globus.Transfer), taskKeys: make(map[string]int)}
+}
+
+// testEndpointID is the endpoint the test monitors watch. The others are for tests that need more
+// than one endpoint.
+const (
+	testEndpointID  = "5e4ea5b2-7a1c-11eb-8a4e-0242ac110002"
+	testEndpointID2 = "6f5fb6c3-7a1c-11eb-8a4e-0242ac110002"
+	testEndpointID3 = "7a60c7d4-7a1c-11eb-8a4e-0242ac110002"
+)
+
+// testCompletionTime is the completion time of the tasks added by AddUpload.
+const testCompletionTime = "2021-01-01T00:00:00"
+
+// AddUpload adds a completed task that uploaded the given destination paths to testEndpointID.
+func (c *FakeGlobusClient) AddUpload(taskID string, destinationPaths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Tasks = append(c.Tasks, globus.Task{
+		TaskID:         taskID,
+		Status:         "SUCCEEDED",
+		CompletionTime: testCompletionTime,
+		TaskExtras:     globus.TaskExtras{DestinationEndpointID: testEndpointID},
+	})
+	for _, p := range destinationPaths {
+		c.Transfers[taskID] = append(c.Transfers[taskID], globus.Transfer{DestinationPath: p})
+	}
+}
+
+func (c *FakeGlobusClient) GetEndpointTaskList(endpointID string, filters map[string]string) (globus.TaskList, error) {
+	scriptErr := c.runScript("GetEndpointTaskList")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.TaskListCalls++
+	c.TaskListFilters = append(c.TaskListFilters, filters)
+	switch {
+	case scriptErr != nil:
+		return globus.TaskList{}, scriptErr
+	case c.TaskListErr != nil:
+		return globus.TaskList{}, c.TaskListErr
+	}
+
+	if c.BeforeTaskListPage != nil {
+		c.BeforeTaskListPage(c.TaskListCalls)
+	}
+
+	if c.TaskListResponse != nil {
+		return *c.TaskListResponse, nil
+	}
+
+	// Like Globus, a page has at most limit tasks and starts after the task named by the last_key of
+	// the page before it. The key stays good when tasks are added or drop out between pages.
+	for _, task := range c.Tasks {
+		if _, ok := c.taskKeys[task.TaskID]; !ok {
+			c.nextTaskKey++
+			c.taskKeys[task.TaskID] = c.nextTaskKey
+		}
+	}
+
+	after := 0
+	if lastKey, ok := filters["last_key"]; ok {
+		after = c.taskKeys[lastKey]
+	}
+
+	limit, err := strconv.Atoi(filters["limit"])
+	if err != nil {
+		limit = len(c.Tasks)
+	}
+
+	var remaining []globus.Task
+	for _, task := range c.Tasks {
+		if taskID, ok := filters["filter_task_id"]; ok && task.TaskID != taskID {
+			continue
+		}
+		if c.taskKeys[task.TaskID] > after {
+			remaining = append(remaining, task)
+		}
+	}
+
+	tasks := []globus.Task{}
+	for _, task := range remaining {
+		if len(tasks) == limit {
+			break
+		}
+		tasks = append(tasks, task)
+	}
+
+	page := globus.TaskList{Tasks: tasks, Limit: limit, HasNextPage: len(tasks) < len(remaining)}
+	if len(tasks) != 0 {
+		page.LastKey = tasks[len(tasks)-1].TaskID
+	}
+
+	return page, nil
+}
+
+func (c *FakeGlobusClient) GetTaskSuccessfulTransfers(taskID string, marker int) (globus.TransferItems, error) {
+	c.mu.Lock()
+	c.transferCallsActive++
+	if c.transferCallsActive > c.MaxTransferCallsActive {
+		c.MaxTransferCallsActive = c.transferCallsActive
+	}
+	delay := c.TransferDelay
+	c.mu.Unlock()
+
+	scriptErr := c.runScript("GetTaskSuccessfulTransfers")
+	time.Sleep(delay)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transferCallsActive--
+
+	c.TransferCalls++
+	c.TransfersFetched = append(c.TransfersFetched, taskID)
+	switch {
+	case scriptErr != nil:
+		return globus.TransferItems{}, scriptErr
+	case c.TransfersErr != nil:
+		return globus.TransferItems{}, c.TransfersErr
+	}
+
+	transfers := c.Transfers[taskID]
+	if c.TransferPageSize == 0 {
+		return globus.TransferItems{Transfers: transfers}, nil
+	}
+
+	items := globus.TransferItems{Marker: marker}
+	end := marker + c.TransferPageSize
+	if end < len(transfers) {
+		items.NextMarker = end
+	} else {
+		end = len(transfers)
+	}
+
+	items.Transfers = transfers[marker:end]
+	return items, nil
+}
+
+func (c *FakeGlobusClient) DeleteEndpointACLRule(endpointID, accessID string) (globus.DeleteEndpointACLRuleResult, error) {
+	scriptErr := c.runScript("DeleteEndpointACLRule")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.DeletedACLs = append(c.DeletedACLs, accessID)
+	switch {
+	case scriptErr != nil:
+		return globus.DeleteEndpointACLRuleResult{}, scriptErr
+	case c.ACLErr != nil:
+		return globus.DeleteEndpointACLRuleResult{}, c.ACLErr
+	}
+
+	return globus.DeleteEndpointACLRuleResult{Code: "Deleted"}, nil
+}
+
+// ExtractError returns GlobusError when it is set, so tests can script what Globus reports.
+func (c *FakeGlobusClient) ExtractError(err error) *GlobusError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err == nil:
+		return nil
+	case c.GlobusError != nil:
+		return c.GlobusError
+	default:
+		return &GlobusError{Message: err.Error()}
+	}
+}
+
+func TestFakeGlobusClientScript(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	globusDown := errors.New("globus down")
+	client.Script("GetEndpointTaskList", append(FailCalls(2, globusDown), FakeCall{Latency: 30 * time.Millisecond})...)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetEndpointTaskList(testEndpointID, nil)
+		require.Equal(t, globusDown, err)
+	}
+
+	start := time.Now()
+	tasks, err := client.GetEndpointTaskList(testEndpointID, nil)
+	require.NoError(t, err)
+	require.Len(t, tasks.Tasks, 1)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(30*time.Millisecond))
+
+	// Once the script has run out calls go back to behaving as usual, and every call was recorded.
+	start = time.Now()
+	_, err = client.GetEndpointTaskList(testEndpointID, nil)
+	require.NoError(t, err)
+	require.Less(t, int64(time.Since(start)), int64(30*time.Millisecond))
+	require.Equal(t, 4, client.TaskListCalls)
+}
+
+func TestFakeGlobusClientScriptIsPerMethod(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	transfersDown := errors.New("transfers down")
+	aclDown := errors.New("acl down")
+	client.Script("GetTaskSuccessfulTransfers", FakeCall{Err: transfersDown})
+	client.Script("DeleteEndpointACLRule", FakeCall{Err: aclDown})
+
+	_, err := client.GetEndpointTaskList(testEndpointID, nil)
+	require.NoError(t, err)
+
+	_, err = client.GetTaskSuccessfulTransfers("task-1", 0)
+	require.Equal(t, transfersDown, err)
+	transfers, err := client.GetTaskSuccessfulTransfers("task-1", 0)
+	require.NoError(t, err)
+	require.Len(t, transfers.Transfers, 1)
+
+	_, err = client.DeleteEndpointACLRule(testEndpointID, "acl-1")
+	require.Equal(t, aclDown, err)
+	_, err = client.DeleteEndpointACLRule(testEndpointID, "acl-1")
+	require.NoError(t, err)
+}
+
+func TestFakeGlobusClientScriptedLatencyOverlaps(t *testing.T) {
+	// Scripted latency is spent without holding the client's lock, so calls in parallel wait together.
+	client := NewFakeGlobusClient()
+	client.Script("GetTaskSuccessfulTransfers", FakeCall{Latency: 50 * time.Millisecond}, FakeCall{Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.GetTaskSuccessfulTransfers("task-1", 0)
+		}()
+	}
+	wg.Wait()
+
+	require.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	require.Equal(t, 2, client.MaxTransferCallsActive)
+}
@@ -0,0 +1,302 @@
+package monitor
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"gorm.io/gorm"
+)
+
+// fakeGlobusUploadStore is an in memory GlobusUploadStore.
+type fakeGlobusUploadStore struct {
+	mu        sync.Mutex
+	uploads   map[string]*GlobusUpload
+	deleteErr error
+}
+
+func newFakeGlobusUploadStore() *fakeGlobusUploadStore {
+	return &fakeGlobusUploadStore{uploads: make(map[string]*GlobusUpload)}
+}
+
+func (s *fakeGlobusUploadStore) GetGlobusUpload(id string) (*GlobusUpload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, ok := s.uploads[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return upload, nil
+}
+
+func (s *fakeGlobusUploadStore) DeleteGlobusUpload(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+
+	delete(s.uploads, id)
+	return nil
+}
+
+func (s *fakeGlobusUploadStore) add(upload *GlobusUpload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[strconv.Itoa(upload.ID)] = upload
+}
+
+// uploadExists returns true if the monitor's fake store still has the upload with id.
+func uploadExists(m *GlobusTaskMonitor, id string) bool {
+	_, err := m.globusUploads.GetGlobusUpload(id)
+	return err == nil
+}
+
+// seedGlobusUploads adds an upload to the monitor's fake store for each id.
+func seedGlobusUploads(m *GlobusTaskMonitor, ids ...int) {
+	store := m.globusUploads.(*fakeGlobusUploadStore)
+	for _, id := range ids {
+		store.add(&GlobusUpload{ID: id, ProjectID: 1, OwnerID: 1})
+	}
+}
+
+// fakeErrorSink records the events it is sent.
+type fakeErrorSink struct {
+	mu     sync.Mutex
+	events []ErrorEvent
+}
+
+func (s *fakeErrorSink) Notify(event ErrorEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+// fakeFileLoadStore is an in memory FileLoadStore. When block is set, adding a file load for that
+// globus upload id sends on started and then waits for the context to be cancelled. When err is set
+// adding a file load fails with it, only for the first failures calls if failures is set. When noop
+// is set adding a file load reports success without storing it. FindFileLoad fails with each of
+// findErrs in turn, with a nil one looking the file load up as usual.
+type fakeFileLoadStore struct {
+	mu        sync.Mutex
+	fileLoads []FileLoad
+	err       error
+	failures  int
+	calls     int
+	block     int
+	started   chan struct{}
+	noop      bool
+	findErrs  []error
+}
+
+func (s *fakeFileLoadStore) AddFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	if s.block != 0 && fileLoad.GlobusUploadID == s.block {
+		s.started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil && (s.failures == 0 || s.calls <= s.failures) {
+		return s.err
+	}
+
+	fileLoad.ID = len(s.fileLoads) + 1
+	if s.noop {
+		return nil
+	}
+
+	s.fileLoads = append(s.fileLoads, *fileLoad)
+	return nil
+}
+
+func (s *fakeFileLoadStore) FindFileLoad(_ context.Context, globusUploadID int) (*FileLoad, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.findErrs) != 0 {
+		err := s.findErrs[0]
+		s.findErrs = s.findErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, fileLoad := range s.fileLoads {
+		if fileLoad.GlobusUploadID == globusUploadID {
+			found := fileLoad
+			return &found, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+// fakeProjectStore is an in memory ProjectStore. Every project exists unless it is in deleted.
+type fakeProjectStore struct {
+	mu      sync.Mutex
+	deleted map[int]bool
+	err     error
+}
+
+func newFakeProjectStore() *fakeProjectStore {
+	return &fakeProjectStore{deleted: make(map[int]bool)}
+}
+
+func (s *fakeProjectStore) ProjectExists(projectID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+
+	return !s.deleted[projectID], nil
+}
+
+// fakeProjectFileStore is an in memory ProjectFileStore, holding the files of each project by path.
+// When err is set finding a file fails with it.
+type fakeProjectFileStore struct {
+	mu    sync.Mutex
+	files map[int]map[string]*mcmodel.File
+	err   error
+}
+
+func newFakeProjectFileStore() *fakeProjectFileStore {
+	return &fakeProjectFileStore{files: make(map[int]map[string]*mcmodel.File)}
+}
+
+func (s *fakeProjectFileStore) FindProjectFile(projectID int, path string) (*mcmodel.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if file, ok := s.files[projectID][path]; ok {
+		return file, nil
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *fakeProjectFileStore) add(projectID int, path string, size uint64, checksum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files[projectID] == nil {
+		s.files[projectID] = make(map[string]*mcmodel.File)
+	}
+
+	s.files[projectID][path] = &mcmodel.File{ProjectID: projectID, Name: filepath.Base(path), Path: path, Size: size, Checksum: checksum, Current: true}
+}
+
+// FindDirectory finds a directory when a file is under it, as the store only holds files.
+func (s *fakeProjectFileStore) FindDirectory(projectID int, path string) (*mcmodel.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p := range s.files[projectID] {
+		if path == "/" || strings.HasPrefix(p, path+"/") {
+			return &mcmodel.File{ProjectID: projectID, Name: filepath.Base(path), Path: path, MimeType: "directory"}, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (s *fakeProjectFileStore) FindFilesByChecksum(projectID int, checksum string) ([]mcmodel.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var files []mcmodel.File
+	for p, file := range s.files[projectID] {
+		if file.Checksum == checksum {
+			found := *file
+			found.Directory = &mcmodel.File{Path: filepath.Dir(p), MimeType: "directory"}
+			files = append(files, found)
+		}
+	}
+
+	return files, nil
+}
+
+func (s *fakeProjectFileStore) ListDirectoryFiles(projectID int, path string) ([]mcmodel.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var files []mcmodel.File
+	for p, file := range s.files[projectID] {
+		if filepath.Dir(p) == path {
+			files = append(files, *file)
+		}
+	}
+
+	return files, nil
+}
+
+func (s *fakeProjectFileStore) MoveDirectory(projectID int, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	moved := make(map[string]*mcmodel.File)
+	for p, file := range s.files[projectID] {
+		if strings.HasPrefix(p, from+"/") {
+			delete(s.files[projectID], p)
+			file.Path = to + strings.TrimPrefix(p, from)
+			moved[file.Path] = file
+		}
+	}
+
+	for p, file := range moved {
+		s.files[projectID][p] = file
+	}
+
+	return nil
+}
+
+// fakeHeartbeatStore is an in memory HeartbeatStore, holding the time of each heartbeat by endpoint
+// and instance.
+type fakeHeartbeatStore struct {
+	mu         sync.Mutex
+	heartbeats map[[2]string]time.Time
+}
+
+func newFakeHeartbeatStore() *fakeHeartbeatStore {
+	return &fakeHeartbeatStore{heartbeats: make(map[[2]string]time.Time)}
+}
+
+func (s *fakeHeartbeatStore) Beat(endpointID, instance string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heartbeats[[2]string{endpointID, instance}] = t
+	return nil
+}
+
+func (s *fakeHeartbeatStore) LiveHeartbeats(endpointID, instance string, since time.Time) ([]MonitorHeartbeat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var live []MonitorHeartbeat
+	for key, t := range s.heartbeats {
+		if key[0] == endpointID && key[1] != instance && !t.Before(since) {
+			live = append(live, MonitorHeartbeat{EndpointID: key[0], Instance: key[1], BeatAt: t})
+		}
+	}
+
+	return live, nil
+}
+
+func (s *fakeHeartbeatStore) RemoveHeartbeat(endpointID, instance string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.heartbeats, [2]string{endpointID, instance})
+	return nil
+}
+
+// has returns true if instance of the monitor for endpointID has a heartbeat.
+func (s *fakeHeartbeatStore) has(endpointID, instance string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.heartbeats[[2]string{endpointID, instance}]
+	return ok
+}
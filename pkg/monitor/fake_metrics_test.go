@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryMetrics is a Metrics that keeps everything in memory so tests can check it.
+type memoryMetrics struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+}
+
+func newMemoryMetrics() *memoryMetrics {
+	return &memoryMetrics{counters: make(map[string]float64), gauges: make(map[string]float64)}
+}
+
+func (m *memoryMetrics) IncCounter(name string, labels Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[metricKey(name, labels)]++
+}
+
+func (m *memoryMetrics) SetGauge(name string, value float64, labels Labels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[metricKey(name, labels)] = value
+}
+
+// counter returns the value of the counter with the given name and labels.
+func (m *memoryMetrics) counter(name string, labels Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[metricKey(name, labels)]
+}
+
+// gauge returns the value of the gauge with the given name and labels.
+func (m *memoryMetrics) gauge(name string, labels Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[metricKey(name, labels)]
+}
+
+func metricKey(name string, labels Labels) string {
+	var pairs []string
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeProcessedUploadStore is an in memory ProcessedUploadStore.
+type fakeProcessedUploadStore struct {
+	mu      sync.Mutex
+	uploads []ProcessedGlobusUpload
+}
+
+func newFakeProcessedUploadStore() *fakeProcessedUploadStore {
+	return &fakeProcessedUploadStore{}
+}
+
+func (s *fakeProcessedUploadStore) AddProcessedUpload(upload *ProcessedGlobusUpload) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload.ID = len(s.uploads) + 1
+	s.uploads = append(s.uploads, *upload)
+	return nil
+}
+
+func (s *fakeProcessedUploadStore) IsProcessed(globusUploadID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, upload := range s.uploads {
+		if upload.GlobusUploadID == globusUploadID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *fakeProcessedUploadStore) DeleteProcessedUploadsBefore(t time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kept []ProcessedGlobusUpload
+	for _, upload := range s.uploads {
+		if !upload.ProcessedAt.Before(t) {
+			kept = append(kept, upload)
+		}
+	}
+
+	deleted := int64(len(s.uploads) - len(kept))
+	s.uploads = kept
+	return deleted, nil
+}
+
+func (s *fakeProcessedUploadStore) OwnersProcessedSince(t time.Time) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[int]bool)
+	var ownerIDs []int
+	for _, upload := range s.uploads {
+		if upload.OwnerID > 0 && !upload.ProcessedAt.Before(t) && !seen[upload.OwnerID] {
+			seen[upload.OwnerID] = true
+			ownerIDs = append(ownerIDs, upload.OwnerID)
+		}
+	}
+
+	sort.Ints(ownerIDs)
+	return ownerIDs, nil
+}
+
+func (s *fakeProcessedUploadStore) OwnerForSubmitter(identity string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.uploads) - 1; i >= 0; i-- {
+		upload := s.uploads[i]
+		if upload.SubmitterIdentity == identity && upload.OwnerID > 0 && !strings.HasPrefix(upload.GlobusUploadID, archiveTaskPrefix) {
+			return upload.OwnerID, nil
+		}
+	}
+
+	return 0, gorm.ErrRecordNotFound
+}
+
+func (s *fakeProcessedUploadStore) uploadIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, upload := range s.uploads {
+		ids = append(ids, upload.GlobusUploadID)
+	}
+
+	return ids
+}
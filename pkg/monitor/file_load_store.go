@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"gorm.io/gorm"
+)
+
+// FileLoad is a request to load the files in a directory into a project. The file loader picks
+// these up and loads the files. TaskReference ties the load back to the Globus task the files came
+// from, so support staff can trace a loaded file back to its transfer. SubmitterIdentity is the
+// Globus identity that submitted the task. OwnerID is the Materials Commons user the files are loaded
+// for, and the two aren't necessarily the same person. Path is the directory the upload was written
+// to, and the loader loads everything under it, so an upload has a single file load however many
+// files and subdirectories it has.
+type FileLoad struct {
+	ID                int       `json:"id"`
+	ProjectID         int       `json:"project_id"`
+	OwnerID           int       `json:"owner_id"`
+	Path              string    `json:"path"`
+	GlobusUploadID    int       `json:"globus_upload_id"`
+	TaskReference     string    `json:"task_reference"`
+	SubmitterIdentity string    `json:"submitter_identity"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (FileLoad) TableName() string {
+	return "file_loads"
+}
+
+// FileLoadStore creates FileLoad entries. AddFileLoad gives up when ctx is cancelled.
+type FileLoadStore interface {
+	AddFileLoad(ctx context.Context, fileLoad *FileLoad) error
+}
+
+// FileLoadFinder is implemented by a FileLoadStore that can look up the file load for a globus upload,
+// which ProcessingOrderLoadFirst uses to add file loads idempotently. FindFileLoad returns
+// gorm.ErrRecordNotFound when the upload has no file load. The store NewGlobusTaskMonitor creates
+// implements it.
+type FileLoadFinder interface {
+	FindFileLoad(ctx context.Context, globusUploadID int) (*FileLoad, error)
+}
+
+var _ FileLoadFinder = (*dbFileLoadStore)(nil)
+
+type dbFileLoadStore struct {
+	db *gorm.DB
+}
+
+func newDBFileLoadStore(db *gorm.DB) *dbFileLoadStore {
+	return &dbFileLoadStore{db: db}
+}
+
+func (s *dbFileLoadStore) AddFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	return s.db.WithContext(ctx).Create(fileLoad).Error
+}
+
+func (s *dbFileLoadStore) FindFileLoad(ctx context.Context, globusUploadID int) (*FileLoad, error) {
+	var fileLoad FileLoad
+	if err := s.db.WithContext(ctx).Where("globus_upload_id = ?", globusUploadID).First(&fileLoad).Error; err != nil {
+		return nil, err
+	}
+
+	return &fileLoad, nil
+}
+
+// FormatTaskReference returns a human readable reference to a Globus task, for example
+// `globus task 4e8a3c2e (label "run 12 images")`. The label is left out when the task doesn't have one.
+func FormatTaskReference(task globus.Task) string {
+	if task.Label == "" {
+		return fmt.Sprintf("globus task %s", task.TaskID)
+	}
+
+	return fmt.Sprintf("globus task %s (label %q)", task.TaskID, task.Label)
+}
@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"context"
+	"time"
+)
+
+// defaultShutdownFlushTimeout bounds how long Run waits for the final flush when it stops.
+const defaultShutdownFlushTimeout = 10 * time.Second
+
+// Flusher is implemented by the parts of the monitor that buffer work, such as a Metrics that
+// sends updates in batches or a ProcessedUploadStore that batches its deletes. When Run stops, Flush
+// is called on every one that implements it so the record of the last pass isn't lost.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// flush flushes every part of the monitor that implements Flusher, giving up once
+// config.ShutdownFlushTimeout has passed. It uses its own context since by the time it's called the
+// context Run was given has usually been cancelled.
+func (m *GlobusTaskMonitor) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.ShutdownFlushTimeout)
+	defer cancel()
+
+	parts := []struct {
+		name string
+		part interface{}
+	}{
+		{"processed uploads", m.processedUploads},
+		{"file loads", m.fileLoads},
+		{"publisher", m.publisher},
+		{"error sink", m.errorSink},
+		{"metrics", m.metrics},
+	}
+
+	for _, p := range parts {
+		flusher, ok := p.part.(Flusher)
+		if !ok {
+			continue
+		}
+
+		if err := flusher.Flush(ctx); err != nil {
+			m.logger.Errorf("Unable to flush %s for globus task monitor on endpoint %s: %s", p.name, m.endpointID, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// batchingProcessedUploadStore queues deletes until it is flushed.
+type batchingProcessedUploadStore struct {
+	*fakeProcessedUploadStore
+	mu             sync.Mutex
+	pendingDeletes []time.Time
+}
+
+func (s *batchingProcessedUploadStore) DeleteProcessedUploadsBefore(t time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingDeletes = append(s.pendingDeletes, t)
+	return 0, nil
+}
+
+func (s *batchingProcessedUploadStore) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.pendingDeletes {
+		if _, err := s.fakeProcessedUploadStore.DeleteProcessedUploadsBefore(t); err != nil {
+			return err
+		}
+	}
+
+	s.pendingDeletes = nil
+	return nil
+}
+
+// blockingMetrics is a Metrics whose Flush doesn't finish until its context is done.
+type blockingMetrics struct {
+	noopMetrics
+}
+
+func (blockingMetrics) Flush(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// runWithTimeout runs the monitor with Run and fails the test if it doesn't return.
+func runWithTimeout(t *testing.T, m *GlobusTaskMonitor) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		m.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("monitor did not stop")
+	}
+}
+
+func TestRunFlushesOnShutdown(t *testing.T) {
+	now := time.Now()
+	store := &batchingProcessedUploadStore{fakeProcessedUploadStore: newFakeProcessedUploadStore()}
+	require.NoError(t, store.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "old", ProcessedAt: now.Add(-30 * 24 * time.Hour)}))
+	require.NoError(t, store.AddProcessedUpload(&ProcessedGlobusUpload{GlobusUploadID: "new", ProcessedAt: now}))
+
+	m := newTestMonitor(NewFakeGlobusClient(), WithAuditRetention(time.Hour), WithStopAfterIdlePasses(1))
+	m.processedUploads = store
+	runWithTimeout(t, m)
+
+	require.Empty(t, store.pendingDeletes)
+	require.Equal(t, []string{"new"}, store.uploadIDs())
+}
+
+func TestShutdownFlushIsBounded(t *testing.T) {
+	m := newTestMonitor(NewFakeGlobusClient(), WithStopAfterIdlePasses(1), WithMetrics(blockingMetrics{}),
+		WithShutdownFlushTimeout(10*time.Millisecond))
+
+	start := time.Now()
+	runWithTimeout(t, m)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+}
@@ -0,0 +1,191 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// GlobusClient is the part of the globus.Client API that the monitor uses. It exists so that tests
+// can substitute a fake for the real client.
+type GlobusClient interface {
+	GetEndpointTaskList(endpointID string, filters map[string]string) (globus.TaskList, error)
+	GetTaskSuccessfulTransfers(taskID string, marker int) (globus.TransferItems, error)
+
+	// ExtractError returns what Globus reported about err, which was returned by a call made on the
+	// client. It returns nil when err is nil.
+	ExtractError(err error) *GlobusError
+}
+
+// GlobusError is what Globus reported about a failed request. StatusCode is the HTTP status of the
+// response, or 0 when it isn't known.
+type GlobusError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *GlobusError) Error() string {
+	return fmt.Sprintf("globus error (status %d, code %s, request %s): %s", e.StatusCode, e.Code, e.RequestID, e.Message)
+}
+
+// IsAuthError returns true if the request failed because our credentials were rejected. These
+// won't go away by retrying.
+func (e *GlobusError) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized ||
+		e.StatusCode == http.StatusForbidden ||
+		strings.HasSuffix(e.Code, "AuthenticationFailed")
+}
+
+// IsNotFound returns true if the request failed because what it asked about, such as an endpoint,
+// doesn't exist.
+func (e *GlobusError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound || strings.HasSuffix(e.Code, "NotFound")
+}
+
+// IsTransient returns true if the request failed for a reason that is likely to clear up by itself,
+// such as Globus being overloaded, or the request never getting a response because of a network
+// error or timeout.
+func (e *GlobusError) IsTransient() bool {
+	return e.StatusCode >= http.StatusInternalServerError ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode == 0 && e.Code == "")
+}
+
+// globusClient adapts a globus.Client to the GlobusClient interface. A globus.Client isn't safe to
+// use from more than one goroutine: each call overwrites the error response it keeps for
+// GetGlobusErrorResponse, and re-authenticating replaces its token. So each call is made on a copy
+// of the client that no other call is using. The copies share the underlying HTTP client, and a copy
+// that is handed back is kept for the next call, along with any token it got by re-authenticating.
+// The error response is kept with the error the call returned rather than being read back later.
+type globusClient struct {
+	mu sync.Mutex
+
+	// latest is the client most recently handed back, which new copies are made from so they start
+	// with the newest token.
+	latest globus.Client
+	idle   []*globus.Client
+}
+
+// NewGlobusClient wraps client so it can be passed to NewGlobusTaskMonitor. The client shouldn't be
+// used other than through the returned GlobusClient, which can be shared by monitors and called from
+// more than one goroutine at once.
+func NewGlobusClient(client *globus.Client) GlobusClient {
+	return &globusClient{latest: *client, idle: []*globus.Client{client}}
+}
+
+// acquire returns a client for one call to use, which must be handed back with release.
+func (c *globusClient) acquire() *globus.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.idle); n != 0 {
+		client := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		return client
+	}
+
+	client := c.latest
+	return &client
+}
+
+func (c *globusClient) release(client *globus.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest = *client
+	c.idle = append(c.idle, client)
+}
+
+// callError is an error returned by a call made through a globusClient, along with the error
+// response Globus sent for it, if any.
+type callError struct {
+	err      error
+	response *globus.ErrorResponse
+}
+
+func (e *callError) Error() string {
+	return e.err.Error()
+}
+
+func (e *callError) Unwrap() error {
+	return e.err
+}
+
+// callFailed wraps err, returned by the call just made on client, with a copy of the error response
+// for it. It must be called before client is released.
+func callFailed(client *globus.Client, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	callErr := &callError{err: err}
+	if response := client.GetGlobusErrorResponse(); response != nil {
+		copied := *response
+		callErr.response = &copied
+	}
+
+	return callErr
+}
+
+func (c *globusClient) GetEndpointTaskList(endpointID string, filters map[string]string) (globus.TaskList, error) {
+	client := c.acquire()
+	defer c.release(client)
+	tasks, err := client.GetEndpointTaskList(endpointID, filters)
+	return tasks, callFailed(client, err)
+}
+
+func (c *globusClient) GetTaskSuccessfulTransfers(taskID string, marker int) (globus.TransferItems, error) {
+	client := c.acquire()
+	defer c.release(client)
+	transfers, err := client.GetTaskSuccessfulTransfers(taskID, marker)
+	return transfers, callFailed(client, err)
+}
+
+func (c *globusClient) DeleteEndpointACLRule(endpointID, accessID string) (globus.DeleteEndpointACLRuleResult, error) {
+	client := c.acquire()
+	defer c.release(client)
+	result, err := client.DeleteEndpointACLRule(endpointID, accessID)
+	return result, callFailed(client, err)
+}
+
+// httpStatusPattern matches the status that globus.ToErrorFromResponse puts in the errors it
+// returns. ErrorResponse doesn't carry the status, so this is the only place to get it from.
+var httpStatusPattern = regexp.MustCompile(`\(HTTP Status: (\d+)\)`)
+
+func (c *globusClient) ExtractError(err error) *GlobusError {
+	if err == nil {
+		return nil
+	}
+
+	globusErr := &GlobusError{Message: err.Error(), StatusCode: httpStatus(err)}
+	var callErr *callError
+	if errors.As(err, &callErr) && callErr.response != nil {
+		globusErr.Code = callErr.response.Code
+		globusErr.Message = callErr.response.Message
+		globusErr.RequestID = callErr.response.RequestID
+	}
+
+	return globusErr
+}
+
+// httpStatus returns the HTTP status of the Globus response that err was created from, or 0 when
+// err didn't come from a response, such as a network error.
+func httpStatus(err error) int {
+	if errors.Is(err, globus.ErrGlobusAuth) {
+		return http.StatusUnauthorized
+	}
+
+	match := httpStatusPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+
+	status, _ := strconv.Atoi(match[1])
+	return status
+}
@@ -0,0 +1,69 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobusClientExtractError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		transient      bool
+	}{
+		// Formatted the way globus.ToErrorFromResponse formats the errors it returns.
+		{name: "server error", err: fmt.Errorf("(HTTP Status: 503)- ServiceUnavailable: try again: %w", errors.New("globus api error")), expectedStatus: http.StatusServiceUnavailable, transient: true},
+		{name: "not found", err: errors.New("(HTTP Status: 404)- EndpointNotFound: no such endpoint"), expectedStatus: http.StatusNotFound},
+		{name: "auth", err: globus.ErrGlobusAuth, expectedStatus: http.StatusUnauthorized},
+		{name: "timeout", err: &url.Error{Op: "Get", URL: "https://transfer.api.globus.org", Err: context.DeadlineExceeded}, transient: true},
+		{name: "network", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, transient: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewGlobusClient(&globus.Client{})
+			globusErr := client.ExtractError(test.err)
+			require.Equal(t, test.expectedStatus, globusErr.StatusCode)
+			require.Equal(t, test.transient, globusErr.IsTransient())
+		})
+	}
+
+	require.Nil(t, NewGlobusClient(&globus.Client{}).ExtractError(nil))
+}
+
+func TestGlobusClientExtractErrorUsesTheCallsResponse(t *testing.T) {
+	client := NewGlobusClient(&globus.Client{})
+	err := fmt.Errorf("unable to list tasks: %w", &callError{
+		err:      errors.New("(HTTP Status: 404)- EndpointNotFound: no such endpoint"),
+		response: &globus.ErrorResponse{Code: "EndpointNotFound", Message: "no such endpoint", RequestID: "req-1"},
+	})
+
+	globusErr := client.ExtractError(err)
+	require.Equal(t, http.StatusNotFound, globusErr.StatusCode)
+	require.Equal(t, "EndpointNotFound", globusErr.Code)
+	require.Equal(t, "no such endpoint", globusErr.Message)
+	require.Equal(t, "req-1", globusErr.RequestID)
+}
+
+func TestGlobusClientCallsUseTheirOwnClient(t *testing.T) {
+	client := NewGlobusClient(&globus.Client{}).(*globusClient)
+
+	// Calls made at once are each given a client of their own.
+	first, second := client.acquire(), client.acquire()
+	require.NotSame(t, first, second)
+
+	// Handed back clients are used again rather than copied.
+	client.release(first)
+	client.release(second)
+	require.Same(t, second, client.acquire())
+	require.Same(t, first, client.acquire())
+}
@@ -2,120 +2,614 @@ package monitor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/apex/log"
 	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
 	"gorm.io/gorm"
 )
 
 type GlobusTaskMonitor struct {
-	client              *globus.Client
+	client              GlobusClient
 	db                  *gorm.DB
 	endpointID          string
+	config              Config
 	finishedGlobusTasks map[string]bool
+	processedUploads    ProcessedUploadStore
+	globusUploads       GlobusUploadStore
+	fileLoads           FileLoadStore
+	metrics             Metrics
+	errorSink           ErrorSink
+	archiveProcessor    ArchiveProcessor
+	projects            ProjectStore
+	projectFiles        ProjectFileStore
+	objectStore         objectstore.ObjectStore
+	uploadSummaries     UploadSummaryStore
+	heartbeats          HeartbeatStore
+	publisher           Publisher
+	tracer              Tracer
+	logger              *log.Entry
+	taskFilters         []TaskFilter
+	processingSlots     processingSlots
+	projectLocks        *projectLocks
+	fileLoadLimiter     *rateLimiter
+	transferLimiter     *rateLimiter
+	now                 func() time.Time
+
+	// heartbeatInstance is the name the monitor's heartbeat is kept under, see WithHeartbeat.
+	heartbeatInstance string
+
+	// dbCheck returns an error when the database can't be reached, see WithPauseWhenDBUnavailable.
+	dbCheck func(ctx context.Context) error
+
+	// lastProcessedTime is the watermark: every task that completed before it has been processed.
+	// It is always in UTC, like the completion times it is compared with.
+	// It and lastPass are only changed while holding statusMu, so HealthStatus can read them.
+	// watermarkKnown is true once a pass or ImportState has set the watermark, rather than it being
+	// the time the monitor was created. dbUnavailable is true while processing is paused because the
+	// database can't be reached, and is also only changed while holding statusMu.
+	statusMu          sync.Mutex
+	lastProcessedTime time.Time
+	lastPass          time.Time
+	watermarkKnown    bool
+	dbUnavailable     bool
+
+	// passMu keeps a pass and ReprocessTask from running at the same time.
+	passMu sync.Mutex
+
+	// taskMarker is the marker from the last pass that handled every task, see TaskChangesClient.
+	taskMarker string
+
+	// onCaughtUp is called once, the first time a pass ends with the watermark within
+	// Config.CaughtUpLag of now. caughtUp records that it has been called.
+	onCaughtUp func()
+	caughtUp   bool
+
+	// clockSkew is how far the host clock was last seen to be behind Globus, see measureClockSkew.
+	clockSkew time.Duration
+
+	// inFlight holds the uploads being processed, see CancelUpload and WithStuckThreshold.
+	inFlightMu sync.Mutex
+	inFlight   map[string]*inFlightUpload
+
+	// pendingVerifications are the checks that processed uploads were loaded, see WithVerifyLoadedFiles.
+	verifyMu             sync.Mutex
+	pendingVerifications []loadVerification
+
+	// deferredPages are the transfers fetched so far for the tasks deferred by WithMaxTransferPages.
+	pagesMu       sync.Mutex
+	deferredPages map[string]*deferredTransferPages
+}
+
+// PassResult summarizes a single pass over the completed tasks for the endpoint.
+type PassResult struct {
+	// TasksSeen is the number of tasks Globus returned.
+	TasksSeen int
+
+	// TasksProcessed is the number of tasks that were new uploads and were processed.
+	TasksProcessed int
+
+	// TasksEmpty is the number of tasks that succeeded without transferring any files.
+	TasksEmpty int
+
+	// TasksWithFailures is the number of processed tasks that succeeded overall, but had files
+	// that failed to transfer.
+	TasksWithFailures int
+
+	// TouchedProjects is the distinct set of projects that the processed tasks changed files in. A
+	// consumer can use it to invalidate per-project caches once per pass rather than once per file.
+	TouchedProjects []ProjectRef
+
+	// Paused is true when the pass didn't process anything because the database couldn't be reached,
+	// see WithPauseWhenDBUnavailable.
+	Paused bool
+
+	// SkippedFiles are the files the tasks handled in the pass left out, in the order they were
+	// processed, each with the reason it was skipped.
+	SkippedFiles []SkippedFile
 }
 
-func NewGlobusTaskMonitor(client *globus.Client, db *gorm.DB, endpointID string) *GlobusTaskMonitor {
-	return &GlobusTaskMonitor{
-		client:              client,
-		db:                  db,
-		endpointID:          endpointID,
+func NewGlobusTaskMonitor(client GlobusClient, db *gorm.DB, endpointID string, opts ...Option) *GlobusTaskMonitor {
+	m := &GlobusTaskMonitor{
+		client:     client,
+		db:         db,
+		endpointID: normalizeEndpointID(endpointID),
+		config: Config{
+			PollInterval:             defaultPollInterval,
+			WatermarkLagWarning:      defaultWatermarkLagWarning,
+			PathNormalizer:           mcpath.IdentityNormalizer,
+			TransferFetchConcurrency: defaultTransferFetchConcurrency,
+			TaskLookback:             defaultTaskLookback,
+			ShutdownFlushTimeout:     defaultShutdownFlushTimeout,
+			TaskPageSize:             defaultTaskPageSize,
+			ClockSkewWarning:         defaultClockSkewWarning,
+			FilterTimeZone:           time.UTC,
+			DBRetries:                defaultDBRetries,
+			DBRetryBackoff:           defaultDBRetryBackoff,
+			CaughtUpLag:              defaultCaughtUpLag,
+		},
 		finishedGlobusTasks: make(map[string]bool),
+		inFlight:            make(map[string]*inFlightUpload),
+		deferredPages:       make(map[string]*deferredTransferPages),
+		projectLocks:        newProjectLocks(),
+		processedUploads:    newDBProcessedUploadStore(db),
+		globusUploads:       newDBGlobusUploadStore(db),
+		fileLoads:           newDBFileLoadStore(db),
+		projects:            newDBProjectStore(db),
+		projectFiles:        newDBProjectFileStore(db),
+		objectStore:         objectstore.NewLocalStore(""),
+		uploadSummaries:     newDBUploadSummaryStore(db),
+		heartbeats:          newDBHeartbeatStore(db),
+		metrics:             noopMetrics{},
+		errorSink:           logErrorSink{},
+		archiveProcessor:    logArchiveProcessor{},
+		publisher:           noopPublisher{},
+		tracer:              noopTracer{},
+		now:                 time.Now,
+		dbCheck:             pingDB(db),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.lastProcessedTime = m.now().UTC()
+	m.logger = log.WithFields(m.logFields())
+	m.fileLoadLimiter = newRateLimiter(m.config.FileLoadRate, m.config.FileLoadBurst)
+	m.transferLimiter = newRateLimiter(m.config.TransferFetchRate, m.config.TransferFetchBurst)
+	m.heartbeatInstance = heartbeatInstance(m.config.InstanceName)
+	if store, ok := m.projectFiles.(*dbProjectFileStore); ok {
+		store.foldCase = m.config.CaseInsensitivePaths
 	}
+
+	return m
 }
 
+// Start runs the monitor in the background. If the endpoint can't be verified (see VerifyEndpoint)
+// the error is logged and the monitor doesn't start.
 func (m *GlobusTaskMonitor) Start(ctx context.Context) {
-	log.Infof("Starting globus task monitor...")
-	go m.monitorAndProcessTasks(ctx)
+	go func() {
+		if err := m.Run(ctx); err != nil {
+			m.logger.Errorf("Globus task monitor for endpoint %s not started: %s", m.endpointID, err)
+		}
+	}()
+}
+
+// Run runs the monitor until ctx is cancelled (or it stops by itself, see WithStopAfterIdlePasses and
+// WithMaxRuntime).
+// Unlike Start it blocks, and it doesn't return until everything the monitor started has stopped
+// and anything buffered has been flushed (see Flusher). It first verifies the endpoint, and returns
+// the error without running if the endpoint doesn't exist or isn't accessible.
+func (m *GlobusTaskMonitor) Run(ctx context.Context) error {
+	if err := m.verifyOnStart(ctx); err != nil {
+		return err
+	}
+
+	if err := m.checkDuplicateMonitors(); err != nil {
+		return err
+	}
+
+	m.run(ctx)
+	return nil
+}
+
+// run is Run without verifying the endpoint.
+func (m *GlobusTaskMonitor) run(ctx context.Context) {
+	m.logger.Infof("Starting globus task monitor...")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if m.config.AuditRetention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.compactProcessedUploads(ctx)
+		}()
+	}
+
+	if m.config.HeartbeatTTL > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.heartbeat(ctx)
+		}()
+	}
+
+	m.monitorAndProcessTasks(ctx)
+
+	// The monitor may have stopped by itself, so make sure the compaction and heartbeat stop too.
+	cancel()
+	wg.Wait()
+
+	m.flush()
 }
 
 func (m *GlobusTaskMonitor) monitorAndProcessTasks(ctx context.Context) {
+	// The passes are run with ctx rather than runtimeCtx, so a pass that is under way when the max
+	// runtime is up is finished before the monitor stops.
+	runtimeCtx, cancel := m.withMaxRuntime(ctx)
+	defer cancel()
+
+	if m.config.StuckThreshold > 0 {
+		go m.watchForStuckUploads(runtimeCtx)
+	}
+
+	if m.config.VerifyLoadedFilesAfter > 0 {
+		go m.watchLoadedFiles(runtimeCtx)
+	}
+
+	idlePasses := 0
 	for {
-		m.retrieveAndProcessUploads(ctx)
+		result := m.retrieveAndProcessUploads(ctx)
+		switch {
+		case result.Paused:
+			// A pass paused for the database isn't idle, the work is still there to be done.
+		case result.TasksProcessed == 0:
+			idlePasses++
+		default:
+			idlePasses = 0
+		}
+
+		if m.config.StopAfterIdlePasses > 0 && idlePasses >= m.config.StopAfterIdlePasses {
+			m.logger.Infof("No globus uploads processed in the last %d passes, stopping globus monitoring...", idlePasses)
+			return
+		}
+
 		select {
-		case <-ctx.Done():
-			log.Infof("Shutting down globus monitoring...")
+		case <-runtimeCtx.Done():
+			if ctx.Err() == nil {
+				m.logger.Infof("Ran for the maximum of %s, stopping globus monitoring...", m.config.MaxRuntime)
+				return
+			}
+
+			m.logger.Infof("Shutting down globus monitoring...")
 			return
-		case <-time.After(10 * time.Second):
+		case <-time.After(m.config.PollInterval):
 		}
 	}
 }
 
-func (m *GlobusTaskMonitor) retrieveAndProcessUploads(c context.Context) {
-	// Build a filter to get all successful tasks that completed in the last week
-	lastWeek := time.Now().AddDate(0, 0, -7).Format("2006-01-02")
+// withMaxRuntime returns a context that is done when ctx is, or once Config.MaxRuntime is up.
+func (m *GlobusTaskMonitor) withMaxRuntime(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.config.MaxRuntime <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, m.config.MaxRuntime)
+}
+
+func (m *GlobusTaskMonitor) retrieveAndProcessUploads(c context.Context) (result PassResult) {
+	m.passMu.Lock()
+	defer m.passMu.Unlock()
+
+	c, span := m.startSpan(c, "globus.pass")
+	defer func() {
+		span.SetAttribute("tasks_seen", result.TasksSeen)
+		span.SetAttribute("tasks_processed", result.TasksProcessed)
+		span.End()
+	}()
+
+	passStart := m.now()
+	m.recordPassStart(passStart)
+	clean := false
+	defer func() {
+		m.reportWatermarkLag()
+		m.checkCaughtUp(clean)
+	}()
+
+	// Build a filter to get all successful tasks that completed within the lookback window. When the
+	// client supports it only the tasks that changed since the last pass are returned.
+	since := m.inFilterTimeZone(m.completionFilterStart(passStart)).Format("2006-01-02")
 	taskFilter := map[string]string{
-		"filter_completion_time": lastWeek,
+		"filter_completion_time": since,
 		"filter_status":          "SUCCEEDED",
 	}
-	tasks, err := m.client.GetEndpointTaskList(m.endpointID, taskFilter)
+	tasks, marker, err := m.listPassTasks(taskFilter)
 
 	if err != nil {
-		log.Infof("globus.GetEndpointTaskList returned the following error: %s - %#v", err, m.client.GetGlobusErrorResponse())
-		return
+		m.handleGlobusError("GetEndpointTaskList", err)
+		return result
 	}
 
-	for _, task := range tasks.Tasks {
-		//log.Infof("Getting successful transfers for Globus Task %s", task.TaskID)
-		transfers, err := m.client.GetTaskSuccessfulTransfers(task.TaskID, 0)
+	m.measureClockSkew(tasks, passStart)
+	result.TasksSeen = len(tasks)
 
-		switch {
-		case err != nil:
-			log.Infof("globus.GetTaskSuccessfulTransfers(%d) returned error %s - %#v", task.TaskID, err, m.client.GetGlobusErrorResponse())
+	// Without the database nothing can be processed or recorded, so the tasks are left for a later
+	// pass and neither the watermark nor the task marker is moved.
+	if !m.dbAvailable(c) {
+		result.Paused = true
+		return result
+	}
+
+	allHandled := true
+	touched := make(projectRefs)
+	var toFetch []globus.Task
+	for _, task := range tasks {
+		task, ok := m.checkCompletionTime(task)
+		if !ok {
+			m.incCounter("tasks_skipped", Labels{"reason": "missing_completion_time"})
 			continue
-		case len(transfers.Transfers) == 0:
-			// No files transferred in this request
+		}
+
+		if !m.passesTaskFilters(task) {
+			m.incCounter("tasks_skipped", Labels{"reason": "task_filter"})
 			continue
-		default:
-			// Files were transferred for this request
-			m.processTransfers(&transfers)
 		}
 
-		// Check if we should stop processing requests
-		select {
-		case <-c.Done():
+		toFetch = append(toFetch, task)
+	}
+
+	// The tasks are processed a page at a time. Once a page is done the watermark is moved up to the
+	// earliest completion time of the tasks that still need processing, so if the monitor stops part
+	// way through a pass it starts again from no later than the first task it didn't finish.
+	pageSize := m.taskPageSize()
+	var unhandled []globus.Task
+	fetches := m.fetchTransfers(c, toFetch)
+	for i := range toFetch {
+		fetch := fetches.get(i)
+		if !m.processFetchedTask(c, fetch, touched, &result, false) {
+			allHandled = false
+			unhandled = append(unhandled, fetch.task)
+		}
+
+		if (i+1)%pageSize == 0 || i == len(toFetch)-1 {
+			m.advanceWatermarkToPending(m.compensateClockSkew(passStart), append(unhandled, toFetch[i+1:]...))
+		}
+
+		// Stop once the pass is cancelled. The watermark isn't moved past the tasks that are left, so
+		// the next pass picks them up.
+		if c.Err() != nil && i < len(toFetch)-1 {
+			allHandled = false
 			break
-		default:
 		}
 	}
+
+	if allHandled {
+		m.advanceWatermark(m.compensateClockSkew(passStart))
+		m.taskMarker = marker
+		clean = true
+	}
+
+	result.TouchedProjects = touched.sorted()
+
+	return result
+}
+
+// processFetchedTask processes a task once its transfers have been fetched, adding what happened to
+// result. It returns false if the task couldn't be handled and needs to be looked at again. When
+// reprocess is true the task is processed even if it has been already.
+func (m *GlobusTaskMonitor) processFetchedTask(ctx context.Context, fetch transferFetch, touched projectRefs, result *PassResult, reprocess bool) bool {
+	task, transfers := fetch.task, fetch.transfers
+	ctx, span := m.startSpan(ctx, "globus.task")
+	defer span.End()
+	span.SetAttribute("task_id", task.TaskID)
+	span.SetAttribute("bytes_transferred", task.BytesTransferred)
+
+	switch {
+	case errors.Is(fetch.err, errTransferPagesDeferred):
+		return false
+	case errors.Is(fetch.err, errFetchNotStarted) || (ctx.Err() != nil && errors.Is(fetch.err, ctx.Err())):
+		// The pass was cancelled before the transfers were fetched, which isn't a Globus error.
+		return false
+	case fetch.err != nil:
+		m.handleGlobusError("GetTaskSuccessfulTransfers", fetch.err)
+		return false
+	case len(transfers.Transfers) == 0:
+		// No files transferred in this request
+		m.logger.Debugf("Globus task %s succeeded without transferring any files", task.TaskID)
+		result.TasksEmpty++
+	default:
+		// Files were transferred for this request
+		if !m.acquireProcessingSlot(ctx) {
+			return false
+		}
+		defer m.releaseProcessingSlot()
+
+		// The files skipped by a task that is retried are reported when it is processed.
+		var skipped skippedFiles
+		outcome := m.processTransfers(ctx, task, &transfers, touched, &skipped, reprocess)
+		if outcome != taskRetry {
+			result.SkippedFiles = append(result.SkippedFiles, skipped...)
+		}
+
+		switch outcome {
+		case taskProcessed:
+			result.TasksProcessed++
+			if hasFileFailures(task) {
+				result.TasksWithFailures++
+				m.reportFileFailures(task)
+			}
+		case taskRetry:
+			return false
+		}
+	}
+
+	return true
+}
+
+// taskOutcome is what became of a task given to processTransfers.
+type taskOutcome int
+
+const (
+	// taskSkipped means there was nothing to do for the task, for example because it was a download
+	// or had already been processed.
+	taskSkipped taskOutcome = iota
+
+	// taskProcessed means the task was an upload and its files were handed on to be loaded.
+	taskProcessed
+
+	// taskRetry means the task couldn't be processed this time, usually because the database
+	// couldn't be reached, and needs to be looked at again on the next pass.
+	taskRetry
+)
+
+// handleGlobusError logs an error returned by a call to Globus and counts it by kind. Authentication
+// errors need someone to fix the credentials, so they are also sent to the ErrorSink.
+func (m *GlobusTaskMonitor) handleGlobusError(call string, err error) {
+	globusErr := m.client.ExtractError(err)
+	switch {
+	case globusErr.IsAuthError():
+		m.incCounter("globus_errors", Labels{"call": call, "kind": "auth"})
+		m.notifyError(ErrorEvent{
+			Kind:   "globus_auth_failed",
+			Err:    globusErr,
+			Fields: log.Fields{"call": call},
+		})
+	case globusErr.IsTransient():
+		m.incCounter("globus_errors", Labels{"call": call, "kind": "transient"})
+		m.logger.Infof("globus.%s returned a transient error: %s", call, globusErr)
+	default:
+		m.incCounter("globus_errors", Labels{"call": call, "kind": "other"})
+		m.logger.Infof("globus.%s returned the following error: %s", call, globusErr)
+	}
+}
+
+// hasFileFailures returns true if some of the files in a task failed to transfer even though the
+// task as a whole succeeded. Skipped files aren't failures, Globus skips files that are already
+// up to date at the destination.
+func hasFileFailures(task globus.Task) bool {
+	return task.Faults > 0 || task.FilesCount > task.FilesTransferred+task.FilesSkipped
+}
+
+// reportFileFailures counts a processed task that had files fail to transfer, and when
+// WithReportFileFailures is set sends it to the ErrorSink so someone can follow up.
+func (m *GlobusTaskMonitor) reportFileFailures(task globus.Task) {
+	m.incCounter("tasks_with_file_failures", nil)
+	m.logger.Warnf("Globus task %s transferred %d of %d files (%d faults)", task.TaskID, task.FilesTransferred, task.FilesCount, task.Faults)
+
+	if !m.config.ReportFileFailures {
+		return
+	}
+
+	m.notifyError(ErrorEvent{
+		Kind: "task_file_failures",
+		Err:  fmt.Errorf("globus task %s had files that failed to transfer", task.TaskID),
+		Fields: log.Fields{
+			"task_id":           task.TaskID,
+			"files":             task.FilesCount,
+			"files_transferred": task.FilesTransferred,
+			"files_skipped":     task.FilesSkipped,
+			"faults":            task.Faults,
+		},
+	})
+}
+
+// passesTaskFilters returns true if the task passes all the filters added with WithTaskFilter.
+func (m *GlobusTaskMonitor) passesTaskFilters(task globus.Task) bool {
+	for _, filter := range m.taskFilters {
+		if !filter(task) {
+			return false
+		}
+	}
+
+	return true
 }
 
-func (m *GlobusTaskMonitor) processTransfers(transfers *globus.TransferItems) {
+// processTransfers processes the transfers for a single task. It returns taskProcessed if the task
+// was an upload that hadn't been seen before, or reprocess is true, in which case the upload's project
+// is added to touched. It returns taskRetry when the upload couldn't be processed this time round. A
+// task that wrote into more than one upload is handled according to Config.MixedUploadPolicy.
+func (m *GlobusTaskMonitor) processTransfers(ctx context.Context, task globus.Task, transfers *globus.TransferItems, touched projectRefs, skipped *skippedFiles, reprocess bool) taskOutcome {
 	transferItem := transfers.Transfers[0]
 
-	// Transfer items with a blank DestinationPath are downloads not uploads.
+	// Transfer items with a blank DestinationPath are downloads not uploads. The SourcePath tells us
+	// who downloaded from which project.
 	if transferItem.DestinationPath == "" {
-		return
+		download := mcpath.ToTransferPathContextFromSource(transferItem.SourcePath)
+		m.logger.Debugf("Globus download of %d files by user %d from project %d", len(transfers.Transfers), download.UserID, download.ProjectID)
+		return taskSkipped
 	}
 
-	// Destination path will have the following format: /__globus_uploads/<id of upload request>/...rest of path...
-	// Split will return ["", "__globus_uploads", "<id of upload request", ....]
-	// So the 3rd entry in the array is the id in the globus_uploads table we want to look up.
-	pieces := strings.Split(transferItem.DestinationPath, "/")
-	if len(pieces) < 4 {
-		// sanity check, because the destination path should at least be /__globus_uploads/<id>/...rest of path...
-		// it should at least have 4 entries in it (See Split return description above)
-		log.Infof("Invalid globus DestinationPath: %s", transferItem.DestinationPath)
-		return
+	// Only act on uploads that were written to our endpoint.
+	if task.DestinationEndpointID != m.endpointID {
+		m.logger.Infof("Skipping globus task %s: destination endpoint %s is not %s", task.TaskID, task.DestinationEndpointID, m.endpointID)
+		m.incCounter("tasks_skipped", Labels{"reason": "destination_endpoint_mismatch"})
+		return taskSkipped
+	}
+
+	// A copy from our endpoint back onto the same paths doesn't change anything.
+	if isLoopbackTransfer(task, transfers.Transfers) {
+		m.logger.Infof("Skipping globus task %s: files were copied onto themselves", task.TaskID)
+		m.incCounter("tasks_skipped", Labels{"reason": "loopback"})
+		return taskSkipped
+	}
+
+	// Archive tasks write into the transfers tree with their own layout, and are handled separately.
+	if isArchiveDestination(transferItem.DestinationPath) {
+		return m.processArchiveTransfers(task, transfers, touched, skipped, reprocess)
+	}
+
+	// A transfer lists the project directories it creates along with its files.
+	files, dirs := splitDirectoryOnlyTransfers(transfers.Transfers)
+	for _, transfer := range dirs {
+		m.logger.Debugf("Skipping %s in globus task %s: it is a project directory, not a file", transfer.DestinationPath, task.TaskID)
+	}
+
+	if len(files) == 0 {
+		m.logger.Infof("Skipping globus task %s: it only has project directories", task.TaskID)
+		m.incCounter("tasks_skipped", Labels{"reason": "directory_only"})
+		return taskSkipped
 	}
 
-	id := pieces[2] // id is the 3rd entry in the path
-	if _, ok := m.finishedGlobusTasks[id]; ok {
+	groups, err := groupTransfersByUpload(files)
+	if err != nil {
+		m.logger.Infof("Skipping globus task %s: %s", task.TaskID, err)
+		m.incCounter("tasks_skipped", Labels{"reason": "invalid_path"})
+		return taskSkipped
+	}
+
+	if len(groups) > 1 {
+		return m.processMixedUploads(ctx, task, groups, touched, skipped, reprocess)
+	}
+
+	return m.processUpload(ctx, task, groups[0].id, groups[0].transfers, touched, skipped, reprocess)
+}
+
+// processUpload processes the transfers a task made into the globus upload with the given id. It
+// returns taskProcessed if the upload hadn't been processed before, or reprocess is true, in which case
+// the upload's project is added to touched. It returns taskRetry when the upload couldn't be processed
+// this time round.
+func (m *GlobusTaskMonitor) processUpload(ctx context.Context, task globus.Task, id string, transfers []globus.Transfer, touched projectRefs, skipped *skippedFiles, reprocess bool) taskOutcome {
+	if !reprocess && m.isFinished(id) {
 		// We've seen this globus task before and already processed it
-		return
+		return taskSkipped
+	}
+
+	globusUpload, err := m.globusUploads.GetGlobusUpload(id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		m.handleMissingUpload(id, task)
+		return taskSkipped
+	case err != nil:
+		// (Hopefully) transient error on database, the task will be retried on the next pass
+		m.logger.Errorf("Unable to look up globus upload %s: %s", id, err)
+		return taskRetry
 	}
 
-	//globusUpload, err := m.globusUploads.GetGlobusUpload(id)
-	//if err != nil {
-	//	// If we find a Globus task, but no corresponding entry in our database that means at some
-	//	// earlier point in time we processed the task by turning it into a file load request and
-	//	// deleting globus upload from our database. So this is an old reference we can just ignore.
-	//	// Add the entry to our hash table of completed requests.
-	//	m.finishedGlobusTasks[id] = true
-	//	return
-	//}
+	// There is nothing to load the files into if the project was deleted after the upload completed.
+	switch exists, checked := m.checkProjectExists(task, globusUpload.ProjectID); {
+	case !checked:
+		return taskRetry
+	case !exists:
+		m.incCounter("tasks_skipped", Labels{"reason": "project_deleted"})
+		m.markFinished(id, task, globusUpload.OwnerID)
+		return taskSkipped
+	}
+
+	// Another upload to the same project may be being processed, possibly by the monitor for another
+	// endpoint, so wait for it to finish.
+	if !m.projectLocks.lock(ctx, globusUpload.ProjectID) {
+		return taskRetry
+	}
+	defer m.projectLocks.unlock(globusUpload.ProjectID)
 
 	// At this point we have a globus upload. What we are going to do is remove the ACL on the directory
 	// so no more files can be uploaded to it. Then we are going to add that directory to the list of
@@ -123,29 +617,287 @@ func (m *GlobusTaskMonitor) processTransfers(transfers *globus.TransferItems) {
 	// the meantime since we've now created a file load from this globus upload we can delete the entry
 	// from the globus_uploads table. Finally we are going to update the status for this background process.
 
-	log.Infof("Processing globus upload %s", id)
+	files, err := resolveUploadFiles(transfers, m.config.DuplicateNamePolicy, m.config.PathNormalizer, m.config.CaseInsensitivePaths)
+	if err != nil {
+		// Only remember this in memory, so the upload is reported once but can still be processed
+		// by a restarted monitor once the problem has been dealt with.
+		m.finishedGlobusTasks[id] = true
+		m.notifyError(ErrorEvent{
+			Kind:   "duplicate_file_name",
+			Err:    err,
+			Fields: log.Fields{"globus_upload_id": id, "task_id": task.TaskID},
+		})
+		return taskSkipped
+	}
+
+	if err := m.removeReplacedFiles(ctx, globusUpload, files); err != nil {
+		// The upload isn't marked as finished, so it will be retried on the next pass.
+		m.logger.Errorf("Unable to remove the replaced files of globus upload %s: %s", id, err)
+		return taskRetry
+	}
+
+	total := len(files)
+	files = m.excludeFiles(id, task, globusUpload, files, skipped)
+	allExcluded := total != 0 && len(files) == 0
+	files = m.applyZeroByteFilePolicy(id, task, globusUpload, files, skipped)
+	uploaded := len(files)
+	files = m.skipUnchangedFiles(id, task, globusUpload, files, skipped)
+
+	m.logger.Infof("Processing globus upload %s (%d files) for project %d", id, len(files), globusUpload.ProjectID)
+	setSpanAttribute(ctx, "user_id", globusUpload.OwnerID)
+	setSpanAttribute(ctx, "project_id", globusUpload.ProjectID)
+
+	// When every file is excluded, or already in the project, there is nothing for the file loader to do.
+	if allExcluded || (uploaded != 0 && len(files) == 0) {
+		if !m.deleteUploadACL(id, task, globusUpload) && m.config.ProcessingOrder == ProcessingOrderLoadFirst {
+			return taskRetry
+		}
+
+		if allExcluded {
+			m.logger.Infof("Skipping file load for globus upload %s: all %d files are excluded", id, total)
+			m.incCounter("tasks_skipped", Labels{"reason": "excluded"})
+		} else {
+			m.logger.Infof("Skipping file load for globus upload %s: all %d files are unchanged", id, uploaded)
+			m.incCounter("tasks_skipped", Labels{"reason": "unchanged"})
+		}
+		m.markFinished(id, task, globusUpload.OwnerID)
+		return taskSkipped
+	}
+
+	if m.config.ProcessingOrder == ProcessingOrderACLFirst {
+		m.deleteUploadACL(id, task, globusUpload)
+	}
+
+	if err := m.placeUploadFiles(ctx, globusUpload, files); err != nil {
+		// The upload isn't marked as finished, so it will be retried on the next pass.
+		m.logger.Errorf("Unable to place the files of globus upload %s: %s", id, err)
+		return taskRetry
+	}
+
+	fileLoad := &FileLoad{
+		ProjectID:         globusUpload.ProjectID,
+		OwnerID:           globusUpload.OwnerID,
+		Path:              globusUpload.Path,
+		GlobusUploadID:    globusUpload.ID,
+		TaskReference:     FormatTaskReference(task),
+		SubmitterIdentity: task.OwnerID,
+	}
+
+	uploadCtx, done := m.startUpload(ctx, id)
+	defer done()
+
+	// Pace the file loads so a backlog of uploads doesn't swamp the file loader.
+	err = m.fileLoadLimiter.Wait(uploadCtx)
+	if err == nil {
+		err = m.withDBRetry(uploadCtx, "add_file_load", func() error {
+			return m.addFileLoad(uploadCtx, fileLoad)
+		})
+	}
+
+	if err != nil {
+		// The upload isn't marked as finished, so it will be retried on the next pass.
+		switch {
+		case ctx.Err() == nil && uploadCtx.Err() != nil:
+			m.logger.Infof("Processing of globus upload %s was cancelled", id)
+		case ctx.Err() != nil:
+			m.logger.Errorf("Unable to add file load request for globus upload %s: %s", id, err)
+		default:
+			m.notifyError(ErrorEvent{
+				Kind:   "file_load_failed",
+				Err:    fmt.Errorf("unable to add file load request for globus upload %s: %w", id, err),
+				Fields: log.Fields{"globus_upload_id": id, "task_id": task.TaskID, "project_id": globusUpload.ProjectID},
+			})
+		}
+		return taskRetry
+	}
+
+	m.logger.WithFields(log.Fields{"globus_upload_id": id, "task_id": task.TaskID, "upload": "new"}).
+		Infof("Created file load (id: %d) for globus upload %s", fileLoad.ID, id)
 
-	//if _, err := m.client.DeleteEndpointACLRule(m.endpointID, globusUpload.GlobusAclID); err != nil {
-	//	log.Infof("Unable to delete ACL: %s", err)
-	//}
+	if !m.verifyFileLoadCreated(uploadCtx, id, task, fileLoad) {
+		return taskRetry
+	}
 
-	//flAdd := model.AddFileLoadModel{
-	//	ProjectID:      globusUpload.ProjectID,
-	//	Owner:          globusUpload.Owner,
-	//	Path:           globusUpload.Path,
-	//	GlobusUploadID: globusUpload.ID,
-	//}
+	// The file load is kept, and found again on the next pass, which tries the ACL again.
+	if m.config.ProcessingOrder == ProcessingOrderLoadFirst && !m.deleteUploadACL(id, task, globusUpload) {
+		return taskRetry
+	}
 
-	//if fl, err := m.fileLoads.AddFileLoad(flAdd); err != nil {
-	//	log.Infof("Unable to add file load request: %s", err)
-	//	return
-	//} else {
-	//	log.Infof("Created file load (id: %s) for globus upload %s", fl.ID, id)
-	//}
+	files = m.detectMovedDirectories(id, task, globusUpload, files, skipped)
+	m.markFinished(id, task, globusUpload.OwnerID)
+	m.incCounter("uploads_new_processed", nil)
+	m.scheduleLoadVerification(id, fileLoad, files)
+	m.updateUploadSummary(globusUpload.ProjectID, len(files), int64(task.BytesTransferred))
+	touched.add(globusUpload.OwnerID, globusUpload.ProjectID)
+	m.publishUploadEvent(ctx, UploadEvent{
+		GlobusUploadID: id,
+		TaskID:         task.TaskID,
+		FileLoadID:     fileLoad.ID,
+		ProjectID:      globusUpload.ProjectID,
+		OwnerID:        globusUpload.OwnerID,
+		Path:           globusUpload.Path,
+		Files:          len(files),
+		ProcessedAt:    m.now(),
+	})
 
 	// Delete the globus upload request as we have now turned it into a file loading request
 	// and won't have to process this request again. If the server stops while loading the
 	// request or there is some other failure, the file loader will take care of picking up
-	// where it left off.
-	//m.globusUploads.DeleteGlobusUpload(id)
+	// where it left off. This is only reached once the file load was verified, see
+	// verifyFileLoadCreated, so the upload is never deleted without a file load to replace it.
+	m.deleteGlobusUpload(ctx, id, task)
+
+	return taskProcessed
+}
+
+// deleteGlobusUpload deletes the globus_uploads entry for an upload that has been turned into a file
+// load. The upload is already marked as finished, so failing to delete it is only logged and counted
+// in globus_upload_delete_failures. A later pass skips it as finished, or as already loaded once the
+// entry is gone.
+func (m *GlobusTaskMonitor) deleteGlobusUpload(ctx context.Context, id string, task globus.Task) {
+	err := m.withDBRetry(ctx, "delete_globus_upload", func() error {
+		return m.globusUploads.DeleteGlobusUpload(id)
+	})
+	if err != nil {
+		m.incCounter("globus_upload_delete_failures", nil)
+		m.logger.WithFields(log.Fields{"globus_upload_id": id, "task_id": task.TaskID}).
+			Errorf("Unable to delete globus upload %s: %s", id, err)
+	}
+}
+
+// handleMissingUpload deals with a globus task whose upload has no corresponding entry in our
+// database. Normally that means at some earlier point in time we processed the task by turning it
+// into a file load request and deleting the globus upload from our database, so it's an old reference
+// we can ignore. Either way the upload is marked as finished so it is only looked at once. These are
+// counted in uploads_already_loaded_ignored, apart from the uploads_new_processed that are new work.
+func (m *GlobusTaskMonitor) handleMissingUpload(id string, task globus.Task) {
+	m.markFinished(id, task, 0)
+	m.incCounter("uploads_already_loaded_ignored", nil)
+	m.logger.WithFields(log.Fields{"globus_upload_id": id, "task_id": task.TaskID, "upload": "already_loaded"}).
+		Infof("Ignoring globus upload %s: it has no globus_uploads entry, so it was loaded earlier", id)
+
+	if m.config.MissingUploadBehavior == MissingUploadNotify {
+		m.notifyError(ErrorEvent{
+			Kind:   "missing_globus_upload",
+			Err:    fmt.Errorf("no globus_uploads entry for upload %s", id),
+			Fields: log.Fields{"globus_upload_id": id, "task_id": task.TaskID},
+		})
+	}
+}
+
+// isLoopbackTransfer returns true if the task copied files from an endpoint back to the same endpoint,
+// with every file's source and destination parsing to the same TransferPathContext. Such a task is a
+// no-op, usually an accidental copy, and loading its files would only make new versions of them.
+func isLoopbackTransfer(task globus.Task, transfers []globus.Transfer) bool {
+	if task.SourceEndpointID == "" || task.SourceEndpointID != task.DestinationEndpointID {
+		return false
+	}
+
+	for _, transfer := range transfers {
+		source := mcpath.ToTransferPathContext(transfer.SourcePath)
+		destination := mcpath.ToTransferPathContext(transfer.DestinationPath)
+		if *source != *destination {
+			return false
+		}
+	}
+
+	return len(transfers) > 0
+}
+
+// isArchiveDestination returns true if path is in the archive layout (see mcpath.ArchiveTransferType).
+func isArchiveDestination(path string) bool {
+	return strings.HasPrefix(path, "/"+mcpath.TransfersRoot+"/") &&
+		mcpath.ToTransferPathContext(path).TransferType == mcpath.ArchiveTransferType
+}
+
+// isDirectoryOnlyDestination returns true if path is the directory of a project in the transfers
+// tree, such as /__transfers/globus/1/2, rather than a file in it. Globus lists the directory when a
+// transfer creates it, and it has nothing to load.
+func isDirectoryOnlyDestination(path string) bool {
+	if !strings.HasPrefix(path, "/"+mcpath.TransfersRoot+"/") {
+		return false
+	}
+
+	_, rel, ok := mcpath.SplitProjectPath(path)
+	return ok && rel == ""
+}
+
+// splitDirectoryOnlyTransfers splits transfers into the files and the directory only entries.
+func splitDirectoryOnlyTransfers(transfers []globus.Transfer) (files, dirs []globus.Transfer) {
+	for _, transfer := range transfers {
+		if isDirectoryOnlyDestination(transfer.DestinationPath) {
+			dirs = append(dirs, transfer)
+		} else {
+			files = append(files, transfer)
+		}
+	}
+
+	return files, dirs
+}
+
+// uploadIDFromDestination returns the id of the globus upload a destination path was written to.
+// Files in the transfers tree are written by the bridge for a transfer request rather than into a
+// globus upload, so they have no upload id and false is returned for them.
+func uploadIDFromDestination(path string) (string, bool) {
+	if strings.HasPrefix(path, "/"+mcpath.TransfersRoot+"/") {
+		return "", false
+	}
+
+	// Destination path will have the following format: /__globus_uploads/<id of upload request>/...rest of path...
+	// Split will return ["", "__globus_uploads", "<id of upload request", ....]
+	// So the 3rd entry in the array is the id in the globus_uploads table we want to look up.
+	pieces := strings.Split(path, "/")
+	if len(pieces) < 4 {
+		// sanity check, because the destination path should at least be /__globus_uploads/<id>/...rest of path...
+		// it should at least have 4 entries in it (See Split return description above)
+		return "", false
+	}
+
+	return pieces[2], true // id is the 3rd entry in the path
+}
+
+// isFinished returns true if the globus upload has already been processed, either by this monitor or
+// by an earlier run that recorded it in the processed uploads table.
+func (m *GlobusTaskMonitor) isFinished(id string) bool {
+	if m.finishedGlobusTasks[id] {
+		return true
+	}
+
+	processed, err := m.processedUploads.IsProcessed(id)
+	if err != nil {
+		m.logger.Errorf("Unable to check if globus upload %s was processed: %s", id, err)
+		return false
+	}
+
+	if processed {
+		m.finishedGlobusTasks[id] = true
+	}
+
+	return processed
+}
+
+// markFinished records that the globus upload, owned by ownerID, has been processed by task. An
+// ownerID of 0 means the owner isn't known.
+func (m *GlobusTaskMonitor) markFinished(id string, task globus.Task, ownerID int) {
+	m.finishedGlobusTasks[id] = true
+
+	upload := &ProcessedGlobusUpload{
+		GlobusUploadID:    id,
+		TaskID:            task.TaskID,
+		OwnerID:           ownerID,
+		SubmitterIdentity: task.OwnerID,
+		ProcessedAt:       m.now(),
+	}
+
+	// The completion time is Globus', so the latency is measured against now in Globus' time. It can't
+	// be negative, which a clock that is behind and not compensated for would otherwise make it.
+	if completed, ok := parseGlobusTime(task.CompletionTime); ok {
+		upload.CompletionTime = &completed
+		if latency := m.compensateClockSkew(upload.ProcessedAt).Sub(completed); latency > 0 {
+			upload.LatencySeconds = latency.Seconds()
+		}
+	}
+	if err := m.processedUploads.AddProcessedUpload(upload); err != nil {
+		m.logger.Errorf("Unable to record processed globus upload %s: %s", id, err)
+	}
 }
@@ -0,0 +1,536 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMonitor creates a monitor against the fake client that polls without any delay between passes.
+func newTestMonitor(client *FakeGlobusClient, opts ...Option) *GlobusTaskMonitor {
+	m := NewGlobusTaskMonitor(client, nil, testEndpointID, opts...)
+	m.config.PollInterval = time.Millisecond
+	m.processedUploads = newFakeProcessedUploadStore()
+	m.globusUploads = newFakeGlobusUploadStore()
+	m.fileLoads = &fakeFileLoadStore{}
+	m.projects = newFakeProjectStore()
+	m.projectFiles = newFakeProjectFileStore()
+	m.heartbeats = newFakeHeartbeatStore()
+	return m
+}
+
+// runUntilStopped runs the monitor loop and fails the test if it doesn't exit on its own.
+func runUntilStopped(t *testing.T, m *GlobusTaskMonitor) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		m.monitorAndProcessTasks(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("monitor did not stop")
+	}
+}
+
+func TestStopAfterIdlePasses(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client, WithStopAfterIdlePasses(3))
+	seedGlobusUploads(m, 1)
+
+	runUntilStopped(t, m)
+
+	// The first pass processes task-1, then it takes 3 idle passes to stop.
+	require.Equal(t, 4, client.TaskListCalls)
+	require.True(t, m.finishedGlobusTasks["1"])
+}
+
+func TestStopAfterIdlePassesWithNoTasks(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithStopAfterIdlePasses(2))
+
+	runUntilStopped(t, m)
+
+	require.Equal(t, 2, client.TaskListCalls)
+}
+
+func TestRunsUntilCancelledByDefault(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client)
+	require.Equal(t, 0, m.config.StopAfterIdlePasses)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	m.monitorAndProcessTasks(ctx)
+
+	require.Greater(t, client.TaskListCalls, 2)
+}
+
+func TestStopAfterMaxRuntime(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithMaxRuntime(50*time.Millisecond))
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+
+	// Every pass has a new upload, so there is always work to do. The hook runs with the client
+	// locked, so the task is added directly.
+	client.BeforeTaskListPage = func(call int) {
+		uploads.add(&GlobusUpload{ID: call, ProjectID: 1, OwnerID: 1})
+		client.Tasks = append(client.Tasks, globus.Task{
+			TaskID:         fmt.Sprintf("task-%d", call),
+			Status:         "SUCCEEDED",
+			CompletionTime: testCompletionTime,
+			TaskExtras:     globus.TaskExtras{DestinationEndpointID: testEndpointID},
+		})
+		client.Transfers[fmt.Sprintf("task-%d", call)] = []globus.Transfer{{DestinationPath: fmt.Sprintf("/__globus_uploads/%d/file.txt", call)}}
+	}
+
+	runUntilStopped(t, m)
+
+	// Each pass is finished before stopping, so every upload listed was loaded.
+	require.Greater(t, client.TaskListCalls, 2)
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, client.TaskListCalls)
+}
+
+func TestMaxRuntimeFinishesPassUnderWay(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.Script("GetTaskSuccessfulTransfers", FakeCall{Latency: 100 * time.Millisecond})
+	m := newTestMonitor(client, WithMaxRuntime(20*time.Millisecond))
+	seedGlobusUploads(m, 1)
+
+	runUntilStopped(t, m)
+
+	// The max runtime was up part way through the first pass, which still loaded the upload.
+	require.Equal(t, 1, client.TaskListCalls)
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+	require.True(t, m.isFinished("1"))
+}
+
+func TestSkipsTasksForOtherDestinationEndpoints(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.Tasks = append(client.Tasks, globus.Task{TaskID: "task-2", Status: "SUCCEEDED", CompletionTime: testCompletionTime, TaskExtras: globus.TaskExtras{DestinationEndpointID: "other-endpoint"}})
+	client.Transfers["task-2"] = []globus.Transfer{{DestinationPath: "/__globus_uploads/2/file.txt"}}
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+	seedGlobusUploads(m, 1, 2)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+
+	require.Equal(t, 1, result.TasksProcessed)
+	require.True(t, m.finishedGlobusTasks["1"])
+	require.False(t, m.finishedGlobusTasks["2"])
+	labels := Labels{"endpoint": testEndpointID, "reason": "destination_endpoint_mismatch"}
+	require.Equal(t, 1.0, metrics.counter("tasks_skipped", labels))
+}
+
+func TestMissingUploadBehavior(t *testing.T) {
+	tests := []struct {
+		name           string
+		behavior       MissingUploadBehavior
+		expectedEvents int
+	}{
+		{name: "ignore", behavior: MissingUploadIgnore, expectedEvents: 0},
+		{name: "notify", behavior: MissingUploadNotify, expectedEvents: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+			sink := &fakeErrorSink{}
+			m := newTestMonitor(client, WithMissingUploadBehavior(test.behavior), WithErrorSink(sink))
+
+			// Upload 1 isn't in the store, so it's missing. Run two passes to show that it's
+			// only reported once.
+			require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+			require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+
+			require.True(t, m.finishedGlobusTasks["1"])
+			require.Len(t, sink.events, test.expectedEvents)
+			if test.expectedEvents != 0 {
+				require.Equal(t, "missing_globus_upload", sink.events[0].Kind)
+				require.Equal(t, "1", sink.events[0].Fields["globus_upload_id"])
+				require.Equal(t, "task-1", sink.events[0].Fields["task_id"])
+			}
+		})
+	}
+}
+
+func TestNewAndAlreadyLoadedUploadsAreCounted(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+
+	// Upload 1 is new work. Upload 2 has no globus_uploads entry, so it was loaded before. Neither
+	// is counted again on the second pass.
+	seedGlobusUploads(m, 1)
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+
+	labels := Labels{"endpoint": testEndpointID}
+	require.Equal(t, 1.0, metrics.counter("uploads_new_processed", labels))
+	require.Equal(t, 1.0, metrics.counter("uploads_already_loaded_ignored", labels))
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+}
+
+func TestTaskFilters(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	client.Tasks[0].OwnerID = "maintenance"
+	client.Tasks[1].BytesTransferred = 10
+	client.Tasks[2].BytesTransferred = 1000
+	metrics := newMemoryMetrics()
+
+	notMaintenance := func(task globus.Task) bool { return task.OwnerID != "maintenance" }
+	largeTransfers := func(task globus.Task) bool { return task.BytesTransferred > 100 }
+	m := newTestMonitor(client, WithMetrics(metrics), WithTaskFilter(notMaintenance), WithTaskFilter(largeTransfers))
+	seedGlobusUploads(m, 1, 2, 3)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+
+	require.Equal(t, 3, result.TasksSeen)
+	require.Equal(t, 1, result.TasksProcessed)
+	require.True(t, m.finishedGlobusTasks["3"])
+
+	// Skipped tasks never have their transfers fetched.
+	require.Equal(t, []string{"task-3"}, client.TransfersFetched)
+	labels := Labels{"endpoint": testEndpointID, "reason": "task_filter"}
+	require.Equal(t, 2.0, metrics.counter("tasks_skipped", labels))
+}
+
+func TestGlobusErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		globusErr      *GlobusError
+		expectedKind   string
+		expectedEvents int
+	}{
+		{name: "auth status", globusErr: &GlobusError{StatusCode: 401}, expectedKind: "auth", expectedEvents: 1},
+		{name: "auth code", globusErr: &GlobusError{Code: "AuthenticationFailed"}, expectedKind: "auth", expectedEvents: 1},
+		{name: "server error", globusErr: &GlobusError{StatusCode: 500, Code: "ServiceUnavailable"}, expectedKind: "transient", expectedEvents: 0},
+		{name: "unknown", globusErr: &GlobusError{StatusCode: 400, Code: "BadRequest"}, expectedKind: "other", expectedEvents: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.TaskListErr = errors.New("request failed")
+			client.GlobusError = test.globusErr
+			sink := &fakeErrorSink{}
+			metrics := newMemoryMetrics()
+			m := newTestMonitor(client, WithErrorSink(sink), WithMetrics(metrics))
+
+			result := m.retrieveAndProcessUploads(context.Background())
+
+			require.Equal(t, PassResult{}, result)
+			labels := Labels{"endpoint": testEndpointID, "call": "GetEndpointTaskList", "kind": test.expectedKind}
+			require.Equal(t, 1.0, metrics.counter("globus_errors", labels))
+			require.Len(t, sink.events, test.expectedEvents)
+			if test.expectedEvents != 0 {
+				require.Equal(t, "globus_auth_failed", sink.events[0].Kind)
+				require.Equal(t, test.globusErr, sink.events[0].Err)
+			}
+		})
+	}
+}
+
+func TestTouchedProjects(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	client.AddUpload("task-4", "/__globus_uploads/4/file.txt")
+	client.AddUpload("task-5", "/__transfers/archive/3/30/c1/file.txt", "/__transfers/archive/3/31/c1/file.txt")
+	m := newTestMonitor(client)
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	uploads.add(&GlobusUpload{ID: 1, OwnerID: 2, ProjectID: 20})
+	uploads.add(&GlobusUpload{ID: 2, OwnerID: 1, ProjectID: 10})
+	uploads.add(&GlobusUpload{ID: 3, OwnerID: 2, ProjectID: 20})
+	uploads.add(&GlobusUpload{ID: 4, OwnerID: 1, ProjectID: 11})
+
+	result := m.retrieveAndProcessUploads(context.Background())
+
+	require.Equal(t, 5, result.TasksProcessed)
+	expected := []ProjectRef{
+		{UserID: 1, ProjectID: 10},
+		{UserID: 1, ProjectID: 11},
+		{UserID: 2, ProjectID: 20},
+		{UserID: 3, ProjectID: 30},
+		{UserID: 3, ProjectID: 31},
+	}
+	require.Equal(t, expected, result.TouchedProjects)
+
+	// Nothing new is processed on the next pass, so no projects were touched.
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Nil(t, result.TouchedProjects)
+}
+
+func TestEmptyTasks(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+
+	require.Equal(t, PassResult{TasksSeen: 2, TasksProcessed: 1, TasksEmpty: 1, TouchedProjects: []ProjectRef{{UserID: 1, ProjectID: 1}}}, result)
+}
+
+func TestTasksWithFileFailures(t *testing.T) {
+	tests := []struct {
+		name           string
+		report         bool
+		expectedEvents int
+	}{
+		{name: "not reported", report: false, expectedEvents: 0},
+		{name: "reported", report: true, expectedEvents: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+			client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+			client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+			client.Tasks[0].FilesCount, client.Tasks[0].FilesTransferred = 1, 1
+			client.Tasks[1].FilesCount, client.Tasks[1].FilesTransferred, client.Tasks[1].Faults = 3, 1, 2
+			client.Tasks[2].FilesCount, client.Tasks[2].FilesTransferred, client.Tasks[2].FilesSkipped = 3, 1, 2
+			sink := &fakeErrorSink{}
+			metrics := newMemoryMetrics()
+			opts := []Option{WithErrorSink(sink), WithMetrics(metrics)}
+			if test.report {
+				opts = append(opts, WithReportFileFailures())
+			}
+			m := newTestMonitor(client, opts...)
+			seedGlobusUploads(m, 1, 2, 3)
+
+			result := m.retrieveAndProcessUploads(context.Background())
+
+			// Only task-2 had failures, task-3 skipped files that were already up to date.
+			require.Equal(t, 3, result.TasksProcessed)
+			require.Equal(t, 1, result.TasksWithFailures)
+			require.Equal(t, 1.0, metrics.counter("tasks_with_file_failures", Labels{"endpoint": testEndpointID}))
+			require.Len(t, sink.events, test.expectedEvents)
+			if test.expectedEvents != 0 {
+				require.Equal(t, "task_file_failures", sink.events[0].Kind)
+				require.Equal(t, "task-2", sink.events[0].Fields["task_id"])
+			}
+
+			// The failures are only counted when the task is processed.
+			result = m.retrieveAndProcessUploads(context.Background())
+			require.Equal(t, 0, result.TasksWithFailures)
+		})
+	}
+}
+
+func TestFileLoadsReferenceTheTask(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.Tasks[0].Label = "run 12 images"
+	m := newTestMonitor(client)
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	uploads.add(&GlobusUpload{ID: 1, OwnerID: 10, ProjectID: 100, Path: "/run12"})
+	uploads.add(&GlobusUpload{ID: 2, OwnerID: 10, ProjectID: 100, Path: "/run13"})
+
+	m.retrieveAndProcessUploads(context.Background())
+
+	fileLoads := m.fileLoads.(*fakeFileLoadStore).fileLoads
+	require.Len(t, fileLoads, 2)
+	require.Equal(t, FileLoad{ID: 1, ProjectID: 100, OwnerID: 10, Path: "/run12", GlobusUploadID: 1, TaskReference: `globus task task-1 (label "run 12 images")`}, fileLoads[0])
+	require.Equal(t, "globus task task-2", fileLoads[1].TaskReference)
+}
+
+func TestRecursiveUploadHasOneDirectoryLoad(t *testing.T) {
+	var paths []string
+	for i := 0; i < 50; i++ {
+		paths = append(paths, fmt.Sprintf("/__globus_uploads/1/run/d%d/sub%d/file%d.txt", i%5, i%3, i))
+	}
+
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", paths...)
+	m := newTestMonitor(client)
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, OwnerID: 10, ProjectID: 100, Path: "/uploads/1"})
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore).fileLoads
+	require.Len(t, fileLoads, 1)
+	require.Equal(t, "/uploads/1", fileLoads[0].Path)
+}
+
+func TestSubmitterIdentityIsRecorded(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	client.Tasks[0].OwnerID = "6b1c7f9a-globus-identity"
+	client.Tasks[1].OwnerID = "e02d3c41-globus-identity"
+	m := newTestMonitor(client)
+	processed := m.processedUploads.(*fakeProcessedUploadStore)
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	uploads.add(&GlobusUpload{ID: 1, OwnerID: 10, ProjectID: 100})
+
+	m.retrieveAndProcessUploads(context.Background())
+
+	// The submitter is kept separate from the Materials Commons owner of the upload.
+	fileLoads := m.fileLoads.(*fakeFileLoadStore).fileLoads
+	require.Len(t, fileLoads, 1)
+	require.Equal(t, "6b1c7f9a-globus-identity", fileLoads[0].SubmitterIdentity)
+	require.Equal(t, 10, fileLoads[0].OwnerID)
+
+	// Upload 2 has no globus_uploads entry, but the audit row still records who submitted it.
+	require.Len(t, processed.uploads, 2)
+	require.Equal(t, "6b1c7f9a-globus-identity", processed.uploads[0].SubmitterIdentity)
+	require.Equal(t, "e02d3c41-globus-identity", processed.uploads[1].SubmitterIdentity)
+}
+
+func TestFileLoadFailuresAreRetried(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.err = errors.New("database down")
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.False(t, m.finishedGlobusTasks["1"])
+
+	fileLoads.err = nil
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+}
+
+func TestLoopbackTransferIsSkipped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/globus/1/2/d1/file.txt")
+	client.Tasks[0].SourceEndpointID = testEndpointID
+	client.Transfers["task-1"][0].SourcePath = "/__transfers/globus/1/2/d1//file.txt"
+	metrics := newMemoryMetrics()
+	fileLoads := &fakeFileLoadStore{}
+	m := newTestMonitor(client, WithMetrics(metrics))
+	m.fileLoads = fileLoads
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 1.0, metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "loopback"}))
+	require.Empty(t, fileLoads.fileLoads)
+}
+
+func TestProjectDirectoryTransferIsSkipped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/globus/1/2")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+
+	// The project directory isn't read as an upload with the id "globus".
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 1.0, metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "directory_only"}))
+	require.False(t, m.isFinished("globus"))
+	require.Empty(t, m.fileLoads.(*fakeFileLoadStore).fileLoads)
+}
+
+func TestProjectDirectoryIsLeftOutOfUpload(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client)
+	dir := writeUploadDir(t, map[string]string{"file.txt": "contents"})
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	client.AddUpload("task-1", "/__transfers/globus/1/2/", "/__globus_uploads/1/file.txt")
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.True(t, m.isFinished("1"))
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+}
+
+func TestTransfersTreeFileIsSkipped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/globus/1/2/", "/__transfers/globus/1/2/file.txt")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+
+	// The file isn't read as being in an upload with the id "globus".
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 1.0, metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "invalid_path"}))
+	require.False(t, m.isFinished("globus"))
+	require.Empty(t, m.fileLoads.(*fakeFileLoadStore).fileLoads)
+}
+
+func TestIsLoopbackTransfer(t *testing.T) {
+	task := globus.Task{TaskExtras: globus.TaskExtras{SourceEndpointID: testEndpointID, DestinationEndpointID: testEndpointID}}
+	same := []globus.Transfer{{SourcePath: "/__globus_uploads/1/file.txt", DestinationPath: "/__globus_uploads/1/file.txt"}}
+	moved := []globus.Transfer{
+		same[0],
+		{SourcePath: "/__transfers/globus/1/2/a.txt", DestinationPath: "/__transfers/globus/1/3/a.txt"},
+	}
+
+	require.True(t, isLoopbackTransfer(task, same))
+	require.False(t, isLoopbackTransfer(task, moved))
+	require.False(t, isLoopbackTransfer(task, nil))
+
+	// The same paths on a different endpoint are a real copy.
+	task.SourceEndpointID = testEndpointID2
+	require.False(t, isLoopbackTransfer(task, same))
+}
+
+func TestProcessedUploadRecordsLatency(t *testing.T) {
+	completed := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		now     time.Time
+		latency float64
+	}{
+		{name: "after completion", now: completed.Add(12*time.Minute + 30*time.Second), latency: 750},
+		{name: "clock behind globus", now: completed.Add(-time.Minute), latency: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+			m := newTestMonitor(client)
+			m.now = func() time.Time { return test.now }
+			seedGlobusUploads(m, 1)
+
+			require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+			uploads := m.processedUploads.(*fakeProcessedUploadStore).uploads
+			require.Len(t, uploads, 1)
+			require.NotNil(t, uploads[0].CompletionTime)
+			require.True(t, completed.Equal(*uploads[0].CompletionTime))
+			require.True(t, test.now.Equal(uploads[0].ProcessedAt))
+			require.Equal(t, test.latency, uploads[0].LatencySeconds)
+		})
+	}
+}
+
+func TestUploadDeletedOnceProcessed(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+	seedGlobusUploads(m, 1, 2)
+
+	require.Equal(t, 2, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.False(t, uploadExists(m, "1"))
+	require.False(t, uploadExists(m, "2"))
+
+	// A failed delete doesn't stop the upload from being finished.
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	seedGlobusUploads(m, 3)
+	m.globusUploads.(*fakeGlobusUploadStore).deleteErr = errors.New("delete failed")
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.True(t, m.isFinished("3"))
+	require.True(t, uploadExists(m, "3"))
+	require.Equal(t, float64(1), metrics.counter("globus_upload_delete_failures", Labels{"endpoint": testEndpointID}))
+}
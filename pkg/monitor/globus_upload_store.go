@@ -0,0 +1,56 @@
+package monitor
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GlobusUpload is a request to upload files into a project over Globus. The files are uploaded
+// into Path, and once the transfer completes the upload is turned into a file load.
+type GlobusUpload struct {
+	ID          int       `json:"id"`
+	UUID        string    `json:"uuid"`
+	Name        string    `json:"name"`
+	State       string    `json:"state"`
+	ProjectID   int       `json:"project_id"`
+	OwnerID     int       `json:"owner_id"`
+	Path        string    `json:"path"`
+	GlobusAclID string    `json:"globus_acl_id"`
+	GlobusPath  string    `json:"globus_path"`
+	GlobusURL   string    `json:"globus_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (GlobusUpload) TableName() string {
+	return "globus_uploads"
+}
+
+// GlobusUploadStore looks up GlobusUpload entries, and deletes them once they have been turned into
+// file loads. GetGlobusUpload returns gorm.ErrRecordNotFound when there is no upload with the given id.
+type GlobusUploadStore interface {
+	GetGlobusUpload(id string) (*GlobusUpload, error)
+	DeleteGlobusUpload(id string) error
+}
+
+type dbGlobusUploadStore struct {
+	db *gorm.DB
+}
+
+func newDBGlobusUploadStore(db *gorm.DB) *dbGlobusUploadStore {
+	return &dbGlobusUploadStore{db: db}
+}
+
+func (s *dbGlobusUploadStore) GetGlobusUpload(id string) (*GlobusUpload, error) {
+	var upload GlobusUpload
+	if err := s.db.Where("id = ?", id).First(&upload).Error; err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+func (s *dbGlobusUploadStore) DeleteGlobusUpload(id string) error {
+	return s.db.Where("id = ?", id).Delete(&GlobusUpload{}).Error
+}
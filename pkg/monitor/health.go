@@ -0,0 +1,52 @@
+package monitor
+
+import "time"
+
+// HealthStatus is a snapshot of the monitor's state for operators diagnosing a running monitor.
+type HealthStatus struct {
+	// Build is the build of the monitor that is running.
+	Build BuildInfo
+
+	// EndpointID is the endpoint the monitor watches.
+	EndpointID string
+
+	// Watermark is the time every task that completed before has been processed.
+	Watermark time.Time
+
+	// LastPass is when the most recent pass started. It is zero until the first pass.
+	LastPass time.Time
+
+	// InFlightUploads is the number of uploads currently being processed.
+	InFlightUploads int
+
+	// DBUnavailable is true while processing is paused because the database can't be reached, see
+	// WithPauseWhenDBUnavailable. The monitor isn't healthy while it is set.
+	DBUnavailable bool
+}
+
+// HealthStatus returns a snapshot of the monitor's state. It is safe to call while the monitor is
+// running.
+func (m *GlobusTaskMonitor) HealthStatus() HealthStatus {
+	m.statusMu.Lock()
+	status := HealthStatus{
+		Build:         GetBuildInfo(),
+		EndpointID:    m.endpointID,
+		Watermark:     m.lastProcessedTime,
+		LastPass:      m.lastPass,
+		DBUnavailable: m.dbUnavailable,
+	}
+	m.statusMu.Unlock()
+
+	m.inFlightMu.Lock()
+	status.InFlightUploads = len(m.inFlight)
+	m.inFlightMu.Unlock()
+
+	return status
+}
+
+// recordPassStart notes when a pass started for HealthStatus.
+func (m *GlobusTaskMonitor) recordPassStart(t time.Time) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	m.lastPass = t
+}
@@ -0,0 +1,27 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInfoDefaults(t *testing.T) {
+	require.Equal(t, BuildInfo{Version: "dev", Commit: "dev", BuildTime: "dev"}, GetBuildInfo())
+}
+
+func TestHealthStatus(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client)
+
+	status := m.HealthStatus()
+	require.Equal(t, GetBuildInfo(), status.Build)
+	require.Equal(t, testEndpointID, status.EndpointID)
+	require.True(t, status.LastPass.IsZero())
+
+	m.retrieveAndProcessUploads(context.Background())
+	status = m.HealthStatus()
+	require.False(t, status.LastPass.IsZero())
+	require.Equal(t, 0, status.InFlightUploads)
+}
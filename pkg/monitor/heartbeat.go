@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"gorm.io/gorm"
+)
+
+// ErrDuplicateMonitor is returned by Run when another monitor for the same endpoint is running and
+// the DuplicateMonitorPolicy is DuplicateMonitorRefuse.
+var ErrDuplicateMonitor = errors.New("another monitor is running for the endpoint")
+
+// DuplicateMonitorPolicy controls what a monitor does when it starts and finds another monitor
+// running for the same endpoint, see WithHeartbeat.
+type DuplicateMonitorPolicy int
+
+const (
+	// DuplicateMonitorWarn logs the other monitors and sends them to the ErrorSink as
+	// duplicate_monitor, and then starts anyway.
+	DuplicateMonitorWarn DuplicateMonitorPolicy = iota
+
+	// DuplicateMonitorRefuse reports the other monitors as DuplicateMonitorWarn does, and then
+	// doesn't start, with Run returning ErrDuplicateMonitor.
+	DuplicateMonitorRefuse
+)
+
+// MonitorHeartbeat records when an instance of the monitor for an endpoint was last seen running.
+type MonitorHeartbeat struct {
+	EndpointID string    `json:"endpoint_id" gorm:"primaryKey"`
+	Instance   string    `json:"instance" gorm:"primaryKey"`
+	BeatAt     time.Time `json:"beat_at"`
+}
+
+func (MonitorHeartbeat) TableName() string {
+	return "globus_monitor_heartbeats"
+}
+
+// HeartbeatStore maintains the MonitorHeartbeat entries.
+type HeartbeatStore interface {
+	// Beat records that instance of the monitor for endpointID was running at t.
+	Beat(endpointID, instance string, t time.Time) error
+
+	// LiveHeartbeats returns the heartbeats for endpointID from instances other than instance that
+	// beat at or after since.
+	LiveHeartbeats(endpointID, instance string, since time.Time) ([]MonitorHeartbeat, error)
+
+	// RemoveHeartbeat removes the heartbeat of instance of the monitor for endpointID.
+	RemoveHeartbeat(endpointID, instance string) error
+}
+
+type dbHeartbeatStore struct {
+	db *gorm.DB
+}
+
+func newDBHeartbeatStore(db *gorm.DB) *dbHeartbeatStore {
+	return &dbHeartbeatStore{db: db}
+}
+
+func (s *dbHeartbeatStore) Beat(endpointID, instance string, t time.Time) error {
+	return s.db.Exec(`
+		INSERT INTO globus_monitor_heartbeats (endpoint_id, instance, beat_at)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE beat_at = VALUES(beat_at)`,
+		endpointID, instance, t).Error
+}
+
+func (s *dbHeartbeatStore) LiveHeartbeats(endpointID, instance string, since time.Time) ([]MonitorHeartbeat, error) {
+	var heartbeats []MonitorHeartbeat
+	err := s.db.Where("endpoint_id = ?", endpointID).
+		Where("instance <> ?", instance).
+		Where("beat_at >= ?", since).
+		Find(&heartbeats).Error
+	return heartbeats, err
+}
+
+func (s *dbHeartbeatStore) RemoveHeartbeat(endpointID, instance string) error {
+	return s.db.Where("endpoint_id = ?", endpointID).
+		Where("instance = ?", instance).
+		Delete(&MonitorHeartbeat{}).Error
+}
+
+// heartbeatInstance is the name a monitor's heartbeat is kept under, see instanceKey.
+func heartbeatInstance(instanceName string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return instanceKey(instanceName, host, os.Getpid())
+}
+
+// instanceKey is the host and process id of a monitor, with its instance name in front when it has
+// one. The host and process id are always there, so replicas that were given the same instance name
+// still see each other's heartbeats, and the name is kept alongside for the logs.
+func instanceKey(instanceName, host string, pid int) string {
+	key := fmt.Sprintf("%s:%d", host, pid)
+	if instanceName != "" {
+		key = instanceName + "@" + key
+	}
+
+	return key
+}
+
+// checkDuplicateMonitors looks for heartbeats from other instances of the monitor for the endpoint
+// that are younger than the heartbeat TTL, and handles them as the DuplicateMonitorPolicy says. Being
+// unable to check is logged and doesn't stop the monitor from starting.
+func (m *GlobusTaskMonitor) checkDuplicateMonitors() error {
+	if m.config.HeartbeatTTL <= 0 {
+		return nil
+	}
+
+	others, err := m.heartbeats.LiveHeartbeats(m.endpointID, m.heartbeatInstance, m.now().Add(-m.config.HeartbeatTTL))
+	if err != nil {
+		m.logger.Errorf("Unable to check for other monitors for endpoint %s: %s", m.endpointID, err)
+		return nil
+	}
+
+	if len(others) == 0 {
+		return nil
+	}
+
+	instances := make([]string, 0, len(others))
+	for _, other := range others {
+		instances = append(instances, other.Instance)
+	}
+
+	err = fmt.Errorf("%w %s: %s", ErrDuplicateMonitor, m.endpointID, strings.Join(instances, ", "))
+	m.incCounter("duplicate_monitors", nil)
+	m.notifyError(ErrorEvent{
+		Kind:   "duplicate_monitor",
+		Err:    err,
+		Fields: log.Fields{"other_instances": instances},
+	})
+
+	if m.config.DuplicateMonitorPolicy == DuplicateMonitorRefuse {
+		return err
+	}
+
+	m.logger.Warnf("Other monitors are running for endpoint %s (%s), starting anyway", m.endpointID, strings.Join(instances, ", "))
+	return nil
+}
+
+// heartbeat records that the monitor is running every third of the heartbeat TTL until ctx is
+// cancelled, and then removes its heartbeat so a monitor started after it doesn't have to wait for
+// the heartbeat to expire.
+func (m *GlobusTaskMonitor) heartbeat(ctx context.Context) {
+	interval := m.config.HeartbeatTTL / 3
+	for {
+		if err := m.heartbeats.Beat(m.endpointID, m.heartbeatInstance, m.now()); err != nil {
+			m.logger.Errorf("Unable to record heartbeat for endpoint %s: %s", m.endpointID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := m.heartbeats.RemoveHeartbeat(m.endpointID, m.heartbeatInstance); err != nil {
+				m.logger.Errorf("Unable to remove heartbeat for endpoint %s: %s", m.endpointID, err)
+			}
+			return
+		case <-time.After(interval):
+		}
+	}
+}
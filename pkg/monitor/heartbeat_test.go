@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newHeartbeatMonitor returns a test monitor with a heartbeat, named instance, that keeps its
+// heartbeat in store.
+func newHeartbeatMonitor(store *fakeHeartbeatStore, instance string, opts ...Option) *GlobusTaskMonitor {
+	opts = append([]Option{WithHeartbeat(time.Minute), WithInstanceName(instance)}, opts...)
+	m := newTestMonitor(NewFakeGlobusClient(), opts...)
+	m.heartbeats = store
+	return m
+}
+
+func TestDuplicateMonitorIsDetected(t *testing.T) {
+	store := newFakeHeartbeatStore()
+	first := newHeartbeatMonitor(store, "first")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- first.Run(ctx) }()
+	require.Eventually(t, func() bool { return store.has(testEndpointID, first.heartbeatInstance) }, 5*time.Second, time.Millisecond)
+
+	// A second monitor for the endpoint sees the first's heartbeat, warns and starts anyway.
+	errorSink := &fakeErrorSink{}
+	metrics := newMemoryMetrics()
+	second := newHeartbeatMonitor(store, "second", WithErrorSink(errorSink), WithMetrics(metrics))
+	require.NoError(t, second.checkDuplicateMonitors())
+	require.Len(t, errorSink.events, 1)
+	require.Equal(t, "duplicate_monitor", errorSink.events[0].Kind)
+	require.Equal(t, []string{first.heartbeatInstance}, errorSink.events[0].Fields["other_instances"])
+	require.Equal(t, float64(1), metrics.counter("duplicate_monitors", Labels{"endpoint": testEndpointID, "instance": "second"}))
+
+	// Or refuses to start.
+	refusing := newHeartbeatMonitor(store, "third", WithDuplicateMonitorPolicy(DuplicateMonitorRefuse))
+	require.True(t, errors.Is(refusing.Run(context.Background()), ErrDuplicateMonitor))
+	require.False(t, store.has(testEndpointID, refusing.heartbeatInstance))
+
+	// A monitor doesn't count its own heartbeat, and the first removes its heartbeat when it stops.
+	require.NoError(t, first.checkDuplicateMonitors())
+	cancel()
+	require.NoError(t, <-done)
+	require.False(t, store.has(testEndpointID, first.heartbeatInstance))
+	require.NoError(t, refusing.checkDuplicateMonitors())
+}
+
+func TestExpiredHeartbeatsAreIgnored(t *testing.T) {
+	store := newFakeHeartbeatStore()
+	errorSink := &fakeErrorSink{}
+	m := newHeartbeatMonitor(store, "second", WithErrorSink(errorSink), WithDuplicateMonitorPolicy(DuplicateMonitorRefuse))
+
+	// A monitor that stopped without removing its heartbeat is no longer running once the heartbeat
+	// is older than the TTL.
+	require.NoError(t, store.Beat(testEndpointID, "first", m.now().Add(-2*time.Minute)))
+	require.NoError(t, store.Beat(testEndpointID2, "other-endpoint", m.now()))
+	require.NoError(t, m.checkDuplicateMonitors())
+	require.Empty(t, errorSink.events)
+}
+
+func TestReplicasWithTheSameNameAreDetected(t *testing.T) {
+	store := newFakeHeartbeatStore()
+	m := newHeartbeatMonitor(store, "replica", WithDuplicateMonitorPolicy(DuplicateMonitorRefuse))
+
+	require.NoError(t, store.Beat(testEndpointID, instanceKey("replica", "other-host", 42), m.now()))
+	require.True(t, errors.Is(m.checkDuplicateMonitors(), ErrDuplicateMonitor))
+}
+
+func TestHeartbeatInstance(t *testing.T) {
+	require.Equal(t, "monitor-1@host-1:42", instanceKey("monitor-1", "host-1", 42))
+	require.Equal(t, "host-1:42", instanceKey("", "host-1", 42))
+	require.Contains(t, heartbeatInstance("monitor-1"), "monitor-1@")
+}
@@ -0,0 +1,36 @@
+package monitor
+
+import "github.com/apex/log"
+
+// identity returns the name/value pairs that tell this monitor apart from others in the same
+// process: the endpoint, and the instance name when WithInstanceName was used. They are added to
+// every log line, metric and ErrorEvent.
+func (m *GlobusTaskMonitor) identity() map[string]string {
+	identity := map[string]string{"endpoint": m.endpointID}
+	if m.config.InstanceName != "" {
+		identity["instance"] = m.config.InstanceName
+	}
+
+	return identity
+}
+
+// logFields returns identity as log fields.
+func (m *GlobusTaskMonitor) logFields() log.Fields {
+	fields := make(log.Fields)
+	for name, value := range m.identity() {
+		fields[name] = value
+	}
+
+	return fields
+}
+
+// notifyError sends event to the ErrorSink with the monitor's identity added to its fields.
+func (m *GlobusTaskMonitor) notifyError(event ErrorEvent) {
+	fields := m.logFields()
+	for name, value := range event.Fields {
+		fields[name] = value
+	}
+
+	event.Fields = fields
+	m.errorSink.Notify(event)
+}
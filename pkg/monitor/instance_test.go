@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sends log entries to the returned handler until the test ends. Monitors pick up the
+// logger when they are created, so they must be created afterwards.
+func captureLogs(t *testing.T) *memory.Handler {
+	handler := memory.New()
+	saved := log.Log
+	log.Log = &log.Logger{Handler: handler, Level: log.DebugLevel}
+	t.Cleanup(func() { log.Log = saved })
+	return handler
+}
+
+func TestInstanceNameIsLoggedAndLabelled(t *testing.T) {
+	logs := captureLogs(t)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	metrics := newMemoryMetrics()
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithInstanceName("monitor-a"), WithMetrics(metrics), WithErrorSink(sink),
+		WithMissingUploadBehavior(MissingUploadNotify))
+	seedGlobusUploads(m, 1)
+
+	m.retrieveAndProcessUploads(context.Background())
+
+	require.NotEmpty(t, logs.Entries)
+	for _, entry := range logs.Entries {
+		require.Equal(t, "monitor-a", entry.Fields["instance"], entry.Message)
+		require.Equal(t, testEndpointID, entry.Fields["endpoint"], entry.Message)
+	}
+
+	require.Contains(t, metrics.gauges, metricKey("watermark_lag_seconds", Labels{"endpoint": testEndpointID, "instance": "monitor-a"}))
+
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "monitor-a", sink.events[0].Fields["instance"])
+	require.Equal(t, "2", sink.events[0].Fields["globus_upload_id"])
+}
+
+func TestNoInstanceName(t *testing.T) {
+	logs := captureLogs(t)
+	client := NewFakeGlobusClient()
+	client.TaskListErr = errors.New("globus is down")
+	m := newTestMonitor(client)
+
+	m.retrieveAndProcessUploads(context.Background())
+
+	require.NotEmpty(t, logs.Entries)
+	for _, entry := range logs.Entries {
+		require.NotContains(t, entry.Fields, "instance")
+		require.Equal(t, testEndpointID, entry.Fields["endpoint"])
+	}
+}
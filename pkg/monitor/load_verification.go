@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"gorm.io/gorm"
+)
+
+// loadVerification is a check, due at due, that the files of a processed upload made it into the
+// project. paths are the paths of the files in the project, starting with a slash.
+type loadVerification struct {
+	id         string
+	fileLoadID int
+	projectID  int
+	paths      []string
+	due        time.Time
+}
+
+// scheduleLoadVerification adds a check that the files of an upload are in its project once
+// Config.VerifyLoadedFilesAfter has passed. It does nothing unless WithVerifyLoadedFiles was given.
+func (m *GlobusTaskMonitor) scheduleLoadVerification(id string, fileLoad *FileLoad, files []UploadFile) {
+	if m.config.VerifyLoadedFilesAfter <= 0 || len(files) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, "/"+file.Path.String())
+	}
+
+	m.verifyMu.Lock()
+	defer m.verifyMu.Unlock()
+	m.pendingVerifications = append(m.pendingVerifications, loadVerification{
+		id:         id,
+		fileLoadID: fileLoad.ID,
+		projectID:  fileLoad.ProjectID,
+		paths:      paths,
+		due:        m.now().Add(m.config.VerifyLoadedFilesAfter),
+	})
+}
+
+// watchLoadedFiles runs the checks that are due every poll interval until ctx is done. Checks that
+// aren't due by then are dropped, so they don't hold up the monitor stopping.
+func (m *GlobusTaskMonitor) watchLoadedFiles(ctx context.Context) {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.verifyLoadedFiles()
+		}
+	}
+}
+
+// verifyLoadedFiles runs the checks that are due, reporting the uploads with files that never appeared
+// in their project. A check that can't look the files up is kept and run again next time. It returns
+// the ids of the uploads found to have missing files.
+func (m *GlobusTaskMonitor) verifyLoadedFiles() []string {
+	now := m.now()
+
+	m.verifyMu.Lock()
+	var due, pending []loadVerification
+	for _, verification := range m.pendingVerifications {
+		if now.Before(verification.due) {
+			pending = append(pending, verification)
+		} else {
+			due = append(due, verification)
+		}
+	}
+	m.pendingVerifications = pending
+	m.verifyMu.Unlock()
+
+	var reported []string
+	var unchecked []loadVerification
+	for _, verification := range due {
+		missing, err := m.missingProjectFiles(verification.projectID, verification.paths)
+		if err != nil {
+			m.logger.Errorf("Unable to verify the files loaded for globus upload %s: %s", verification.id, err)
+			unchecked = append(unchecked, verification)
+			continue
+		}
+
+		if len(missing) == 0 {
+			continue
+		}
+
+		reported = append(reported, verification.id)
+		m.incCounter("loaded_files_missing", nil)
+		m.notifyError(ErrorEvent{
+			Kind: "loaded_files_missing",
+			Err: fmt.Errorf("%d of %d files from globus upload %s are not in project %d: %s",
+				len(missing), len(verification.paths), verification.id, verification.projectID, strings.Join(missing, ", ")),
+			Fields: log.Fields{
+				"globus_upload_id": verification.id,
+				"file_load_id":     verification.fileLoadID,
+				"project_id":       verification.projectID,
+				"missing_files":    missing,
+			},
+		})
+	}
+
+	if len(unchecked) != 0 {
+		m.verifyMu.Lock()
+		m.pendingVerifications = append(m.pendingVerifications, unchecked...)
+		m.verifyMu.Unlock()
+	}
+
+	return reported
+}
+
+// missingProjectFiles returns the paths that aren't in the project.
+func (m *GlobusTaskMonitor) missingProjectFiles(projectID int, paths []string) ([]string, error) {
+	var missing []string
+	for _, path := range paths {
+		_, err := m.projectFiles.FindProjectFile(projectID, path)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			missing = append(missing, path)
+		case err != nil:
+			return nil, err
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyLoadedFilesReportsMissingFiles(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/a.txt", "/__globus_uploads/1/b.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/c.txt")
+	metrics := newMemoryMetrics()
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithVerifyLoadedFiles(time.Hour), WithMetrics(metrics), WithErrorSink(sink))
+	seedGlobusUploads(m, 1, 2)
+	projectFiles := m.projectFiles.(*fakeProjectFileStore)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	require.Equal(t, 2, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+
+	// The loader only gets as far as a.txt in upload 1, but loads all of upload 2.
+	projectFiles.add(1, "/d1/a.txt", 1, "")
+	projectFiles.add(1, "/c.txt", 1, "")
+
+	// Nothing is checked until the delay is up.
+	require.Empty(t, m.verifyLoadedFiles())
+	require.Empty(t, sink.events)
+
+	now = now.Add(time.Hour)
+	require.Equal(t, []string{"1"}, m.verifyLoadedFiles())
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "loaded_files_missing", sink.events[0].Kind)
+	require.Equal(t, "1", sink.events[0].Fields["globus_upload_id"])
+	require.Equal(t, []string{"/b.txt"}, sink.events[0].Fields["missing_files"])
+	require.Equal(t, float64(1), metrics.counter("loaded_files_missing", Labels{"endpoint": testEndpointID}))
+
+	// Each upload is only checked once.
+	require.Empty(t, m.verifyLoadedFiles())
+	require.Len(t, sink.events, 1)
+}
+
+func TestVerifyLoadedFilesRetriesLookupFailures(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt")
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithVerifyLoadedFiles(time.Minute), WithErrorSink(sink))
+	seedGlobusUploads(m, 1)
+	projectFiles := m.projectFiles.(*fakeProjectFileStore)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+
+	now = now.Add(time.Minute)
+	projectFiles.err = errors.New("connection refused")
+	require.Empty(t, m.verifyLoadedFiles())
+	require.Empty(t, sink.events)
+
+	projectFiles.err = nil
+	require.Equal(t, []string{"1"}, m.verifyLoadedFiles())
+	require.Len(t, sink.events, 1)
+}
+
+func TestLoadedFilesNotVerifiedByDefault(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Empty(t, m.pendingVerifications)
+}
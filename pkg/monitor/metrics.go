@@ -0,0 +1,35 @@
+package monitor
+
+// Labels are the name/value pairs attached to a metric.
+type Labels map[string]string
+
+// Metrics receives the counters and gauges that the monitor updates as it runs. The default
+// implementation discards them; WithMetrics hooks up a real one.
+type Metrics interface {
+	IncCounter(name string, labels Labels)
+	SetGauge(name string, value float64, labels Labels)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) IncCounter(name string, labels Labels)              {}
+func (noopMetrics) SetGauge(name string, value float64, labels Labels) {}
+
+// incCounter increments a counter, adding the monitor's identity (see identity) to its labels.
+func (m *GlobusTaskMonitor) incCounter(name string, labels Labels) {
+	m.metrics.IncCounter(name, m.metricLabels(labels))
+}
+
+// setGauge sets a gauge, adding the monitor's identity to its labels.
+func (m *GlobusTaskMonitor) setGauge(name string, value float64, labels Labels) {
+	m.metrics.SetGauge(name, value, m.metricLabels(labels))
+}
+
+func (m *GlobusTaskMonitor) metricLabels(labels Labels) Labels {
+	all := Labels(m.identity())
+	for name, value := range labels {
+		all[name] = value
+	}
+
+	return all
+}
@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	globus "github.com/materials-commons/goglobus"
+)
+
+// MissingCompletionTimePolicy controls what happens to a succeeded task that has no completion time,
+// which Globus has been seen to return for a task caught part way through a status change.
+type MissingCompletionTimePolicy int
+
+const (
+	// MissingCompletionTimeSkip skips the task and counts it in tasks_skipped, with the reason
+	// missing_completion_time.
+	MissingCompletionTimeSkip MissingCompletionTimePolicy = iota
+
+	// MissingCompletionTimeUseRequestTime uses the time the task was requested as its completion
+	// time. A task with neither is skipped.
+	MissingCompletionTimeUseRequestTime
+)
+
+// checkCompletionTime applies the configured MissingCompletionTimePolicy to task. It returns the task
+// to process, with its completion time filled in when it was missing, and false if the task should
+// be skipped.
+func (m *GlobusTaskMonitor) checkCompletionTime(task globus.Task) (globus.Task, bool) {
+	if task.CompletionTime != "" {
+		return task, true
+	}
+
+	if m.config.MissingCompletionTimePolicy == MissingCompletionTimeUseRequestTime && task.RequestTime != "" {
+		m.logger.Infof("Globus task %s has no completion time, using its request time %s", task.TaskID, task.RequestTime)
+		task.CompletionTime = task.RequestTime
+		return task, true
+	}
+
+	m.logger.Warnf("Skipping globus task %s (status %s), it has no completion time", task.TaskID, task.Status)
+	return task, false
+}
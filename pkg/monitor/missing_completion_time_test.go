@@ -0,0 +1,41 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingCompletionTime(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      MissingCompletionTimePolicy
+		requestTime string
+		processed   int
+		skipped     float64
+	}{
+		{name: "skip by default", policy: MissingCompletionTimeSkip, requestTime: "2021-01-01T00:00:00", processed: 1, skipped: 1},
+		{name: "use request time", policy: MissingCompletionTimeUseRequestTime, requestTime: "2021-01-01T00:00:00", processed: 2, skipped: 0},
+		{name: "no request time either", policy: MissingCompletionTimeUseRequestTime, requestTime: "", processed: 1, skipped: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+			client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+			client.Tasks[1].CompletionTime = ""
+			client.Tasks[1].RequestTime = test.requestTime
+
+			metrics := newMemoryMetrics()
+			m := newTestMonitor(client, WithMetrics(metrics), WithMissingCompletionTimePolicy(test.policy))
+			seedGlobusUploads(m, 1, 2)
+
+			result := m.retrieveAndProcessUploads(context.Background())
+			require.Equal(t, 2, result.TasksSeen)
+			require.Equal(t, test.processed, result.TasksProcessed)
+			require.Equal(t, test.skipped, metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "missing_completion_time"}))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	globus "github.com/materials-commons/goglobus"
+)
+
+// MixedUploadPolicy controls what happens to a task whose files were written into more than one
+// globus upload. Each upload has its own directory, so this only happens when a single submission
+// picked up files for several uploads.
+type MixedUploadPolicy int
+
+const (
+	// MixedUploadsSplit processes the files for each upload on their own, as though each upload had
+	// been written by a task of its own. The task is retried if any of the uploads needs to be, and
+	// the uploads already processed are skipped when it is.
+	MixedUploadsSplit MixedUploadPolicy = iota
+
+	// MixedUploadsReject doesn't process any of the uploads, and sends the task to the ErrorSink as
+	// mixed_upload_ids. As with duplicate file names this is only remembered in memory, so a
+	// restarted monitor will look at the uploads again once the problem has been dealt with.
+	MixedUploadsReject
+)
+
+// uploadTransfers are the transfers in a task that were written into the globus upload with id.
+type uploadTransfers struct {
+	id        string
+	transfers []globus.Transfer
+}
+
+// groupTransfersByUpload groups transfers by the globus upload they were written into, keeping the
+// order the uploads first appear in. It returns an error for a destination path that isn't in an
+// upload.
+func groupTransfersByUpload(transfers []globus.Transfer) ([]uploadTransfers, error) {
+	var groups []uploadTransfers
+	index := make(map[string]int)
+	for _, transfer := range transfers {
+		id, ok := uploadIDFromDestination(transfer.DestinationPath)
+		if !ok {
+			return nil, fmt.Errorf("invalid globus DestinationPath: %s", transfer.DestinationPath)
+		}
+
+		i, ok := index[id]
+		if !ok {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, uploadTransfers{id: id})
+		}
+		groups[i].transfers = append(groups[i].transfers, transfer)
+	}
+
+	return groups, nil
+}
+
+// processMixedUploads handles a task whose transfers were written into more than one globus upload,
+// according to Config.MixedUploadPolicy.
+func (m *GlobusTaskMonitor) processMixedUploads(ctx context.Context, task globus.Task, groups []uploadTransfers, touched projectRefs, skipped *skippedFiles, reprocess bool) taskOutcome {
+	ids := make([]string, 0, len(groups))
+	finished := 0
+	for _, group := range groups {
+		ids = append(ids, group.id)
+		if m.isFinished(group.id) {
+			finished++
+		}
+	}
+
+	// The task has been dealt with before, either processed or rejected.
+	if !reprocess && finished == len(groups) {
+		return taskSkipped
+	}
+	m.incCounter("tasks_mixed_upload_ids", nil)
+
+	if m.config.MixedUploadPolicy == MixedUploadsReject {
+		for _, id := range ids {
+			m.finishedGlobusTasks[id] = true
+		}
+
+		m.incCounter("tasks_skipped", Labels{"reason": "mixed_upload_ids"})
+		m.notifyError(ErrorEvent{
+			Kind:   "mixed_upload_ids",
+			Err:    fmt.Errorf("globus task %s wrote files into %d globus uploads: %s", task.TaskID, len(ids), strings.Join(ids, ", ")),
+			Fields: log.Fields{"task_id": task.TaskID, "globus_upload_ids": ids},
+		})
+		return taskSkipped
+	}
+
+	m.logger.Infof("Globus task %s wrote files into %d globus uploads (%s), processing each on its own",
+		task.TaskID, len(ids), strings.Join(ids, ", "))
+
+	outcome := taskSkipped
+	for _, group := range groups {
+		switch m.processUpload(ctx, task, group.id, group.transfers, touched, skipped, reprocess) {
+		case taskRetry:
+			outcome = taskRetry
+		case taskProcessed:
+			if outcome != taskRetry {
+				outcome = taskProcessed
+			}
+		}
+	}
+
+	return outcome
+}
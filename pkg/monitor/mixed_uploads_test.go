@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMixedUploadsSplit(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt", "/__globus_uploads/2/b.txt", "/__globus_uploads/1/c.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1, 2)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 2)
+	require.Equal(t, 1, fileLoads.fileLoads[0].GlobusUploadID)
+	require.Equal(t, 2, fileLoads.fileLoads[1].GlobusUploadID)
+	require.True(t, m.isFinished("1"))
+	require.True(t, m.isFinished("2"))
+}
+
+func TestMixedUploadsSplitRetriesUnfinishedUploads(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt", "/__globus_uploads/2/b.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1, 2)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.err = errors.New("insert failed")
+	fileLoads.failures = 1
+
+	// Upload 1 fails and upload 2 goes ahead, so the task is retried for upload 1 alone.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.False(t, m.isFinished("1"))
+	require.True(t, m.isFinished("2"))
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 2)
+	require.Equal(t, 2, fileLoads.fileLoads[0].GlobusUploadID)
+	require.Equal(t, 1, fileLoads.fileLoads[1].GlobusUploadID)
+	require.True(t, m.isFinished("1"))
+}
+
+func TestMixedUploadsReject(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt", "/__globus_uploads/2/b.txt")
+	metrics := newMemoryMetrics()
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithMixedUploadPolicy(MixedUploadsReject), WithMetrics(metrics), WithErrorSink(sink))
+	seedGlobusUploads(m, 1, 2)
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Empty(t, m.fileLoads.(*fakeFileLoadStore).fileLoads)
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "mixed_upload_ids", sink.events[0].Kind)
+	require.Equal(t, []string{"1", "2"}, sink.events[0].Fields["globus_upload_ids"])
+	require.Equal(t, float64(1), metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "mixed_upload_ids"}))
+
+	// The task is only reported once.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, sink.events, 1)
+}
@@ -0,0 +1,310 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"gorm.io/gorm"
+)
+
+// DirectoryMover is implemented by a ProjectFileStore that can find and move the directories of a
+// project, which WithMoveDetection needs. The store NewGlobusTaskMonitor uses implements it. Paths
+// start with a slash.
+type DirectoryMover interface {
+	// FindDirectory returns the directory at path, and gorm.ErrRecordNotFound when there isn't one.
+	FindDirectory(projectID int, path string) (*mcmodel.File, error)
+
+	// FindFilesByChecksum returns the current files in the project with checksum, with their
+	// Directory filled in.
+	FindFilesByChecksum(projectID int, checksum string) ([]mcmodel.File, error)
+
+	// ListDirectoryFiles returns the current files directly in the directory at path, leaving out
+	// its subdirectories.
+	ListDirectoryFiles(projectID int, path string) ([]mcmodel.File, error)
+
+	// MoveDirectory moves the directory at from, and everything under it, to to. The parent
+	// directory of to must already exist.
+	MoveDirectory(projectID int, from, to string) error
+}
+
+var _ DirectoryMover = (*dbProjectFileStore)(nil)
+
+func (s *dbProjectFileStore) FindDirectory(projectID int, path string) (*mcmodel.File, error) {
+	return s.findDirectory(s.db, projectID, path)
+}
+
+func (s *dbProjectFileStore) findDirectory(db *gorm.DB, projectID int, path string) (*mcmodel.File, error) {
+	var dir mcmodel.File
+	err := db.Where("project_id = ?", projectID).
+		Where(s.equals("path"), path).
+		Where("mime_type = ?", "directory").
+		First(&dir).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &dir, nil
+}
+
+func (s *dbProjectFileStore) FindFilesByChecksum(projectID int, checksum string) ([]mcmodel.File, error) {
+	var files []mcmodel.File
+	err := s.db.Preload("Directory").
+		Where("project_id = ?", projectID).
+		Where("checksum = ?", checksum).
+		Where("current = ?", true).
+		Where("mime_type <> ?", "directory").
+		Find(&files).Error
+	return files, err
+}
+
+func (s *dbProjectFileStore) ListDirectoryFiles(projectID int, path string) ([]mcmodel.File, error) {
+	dir, err := s.FindDirectory(projectID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []mcmodel.File
+	err = s.db.Where("project_id = ?", projectID).
+		Where("directory_id = ?", dir.ID).
+		Where("current = ?", true).
+		Where("mime_type <> ?", "directory").
+		Find(&files).Error
+	return files, err
+}
+
+// MoveDirectory renames the directory, moves it under the parent of to, and rewrites the paths of the
+// directories under it. Files are found through their directory, so they move with it.
+func (s *dbProjectFileStore) MoveDirectory(projectID int, from, to string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		dir, err := s.findDirectory(tx, projectID, from)
+		if err != nil {
+			return err
+		}
+
+		parent, err := s.findDirectory(tx, projectID, path.Dir(to))
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&mcmodel.File{}).
+			Where("project_id = ?", projectID).
+			Where("mime_type = ?", "directory").
+			Where("path LIKE ?", escapeLike(from)+"/%").
+			Update("path", gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", to, len(from)+1)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(dir).Updates(map[string]interface{}{
+			"path":         to,
+			"name":         path.Base(to),
+			"directory_id": parent.ID,
+		}).Error
+	})
+}
+
+// escapeLike escapes the characters that are wildcards in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// detectMovedDirectories looks for directories of an upload that are a directory already in the
+// project under a new name, and moves the directory in the project rather than loading its files
+// again. It is called once the upload's file load has been created and nothing is left that could
+// have the task retried, so a retried task never finds the project already changed. A file loader
+// that gets to the upload first has already added the directory under its new name, and then nothing
+// is moved. A directory of the upload is taken to be a moved one when it isn't in the project, its
+// parent is, and the files directly in it have the same names and checksums as the files directly in
+// a directory of the project. By then the files have been placed at their Paths in the upload
+// directory, so that is where they are read from. Once a directory is moved, the files of the upload
+// under it that are now in the project, unchanged, are removed from the upload directory and skipped
+// as moved. It does nothing unless move detection is on for the upload's project and the
+// ProjectFileStore is a DirectoryMover, and returns the files that will be loaded.
+func (m *GlobusTaskMonitor) detectMovedDirectories(id string, task globus.Task, upload *GlobusUpload, files []UploadFile, skipped *skippedFiles) []UploadFile {
+	mover, ok := m.projectFiles.(DirectoryMover)
+	if !ok || !m.detectsMoves(upload.ProjectID) {
+		return files
+	}
+
+	byDir := make(map[string][]UploadFile)
+	for _, file := range files {
+		dir := path.Dir("/" + file.Path.String())
+		byDir[dir] = append(byDir[dir], file)
+	}
+
+	// Parents are looked at before their children, so a child of a moved directory is found in the
+	// project rather than taken for another move.
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/"); di != dj {
+			return di < dj
+		}
+
+		return dirs[i] < dirs[j]
+	})
+
+	var moved []string
+	for _, dir := range dirs {
+		if dir == "/" || isUnderDirectory(moved, dir) || !m.isNewDirectory(mover, upload.ProjectID, dir) {
+			continue
+		}
+
+		from, found := m.findMovedDirectory(mover, upload, dir, byDir[dir])
+		if !found {
+			continue
+		}
+
+		if err := mover.MoveDirectory(upload.ProjectID, from, dir); err != nil {
+			m.logger.Errorf("Unable to move directory %s to %s in project %d: %s", from, dir, upload.ProjectID, err)
+			continue
+		}
+
+		m.logger.Infof("Moved directory %s to %s in project %d for globus upload %s (task %s)", from, dir, upload.ProjectID, id, task.TaskID)
+		m.incCounter("directories_moved", nil)
+		moved = append(moved, dir)
+	}
+
+	if len(moved) == 0 {
+		return files
+	}
+
+	var loaded []UploadFile
+	for _, file := range files {
+		projectPath := "/" + file.Path.String()
+		local := filepath.Join(upload.Path, file.Path.String())
+		if !isUnderDirectory(moved, projectPath) || !m.isUnchangedFile(upload.ProjectID, projectPath, local) {
+			loaded = append(loaded, file)
+			continue
+		}
+
+		if err := m.objectStore.Delete(context.Background(), local); err != nil {
+			// The file is still there, so the file loader will load it.
+			m.logger.Errorf("Unable to remove moved file %s from globus upload %s: %s", file.Path, id, err)
+			loaded = append(loaded, file)
+			continue
+		}
+
+		m.skipFile(skipped, uploadSkippedFile(id, task, upload, file, "moved"))
+	}
+
+	return loaded
+}
+
+// detectsMoves returns true if WithMoveDetection turned move detection on, and
+// WithMoveDetectionProjects either wasn't given any projects or was given projectID.
+func (m *GlobusTaskMonitor) detectsMoves(projectID int) bool {
+	if !m.config.MoveDetection {
+		return false
+	}
+
+	if len(m.config.MoveDetectionProjects) == 0 {
+		return true
+	}
+
+	for _, id := range m.config.MoveDetectionProjects {
+		if id == projectID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNewDirectory returns true if the directory at p isn't in the project, but its parent is.
+func (m *GlobusTaskMonitor) isNewDirectory(mover DirectoryMover, projectID int, p string) bool {
+	if _, err := mover.FindDirectory(projectID, p); !errors.Is(err, gorm.ErrRecordNotFound) {
+		if err != nil {
+			m.logger.Errorf("Unable to look up directory %s in project %d: %s", p, projectID, err)
+		}
+		return false
+	}
+
+	if _, err := mover.FindDirectory(projectID, path.Dir(p)); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			m.logger.Errorf("Unable to look up directory %s in project %d: %s", path.Dir(p), projectID, err)
+		}
+		return false
+	}
+
+	return true
+}
+
+// findMovedDirectory returns the directory of the project holding the same files, by name and
+// checksum, as files, the files of the upload directly in dir.
+func (m *GlobusTaskMonitor) findMovedDirectory(mover DirectoryMover, upload *GlobusUpload, dir string, files []UploadFile) (string, bool) {
+	checksums := make(map[string]string)
+	for _, file := range files {
+		checksum, err := fileChecksum(m.objectStore, filepath.Join(upload.Path, file.Path.String()))
+		if err != nil {
+			m.logger.Errorf("Unable to checksum %s: %s", file.Path, err)
+			return "", false
+		}
+		checksums[path.Base(file.Path.String())] = checksum
+	}
+
+	name := path.Base(files[0].Path.String())
+	candidates, err := mover.FindFilesByChecksum(upload.ProjectID, checksums[name])
+	if err != nil {
+		m.logger.Errorf("Unable to look up files by checksum in project %d: %s", upload.ProjectID, err)
+		return "", false
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Name != name || candidate.Directory == nil {
+			continue
+		}
+
+		// A directory can't be moved into itself.
+		from := candidate.Directory.Path
+		if from == "/" || from == dir || strings.HasPrefix(dir, from+"/") {
+			continue
+		}
+
+		existing, err := mover.ListDirectoryFiles(upload.ProjectID, from)
+		if err != nil {
+			m.logger.Errorf("Unable to list directory %s in project %d: %s", from, upload.ProjectID, err)
+			continue
+		}
+
+		if sameFiles(existing, checksums) {
+			return from, true
+		}
+	}
+
+	return "", false
+}
+
+// sameFiles returns true if files are exactly the files in checksums, by name and checksum.
+func sameFiles(files []mcmodel.File, checksums map[string]string) bool {
+	if len(files) != len(checksums) {
+		return false
+	}
+
+	for _, file := range files {
+		if checksum, ok := checksums[file.Name]; !ok || checksum != file.Checksum {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isUnderDirectory returns true if p is one of dirs, or is under one of them.
+func isUnderDirectory(dirs []string, p string) bool {
+	for _, dir := range dirs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// renamedDirUpload sets up project 1 with the files of run1, and globus upload 1 with the same
+// files uploaded again after run1 was renamed to run1-final.
+func renamedDirUpload(t *testing.T, m *GlobusTaskMonitor, client *FakeGlobusClient) *fakeProjectFileStore {
+	files := map[string]string{"a.txt": "a", "b.txt": "b", "sub/c.txt": "c"}
+	projectFiles := m.projectFiles.(*fakeProjectFileStore)
+	loaded := writeUploadDir(t, map[string]string{"run1/a.txt": "a", "run1/b.txt": "b", "run1/sub/c.txt": "c"})
+	loadUploadDir(t, projectFiles, 1, loaded)
+
+	renamed := make(map[string]string)
+	var paths []string
+	for name, contents := range files {
+		renamed["run1-final/"+name] = contents
+		paths = append(paths, "/__globus_uploads/1/run1-final/"+name)
+	}
+
+	dir := writeUploadDir(t, renamed)
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	client.AddUpload("task-1", paths...)
+	return projectFiles
+}
+
+func TestMoveDetection(t *testing.T) {
+	client := NewFakeGlobusClient()
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMoveDetection(true), WithMetrics(metrics))
+	projectFiles := renamedDirUpload(t, m, client)
+	upload, err := m.globusUploads.(*fakeGlobusUploadStore).GetGlobusUpload("1")
+	require.NoError(t, err)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+
+	// The existing files are moved once the file load has been created, and removed from the upload
+	// so the file loader doesn't load them again.
+	require.Len(t, projectFiles.files[1], 3)
+	for _, p := range []string{"/run1-final/a.txt", "/run1-final/b.txt", "/run1-final/sub/c.txt"} {
+		require.Contains(t, projectFiles.files[1], p)
+	}
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+	_, err = os.Stat(filepath.Join(upload.Path, "run1-final/a.txt"))
+	require.True(t, os.IsNotExist(err))
+	require.True(t, m.isFinished("1"))
+	require.Len(t, result.SkippedFiles, 3)
+	for _, skipped := range result.SkippedFiles {
+		require.Equal(t, "moved", skipped.Reason)
+	}
+	require.Equal(t, float64(1), metrics.counter("directories_moved", Labels{"endpoint": testEndpointID}))
+	require.Equal(t, float64(3), metrics.counter("files_skipped", Labels{"endpoint": testEndpointID, "reason": "moved"}))
+}
+
+func TestMoveDetectionWaitsForTheFileLoad(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithMoveDetection(true))
+	projectFiles := renamedDirUpload(t, m, client)
+	m.fileLoads.(*fakeFileLoadStore).err = errors.New("insert failed")
+
+	// The task is retried, so the project is left as it was.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Contains(t, projectFiles.files[1], "/run1/a.txt")
+	require.NotContains(t, projectFiles.files[1], "/run1-final/a.txt")
+	require.False(t, m.isFinished("1"))
+}
+
+func TestMoveDetectionIsOnlyForChosenProjects(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithMoveDetection(true), WithMoveDetectionProjects([]int{2}))
+	projectFiles := renamedDirUpload(t, m, client)
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Contains(t, projectFiles.files[1], "/run1/a.txt")
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+}
+
+func TestMoveDetectionNeedsMatchingFiles(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithMoveDetection(true))
+	projectFiles := m.projectFiles.(*fakeProjectFileStore)
+	loadUploadDir(t, projectFiles, 1, writeUploadDir(t, map[string]string{"run1/a.txt": "a", "run1/b.txt": "b"}))
+
+	// run2 only has one of the files in run1, so it is a new directory rather than run1 renamed.
+	dir := writeUploadDir(t, map[string]string{"run2/a.txt": "a"})
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	client.AddUpload("task-1", "/__globus_uploads/1/run2/a.txt")
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Contains(t, projectFiles.files[1], "/run1/a.txt")
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+}
+
+func TestMoveDetectionIsOffByDefault(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "no options"},
+		{name: "only projects", opts: []Option{WithMoveDetectionProjects([]int{1})}},
+		{name: "turned off", opts: []Option{WithMoveDetection(false)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			m := newTestMonitor(client, test.opts...)
+			projectFiles := renamedDirUpload(t, m, client)
+
+			require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+			require.Contains(t, projectFiles.files[1], "/run1/a.txt")
+			require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// MultiEndpointMonitor watches several endpoints that share a Globus client and database, running a
+// GlobusTaskMonitor for each.
+type MultiEndpointMonitor struct {
+	monitors map[string]*GlobusTaskMonitor
+}
+
+// NewMultiEndpointMonitor creates a monitor for each endpoint. Every endpoint uses defaults, with the
+// non zero fields from its entry in overrides (if it has one) layered on top, so an endpoint only needs
+// to list the settings that are different for it. The opts are applied to every endpoint's monitor.
+// The monitors share their project locks, so uploads to a project are processed one at a time
+// whichever endpoint they arrive through.
+func NewMultiEndpointMonitor(client GlobusClient, db *gorm.DB, endpointIDs []string, defaults Config, overrides map[string]Config, opts ...Option) *MultiEndpointMonitor {
+	mm := &MultiEndpointMonitor{monitors: make(map[string]*GlobusTaskMonitor)}
+	locks := newProjectLocks()
+	for _, endpointID := range endpointIDs {
+		config := overrideConfig(defaults, overrides[endpointID])
+		endpointOpts := append([]Option{withConfig(config), withProjectLocks(locks)}, opts...)
+		m := NewGlobusTaskMonitor(client, db, endpointID, endpointOpts...)
+		mm.monitors[m.endpointID] = m
+	}
+
+	return mm
+}
+
+// overrideConfig returns base with every field that is set in override replaced by override's value.
+// Since only set fields are copied an override can't turn a setting back off, for example it can't
+// unset ReportFileFailures once the defaults turn it on.
+func overrideConfig(base, override Config) Config {
+	result := reflect.ValueOf(&base).Elem()
+	o := reflect.ValueOf(override)
+	for i := 0; i < o.NumField(); i++ {
+		if !o.Field(i).IsZero() {
+			result.Field(i).Set(o.Field(i))
+		}
+	}
+
+	return base
+}
+
+// Monitor returns the monitor for endpointID, or nil if it isn't one of the endpoints being watched.
+func (mm *MultiEndpointMonitor) Monitor(endpointID string) *GlobusTaskMonitor {
+	return mm.monitors[normalizeEndpointID(endpointID)]
+}
+
+// EndpointIDs returns the endpoints being watched, sorted.
+func (mm *MultiEndpointMonitor) EndpointIDs() []string {
+	ids := make([]string, 0, len(mm.monitors))
+	for id := range mm.monitors {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// Run runs the monitor for every endpoint until ctx is cancelled, and returns once they have all stopped.
+// Every endpoint is verified first, and if any of them fails none of the monitors are started.
+func (mm *MultiEndpointMonitor) Run(ctx context.Context) error {
+	for _, id := range mm.EndpointIDs() {
+		if err := mm.monitors[id].verifyOnStart(ctx); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, m := range mm.monitors {
+		wg.Add(1)
+		go func(m *GlobusTaskMonitor) {
+			defer wg.Done()
+			m.run(ctx)
+		}(m)
+	}
+
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverrideConfig(t *testing.T) {
+	defaults := Config{PollInterval: time.Minute, TaskLookback: 7 * 24 * time.Hour, DuplicateNamePolicy: DuplicateNameRename}
+	override := Config{TaskLookback: 24 * time.Hour}
+
+	config := overrideConfig(defaults, override)
+	require.Equal(t, Config{PollInterval: time.Minute, TaskLookback: 24 * time.Hour, DuplicateNamePolicy: DuplicateNameRename}, config)
+	require.Equal(t, defaults, overrideConfig(defaults, Config{}))
+}
+
+func TestEndpointLookbackOverride(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	overrides := map[string]Config{testEndpointID2: {TaskLookback: 24 * time.Hour}}
+	mm := NewMultiEndpointMonitor(client, nil, []string{testEndpointID, testEndpointID2}, Config{}, overrides)
+	require.Equal(t, []string{testEndpointID, testEndpointID2}, mm.EndpointIDs())
+
+	since := make(map[string]string)
+	for _, id := range mm.EndpointIDs() {
+		m := mm.Monitor(id)
+		m.now = func() time.Time { return now }
+		m.retrieveAndProcessUploads(context.Background())
+		since[id] = client.TaskListFilters[len(client.TaskListFilters)-1]["filter_completion_time"]
+	}
+
+	// Only the second endpoint has the shorter lookback, the first keeps the default of a week.
+	require.Equal(t, map[string]string{testEndpointID: "2021-03-08", testEndpointID2: "2021-03-14"}, since)
+	require.Equal(t, defaultTaskLookback, mm.Monitor(testEndpointID).config.TaskLookback)
+	require.Equal(t, defaultPollInterval, mm.Monitor(testEndpointID2).config.PollInterval)
+}
+
+func TestMultiEndpointMonitorRun(t *testing.T) {
+	client := NewFakeGlobusClient()
+	mm := NewMultiEndpointMonitor(client, nil, []string{testEndpointID, testEndpointID2}, Config{PollInterval: time.Millisecond, StopAfterIdlePasses: 1}, nil)
+
+	done := make(chan struct{})
+	go func() {
+		mm.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("multi endpoint monitor didn't stop")
+	}
+
+	// Each endpoint is verified and then makes a single pass.
+	require.Equal(t, 4, client.TaskListCalls)
+}
@@ -0,0 +1,626 @@
+package monitor
+
+import (
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// Config holds the settings that control how the monitor polls for and processes tasks. Zero
+// values are replaced with defaults by NewGlobusTaskMonitor.
+type Config struct {
+	// PollInterval is how long to wait between passes.
+	PollInterval time.Duration
+
+	// StopAfterIdlePasses stops the monitor after this many passes in a row processed no tasks.
+	// A value of 0 means keep running until the context is cancelled.
+	StopAfterIdlePasses int
+
+	// MaxRuntime stops the monitor once it has been running this long. A value of 0 means keep
+	// running until the context is cancelled.
+	MaxRuntime time.Duration
+
+	// AuditRetention is how long processed upload entries are kept. A value of 0 keeps them forever.
+	AuditRetention time.Duration
+
+	// MissingUploadBehavior is what to do with a completed upload that has no globus_uploads entry.
+	MissingUploadBehavior MissingUploadBehavior
+
+	// DuplicateNamePolicy is what to do when an upload has more than one file with the same path.
+	DuplicateNamePolicy DuplicateNamePolicy
+
+	// WatermarkLagWarning is how far behind the watermark can get before a warning is logged. A
+	// value of 0 turns off the warning.
+	WatermarkLagWarning time.Duration
+
+	// ReportFileFailures sends processed tasks that had files fail to transfer to the ErrorSink.
+	ReportFileFailures bool
+
+	// PathNormalizer is applied to the path of each uploaded file before duplicates are checked for
+	// and the file load is created.
+	PathNormalizer mcpath.PathNormalizer
+
+	// TransferFetchConcurrency is how many tasks can have their successful transfers being fetched, or
+	// fetched and waiting to be processed, at once.
+	TransferFetchConcurrency int
+
+	// TransferFetchRate is how many requests a second can be made for the successful transfers of
+	// tasks. A value of 0 means there is no limit. TransferFetchBurst is how many can be made at once
+	// after a quiet spell.
+	TransferFetchRate  float64
+	TransferFetchBurst int
+
+	// TaskLookback is how far back the monitor asks Globus for completed tasks. Processed upload
+	// entries are always kept for at least this long.
+	TaskLookback time.Duration
+
+	// ShutdownFlushTimeout bounds how long Run waits for buffered work to be flushed when it stops.
+	ShutdownFlushTimeout time.Duration
+
+	// TaskPageSize is how many tasks are asked for in each task list request. It is clamped to the
+	// 1000 that Globus allows.
+	TaskPageSize int
+
+	// ClockSkewWarning is how far the host clock can be behind Globus before a warning is logged.
+	ClockSkewWarning time.Duration
+
+	// CompensateClockSkew moves the watermark and the lookback window forward by how far the host
+	// clock is behind Globus, so tasks that completed in the gap aren't missed.
+	CompensateClockSkew bool
+
+	// FileLoadRate is how many file loads a second can be created. A value of 0 means there is no
+	// limit. FileLoadBurst is how many can be created at once after a quiet spell.
+	FileLoadRate  float64
+	FileLoadBurst int
+
+	// ZeroByteFilePolicy is what to do with empty files in an upload.
+	ZeroByteFilePolicy ZeroByteFilePolicy
+
+	// SkipUnchangedFiles leaves out the files of an upload that are already in the project with the
+	// same size and checksum.
+	SkipUnchangedFiles bool
+
+	// ExcludeGlobs are the patterns for files that are left out of an upload, see WithExcludeGlobs.
+	ExcludeGlobs []string
+
+	// FilterTimeZone is the time zone the completion times in task list filters are written in. It
+	// defaults to UTC, which is how Globus reads them.
+	FilterTimeZone *time.Location
+
+	// MissingCompletionTimePolicy is what to do with a task that has no completion time.
+	MissingCompletionTimePolicy MissingCompletionTimePolicy
+
+	// ProjectUploadSummaries turns on keeping a ProjectUploadSummary for each project up to date.
+	ProjectUploadSummaries bool
+
+	// InstanceName tells apart monitors for the same endpoint, for example when several run for high
+	// availability. It is added to log lines and metrics alongside the endpoint when it is set.
+	InstanceName string
+
+	// DBRetries is how many times creating a file load is retried when it fails with a transient
+	// database error, such as a deadlock. DBRetryBackoff is how long to wait before the first retry,
+	// doubling for each one after it. A negative DBRetries turns retries off.
+	DBRetries      int
+	DBRetryBackoff time.Duration
+
+	// CaughtUpLag is how close the watermark has to get to now for the monitor to count as caught up,
+	// see WithOnCaughtUp.
+	CaughtUpLag time.Duration
+
+	// DeleteUploadACLs removes the ACL rule on an upload's directory when the upload is processed.
+	DeleteUploadACLs bool
+
+	// ProcessingOrder is the order the steps of processing an upload are taken in.
+	ProcessingOrder ProcessingOrder
+
+	// FilterFromWatermark asks Globus only for tasks that completed since the watermark, when it is
+	// later than the start of the lookback window.
+	FilterFromWatermark bool
+
+	// SkipVerifyFileLoad turns off reading an upload's file load back before the upload is finished,
+	// which otherwise leaves the upload to be retried when the file load isn't there.
+	SkipVerifyFileLoad bool
+
+	// StuckThreshold is how long an upload can be in flight before it is reported as stuck. A value of
+	// 0 means uploads are never reported.
+	StuckThreshold time.Duration
+
+	// MixedUploadPolicy is what happens to a task whose files were written into more than one upload.
+	MixedUploadPolicy MixedUploadPolicy
+
+	// VerifyLoadedFilesAfter is how long after an upload is processed its files are checked for in
+	// the project. A value of 0 means they aren't checked.
+	VerifyLoadedFilesAfter time.Duration
+
+	// CaseInsensitivePaths treats paths that only differ in case as the same path.
+	CaseInsensitivePaths bool
+
+	// PauseWhenDBUnavailable stops processing uploads while the database can't be reached.
+	PauseWhenDBUnavailable bool
+
+	// MaxTransferPages is the most pages of transfers fetched for a task in a pass. A value of 0
+	// means there is no limit.
+	MaxTransferPages int
+
+	// TransferPageLimitPolicy is what happens to a task with more than MaxTransferPages pages.
+	TransferPageLimitPolicy TransferPageLimitPolicy
+
+	// MoveDetection has directories of an upload that are existing directories of the project under
+	// a new name moved rather than having their files loaded again.
+	MoveDetection bool
+
+	// MoveDetectionProjects limits MoveDetection to these projects. When it is empty every project
+	// has its directories moved.
+	MoveDetectionProjects []int
+
+	// HeartbeatTTL is how long a monitor's heartbeat lasts before it is no longer taken to be running.
+	// A value of 0 means heartbeats aren't recorded or checked.
+	HeartbeatTTL time.Duration
+
+	// DuplicateMonitorPolicy is what a monitor does when it starts and finds a live heartbeat from
+	// another monitor for the endpoint.
+	DuplicateMonitorPolicy DuplicateMonitorPolicy
+}
+
+// MissingUploadBehavior controls what happens when a completed upload task refers to a globus upload
+// that isn't in the globus_uploads table.
+type MissingUploadBehavior int
+
+const (
+	// MissingUploadIgnore assumes the upload was processed and deleted by an earlier run and quietly
+	// marks it as finished.
+	MissingUploadIgnore MissingUploadBehavior = iota
+
+	// MissingUploadNotify marks the upload as finished, but also reports it to the ErrorSink. This
+	// is for tracking down uploads that disappear unexpectedly.
+	MissingUploadNotify
+)
+
+const defaultPollInterval = 10 * time.Second
+
+// TaskFilter decides whether a task should be processed. It returns false to skip the task.
+type TaskFilter func(task globus.Task) bool
+
+// Option configures a GlobusTaskMonitor.
+type Option func(m *GlobusTaskMonitor)
+
+// WithStopAfterIdlePasses makes the monitor exit once it has gone n passes in a row without processing
+// any tasks. This turns the monitor into a batch job that drains everything outstanding and then stops.
+func WithStopAfterIdlePasses(n int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.StopAfterIdlePasses = n
+	}
+}
+
+// WithMaxRuntime makes the monitor exit once it has been running for d, however much work is left.
+// A pass that is under way when d is up is finished first, so the monitor stops cleanly. Like
+// WithStopAfterIdlePasses it is meant for running the monitor as a job.
+func WithMaxRuntime(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MaxRuntime = d
+	}
+}
+
+// WithAuditRetention turns on the periodic purge of processed upload entries older than d. Entries
+// are always kept for at least as long as the window of tasks requested from Globus.
+func WithAuditRetention(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.AuditRetention = d
+	}
+}
+
+// WithMetrics sets where the monitor reports its counters and gauges.
+func WithMetrics(metrics Metrics) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.metrics = metrics
+	}
+}
+
+// WithMissingUploadBehavior sets what to do with completed uploads that have no globus_uploads entry.
+// The default is MissingUploadIgnore.
+func WithMissingUploadBehavior(behavior MissingUploadBehavior) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MissingUploadBehavior = behavior
+	}
+}
+
+// WithErrorSink sets where the monitor reports problems that need attention.
+func WithErrorSink(sink ErrorSink) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.errorSink = sink
+	}
+}
+
+// WithTaskFilter adds a filter that every task must pass before its transfers are fetched. The option
+// can be given more than once, in which case a task is only processed if all the filters pass.
+func WithTaskFilter(filter TaskFilter) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.taskFilters = append(m.taskFilters, filter)
+	}
+}
+
+// WithDuplicateNamePolicy sets what to do when an upload has more than one file with the same path.
+// The default is DuplicateNameError.
+func WithDuplicateNamePolicy(policy DuplicateNamePolicy) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.DuplicateNamePolicy = policy
+	}
+}
+
+// WithWatermarkLagWarning sets how far behind the watermark can get before the monitor logs a warning.
+func WithWatermarkLagWarning(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.WatermarkLagWarning = d
+	}
+}
+
+// WithArchiveProcessor sets what handles tasks that transferred files into the archive layout.
+func WithArchiveProcessor(processor ArchiveProcessor) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.archiveProcessor = processor
+	}
+}
+
+// WithReportFileFailures turns on sending processed tasks that had files fail to transfer to the
+// ErrorSink, so they can be followed up on.
+func WithReportFileFailures() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ReportFileFailures = true
+	}
+}
+
+// WithPathNormalizer sets how uploaded file paths are normalized before they are stored. The default,
+// mcpath.IdentityNormalizer, leaves them unchanged.
+func WithPathNormalizer(normalize mcpath.PathNormalizer) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.PathNormalizer = normalize
+	}
+}
+
+// WithTransferFetchConcurrency sets how many tasks can have their successful transfers being fetched,
+// or fetched and waiting to be processed, at once. Raising it speeds up catching up on a backlog of
+// small tasks, at the cost of more load on Globus, which WithTransferFetchRate can bound. A value of
+// 1 fetches the transfers for one task at a time.
+func WithTransferFetchConcurrency(n int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.TransferFetchConcurrency = n
+	}
+}
+
+// WithTransferFetchRate limits the requests for the successful transfers of tasks to rate a second,
+// with up to burst made at once after a quiet spell, however many tasks are being fetched at once. A
+// rate of 0 or less means there is no limit.
+func WithTransferFetchRate(rate float64, burst int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.TransferFetchRate = rate
+		m.config.TransferFetchBurst = burst
+	}
+}
+
+// WithPublisher sets where an UploadEvent is published for each processed upload.
+func WithPublisher(publisher Publisher) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.publisher = publisher
+	}
+}
+
+// WithTaskLookback sets how far back the monitor asks Globus for completed tasks. The default is a week.
+func WithTaskLookback(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.TaskLookback = d
+	}
+}
+
+// WithShutdownFlushTimeout sets how long Run waits for buffered work to be flushed when it stops.
+func WithShutdownFlushTimeout(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ShutdownFlushTimeout = d
+	}
+}
+
+// WithTaskPageSize sets how many tasks are asked for in each task list request. Smaller pages make
+// each request cheaper and use less memory while catching up. The default, and the most Globus
+// allows, is 1000.
+func WithTaskPageSize(n int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.TaskPageSize = n
+	}
+}
+
+// WithTracer sets the Tracer used to trace each pass, and each task processed in a pass.
+func WithTracer(tracer Tracer) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.tracer = tracer
+	}
+}
+
+// WithClockSkewWarning sets how far the host clock can be behind Globus before the monitor logs a
+// warning.
+func WithClockSkewWarning(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ClockSkewWarning = d
+	}
+}
+
+// WithClockSkewCompensation turns on moving the watermark and the lookback window forward by how far
+// the host clock is behind Globus.
+func WithClockSkewCompensation() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.CompensateClockSkew = true
+	}
+}
+
+// WithGlobalConcurrency limits how many tasks can be processed at once to n. The limit is shared by
+// every monitor the returned Option is applied to, so passing it to NewMultiEndpointMonitor bounds
+// the processing across all of the endpoints, with each endpoint waiting its turn for a slot. This is
+// separate from WithTransferFetchConcurrency, which only limits calls to Globus for one endpoint.
+// A value of 0 or less means there is no limit.
+func WithGlobalConcurrency(n int) Option {
+	var slots processingSlots
+	if n > 0 {
+		slots = make(processingSlots, n)
+	}
+
+	return func(m *GlobusTaskMonitor) {
+		m.processingSlots = slots
+	}
+}
+
+// WithFileLoadRate limits how fast file loads are created to rate a second, with up to burst created
+// at once after a quiet spell. The monitor keeps polling at its usual interval, but a pass waits
+// before handing each upload to the file loader when it is going faster than rate. A rate of 0 or
+// less means there is no limit.
+func WithFileLoadRate(rate float64, burst int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.FileLoadRate = rate
+		m.config.FileLoadBurst = burst
+	}
+}
+
+// WithZeroByteFilePolicy sets what to do with empty files in an upload. The default is
+// ZeroByteFileLoad.
+func WithZeroByteFilePolicy(policy ZeroByteFilePolicy) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ZeroByteFilePolicy = policy
+	}
+}
+
+// WithSkipUnchangedFiles leaves out the files of an upload that are already in the project at the
+// same path with the same size and checksum, so uploading a directory again only loads the files
+// that changed. An upload where nothing changed doesn't create a file load at all.
+func WithSkipUnchangedFiles() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.SkipUnchangedFiles = true
+	}
+}
+
+// WithMissingCompletionTimePolicy sets what to do with a task that has no completion time. The
+// default is MissingCompletionTimeSkip.
+func WithMissingCompletionTimePolicy(policy MissingCompletionTimePolicy) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MissingCompletionTimePolicy = policy
+	}
+}
+
+// WithProjectUploadSummaries turns on adding each processed upload to the ProjectUploadSummary for its
+// project, giving a read model of the uploads to each project that is cheap to query.
+func WithProjectUploadSummaries() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ProjectUploadSummaries = true
+	}
+}
+
+// WithInstanceName names the monitor, so its log lines, metrics and ErrorEvents can be told apart
+// from those of other monitors in the same process. They already carry the endpoint, the name is
+// for telling apart monitors that watch the same one.
+func WithInstanceName(name string) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.InstanceName = name
+	}
+}
+
+// WithFilterTimeZone sets the time zone the completion times in task list filters are written in. The
+// default, UTC, matches how Globus reads them, so this is only needed for a Globus deployment that
+// reads them in another zone.
+func WithFilterTimeZone(loc *time.Location) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.FilterTimeZone = loc
+	}
+}
+
+// WithDBRetries sets how many times creating a file load is retried when it fails with a transient
+// database error, waiting backoff before the first retry and doubling the wait for each one after it.
+// An upload that still can't be loaded is reported to the ErrorSink and tried again on the next pass.
+// The default is 3 retries starting at 100ms. A retries of 0 turns retrying off.
+func WithDBRetries(retries int, backoff time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.DBRetries = retries
+		m.config.DBRetryBackoff = backoff
+	}
+}
+
+// WithOnCaughtUp sets a function that is called once, the first time a pass ends with the watermark
+// within Config.CaughtUpLag of now. A monitor started with a backlog of uploads calls it when it has
+// worked through them, so systems waiting on the uploads know when to start normal operation. It is
+// called on the goroutine running the passes, so it should return quickly.
+func WithOnCaughtUp(fn func()) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.onCaughtUp = fn
+	}
+}
+
+// WithCaughtUpLag sets how close the watermark has to get to now for the monitor to count as caught
+// up, see WithOnCaughtUp. The default is a minute.
+func WithCaughtUpLag(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.CaughtUpLag = d
+	}
+}
+
+// WithDeleteUploadACLs turns on removing the ACL rule that lets files be written to an upload's
+// directory when the upload is processed, so nothing more can be added to it while it is loaded. It
+// needs a client that implements ACLClient. An ACL that can't be deleted is reported to the ErrorSink
+// as acl_delete_failed, and the upload is loaded anyway.
+func WithDeleteUploadACLs() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.DeleteUploadACLs = true
+	}
+}
+
+// WithExcludeGlobs leaves out the files of an upload whose path in the project matches one of globs,
+// such as the .DS_Store, ~$ and .part files that sync tools leave behind. A glob without a slash, like
+// "*.part", is matched against the file's name in any directory, and one with a slash against its
+// whole path. Excluded files are removed from the upload directory and counted in files_skipped. An
+// upload where every file is excluded doesn't create a file load.
+func WithExcludeGlobs(globs []string) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ExcludeGlobs = globs
+	}
+}
+
+// WithFilterFromWatermark narrows the tasks asked for on each pass to those that completed since the
+// watermark, when it is more recent than the start of the lookback window, so Globus sends fewer
+// tasks. Completion times are filtered by day, so the tasks from earlier on the watermark's day are
+// still sent, and are skipped as before if they have been processed.
+func WithFilterFromWatermark() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.FilterFromWatermark = true
+	}
+}
+
+// WithObjectStore sets the ObjectStore the files of an upload are read and removed through, with the
+// path of each file in the upload directory as its key. The default is the local file system.
+func WithObjectStore(store objectstore.ObjectStore) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.objectStore = store
+	}
+}
+
+// WithProcessingOrder sets the order the steps of processing an upload are taken in. The default is
+// ProcessingOrderACLFirst.
+func WithProcessingOrder(order ProcessingOrder) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.ProcessingOrder = order
+	}
+}
+
+// WithVerifyFileLoadCreated sets whether the file load for an upload is read back, by its globus upload
+// id, before the upload is finished. When it can't be found the upload is left unfinished and tried
+// again on the next pass, rather than being finished with nothing for the file loader to load. It is
+// on by default, and needs a FileLoadStore that is a FileLoadFinder.
+func WithVerifyFileLoadCreated(verify bool) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.SkipVerifyFileLoad = !verify
+	}
+}
+
+// WithStuckThreshold reports uploads that have been in flight for longer than d, such as one hung on
+// a write that never returns. The uploads being processed are checked every poll interval while the
+// monitor runs, and each stuck upload is logged, counted in uploads_stuck and sent to the ErrorSink
+// as upload_stuck, once. The stuck upload is left alone, see CancelUpload.
+func WithStuckThreshold(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.StuckThreshold = d
+	}
+}
+
+// WithMixedUploadPolicy sets what happens to a task whose files were written into more than one globus
+// upload. The default is MixedUploadsSplit.
+func WithMixedUploadPolicy(policy MixedUploadPolicy) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MixedUploadPolicy = policy
+	}
+}
+
+// WithVerifyLoadedFiles checks, d after an upload is processed, that each of the files it handed to
+// the file loader is in the project. The uploads with files that never appeared are counted in
+// loaded_files_missing and sent to the ErrorSink as loaded_files_missing, listing the files. The checks
+// run in the background while the monitor runs, and those not yet due when it stops aren't run.
+func WithVerifyLoadedFiles(d time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.VerifyLoadedFilesAfter = d
+	}
+}
+
+// WithCaseInsensitivePaths sets whether paths that only differ in case, such as Dir/File.txt and
+// dir/file.txt, are the same path, as they are on case insensitive storage. When they are, the files
+// of an upload with such paths are handled by the DuplicateNamePolicy, and files are looked up in the
+// project whatever the case of their path. The default is false.
+func WithCaseInsensitivePaths(caseInsensitive bool) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.CaseInsensitivePaths = caseInsensitive
+	}
+}
+
+// WithPauseWhenDBUnavailable checks the database can be reached at the start of each pass, and while it
+// can't, pauses processing. Globus is still polled, but no upload is processed or marked as done and
+// the watermark stays where it is, so the tasks that completed in the meantime are processed once the
+// database is back. HealthStatus reports DBUnavailable while processing is paused, and the outage is
+// sent to the ErrorSink as db_unavailable when it starts.
+func WithPauseWhenDBUnavailable() Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.PauseWhenDBUnavailable = true
+	}
+}
+
+// WithMaxTransferPages limits the pages of transfers fetched for a task to n, so a task with a huge
+// number of files can't hold up the pass. A task with more pages is logged, counted in
+// transfer_page_limit_exceeded and handled as the TransferPageLimitPolicy says, deferring the rest of
+// its pages to the following passes by default. A value of 0, the default, means there is no limit.
+func WithMaxTransferPages(n int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MaxTransferPages = n
+	}
+}
+
+// WithTransferPageLimitPolicy sets what happens to a task with more pages of transfers than
+// WithMaxTransferPages allows. The default is TransferPagesDefer.
+func WithTransferPageLimitPolicy(policy TransferPageLimitPolicy) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.TransferPageLimitPolicy = policy
+	}
+}
+
+// WithMoveDetection sets whether a directory that was renamed and uploaded again is recognized and
+// moved in the project, rather than its files being loaded again as new files next to the old ones.
+// A directory of an upload that isn't in the project is taken to be a moved one when the files
+// directly in it have the same names and checksums as the files directly in an existing directory.
+// Its files are counted in files_skipped as moved. It needs a ProjectFileStore that is a
+// DirectoryMover. It is off by default.
+func WithMoveDetection(enabled bool) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MoveDetection = enabled
+	}
+}
+
+// WithMoveDetectionProjects limits WithMoveDetection to the given projects. A copy of a directory
+// looks the same as a renamed one, and would move the original away, so this keeps it to the
+// projects whose owners have asked for it. It doesn't turn move detection on by itself.
+func WithMoveDetectionProjects(projectIDs []int) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.MoveDetectionProjects = projectIDs
+	}
+}
+
+// WithHeartbeat has the monitor record a heartbeat for its endpoint and instance while it runs, and
+// check for the live heartbeats of other monitors for the endpoint when it starts, so two monitors
+// accidentally run for the same endpoint are caught. A heartbeat is live for ttl after it is recorded,
+// and is recorded every third of ttl. Another monitor running is counted in duplicate_monitors, sent
+// to the ErrorSink as duplicate_monitor, and then handled as the DuplicateMonitorPolicy says. Monitors
+// are told apart by their host and process id, with their instance name (see WithInstanceName)
+// alongside when they have one, so replicas given the same name are still caught.
+func WithHeartbeat(ttl time.Duration) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.HeartbeatTTL = ttl
+	}
+}
+
+// WithDuplicateMonitorPolicy sets what a monitor does when it starts and finds another monitor
+// running for the same endpoint, see WithHeartbeat. The default is DuplicateMonitorWarn.
+func WithDuplicateMonitorPolicy(policy DuplicateMonitorPolicy) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.config.DuplicateMonitorPolicy = policy
+	}
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProcessedGlobusUpload records a globus upload that the monitor has processed. The table is both an
+// audit trail and a durable version of the in memory set of finished uploads, so a restarted monitor
+// doesn't process an upload a second time. OwnerID is the user the upload belongs to, or 0 when it
+// isn't known. SubmitterIdentity is the Globus identity that submitted the task, which isn't
+// necessarily the owner's. CompletionTime is when Globus completed the task, and LatencySeconds is how
+// long after that it was processed, for reporting how quickly uploads are processed. Both are left
+// unset when the task's completion time isn't known.
+type ProcessedGlobusUpload struct {
+	ID                int        `json:"id"`
+	GlobusUploadID    string     `json:"globus_upload_id"`
+	TaskID            string     `json:"task_id"`
+	OwnerID           int        `json:"owner_id"`
+	SubmitterIdentity string     `json:"submitter_identity"`
+	CompletionTime    *time.Time `json:"completion_time"`
+	ProcessedAt       time.Time  `json:"processed_at"`
+	LatencySeconds    float64    `json:"latency_seconds"`
+}
+
+func (ProcessedGlobusUpload) TableName() string {
+	return "processed_globus_uploads"
+}
+
+// ProcessedUploadStore persists the ProcessedGlobusUpload entries.
+type ProcessedUploadStore interface {
+	AddProcessedUpload(upload *ProcessedGlobusUpload) error
+	IsProcessed(globusUploadID string) (bool, error)
+	DeleteProcessedUploadsBefore(t time.Time) (int64, error)
+	OwnersProcessedSince(t time.Time) ([]int, error)
+}
+
+// SubmitterOwnerFinder is implemented by a ProcessedUploadStore that can find the user a Globus
+// identity has had globus uploads processed for. The store NewGlobusTaskMonitor creates implements it.
+type SubmitterOwnerFinder interface {
+	// OwnerForSubmitter returns the owner of the globus upload most recently processed for a task
+	// submitted by identity, and gorm.ErrRecordNotFound when there isn't one. Archive tasks aren't
+	// looked at, as their owner is taken from their paths.
+	OwnerForSubmitter(identity string) (int, error)
+}
+
+var _ SubmitterOwnerFinder = (*dbProcessedUploadStore)(nil)
+
+type dbProcessedUploadStore struct {
+	db *gorm.DB
+}
+
+func newDBProcessedUploadStore(db *gorm.DB) *dbProcessedUploadStore {
+	return &dbProcessedUploadStore{db: db}
+}
+
+func (s *dbProcessedUploadStore) AddProcessedUpload(upload *ProcessedGlobusUpload) error {
+	return s.db.Create(upload).Error
+}
+
+func (s *dbProcessedUploadStore) IsProcessed(globusUploadID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&ProcessedGlobusUpload{}).
+		Where("globus_upload_id = ?", globusUploadID).
+		Count(&count).Error
+	return count != 0, err
+}
+
+func (s *dbProcessedUploadStore) DeleteProcessedUploadsBefore(t time.Time) (int64, error) {
+	result := s.db.Where("processed_at < ?", t).Delete(&ProcessedGlobusUpload{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *dbProcessedUploadStore) OwnersProcessedSince(t time.Time) ([]int, error) {
+	var ownerIDs []int
+	err := s.db.Model(&ProcessedGlobusUpload{}).
+		Distinct("owner_id").
+		Where("processed_at >= ? AND owner_id > 0", t).
+		Order("owner_id").
+		Pluck("owner_id", &ownerIDs).Error
+	return ownerIDs, err
+}
+
+func (s *dbProcessedUploadStore) OwnerForSubmitter(identity string) (int, error) {
+	var upload ProcessedGlobusUpload
+	err := s.db.Where("submitter_identity = ? AND owner_id > 0", identity).
+		Where("globus_upload_id NOT LIKE ?", archiveTaskPrefix+"%").
+		Order("processed_at DESC").
+		First(&upload).Error
+	return upload.OwnerID, err
+}
@@ -0,0 +1,30 @@
+package monitor
+
+import "context"
+
+// processingSlots bounds how many tasks can be processed at once. It is shared by every monitor
+// given the same WithGlobalConcurrency Option, so monitors for different endpoints draw from a single
+// budget rather than each having their own.
+type processingSlots chan struct{}
+
+// acquireProcessingSlot waits for a processing slot, returning false if ctx is cancelled first. It
+// always succeeds straight away when there is no global limit.
+func (m *GlobusTaskMonitor) acquireProcessingSlot(ctx context.Context) bool {
+	if m.processingSlots == nil {
+		return true
+	}
+
+	select {
+	case m.processingSlots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseProcessingSlot gives back a slot taken by acquireProcessingSlot.
+func (m *GlobusTaskMonitor) releaseProcessingSlot() {
+	if m.processingSlots != nil {
+		<-m.processingSlots
+	}
+}
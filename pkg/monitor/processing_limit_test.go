@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// overlapFileLoadStore is a FileLoadStore that takes a while to add each file load, and records the
+// most that were being added at the same time.
+type overlapFileLoadStore struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	added     int
+}
+
+func (s *overlapFileLoadStore) AddFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	s.mu.Lock()
+	s.active++
+	if s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+	s.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	s.added++
+	return nil
+}
+
+func TestGlobalConcurrencyAcrossEndpoints(t *testing.T) {
+	endpointIDs := []string{testEndpointID, testEndpointID2}
+	client := NewFakeGlobusClient()
+	for i := 1; i <= 6; i++ {
+		taskID := "task-" + strconv.Itoa(i)
+		client.Tasks = append(client.Tasks, globus.Task{TaskID: taskID, Status: "SUCCEEDED", CompletionTime: testCompletionTime, TaskExtras: globus.TaskExtras{DestinationEndpointID: endpointIDs[i%2]}})
+		client.Transfers[taskID] = []globus.Transfer{{DestinationPath: "/__globus_uploads/" + strconv.Itoa(i) + "/file.txt"}}
+	}
+
+	store := &overlapFileLoadStore{}
+	mm := NewMultiEndpointMonitor(client, nil, endpointIDs, Config{}, nil, WithGlobalConcurrency(1))
+	for _, id := range endpointIDs {
+		m := mm.Monitor(id)
+		m.processedUploads = newFakeProcessedUploadStore()
+		m.globusUploads = newFakeGlobusUploadStore()
+		m.projects = newFakeProjectStore()
+		m.fileLoads = store
+		seedGlobusUploads(m, 1, 2, 3, 4, 5, 6)
+	}
+
+	var wg sync.WaitGroup
+	processed := make([]int, len(endpointIDs))
+	for i, id := range endpointIDs {
+		wg.Add(1)
+		go func(i int, m *GlobusTaskMonitor) {
+			defer wg.Done()
+			processed[i] = m.retrieveAndProcessUploads(context.Background()).TasksProcessed
+		}(i, mm.Monitor(id))
+	}
+	wg.Wait()
+
+	require.Equal(t, []int{3, 3}, processed)
+	require.Equal(t, 6, store.added)
+	require.Equal(t, 1, store.maxActive)
+}
+
+func TestGlobalConcurrencyZeroMeansNoLimit(t *testing.T) {
+	m := newTestMonitor(NewFakeGlobusClient(), WithGlobalConcurrency(0))
+	require.Nil(t, m.processingSlots)
+	require.True(t, m.acquireProcessingSlot(context.Background()))
+	m.releaseProcessingSlot()
+}
+
+func TestAcquireProcessingSlotGivesUpWhenCancelled(t *testing.T) {
+	m := newTestMonitor(NewFakeGlobusClient(), WithGlobalConcurrency(1))
+	require.True(t, m.acquireProcessingSlot(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, m.acquireProcessingSlot(ctx))
+
+	m.releaseProcessingSlot()
+	require.True(t, m.acquireProcessingSlot(context.Background()))
+}
@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ProcessingOrder controls the order the steps of processing an upload are taken in. Whichever order
+// is used, an upload is only finished, and its globus_uploads entry deleted, once every step has
+// succeeded, so a pass that fails part way through leaves the upload to be picked up again on the
+// next pass.
+type ProcessingOrder int
+
+const (
+	// ProcessingOrderACLFirst deletes the upload's ACL, adds the file load and then finishes and
+	// deletes the upload. A failure to delete the ACL is reported but doesn't stop the file load
+	// from being added. As with ProcessingOrderLoadFirst, the file load is added idempotently.
+	ProcessingOrderACLFirst ProcessingOrder = iota
+
+	// ProcessingOrderLoadFirst adds the file load, then deletes the ACL, then finishes and deletes the
+	// upload. The file load is added idempotently: when the upload already has one, from a pass that
+	// failed to delete the ACL, that one is kept rather than a second being added. A failure to
+	// delete the ACL is reported and the upload is left unfinished so the deletion is tried again on
+	// the next pass.
+	ProcessingOrderLoadFirst
+)
+
+// addFileLoad adds fileLoad. With a FileLoadStore that is a FileLoadFinder, an existing file load for
+// the same upload, added by a pass that failed after adding it, is used instead, and fileLoad is
+// filled in from it. That is the case whatever the ProcessingOrder, since any step after the file
+// load, such as verifying it, can have the upload retried.
+func (m *GlobusTaskMonitor) addFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	finder, ok := m.fileLoads.(FileLoadFinder)
+	if !ok {
+		return m.fileLoads.AddFileLoad(ctx, fileLoad)
+	}
+
+	existing, err := finder.FindFileLoad(ctx, fileLoad.GlobusUploadID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return m.fileLoads.AddFileLoad(ctx, fileLoad)
+	case err != nil:
+		return err
+	}
+
+	m.logger.Infof("Using existing file load (id: %d) for globus upload %d", existing.ID, fileLoad.GlobusUploadID)
+	*fileLoad = *existing
+	return nil
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// setupProcessingOrder returns a monitor that deletes ACLs, using order, with one upload to process.
+func setupProcessingOrder(order ProcessingOrder) (*GlobusTaskMonitor, *FakeGlobusClient, *fakeFileLoadStore) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client, WithDeleteUploadACLs(), WithProcessingOrder(order))
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, GlobusAclID: "acl-1"})
+	return m, client, m.fileLoads.(*fakeFileLoadStore)
+}
+
+func TestACLFirstRecoversFromFileLoadFailure(t *testing.T) {
+	m, client, fileLoads := setupProcessingOrder(ProcessingOrderACLFirst)
+	fileLoads.err = errors.New("insert failed")
+	fileLoads.failures = 1
+
+	// The ACL is deleted before the file load fails, and the upload is left to be retried.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, []string{"acl-1"}, client.DeletedACLs)
+	require.Empty(t, fileLoads.fileLoads)
+	require.False(t, m.isFinished("1"))
+	require.True(t, uploadExists(m, "1"))
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.True(t, m.isFinished("1"))
+	require.False(t, uploadExists(m, "1"))
+}
+
+func TestLoadFirstRecoversFromFileLoadFailure(t *testing.T) {
+	m, client, fileLoads := setupProcessingOrder(ProcessingOrderLoadFirst)
+	fileLoads.err = errors.New("insert failed")
+	fileLoads.failures = 1
+
+	// The ACL is left alone until there is a file load, so the upload is untouched when it fails.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Empty(t, client.DeletedACLs)
+	require.Empty(t, fileLoads.fileLoads)
+	require.False(t, m.isFinished("1"))
+	require.True(t, uploadExists(m, "1"))
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.Equal(t, []string{"acl-1"}, client.DeletedACLs)
+	require.True(t, m.isFinished("1"))
+	require.False(t, uploadExists(m, "1"))
+}
+
+func TestLoadFirstRecoversFromACLFailure(t *testing.T) {
+	m, client, fileLoads := setupProcessingOrder(ProcessingOrderLoadFirst)
+	client.Script("DeleteEndpointACLRule", FakeCall{Err: errors.New("request failed")})
+	sink := &fakeErrorSink{}
+	m.errorSink = sink
+
+	// The file load is kept when the ACL can't be deleted, but the upload isn't finished.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.False(t, m.isFinished("1"))
+	require.True(t, uploadExists(m, "1"))
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "acl_delete_failed", sink.events[0].Kind)
+
+	// The next pass deletes the ACL and uses the file load that is already there.
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.Equal(t, []string{"acl-1", "acl-1"}, client.DeletedACLs)
+	require.True(t, m.isFinished("1"))
+	require.False(t, uploadExists(m, "1"))
+}
+
+func TestACLFirstFinishesDespiteACLFailure(t *testing.T) {
+	m, client, fileLoads := setupProcessingOrder(ProcessingOrderACLFirst)
+	client.Script("DeleteEndpointACLRule", FakeCall{Err: errors.New("request failed")})
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.True(t, m.isFinished("1"))
+	require.False(t, uploadExists(m, "1"))
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+)
+
+// projectLocks serializes the processing of uploads to the same project, so two uploads that
+// complete at about the same time don't interleave their changes to the project. Uploads to
+// different projects are processed in parallel. The monitors created by NewMultiEndpointMonitor
+// share one, since uploads to a project can arrive through any of the endpoints.
+type projectLocks struct {
+	mu    sync.Mutex
+	locks map[int]*projectLock
+}
+
+// projectLock is the lock for a single project. users counts the holders and waiters, so the lock
+// can be dropped once nobody needs it.
+type projectLock struct {
+	held  chan struct{}
+	users int
+}
+
+func newProjectLocks() *projectLocks {
+	return &projectLocks{locks: make(map[int]*projectLock)}
+}
+
+// lock waits for the lock on projectID, returning false if ctx is cancelled first. When it returns
+// true the lock must be given back with unlock.
+func (l *projectLocks) lock(ctx context.Context, projectID int) bool {
+	l.mu.Lock()
+	pl, ok := l.locks[projectID]
+	if !ok {
+		pl = &projectLock{held: make(chan struct{}, 1)}
+		l.locks[projectID] = pl
+	}
+	pl.users++
+	l.mu.Unlock()
+
+	select {
+	case pl.held <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		l.release(projectID, pl)
+		return false
+	}
+}
+
+// unlock gives back the lock on projectID taken by lock.
+func (l *projectLocks) unlock(projectID int) {
+	l.mu.Lock()
+	pl := l.locks[projectID]
+	l.mu.Unlock()
+
+	<-pl.held
+	l.release(projectID, pl)
+}
+
+// release drops a user of pl, removing it once it has none.
+func (l *projectLocks) release(projectID int, pl *projectLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pl.users--
+	if pl.users == 0 {
+		delete(l.locks, projectID)
+	}
+}
+
+// withProjectLocks makes the monitor use locks rather than its own projectLocks.
+func withProjectLocks(locks *projectLocks) Option {
+	return func(m *GlobusTaskMonitor) {
+		m.projectLocks = locks
+	}
+}
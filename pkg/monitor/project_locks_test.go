@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// projectOverlapFileLoadStore is a FileLoadStore that takes a while to add each file load, and
+// records the most that were being added at the same time, overall and for each project.
+type projectOverlapFileLoadStore struct {
+	mu             sync.Mutex
+	active         map[int]int
+	maxActive      map[int]int
+	totalActive    int
+	maxTotalActive int
+}
+
+func (s *projectOverlapFileLoadStore) AddFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	s.mu.Lock()
+	s.active[fileLoad.ProjectID]++
+	if s.active[fileLoad.ProjectID] > s.maxActive[fileLoad.ProjectID] {
+		s.maxActive[fileLoad.ProjectID] = s.active[fileLoad.ProjectID]
+	}
+	s.totalActive++
+	if s.totalActive > s.maxTotalActive {
+		s.maxTotalActive = s.totalActive
+	}
+	s.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[fileLoad.ProjectID]--
+	s.totalActive--
+	return nil
+}
+
+func TestUploadsToAProjectAreSerialized(t *testing.T) {
+	// Each upload arrives through its own endpoint, so they are all processed at the same time
+	// unless something stops them. Uploads 1 and 2 are to project 1, upload 3 is to project 2.
+	endpointIDs := []string{testEndpointID, testEndpointID2, testEndpointID3}
+	projectIDs := []int{1, 1, 2}
+	client := NewFakeGlobusClient()
+	for i, endpointID := range endpointIDs {
+		taskID := "task-" + strconv.Itoa(i+1)
+		client.Tasks = append(client.Tasks, globus.Task{TaskID: taskID, Status: "SUCCEEDED", CompletionTime: testCompletionTime, TaskExtras: globus.TaskExtras{DestinationEndpointID: endpointID}})
+		client.Transfers[taskID] = []globus.Transfer{{DestinationPath: "/__globus_uploads/" + strconv.Itoa(i+1) + "/file.txt"}}
+	}
+
+	store := &projectOverlapFileLoadStore{active: make(map[int]int), maxActive: make(map[int]int)}
+	mm := NewMultiEndpointMonitor(client, nil, endpointIDs, Config{}, nil)
+	for _, id := range endpointIDs {
+		m := mm.Monitor(id)
+		m.processedUploads = newFakeProcessedUploadStore()
+		m.projects = newFakeProjectStore()
+		m.fileLoads = store
+		uploads := newFakeGlobusUploadStore()
+		for i, projectID := range projectIDs {
+			uploads.add(&GlobusUpload{ID: i + 1, ProjectID: projectID, OwnerID: 1})
+		}
+		m.globusUploads = uploads
+	}
+
+	var wg sync.WaitGroup
+	processed := make([]int, len(endpointIDs))
+	for i, id := range endpointIDs {
+		wg.Add(1)
+		go func(i int, m *GlobusTaskMonitor) {
+			defer wg.Done()
+			processed[i] = m.retrieveAndProcessUploads(context.Background()).TasksProcessed
+		}(i, mm.Monitor(id))
+	}
+	wg.Wait()
+
+	require.Equal(t, []int{1, 1, 1}, processed)
+	require.Equal(t, map[int]int{1: 1, 2: 1}, store.maxActive)
+	require.Equal(t, 2, store.maxTotalActive)
+}
+
+func TestProjectLockGivesUpWhenCancelled(t *testing.T) {
+	locks := newProjectLocks()
+	require.True(t, locks.lock(context.Background(), 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, locks.lock(ctx, 1))
+
+	// Other projects aren't held up.
+	require.True(t, locks.lock(context.Background(), 2))
+	locks.unlock(2)
+
+	locks.unlock(1)
+	require.True(t, locks.lock(context.Background(), 1))
+	locks.unlock(1)
+	require.Empty(t, locks.locks)
+}
@@ -0,0 +1,38 @@
+package monitor
+
+import "sort"
+
+// ProjectRef identifies a project whose files were changed by a user's transfer.
+type ProjectRef struct {
+	UserID    int
+	ProjectID int
+}
+
+// projectRefs is the set of projects touched during a pass.
+type projectRefs map[ProjectRef]bool
+
+func (refs projectRefs) add(userID, projectID int) {
+	refs[ProjectRef{UserID: userID, ProjectID: projectID}] = true
+}
+
+// sorted returns the projects ordered by user and then project, or nil if there are none.
+func (refs projectRefs) sorted() []ProjectRef {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	sortedRefs := make([]ProjectRef, 0, len(refs))
+	for ref := range refs {
+		sortedRefs = append(sortedRefs, ref)
+	}
+
+	sort.Slice(sortedRefs, func(i, j int) bool {
+		if sortedRefs[i].UserID != sortedRefs[j].UserID {
+			return sortedRefs[i].UserID < sortedRefs[j].UserID
+		}
+
+		return sortedRefs[i].ProjectID < sortedRefs[j].ProjectID
+	})
+
+	return sortedRefs
+}
@@ -0,0 +1,50 @@
+package monitor
+
+import (
+	globus "github.com/materials-commons/goglobus"
+	"gorm.io/gorm"
+)
+
+// ProjectStore checks on the projects that uploads are loaded into.
+type ProjectStore interface {
+	// ProjectExists returns false when the project has been deleted, including when it has only been
+	// soft deleted.
+	ProjectExists(projectID int) (bool, error)
+}
+
+type dbProjectStore struct {
+	db *gorm.DB
+}
+
+func newDBProjectStore(db *gorm.DB) *dbProjectStore {
+	return &dbProjectStore{db: db}
+}
+
+func (s *dbProjectStore) ProjectExists(projectID int) (bool, error) {
+	var count int64
+	err := s.db.Table("projects").
+		Where("id = ?", projectID).
+		Where("deleted_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+// checkProjectExists returns whether the project a task's files are going into still exists, logging
+// the reason when it doesn't. The second value is false when the database couldn't be checked, in
+// which case the task should be retried on a later pass.
+func (m *GlobusTaskMonitor) checkProjectExists(task globus.Task, projectID int) (exists bool, checked bool) {
+	exists, err := m.projects.ProjectExists(projectID)
+	switch {
+	case err != nil:
+		m.logger.Errorf("Unable to check if project %d for globus task %s exists: %s", projectID, task.TaskID, err)
+		return false, false
+	case !exists:
+		m.logger.Infof("Skipping files from globus task %s: project %d has been deleted", task.TaskID, projectID)
+	}
+
+	return exists, true
+}
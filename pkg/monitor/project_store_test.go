@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadToDeletedProjectIsSkipped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 99, OwnerID: 1})
+	seedGlobusUploads(m, 2)
+	m.projects.(*fakeProjectStore).deleted[99] = true
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Equal(t, []ProjectRef{{UserID: 1, ProjectID: 1}}, result.TouchedProjects)
+
+	fileLoads := m.fileLoads.(*fakeFileLoadStore).fileLoads
+	require.Len(t, fileLoads, 1)
+	require.Equal(t, 2, fileLoads[0].GlobusUploadID)
+
+	// The upload to the deleted project is finished with, so it isn't looked at again.
+	require.True(t, m.finishedGlobusTasks["1"])
+	labels := Labels{"endpoint": testEndpointID, "reason": "project_deleted"}
+	require.Equal(t, 1.0, metrics.counter("tasks_skipped", labels))
+}
+
+func TestProjectCheckFailureRetriesUpload(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+	m.projects.(*fakeProjectStore).err = errors.New("database unavailable")
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.False(t, m.finishedGlobusTasks["1"])
+
+	m.projects.(*fakeProjectStore).err = nil
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+}
+
+func TestArchiveFilesForDeletedProjectAreDropped(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/archive/1/2/c1/file.txt", "/__transfers/archive/1/3/c1/file.txt")
+	processor := &fakeArchiveProcessor{files: make(map[string][]*mcpath.TransferPathContext)}
+	m := newTestMonitor(client, WithArchiveProcessor(processor))
+	m.projects.(*fakeProjectStore).deleted[3] = true
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Equal(t, []ProjectRef{{UserID: 1, ProjectID: 2}}, result.TouchedProjects)
+	require.Len(t, processor.files["task-1"], 1)
+	require.Equal(t, 2, processor.files["task-1"][0].ProjectID)
+}
@@ -0,0 +1,48 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// UploadEventTopic is the topic UploadEvents are published to.
+const UploadEventTopic = "globus.uploads.processed"
+
+// Publisher sends events to a message queue, such as NATS or SQS, so that processing uploads can be
+// handled outside of the monitor. The default does nothing; WithPublisher sets a real one.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	return nil
+}
+
+// UploadEvent is published, as JSON, once a globus upload has been turned into a file load.
+type UploadEvent struct {
+	GlobusUploadID string    `json:"globus_upload_id"`
+	TaskID         string    `json:"task_id"`
+	FileLoadID     int       `json:"file_load_id"`
+	ProjectID      int       `json:"project_id"`
+	OwnerID        int       `json:"owner_id"`
+	Path           string    `json:"path"`
+	Files          int       `json:"files"`
+	ProcessedAt    time.Time `json:"processed_at"`
+}
+
+// publishUploadEvent publishes the event for a processed upload. The upload has already been
+// processed, so a failure is only logged and counted rather than causing the upload to be retried.
+func (m *GlobusTaskMonitor) publishUploadEvent(ctx context.Context, event UploadEvent) {
+	payload, err := json.Marshal(event)
+	if err == nil {
+		err = m.publisher.Publish(ctx, UploadEventTopic, payload)
+	}
+
+	if err != nil {
+		m.logger.Errorf("Unable to publish upload event for globus upload %s: %s", event.GlobusUploadID, err)
+		m.incCounter("publish_errors", nil)
+	}
+}
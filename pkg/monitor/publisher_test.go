@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPublisher records what is published to it.
+type memoryPublisher struct {
+	mu       sync.Mutex
+	messages map[string][][]byte
+	err      error
+}
+
+func newMemoryPublisher() *memoryPublisher {
+	return &memoryPublisher{messages: make(map[string][][]byte)}
+}
+
+func (p *memoryPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+
+	p.messages[topic] = append(p.messages[topic], payload)
+	return nil
+}
+
+func TestProcessedUploadIsPublished(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/file.txt", "/__globus_uploads/1/other.txt")
+	publisher := newMemoryPublisher()
+	m := newTestMonitor(client, WithPublisher(publisher))
+	m.now = func() time.Time { return now }
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 2, OwnerID: 3, Path: "/uploads/1"})
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, publisher.messages[UploadEventTopic], 1)
+
+	var event UploadEvent
+	require.NoError(t, json.Unmarshal(publisher.messages[UploadEventTopic][0], &event))
+	expected := UploadEvent{
+		GlobusUploadID: "1",
+		TaskID:         "task-1",
+		FileLoadID:     1,
+		ProjectID:      2,
+		OwnerID:        3,
+		Path:           "/uploads/1",
+		Files:          2,
+		ProcessedAt:    now,
+	}
+	require.Equal(t, expected, event)
+}
+
+func TestPublishFailureDoesNotRetryUpload(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	publisher := newMemoryPublisher()
+	publisher.err = errors.New("queue unavailable")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithPublisher(publisher), WithMetrics(metrics))
+	seedGlobusUploads(m, 1)
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.True(t, m.finishedGlobusTasks["1"])
+	require.Equal(t, 1.0, metrics.counter("publish_errors", Labels{"endpoint": testEndpointID}))
+}
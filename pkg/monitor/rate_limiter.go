@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter paces events to a steady rate, while letting up to burst of them through at once after
+// a quiet spell. It tracks the time the next event is due, and an event waits until it is no more
+// than burst-1 intervals early.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	due      time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing rate events a second, or nil when rate isn't positive
+// to mean there is no limit. A burst below 1 is treated as 1.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate), burst: burst}
+}
+
+// Wait blocks until the next event is allowed, or ctx is cancelled. A nil rateLimiter never blocks.
+// An event that is cancelled while waiting still uses up its place.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	due := l.due
+	if due.Before(now) {
+		due = now
+	}
+	l.due = due.Add(l.interval)
+	delay := due.Sub(now) - time.Duration(l.burst-1)*l.interval
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
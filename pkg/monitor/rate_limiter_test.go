@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// timedFileLoadStore is a FileLoadStore that records when each file load was added.
+type timedFileLoadStore struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (s *timedFileLoadStore) AddFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.times = append(s.times, time.Now())
+	fileLoad.ID = len(s.times)
+	return nil
+}
+
+func TestFileLoadRate(t *testing.T) {
+	client := NewFakeGlobusClient()
+	var ids []int
+	for i := 1; i <= 5; i++ {
+		client.AddUpload("task-"+strconv.Itoa(i), "/__globus_uploads/"+strconv.Itoa(i)+"/file.txt")
+		ids = append(ids, i)
+	}
+
+	store := &timedFileLoadStore{}
+	m := newTestMonitor(client, WithFileLoadRate(50, 2))
+	m.fileLoads = store
+	seedGlobusUploads(m, ids...)
+
+	require.Equal(t, 5, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, store.times, 5)
+
+	// The first two make up the burst, after that they are at least 20ms apart.
+	interval := 20 * time.Millisecond
+	for i := 2; i < len(store.times); i++ {
+		require.GreaterOrEqual(t, int64(store.times[i].Sub(store.times[i-1])), int64(interval-2*time.Millisecond), "file load %d", i)
+	}
+	require.GreaterOrEqual(t, int64(store.times[4].Sub(store.times[0])), int64(3*interval-2*time.Millisecond))
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	limiter := newRateLimiter(1, 1)
+	require.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, limiter.Wait(ctx))
+}
+
+func TestNoRateLimit(t *testing.T) {
+	var limiter *rateLimiter
+	require.Nil(t, newRateLimiter(0, 10))
+	require.NoError(t, limiter.Wait(context.Background()))
+}
@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden rewrites the expected outcomes of the recorded response tests from what the monitor
+// does now, see testdata/recorded/README.md.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// recordedOutcome is what the monitor did with a set of recorded responses: the file loads it created,
+// the uploads it finished, the ACLs it deleted and the counters it kept. The fields that change from
+// run to run, such as ids and times, are left out.
+type recordedOutcome struct {
+	FileLoads        []recordedFileLoad        `json:"file_loads"`
+	ProcessedUploads []recordedProcessedUpload `json:"processed_uploads"`
+	DeletedACLs      []string                  `json:"deleted_acls"`
+	Counters         map[string]float64        `json:"counters"`
+}
+
+type recordedFileLoad struct {
+	GlobusUploadID    int    `json:"globus_upload_id"`
+	ProjectID         int    `json:"project_id"`
+	OwnerID           int    `json:"owner_id"`
+	Path              string `json:"path"`
+	TaskReference     string `json:"task_reference"`
+	SubmitterIdentity string `json:"submitter_identity"`
+}
+
+type recordedProcessedUpload struct {
+	GlobusUploadID    string `json:"globus_upload_id"`
+	TaskID            string `json:"task_id"`
+	OwnerID           int    `json:"owner_id"`
+	SubmitterIdentity string `json:"submitter_identity"`
+}
+
+// readJSON decodes the JSON file at path into v.
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, v), path)
+}
+
+// loadRecordedResponses sets up client to answer with the task list and successful transfers
+// recorded in dir, and adds the globus_uploads rows recorded there to the monitor's store.
+func loadRecordedResponses(t *testing.T, m *GlobusTaskMonitor, client *FakeGlobusClient, dir string) {
+	t.Helper()
+	var taskList globus.TaskList
+	readJSON(t, filepath.Join(dir, "task_list.json"), &taskList)
+	client.Tasks = taskList.Tasks
+
+	transfers, err := filepath.Glob(filepath.Join(dir, "successful_transfers", "*.json"))
+	require.NoError(t, err)
+	for _, path := range transfers {
+		var items globus.TransferItems
+		readJSON(t, path, &items)
+		client.Transfers[strings.TrimSuffix(filepath.Base(path), ".json")] = items.Transfers
+	}
+
+	var uploads []GlobusUpload
+	readJSON(t, filepath.Join(dir, "globus_uploads.json"), &uploads)
+	for i := range uploads {
+		m.globusUploads.(*fakeGlobusUploadStore).add(&uploads[i])
+	}
+}
+
+func TestRecordedResponses(t *testing.T) {
+	dir := filepath.Join("testdata", "recorded")
+	client := NewFakeGlobusClient()
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithStopAfterIdlePasses(2), WithDeleteUploadACLs(), WithMetrics(metrics))
+	loadRecordedResponses(t, m, client, dir)
+
+	runUntilStopped(t, m)
+
+	got := recordedOutcome{DeletedACLs: client.DeletedACLs, Counters: metrics.counters}
+	for _, fileLoad := range m.fileLoads.(*fakeFileLoadStore).fileLoads {
+		got.FileLoads = append(got.FileLoads, recordedFileLoad{
+			GlobusUploadID:    fileLoad.GlobusUploadID,
+			ProjectID:         fileLoad.ProjectID,
+			OwnerID:           fileLoad.OwnerID,
+			Path:              fileLoad.Path,
+			TaskReference:     fileLoad.TaskReference,
+			SubmitterIdentity: fileLoad.SubmitterIdentity,
+		})
+	}
+	for _, upload := range m.processedUploads.(*fakeProcessedUploadStore).uploads {
+		got.ProcessedUploads = append(got.ProcessedUploads, recordedProcessedUpload{
+			GlobusUploadID:    upload.GlobusUploadID,
+			TaskID:            upload.TaskID,
+			OwnerID:           upload.OwnerID,
+			SubmitterIdentity: upload.SubmitterIdentity,
+		})
+	}
+	sort.Slice(got.FileLoads, func(i, j int) bool { return got.FileLoads[i].GlobusUploadID < got.FileLoads[j].GlobusUploadID })
+	sort.Slice(got.ProcessedUploads, func(i, j int) bool {
+		return got.ProcessedUploads[i].GlobusUploadID < got.ProcessedUploads[j].GlobusUploadID
+	})
+	sort.Strings(got.DeletedACLs)
+
+	goldenPath := filepath.Join(dir, "expected.json")
+	if *updateGolden {
+		b, err := json.MarshalIndent(got, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(goldenPath, append(b, '\n'), 0644))
+	}
+
+	var expected recordedOutcome
+	readJSON(t, goldenPath, &expected)
+	require.Equal(t, expected, got)
+}
@@ -0,0 +1,47 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReprocessTask runs a single task through processing again, whether or not it has been processed
+// before. It is for debugging a task whose upload didn't turn out as expected. Unlike a pass it
+// ignores the task filters and doesn't move the watermark. Processing an upload deletes its
+// globus_uploads entry, which has to be restored first, otherwise the upload is ignored as already
+// loaded. The monitor has no dry run mode, use
+// AuditRange to see what would be processed without processing it.
+func (m *GlobusTaskMonitor) ReprocessTask(ctx context.Context, taskID string) (PassResult, error) {
+	m.passMu.Lock()
+	defer m.passMu.Unlock()
+
+	var result PassResult
+	tasks, err := m.listTasks(map[string]string{"filter_task_id": taskID})
+	if err != nil {
+		return result, fmt.Errorf("unable to get globus task %s: %w", taskID, m.client.ExtractError(err))
+	}
+
+	var fetch transferFetch
+	for _, task := range tasks {
+		if task.TaskID == taskID {
+			fetch.task = task
+			break
+		}
+	}
+
+	if fetch.task.TaskID == "" {
+		return result, fmt.Errorf("globus task %s not found for endpoint %s", taskID, m.endpointID)
+	}
+
+	fetch.transfers, err = m.getTaskTransfers(ctx, taskID)
+	if err != nil {
+		return result, fmt.Errorf("unable to get transfers for globus task %s: %w", taskID, m.client.ExtractError(err))
+	}
+
+	result.TasksSeen = 1
+	touched := make(projectRefs)
+	m.processFetchedTask(ctx, fetch, touched, &result, true)
+	result.TouchedProjects = touched.sorted()
+
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReprocessTask(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1, 2)
+
+	require.Equal(t, 2, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	watermark := m.lastProcessedTime
+
+	// Processing deleted the upload, so it has to be put back before it can be processed again.
+	seedGlobusUploads(m, 2)
+	result, err := m.ReprocessTask(context.Background(), "task-2")
+	require.NoError(t, err)
+	require.Equal(t, PassResult{TasksSeen: 1, TasksProcessed: 1, TouchedProjects: []ProjectRef{{UserID: 1, ProjectID: 1}}}, result)
+
+	// Only the one task was processed again, and the watermark wasn't moved.
+	fileLoads := m.fileLoads.(*fakeFileLoadStore).fileLoads
+	require.Len(t, fileLoads, 3)
+	require.Equal(t, 2, fileLoads[2].GlobusUploadID)
+	require.Equal(t, watermark, m.lastProcessedTime)
+}
+
+func TestReprocessArchiveTask(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__transfers/archive/1/2/c1/file.txt")
+	processor := &fakeArchiveProcessor{files: make(map[string][]*mcpath.TransferPathContext)}
+	m := newTestMonitor(client, WithArchiveProcessor(processor))
+	m.finishedGlobusTasks["archive:task-1"] = true
+
+	result, err := m.ReprocessTask(context.Background(), "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Len(t, processor.files["task-1"], 1)
+}
+
+func TestReprocessUnknownTask(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client)
+
+	_, err := m.ReprocessTask(context.Background(), "task-9")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "task-9 not found")
+	require.Equal(t, 0, client.TransferCalls)
+}
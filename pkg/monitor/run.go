@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apex/log"
+	globus "github.com/materials-commons/goglobus"
+	mcdb "github.com/materials-commons/gomcdb"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// RunConfig has everything Run needs to set up and run a monitor.
+type RunConfig struct {
+	// GlobusClientUser and GlobusClientToken are the id and secret of the Globus confidential client
+	// the monitor uses.
+	GlobusClientUser  string
+	GlobusClientToken string
+
+	// EndpointID is the Globus endpoint to monitor.
+	EndpointID string
+
+	// DSN is the database to connect to. When blank it is built from the environment.
+	DSN string
+
+	// Monitor holds the monitor settings. Zero values are replaced with defaults.
+	Monitor Config
+}
+
+// These are variables so tests can run without Globus or a database.
+var (
+	newGlobusClientFn = newGlobusClient
+	openDBFn          = openDB
+)
+
+// Run connects to Globus and the database, and runs a GlobusTaskMonitor until ctx is cancelled or
+// the process receives SIGINT or SIGTERM. It only returns once the monitor has shut down.
+func Run(ctx context.Context, config RunConfig) error {
+	client, err := newGlobusClientFn(config)
+	if err != nil {
+		return fmt.Errorf("unable to create globus client: %w", err)
+	}
+
+	db, err := openDBFn(config)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	go func() {
+		select {
+		case sig := <-c:
+			log.Infof("Got %s signal, stopping globus monitoring...", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	m := NewGlobusTaskMonitor(client, db, config.EndpointID, withConfig(config.Monitor))
+	return m.Run(ctx)
+}
+
+// withConfig copies the non zero settings in config over the monitor's defaults.
+func withConfig(config Config) Option {
+	return func(m *GlobusTaskMonitor) {
+		defaults := m.config
+		m.config = config
+		if m.config.PollInterval == 0 {
+			m.config.PollInterval = defaults.PollInterval
+		}
+
+		if m.config.WatermarkLagWarning == 0 {
+			m.config.WatermarkLagWarning = defaults.WatermarkLagWarning
+		}
+
+		if m.config.PathNormalizer == nil {
+			m.config.PathNormalizer = defaults.PathNormalizer
+		}
+
+		if m.config.TransferFetchConcurrency == 0 {
+			m.config.TransferFetchConcurrency = defaults.TransferFetchConcurrency
+		}
+
+		if m.config.TaskLookback == 0 {
+			m.config.TaskLookback = defaults.TaskLookback
+		}
+
+		if m.config.TaskPageSize == 0 {
+			m.config.TaskPageSize = defaults.TaskPageSize
+		}
+
+		if m.config.ShutdownFlushTimeout == 0 {
+			m.config.ShutdownFlushTimeout = defaults.ShutdownFlushTimeout
+		}
+
+		if m.config.ClockSkewWarning == 0 {
+			m.config.ClockSkewWarning = defaults.ClockSkewWarning
+		}
+
+		if m.config.FilterTimeZone == nil {
+			m.config.FilterTimeZone = defaults.FilterTimeZone
+		}
+
+		if m.config.DBRetries == 0 {
+			m.config.DBRetries = defaults.DBRetries
+		}
+
+		if m.config.DBRetryBackoff == 0 {
+			m.config.DBRetryBackoff = defaults.DBRetryBackoff
+		}
+
+		if m.config.CaughtUpLag == 0 {
+			m.config.CaughtUpLag = defaults.CaughtUpLag
+		}
+	}
+}
+
+func newGlobusClient(config RunConfig) (GlobusClient, error) {
+	client, err := globus.CreateConfidentialClient(config.GlobusClientUser, config.GlobusClientToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Authenticate(); err != nil {
+		return nil, err
+	}
+
+	return NewGlobusClient(client), nil
+}
+
+func openDB(config RunConfig) (*gorm.DB, error) {
+	dsn := config.DSN
+	if dsn == "" {
+		dsn = mcdb.MakeDSNFromEnv()
+	}
+
+	gormConfig := &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	}
+
+	return gorm.Open(mysql.Open(dsn), gormConfig)
+}
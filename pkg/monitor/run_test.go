@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// withFakeRunDeps makes Run use client and no database for the rest of the test.
+func withFakeRunDeps(t *testing.T, client GlobusClient, dbErr error) {
+	newGlobusClientFn = func(config RunConfig) (GlobusClient, error) { return client, nil }
+	openDBFn = func(config RunConfig) (*gorm.DB, error) { return nil, dbErr }
+	t.Cleanup(func() {
+		newGlobusClientFn = newGlobusClient
+		openDBFn = openDB
+	})
+}
+
+func TestRunReturnsWhenCancelled(t *testing.T) {
+	client := NewFakeGlobusClient()
+	withFakeRunDeps(t, client, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		config := RunConfig{EndpointID: testEndpointID, Monitor: Config{PollInterval: time.Millisecond}}
+		done <- Run(ctx, config)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatalf("Run did not return after the context was cancelled")
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	require.Greater(t, client.TaskListCalls, 0)
+}
+
+func TestMonitorRunStopsCompaction(t *testing.T) {
+	// The monitor stops by itself, which must also stop the compaction for Run to return.
+	m := newTestMonitor(NewFakeGlobusClient(), WithStopAfterIdlePasses(1), WithAuditRetention(time.Hour))
+
+	done := make(chan struct{})
+	go func() {
+		m.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Run did not return after the monitor stopped")
+	}
+}
+
+func TestRunReturnsSetupErrors(t *testing.T) {
+	withFakeRunDeps(t, NewFakeGlobusClient(), errors.New("no database"))
+
+	err := Run(context.Background(), RunConfig{EndpointID: testEndpointID})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no database")
+}
+
+func TestWithConfigKeepsDefaults(t *testing.T) {
+	m := NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID, withConfig(Config{StopAfterIdlePasses: 2}))
+	require.Equal(t, defaultPollInterval, m.config.PollInterval)
+	require.Equal(t, defaultWatermarkLagWarning, m.config.WatermarkLagWarning)
+	require.Equal(t, 2, m.config.StopAfterIdlePasses)
+}
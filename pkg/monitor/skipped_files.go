@@ -0,0 +1,41 @@
+package monitor
+
+import globus "github.com/materials-commons/goglobus"
+
+// SkippedFile is a file in a task that the monitor left out when it processed the task, see
+// PassResult.SkippedFiles.
+type SkippedFile struct {
+	TaskID string
+
+	// GlobusUploadID is the upload the file was written into, and is blank for an archive task.
+	GlobusUploadID string
+
+	ProjectID int
+
+	// Path is the path of the file within its project.
+	Path string
+
+	// Reason is why the file was left out, using the reason labels of the files_skipped counter:
+	// excluded, zero_byte, unchanged, moved, project_deleted or not_owner.
+	Reason string
+}
+
+// skippedFiles collects the files skipped while a task is processed.
+type skippedFiles []SkippedFile
+
+// skipFile counts a file that is being left out in files_skipped, and adds it to skipped.
+func (m *GlobusTaskMonitor) skipFile(skipped *skippedFiles, file SkippedFile) {
+	m.incCounter("files_skipped", Labels{"reason": file.Reason})
+	*skipped = append(*skipped, file)
+}
+
+// uploadSkippedFile returns the SkippedFile for a file of an upload.
+func uploadSkippedFile(id string, task globus.Task, upload *GlobusUpload, file UploadFile, reason string) SkippedFile {
+	return SkippedFile{
+		TaskID:         task.TaskID,
+		GlobusUploadID: id,
+		ProjectID:      upload.ProjectID,
+		Path:           file.Path.String(),
+		Reason:         reason,
+	}
+}
@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkippedFilesAreReported(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithExcludeGlobs([]string{".DS_Store"}), WithZeroByteFilePolicy(ZeroByteFileSkip),
+		WithSkipUnchangedFiles())
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+
+	loaded := writeUploadDir(t, map[string]string{"d1/same.txt": "same"})
+	loadUploadDir(t, m.projectFiles.(*fakeProjectFileStore), 1, loaded)
+
+	dir := writeUploadDir(t, map[string]string{"d1/same.txt": "same", "d1/.DS_Store": "x", "empty.txt": "", "new.txt": "new"})
+	uploads.add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/same.txt", "/__globus_uploads/1/d1/.DS_Store",
+		"/__globus_uploads/1/empty.txt", "/__globus_uploads/1/new.txt")
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Equal(t, []SkippedFile{
+		{TaskID: "task-1", GlobusUploadID: "1", ProjectID: 1, Path: "d1/.DS_Store", Reason: "excluded"},
+		{TaskID: "task-1", GlobusUploadID: "1", ProjectID: 1, Path: "empty.txt", Reason: "zero_byte"},
+		{TaskID: "task-1", GlobusUploadID: "1", ProjectID: 1, Path: "d1/same.txt", Reason: "unchanged"},
+	}, result.SkippedFiles)
+
+	// A task that skips nothing adds nothing to the report.
+	dir2 := writeUploadDir(t, map[string]string{"a.txt": "a"})
+	uploads.add(&GlobusUpload{ID: 2, ProjectID: 1, OwnerID: 1, Path: dir2})
+	client.AddUpload("task-2", "/__globus_uploads/2/a.txt")
+
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Empty(t, result.SkippedFiles)
+}
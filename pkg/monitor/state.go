@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// stateVersion is the version of the MonitorState schema written by ExportState. It changes when a
+// change to the schema means older versions can't be read correctly.
+const stateVersion = 1
+
+// MonitorState is the dedup and watermark state of a monitor, as written by ExportState.
+type MonitorState struct {
+	// Version is the version of the schema, see stateVersion.
+	Version int `json:"version"`
+
+	// EndpointID is the endpoint the state belongs to.
+	EndpointID string `json:"endpoint_id"`
+
+	// Watermark is the time every task that completed before has been processed, in UTC.
+	Watermark time.Time `json:"watermark"`
+
+	// FinishedIDs are the ids of the uploads, and archive tasks, known to have been processed. They
+	// are sorted so the same state always exports the same way.
+	FinishedIDs []string `json:"finished_ids"`
+}
+
+// ExportState returns the monitor's in memory dedup and watermark state as JSON, so it can be
+// inspected or loaded into another monitor with ImportState. It waits for a running pass to finish.
+func (m *GlobusTaskMonitor) ExportState() ([]byte, error) {
+	m.passMu.Lock()
+	defer m.passMu.Unlock()
+
+	state := MonitorState{Version: stateVersion, EndpointID: m.endpointID, FinishedIDs: []string{}}
+	for id := range m.finishedGlobusTasks {
+		state.FinishedIDs = append(state.FinishedIDs, id)
+	}
+	sort.Strings(state.FinishedIDs)
+
+	m.statusMu.Lock()
+	state.Watermark = m.lastProcessedTime
+	m.statusMu.Unlock()
+
+	return json.MarshalIndent(state, "", "  ")
+}
+
+// ImportState replaces the monitor's in memory dedup and watermark state with state written by
+// ExportState. The state has to be for the monitor's endpoint. Nothing is written to the database,
+// so the imported ids are only remembered by this monitor.
+func (m *GlobusTaskMonitor) ImportState(data []byte) error {
+	var state MonitorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("unable to parse monitor state: %w", err)
+	}
+
+	switch {
+	case state.Version != stateVersion:
+		return fmt.Errorf("unsupported monitor state version %d", state.Version)
+	case state.EndpointID != m.endpointID:
+		return fmt.Errorf("monitor state is for endpoint %s, not %s", state.EndpointID, m.endpointID)
+	}
+
+	m.passMu.Lock()
+	defer m.passMu.Unlock()
+
+	m.finishedGlobusTasks = make(map[string]bool, len(state.FinishedIDs))
+	for _, id := range state.FinishedIDs {
+		m.finishedGlobusTasks[id] = true
+	}
+
+	m.statusMu.Lock()
+	m.lastProcessedTime = state.Watermark.UTC()
+	m.watermarkKnown = true
+	m.statusMu.Unlock()
+
+	return nil
+}
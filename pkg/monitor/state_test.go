@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportStateRoundTrip(t *testing.T) {
+	watermark := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	m := newTestMonitor(NewFakeGlobusClient())
+	m.finishedGlobusTasks["2"] = true
+	m.finishedGlobusTasks["1"] = true
+	m.finishedGlobusTasks["archive:task-1"] = true
+	m.lastProcessedTime = watermark
+
+	data, err := m.ExportState()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"version": 1,
+		"endpoint_id": "`+testEndpointID+`",
+		"watermark": "2021-03-15T12:00:00Z",
+		"finished_ids": ["1", "2", "archive:task-1"]
+	}`, string(data))
+
+	other := newTestMonitor(NewFakeGlobusClient())
+	require.NoError(t, other.ImportState(data))
+	require.Equal(t, m.finishedGlobusTasks, other.finishedGlobusTasks)
+	require.True(t, watermark.Equal(other.lastProcessedTime))
+
+	exported, err := other.ExportState()
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(exported))
+}
+
+func TestImportStateRejectsBadState(t *testing.T) {
+	m := newTestMonitor(NewFakeGlobusClient())
+	require.Error(t, m.ImportState([]byte("not json")))
+	require.Error(t, m.ImportState([]byte(`{"version": 2, "endpoint_id": "`+testEndpointID+`"}`)))
+	require.Error(t, m.ImportState([]byte(`{"version": 1, "endpoint_id": "`+testEndpointID2+`"}`)))
+}
+
+func TestImportedStatePreventsReprocessing(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	processed := newFakeProcessedUploadStore()
+	m := newTestMonitor(client)
+	m.processedUploads = processed
+	seedGlobusUploads(m, 1, 2)
+	require.NoError(t, m.ImportState([]byte(`{"version": 1, "endpoint_id": "`+testEndpointID+`", "finished_ids": ["1"]}`)))
+
+	// The processed upload store has no record of either upload, but the imported state covers 1.
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, []string{"2"}, processed.uploadIDs())
+}
+
+func TestImportedWatermarkIsStoredInUTC(t *testing.T) {
+	m := newTestMonitor(NewFakeGlobusClient())
+	require.NoError(t, m.ImportState([]byte(`{
+		"version": 1,
+		"endpoint_id": "`+testEndpointID+`",
+		"watermark": "2021-03-15T07:00:00-05:00",
+		"finished_ids": []
+	}`)))
+	require.Equal(t, time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC), m.lastProcessedTime)
+
+	data, err := m.ExportState()
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"watermark": "2021-03-15T12:00:00Z"`)
+}
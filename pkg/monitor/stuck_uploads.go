@@ -0,0 +1,69 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// inFlightUpload is an upload that is being processed. reported is set once it has been reported as
+// stuck, so it is only reported the once however long it stays stuck.
+type inFlightUpload struct {
+	cancel   context.CancelFunc
+	started  time.Time
+	reported bool
+}
+
+// watchForStuckUploads checks for stuck uploads every poll interval until ctx is done. It runs apart
+// from the passes, since a stuck upload holds up the pass processing it.
+func (m *GlobusTaskMonitor) watchForStuckUploads(ctx context.Context) {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reportStuckUploads()
+		}
+	}
+}
+
+// reportStuckUploads reports the uploads that have been in flight for longer than
+// Config.StuckThreshold and haven't been reported yet. It returns the ids of the uploads it reported.
+func (m *GlobusTaskMonitor) reportStuckUploads() []string {
+	now := m.now()
+
+	m.inFlightMu.Lock()
+	stuck := make(map[string]time.Duration)
+	for id, upload := range m.inFlight {
+		if elapsed := now.Sub(upload.started); !upload.reported && elapsed > m.config.StuckThreshold {
+			upload.reported = true
+			stuck[id] = elapsed
+		}
+	}
+	m.inFlightMu.Unlock()
+
+	ids := make([]string, 0, len(stuck))
+	for id := range stuck {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		m.logger.Warnf("Globus upload %s has been in flight for %s, longer than the stuck threshold of %s",
+			id, stuck[id].Round(time.Second), m.config.StuckThreshold)
+		m.incCounter("uploads_stuck", nil)
+		m.notifyError(ErrorEvent{
+			Kind:   "upload_stuck",
+			Err:    fmt.Errorf("globus upload %s has been in flight for %s", id, stuck[id].Round(time.Second)),
+			Fields: log.Fields{"globus_upload_id": id, "in_flight_seconds": stuck[id].Seconds()},
+		})
+	}
+
+	return ids
+}
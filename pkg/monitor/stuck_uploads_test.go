@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportStuckUploads(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	metrics := newMemoryMetrics()
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithStuckThreshold(time.Minute), WithMetrics(metrics), WithErrorSink(sink))
+	seedGlobusUploads(m, 1)
+
+	var clockMu sync.Mutex
+	now := time.Now()
+	m.now = func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		now = now.Add(d)
+	}
+
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.block = 1
+	fileLoads.started = make(chan struct{})
+	done := make(chan PassResult)
+	go func() {
+		done <- m.retrieveAndProcessUploads(context.Background())
+	}()
+
+	select {
+	case <-fileLoads.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("upload 1 was never processed")
+	}
+
+	// Nothing is reported until the upload has been in flight for longer than the threshold, and
+	// then it is only reported once.
+	require.Empty(t, m.reportStuckUploads())
+	advance(time.Minute + time.Second)
+	require.Equal(t, []string{"1"}, m.reportStuckUploads())
+	require.Empty(t, m.reportStuckUploads())
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "upload_stuck", sink.events[0].Kind)
+	require.Equal(t, "1", sink.events[0].Fields["globus_upload_id"])
+	require.Equal(t, float64(1), metrics.counter("uploads_stuck", Labels{"endpoint": testEndpointID}))
+
+	require.True(t, m.CancelUpload("1"))
+	<-done
+	require.Empty(t, m.reportStuckUploads())
+}
+
+func TestStuckUploadReportedWhileMonitorRuns(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithStuckThreshold(50*time.Millisecond), WithErrorSink(sink))
+	seedGlobusUploads(m, 1)
+
+	// The file load hangs until the monitor is stopped, holding up the pass it is in.
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.block = 1
+	fileLoads.started = make(chan struct{}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.monitorAndProcessTasks(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return len(sink.events) == 1 && sink.events[0].Kind == "upload_stuck"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("monitor did not stop")
+	}
+}
@@ -0,0 +1,42 @@
+package monitor
+
+import (
+	"errors"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// ErrTaskMarkerExpired is returned by a TaskChangesClient that no longer recognizes the marker it
+// was given.
+var ErrTaskMarkerExpired = errors.New("task marker has expired")
+
+// TaskChangesClient is implemented by a GlobusClient that can return only the tasks that changed
+// since an earlier request. The Globus task list API has no such marker, so the monitor only uses it
+// when the client passed to NewGlobusTaskMonitor implements this interface, and otherwise asks for
+// every task in the lookback window on each pass.
+type TaskChangesClient interface {
+	// GetEndpointTaskChanges returns the tasks for the endpoint that match filters and changed since
+	// marker, along with the marker to pass next time. An empty marker asks for every task matching
+	// filters.
+	GetEndpointTaskChanges(endpointID, marker string, filters map[string]string) ([]globus.Task, string, error)
+}
+
+// listPassTasks returns the tasks a pass needs to look at, and the marker to remember once they have
+// all been handled. When the client doesn't support markers, or the remembered marker has expired,
+// it falls back to listing every task matching filters.
+func (m *GlobusTaskMonitor) listPassTasks(filters map[string]string) ([]globus.Task, string, error) {
+	changes, ok := m.client.(TaskChangesClient)
+	if !ok {
+		tasks, err := m.listTasks(filters)
+		return tasks, "", err
+	}
+
+	tasks, marker, err := changes.GetEndpointTaskChanges(m.endpointID, m.taskMarker, filters)
+	if errors.Is(err, ErrTaskMarkerExpired) && m.taskMarker != "" {
+		m.incCounter("task_marker_expired", nil)
+		m.taskMarker = ""
+		tasks, marker, err = changes.GetEndpointTaskChanges(m.endpointID, "", filters)
+	}
+
+	return tasks, marker, err
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskChangesClient adds markers to FakeGlobusClient. A marker is the number of tasks that had
+// been added when it was handed out, so the changes since it are the tasks added afterwards.
+type fakeTaskChangesClient struct {
+	*FakeGlobusClient
+	markers    []string
+	expireNext bool
+}
+
+var _ TaskChangesClient = (*fakeTaskChangesClient)(nil)
+
+func (c *fakeTaskChangesClient) GetEndpointTaskChanges(endpointID, marker string, filters map[string]string) ([]globus.Task, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.markers = append(c.markers, marker)
+	if c.expireNext && marker != "" {
+		c.expireNext = false
+		return nil, "", ErrTaskMarkerExpired
+	}
+
+	start := 0
+	if marker != "" {
+		start, _ = strconv.Atoi(marker)
+	}
+
+	return append([]globus.Task(nil), c.Tasks[start:]...), strconv.Itoa(len(c.Tasks)), nil
+}
+
+// newTaskChangesMonitor returns a test monitor that uses client.
+func newTaskChangesMonitor(client *fakeTaskChangesClient, opts ...Option) *GlobusTaskMonitor {
+	m := newTestMonitor(client.FakeGlobusClient, opts...)
+	m.client = client
+	return m
+}
+
+func TestTaskChangesSinceMarker(t *testing.T) {
+	client := &fakeTaskChangesClient{FakeGlobusClient: NewFakeGlobusClient()}
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+
+	m := newTaskChangesMonitor(client)
+	seedGlobusUploads(m, 1, 2, 3)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 2, result.TasksSeen)
+	require.Equal(t, 2, result.TasksProcessed)
+
+	// The next pass only gets the task added since the first one.
+	client.AddUpload("task-3", "/__globus_uploads/3/file.txt")
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksSeen)
+	require.Equal(t, 1, result.TasksProcessed)
+
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 0, result.TasksSeen)
+
+	require.Equal(t, []string{"", "2", "3"}, client.markers)
+	require.Equal(t, 0, client.TaskListCalls)
+}
+
+func TestTaskChangesMarkerKeptUntilPassHandlesEverything(t *testing.T) {
+	client := &fakeTaskChangesClient{FakeGlobusClient: NewFakeGlobusClient()}
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.TransfersErr = errors.New("transfers unavailable")
+
+	m := newTaskChangesMonitor(client)
+	seedGlobusUploads(m, 1)
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 0, result.TasksProcessed)
+
+	// The failed task is returned again because the marker wasn't moved past it.
+	client.TransfersErr = nil
+	result = m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksProcessed)
+	require.Equal(t, []string{"", ""}, client.markers)
+}
+
+func TestTaskChangesExpiredMarker(t *testing.T) {
+	client := &fakeTaskChangesClient{FakeGlobusClient: NewFakeGlobusClient()}
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+
+	metrics := newMemoryMetrics()
+	m := newTaskChangesMonitor(client, WithMetrics(metrics))
+	seedGlobusUploads(m, 1)
+	m.retrieveAndProcessUploads(context.Background())
+
+	// An expired marker starts over from the full window, and the processed task isn't processed again.
+	client.expireNext = true
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 1, result.TasksSeen)
+	require.Equal(t, 0, result.TasksProcessed)
+	require.Equal(t, []string{"", "1", ""}, client.markers)
+	require.Equal(t, float64(1), metrics.counter("task_marker_expired", Labels{"endpoint": testEndpointID}))
+}
+
+func TestTaskChangesUnsupportedUsesWindow(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+	m.retrieveAndProcessUploads(context.Background())
+
+	require.Equal(t, 1, client.TaskListCalls)
+	require.Contains(t, client.TaskListFilters[0], "filter_completion_time")
+	require.Equal(t, "", m.taskMarker)
+}
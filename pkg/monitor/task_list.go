@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+const (
+	// maxTaskPageSize is the most tasks Globus will return for a single task list request.
+	maxTaskPageSize = 1000
+
+	// defaultTaskPageSize is the page size used when Config.TaskPageSize isn't set.
+	defaultTaskPageSize = maxTaskPageSize
+)
+
+// taskPageSize returns the configured page size clamped to what Globus allows.
+func (m *GlobusTaskMonitor) taskPageSize() int {
+	switch size := m.config.TaskPageSize; {
+	case size < 1:
+		return defaultTaskPageSize
+	case size > maxTaskPageSize:
+		return maxTaskPageSize
+	default:
+		return size
+	}
+}
+
+// errTaskListTruncated is returned by listTasks when Globus stops answering with tasks, or stops
+// moving on to the next page, before the last page. Returning the tasks listed so far would let the
+// pass treat the list as complete, and move the watermark past the tasks on the pages that were never
+// fetched.
+var errTaskListTruncated = errors.New("globus task list ended before the last page")
+
+// listTasks returns every task for the endpoint that matches filters, requesting them from Globus a
+// page at a time. Each page after the first starts from the last_key Globus returned with the one
+// before it rather than from an offset, so tasks completing while the pages are fetched don't shift
+// later pages. A task that turns up on more than one page is only returned once, and a last_key that
+// doesn't move on fails the list with errTaskListTruncated rather than asking for the same page
+// forever. filters isn't changed.
+func (m *GlobusTaskMonitor) listTasks(filters map[string]string) ([]globus.Task, error) {
+	pageSize := m.taskPageSize()
+	var tasks []globus.Task
+	seen := make(map[string]bool)
+	for lastKey := ""; ; {
+		pageFilters := make(map[string]string, len(filters)+2)
+		for k, v := range filters {
+			pageFilters[k] = v
+		}
+		pageFilters["limit"] = strconv.Itoa(pageSize)
+		if lastKey != "" {
+			pageFilters["last_key"] = lastKey
+		}
+
+		page, err := m.client.GetEndpointTaskList(m.endpointID, pageFilters)
+		if err != nil {
+			return nil, err
+		}
+
+		// Globus has been seen to answer without a DATA entry. For a first page with nothing after it
+		// that's an empty list, but anywhere else the pages after it can't be fetched, so the list is
+		// incomplete.
+		if page.Tasks == nil {
+			m.logger.Warnf("Globus returned no task list for endpoint %s (has next page %t)", m.endpointID, page.HasNextPage)
+			if lastKey == "" && !page.HasNextPage {
+				return nil, nil
+			}
+			return nil, errTaskListTruncated
+		}
+
+		for _, task := range page.Tasks {
+			if seen[task.TaskID] {
+				continue
+			}
+			seen[task.TaskID] = true
+			tasks = append(tasks, task)
+		}
+
+		if !page.HasNextPage || page.LastKey == "" {
+			return tasks, nil
+		}
+
+		if page.LastKey == lastKey {
+			m.logger.Warnf("Globus returned the same last_key %s twice for endpoint %s", lastKey, m.endpointID)
+			return nil, errTaskListTruncated
+		}
+
+		lastKey = page.LastKey
+	}
+}
+
+// inFilterTimeZone returns t in the time zone the completion time filters are written in. Globus reads
+// the times in filters without a zone as UTC, which is the default, so a filter means the same thing
+// whatever zone the host is in.
+func (m *GlobusTaskMonitor) inFilterTimeZone(t time.Time) time.Time {
+	if m.config.FilterTimeZone == nil {
+		return t.UTC()
+	}
+
+	return t.In(m.config.FilterTimeZone)
+}
@@ -0,0 +1,170 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskPageSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageSize int
+		expected int
+	}{
+		{name: "default", pageSize: 0, expected: 1000},
+		{name: "smaller", pageSize: 25, expected: 25},
+		{name: "clamped to the globus max", pageSize: 5000, expected: 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestMonitor(NewFakeGlobusClient(), WithTaskPageSize(test.pageSize))
+			require.Equal(t, test.expected, m.taskPageSize())
+		})
+	}
+}
+
+func TestTasksAreListedInPages(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithTaskPageSize(2))
+	for i := 1; i <= 5; i++ {
+		client.AddUpload(fmt.Sprintf("task-%d", i), fmt.Sprintf("/__globus_uploads/%d/file.txt", i))
+		seedGlobusUploads(m, i)
+	}
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 5, result.TasksSeen)
+	require.Equal(t, 5, result.TasksProcessed)
+
+	require.Equal(t, 3, client.TaskListCalls)
+	var lastKeys []string
+	for _, filters := range client.TaskListFilters {
+		require.Equal(t, "2", filters["limit"])
+		require.Equal(t, "SUCCEEDED", filters["filter_status"])
+		lastKeys = append(lastKeys, filters["last_key"])
+	}
+	require.Equal(t, []string{"", "task-2", "task-4"}, lastKeys)
+}
+
+func TestTaskListChangingBetweenPages(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithTaskPageSize(2))
+	for i := 1; i <= 6; i++ {
+		client.AddUpload(fmt.Sprintf("task-%d", i), fmt.Sprintf("/__globus_uploads/%d/file.txt", i))
+		seedGlobusUploads(m, i)
+	}
+
+	// After the first page task-1, which has been returned, and task-4, which hasn't, drop out of
+	// the window. With offsets the second page would start at task-5 and task-3 would be skipped.
+	client.BeforeTaskListPage = func(call int) {
+		if call == 2 {
+			client.Tasks = append(client.Tasks[1:3], client.Tasks[4:]...)
+		}
+	}
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 5, result.TasksSeen)
+	require.Equal(t, 5, result.TasksProcessed)
+	require.ElementsMatch(t, []string{"task-1", "task-2", "task-3", "task-5", "task-6"}, client.TransfersFetched)
+}
+
+func TestTaskListPagesThatRepeat(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+
+	watermark := m.lastProcessedTime
+
+	// A page that always says there is more and never moves its last_key on. The tasks after it can't
+	// be listed, so nothing is processed and the watermark stays where it was.
+	client.TaskListResponse = &globus.TaskList{Tasks: client.Tasks, HasNextPage: true, LastKey: "task-1"}
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 2, client.TaskListCalls)
+	require.Equal(t, 0, result.TasksSeen)
+	require.Empty(t, client.TransfersFetched)
+	require.Equal(t, watermark, m.lastProcessedTime)
+
+	_, err := m.listTasks(nil)
+	require.ErrorIs(t, err, errTaskListTruncated)
+}
+
+func TestNilTaskListIsAnEmptyPass(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TaskListResponse = &globus.TaskList{}
+	m := newTestMonitor(client)
+
+	require.NotPanics(t, func() {
+		require.Equal(t, PassResult{}, m.retrieveAndProcessUploads(context.Background()))
+	})
+	require.Equal(t, 1, client.TaskListCalls)
+	require.Equal(t, 0, client.TransferCalls)
+}
+
+func TestNilTaskListWithMorePagesDoesNotMoveTheWatermark(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.AddUpload("task-2", "/__globus_uploads/2/file.txt")
+	m := newTestMonitor(client, WithTaskPageSize(1))
+	seedGlobusUploads(m, 1, 2)
+	watermark := m.lastProcessedTime
+
+	// The second page comes back without any tasks, though the first said there was more.
+	client.BeforeTaskListPage = func(call int) {
+		if call == 2 {
+			client.TaskListResponse = &globus.TaskList{HasNextPage: true, LastKey: "task-2"}
+		}
+	}
+
+	result := m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 0, result.TasksSeen)
+	require.Empty(t, client.TransfersFetched)
+	require.Equal(t, watermark, m.lastProcessedTime)
+
+	_, err := m.listTasks(nil)
+	require.ErrorIs(t, err, errTaskListTruncated)
+}
+
+func TestCompletionFilterIsUTC(t *testing.T) {
+	// 23:30 on the 15th in New York is already 03:30 on the 16th in UTC.
+	newYork := time.FixedZone("EDT", -4*60*60)
+	now := time.Date(2021, 3, 15, 23, 30, 0, 0, newYork)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{name: "default", expected: "2021-03-16"},
+		{name: "zone", opts: []Option{WithFilterTimeZone(newYork)}, expected: "2021-03-15"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			m := newTestMonitor(client, append(test.opts, WithTaskLookback(time.Hour))...)
+			m.now = func() time.Time { return now }
+
+			// The filter doesn't change with the zone the clock reports the time in.
+			m.retrieveAndProcessUploads(context.Background())
+			m.now = func() time.Time { return now.UTC() }
+			m.retrieveAndProcessUploads(context.Background())
+
+			require.Equal(t, test.expected, client.TaskListFilters[0]["filter_completion_time"])
+			require.Equal(t, test.expected, client.TaskListFilters[1]["filter_completion_time"])
+		})
+	}
+}
+
+func TestCompletionRangeFilterIsUTC(t *testing.T) {
+	newYork := time.FixedZone("EDT", -4*60*60)
+	m := newTestMonitor(NewFakeGlobusClient())
+	filter := m.completionRangeFilter(time.Date(2021, 3, 15, 22, 0, 0, 0, newYork), time.Date(2021, 3, 15, 23, 0, 0, 0, newYork))
+	require.Equal(t, "2021-03-16T02:00:00,2021-03-16T03:00:00", filter["filter_completion_time"])
+}
@@ -0,0 +1,46 @@
+package monitor
+
+import "context"
+
+// Tracer starts the spans the monitor uses to trace passes and the tasks processed in them. It
+// matches the shape of an OpenTelemetry tracer, so one can be adapted to it in a few lines. The
+// default does nothing; WithTracer sets a real one.
+type Tracer interface {
+	// Start starts a span named name, as a child of the span in ctx if there is one, and returns a
+	// context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End()
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End()                                       {}
+
+type spanKey struct{}
+
+// startSpan starts a span with the endpoint as an attribute. The span is kept in the returned context
+// so that code further down can add to it with setSpanAttribute.
+func (m *GlobusTaskMonitor) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := m.tracer.Start(ctx, name)
+	span.SetAttribute("endpoint", m.endpointID)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// setSpanAttribute sets an attribute on the span started by startSpan in ctx, if there is one.
+func setSpanAttribute(ctx context.Context, key string, value interface{}) {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		span.SetAttribute(key, value)
+	}
+}
@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTracer records the spans it starts, like an in memory exporter.
+type memoryTracer struct {
+	mu    sync.Mutex
+	spans []*memorySpan
+}
+
+type memorySpan struct {
+	name       string
+	parent     *memorySpan
+	attributes map[string]interface{}
+	ended      bool
+}
+
+type memorySpanKey struct{}
+
+func (t *memoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parent, _ := ctx.Value(memorySpanKey{}).(*memorySpan)
+	span := &memorySpan{name: name, parent: parent, attributes: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+func (s *memorySpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *memorySpan) End() {
+	s.ended = true
+}
+
+func TestPassAndTaskSpans(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.Tasks[0].BytesTransferred = 1234
+	tracer := &memoryTracer{}
+	m := newTestMonitor(client, WithTracer(tracer))
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 2, OwnerID: 3})
+
+	m.retrieveAndProcessUploads(context.Background())
+
+	require.Len(t, tracer.spans, 2)
+	pass, task := tracer.spans[0], tracer.spans[1]
+
+	require.Equal(t, "globus.pass", pass.name)
+	require.Nil(t, pass.parent)
+	require.True(t, pass.ended)
+	require.Equal(t, map[string]interface{}{"endpoint": testEndpointID, "tasks_seen": 1, "tasks_processed": 1}, pass.attributes)
+
+	require.Equal(t, "globus.task", task.name)
+	require.Equal(t, pass, task.parent)
+	require.True(t, task.ended)
+	expected := map[string]interface{}{
+		"endpoint":          testEndpointID,
+		"task_id":           "task-1",
+		"bytes_transferred": 1234,
+		"user_id":           3,
+		"project_id":        2,
+	}
+	require.Equal(t, expected, task.attributes)
+}
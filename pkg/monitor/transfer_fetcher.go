@@ -0,0 +1,90 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// defaultTransferFetchConcurrency is how many tasks can have their transfers fetched ahead of the
+// processing when a pass doesn't set its own limit. The fetches still run ahead of the processing.
+const defaultTransferFetchConcurrency = 1
+
+// errFetchNotStarted is the result of the fetches that weren't started because ctx was done.
+var errFetchNotStarted = errors.New("transfer fetch not started, the pass was cancelled")
+
+// transferFetch is the result of fetching the successful transfers for a task.
+type transferFetch struct {
+	task      globus.Task
+	transfers globus.TransferItems
+	err       error
+}
+
+// transferFetches are the results of the fetches started by fetchTransfers, in task order.
+type transferFetches struct {
+	results []chan transferFetch
+
+	// window holds a slot for each task whose transfers are being fetched or are waiting to be
+	// received, so fetching never runs more than its size ahead of the caller.
+	window chan struct{}
+}
+
+// get waits for the result of fetching the transfers for the i'th task, and frees its slot in the
+// window so the fetch for a later task can start. A fetch that wasn't started never had a slot.
+func (f *transferFetches) get(i int) transferFetch {
+	fetch := <-f.results[i]
+	if !errors.Is(fetch.err, errFetchNotStarted) {
+		<-f.window
+	}
+	return fetch
+}
+
+// fetchTransfers starts fetching the successful transfers for tasks, with at most
+// config.TransferFetchConcurrency tasks being fetched, or fetched and not yet received, at a time.
+// Globus has no call to fetch the transfers for more than one task, so rather than batching, the
+// fetches are pipelined: the results are received in the same order as tasks, so the caller can
+// process a task as soon as its transfers arrive while the fetches for the tasks after it carry on.
+// Fetches are started in task order, so the task the caller is waiting on is never stuck behind
+// later ones, and a slot is only freed when the caller receives a result, so a long list of tasks
+// isn't all held in memory waiting to be processed. The caller must receive every result, or stop
+// only once ctx is done. Once ctx is done no more fetches are started, and the tasks left get
+// errFetchNotStarted.
+func (m *GlobusTaskMonitor) fetchTransfers(ctx context.Context, tasks []globus.Task) *transferFetches {
+	concurrency := m.config.TransferFetchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	fetches := &transferFetches{
+		results: make([]chan transferFetch, len(tasks)),
+		window:  make(chan struct{}, concurrency),
+	}
+	for i := range tasks {
+		fetches.results[i] = make(chan transferFetch, 1)
+	}
+
+	go func() {
+		for i, task := range tasks {
+			select {
+			case fetches.window <- struct{}{}:
+				if ctx.Err() == nil {
+					go func(task globus.Task, result chan<- transferFetch) {
+						transfers, err := m.getTaskTransfers(ctx, task.TaskID)
+						result <- transferFetch{task: task, transfers: transfers, err: err}
+					}(task, fetches.results[i])
+					continue
+				}
+				<-fetches.window
+			case <-ctx.Done():
+			}
+
+			for j := i; j < len(tasks); j++ {
+				fetches.results[j] <- transferFetch{task: tasks[j], err: errFetchNotStarted}
+			}
+			return
+		}
+	}()
+
+	return fetches
+}
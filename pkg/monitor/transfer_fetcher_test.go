@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+// addSmallUploads adds n tasks that each upload a single file to their own globus upload.
+func addSmallUploads(m *GlobusTaskMonitor, client *FakeGlobusClient, n int) {
+	for i := 1; i <= n; i++ {
+		client.AddUpload(fmt.Sprintf("task-%d", i), fmt.Sprintf("/__globus_uploads/%d/file.txt", i))
+		seedGlobusUploads(m, i)
+	}
+}
+
+func TestTransferFetchesArePipelined(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{name: "one at a time", concurrency: 1},
+		{name: "limited", concurrency: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.TransferDelay = 5 * time.Millisecond
+			m := newTestMonitor(client, WithTransferFetchConcurrency(test.concurrency))
+			addSmallUploads(m, client, 12)
+
+			result := m.retrieveAndProcessUploads(context.Background())
+			require.Equal(t, 12, result.TasksProcessed)
+			require.Equal(t, 12, client.TransferCalls)
+			require.Equal(t, test.concurrency, client.MaxTransferCallsActive)
+
+			// Fetches are started in task order.
+			for i, taskID := range client.TransfersFetched[:test.concurrency] {
+				require.Contains(t, []string{"task-1", "task-2", "task-3"}, taskID, "fetch %d", i)
+			}
+		})
+	}
+}
+
+func TestTransferFetchesStopWhenCancelled(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client)
+	addSmallUploads(m, client, 3)
+	tasks := []globus.Task{{TaskID: "task-1"}, {TaskID: "task-2"}, {TaskID: "task-3"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fetches := m.fetchTransfers(ctx, tasks)
+	for i := range tasks {
+		require.ErrorIs(t, fetches.get(i).err, errFetchNotStarted)
+	}
+	require.Equal(t, 0, client.TransferCalls)
+}
+
+func TestTransferFetchesStayWithinTheWindow(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithTransferFetchConcurrency(2))
+	addSmallUploads(m, client, 6)
+	tasks := []globus.Task{{TaskID: "task-1"}, {TaskID: "task-2"}, {TaskID: "task-3"}, {TaskID: "task-4"},
+		{TaskID: "task-5"}, {TaskID: "task-6"}}
+	transferCalls := func() int {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return client.TransferCalls
+	}
+
+	// Nothing has been received, so only the first two tasks are fetched.
+	fetches := m.fetchTransfers(context.Background(), tasks)
+	require.Eventually(t, func() bool { return transferCalls() == 2 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, 2, transferCalls())
+
+	// Receiving a result lets the fetch for the next task start.
+	require.Equal(t, "task-1", fetches.get(0).task.TaskID)
+	require.Eventually(t, func() bool { return transferCalls() == 3 }, time.Second, time.Millisecond)
+
+	for i := 1; i < len(tasks); i++ {
+		require.NoError(t, fetches.get(i).err)
+	}
+	require.Equal(t, 6, transferCalls())
+}
+
+func TestTransferFetchesKeepToTheRate(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TransferDelay = 30 * time.Millisecond
+	m := newTestMonitor(client, WithTransferFetchConcurrency(4), WithTransferFetchRate(50, 2))
+	addSmallUploads(m, client, 12)
+
+	// After the first two, one request can be made every 20ms however many fetches are waiting.
+	start := time.Now()
+	require.Equal(t, 12, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(190*time.Millisecond))
+	require.Equal(t, 12, client.TransferCalls)
+	require.Greater(t, client.MaxTransferCallsActive, 1)
+}
+
+func BenchmarkCatchUp(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				client := NewFakeGlobusClient()
+				client.TransferDelay = time.Millisecond
+				m := newTestMonitor(client, WithTransferFetchConcurrency(concurrency))
+				addSmallUploads(m, client, 50)
+				m.retrieveAndProcessUploads(context.Background())
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// TransferPageLimitPolicy controls what happens to a task that has more pages of transfers than
+// Config.MaxTransferPages.
+type TransferPageLimitPolicy int
+
+const (
+	// TransferPagesDefer leaves the task unprocessed, remembering the pages fetched so far, and fetches
+	// up to MaxTransferPages more of them on each pass until it has them all. The task then holds the
+	// watermark back, as any task that couldn't be handled does. What has been fetched is only kept in
+	// memory, so a restarted monitor starts again from the first page.
+	TransferPagesDefer TransferPageLimitPolicy = iota
+
+	// TransferPagesProcessPartial processes the task with the transfers in the pages that were fetched
+	// and ignores the rest. The file load for an upload loads everything in its directory, so the
+	// files left out are still loaded, but they aren't checked or counted as the fetched ones are.
+	TransferPagesProcessPartial
+)
+
+// errTransferPagesDeferred is returned when fetching the transfers for a task stopped at
+// MaxTransferPages under TransferPagesDefer.
+var errTransferPagesDeferred = errors.New("transfer pages deferred to the next pass")
+
+// deferredTransferPages are the transfers fetched so far for a task that was deferred, and the marker
+// of the page to carry on from.
+type deferredTransferPages struct {
+	transfers  []globus.Transfer
+	nextMarker int
+}
+
+// getTaskTransfers fetches the successful transfers for a task, following the pages until there are
+// no more or Config.MaxTransferPages have been fetched. Going over the limit is logged and counted in
+// transfer_page_limit_exceeded, and then handled as Config.TransferPageLimitPolicy says. Once ctx is
+// done no more pages are fetched, and ctx's error is returned.
+func (m *GlobusTaskMonitor) getTaskTransfers(ctx context.Context, taskID string) (globus.TransferItems, error) {
+	m.pagesMu.Lock()
+	pending, deferred := m.deferredPages[taskID]
+	m.pagesMu.Unlock()
+
+	var items globus.TransferItems
+	marker := 0
+	if deferred {
+		items.Transfers, marker = pending.transfers, pending.nextMarker
+	}
+
+	transfers, nextMarker, err := m.fetchTransferPages(ctx, taskID, marker, m.config.MaxTransferPages)
+	if err != nil {
+		return globus.TransferItems{}, err
+	}
+
+	items.Transfers = append(items.Transfers, transfers...)
+	if nextMarker != 0 {
+		return m.transferPageLimitExceeded(taskID, items, nextMarker)
+	}
+
+	if deferred {
+		m.pagesMu.Lock()
+		delete(m.deferredPages, taskID)
+		m.pagesMu.Unlock()
+	}
+
+	return items, nil
+}
+
+// fetchTransferPages fetches up to maxPages pages of the successful transfers for a task, starting
+// at marker, or all of them when maxPages is 0. It returns the marker of the next page, which is 0
+// when there are no pages left. Unlike getTaskTransfers it doesn't remember or count anything. Each
+// page waits its turn under WithTransferFetchRate.
+func (m *GlobusTaskMonitor) fetchTransferPages(ctx context.Context, taskID string, marker, maxPages int) ([]globus.Transfer, int, error) {
+	var transfers []globus.Transfer
+	for pages := 0; maxPages == 0 || pages < maxPages; pages++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		if err := m.transferLimiter.Wait(ctx); err != nil {
+			return nil, 0, err
+		}
+
+		page, err := m.client.GetTaskSuccessfulTransfers(taskID, marker)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		transfers = append(transfers, page.Transfers...)
+		if page.NextMarker == 0 {
+			return transfers, 0, nil
+		}
+
+		marker = page.NextMarker
+	}
+
+	return transfers, marker, nil
+}
+
+// transferPageLimitExceeded handles a task that still has pages left, starting at nextMarker, once
+// MaxTransferPages have been fetched.
+func (m *GlobusTaskMonitor) transferPageLimitExceeded(taskID string, items globus.TransferItems, nextMarker int) (globus.TransferItems, error) {
+	m.logger.Warnf("Globus task %s has more than %d pages of transfers, %d transfers fetched so far",
+		taskID, m.config.MaxTransferPages, len(items.Transfers))
+
+	if m.config.TransferPageLimitPolicy == TransferPagesProcessPartial {
+		m.incCounter("transfer_page_limit_exceeded", Labels{"policy": "process_partial"})
+		return items, nil
+	}
+
+	m.incCounter("transfer_page_limit_exceeded", Labels{"policy": "defer"})
+	m.pagesMu.Lock()
+	m.deferredPages[taskID] = &deferredTransferPages{transfers: items.Transfers, nextMarker: nextMarker}
+	m.pagesMu.Unlock()
+
+	return globus.TransferItems{}, errTransferPagesDeferred
+}
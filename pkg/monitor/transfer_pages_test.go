@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTaskTransfersFollowsPages(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TransferPageSize = 2
+	m := newTestMonitor(client)
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt", "/__globus_uploads/1/b.txt", "/__globus_uploads/1/c.txt",
+		"/__globus_uploads/1/d.txt", "/__globus_uploads/1/e.txt")
+
+	transfers, err := m.getTaskTransfers(context.Background(), "task-1")
+	require.NoError(t, err)
+	require.Len(t, transfers.Transfers, 5)
+	require.Equal(t, 3, client.TransferCalls)
+}
+
+func TestMaxTransferPages(t *testing.T) {
+	paths := []string{"/__globus_uploads/1/a.txt", "/__globus_uploads/1/b.txt", "/__globus_uploads/1/c.txt",
+		"/__globus_uploads/1/d.txt", "/__globus_uploads/1/e.txt"}
+
+	t.Run("defer", func(t *testing.T) {
+		client := NewFakeGlobusClient()
+		client.TransferPageSize = 2
+		metrics := newMemoryMetrics()
+		m := newTestMonitor(client, WithMaxTransferPages(2), WithMetrics(metrics))
+		fileLoads := m.fileLoads.(*fakeFileLoadStore)
+		seedGlobusUploads(m, 1)
+		client.AddUpload("task-1", paths...)
+
+		// The first pass stops at the limit, and leaves the task for the next one.
+		require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Equal(t, 2, client.TransferCalls)
+		require.Empty(t, fileLoads.fileLoads)
+		require.False(t, m.isFinished("1"))
+		require.Equal(t, float64(1), metrics.counter("transfer_page_limit_exceeded", Labels{"endpoint": testEndpointID, "policy": "defer"}))
+
+		// The next pass carries on from the page it stopped at.
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Equal(t, 3, client.TransferCalls)
+		require.Len(t, fileLoads.fileLoads, 1)
+		require.True(t, m.isFinished("1"))
+		require.Empty(t, m.deferredPages)
+	})
+
+	t.Run("process partial", func(t *testing.T) {
+		client := NewFakeGlobusClient()
+		client.TransferPageSize = 2
+		metrics := newMemoryMetrics()
+		m := newTestMonitor(client, WithMaxTransferPages(2), WithTransferPageLimitPolicy(TransferPagesProcessPartial), WithMetrics(metrics))
+		client.AddUpload("task-1", paths...)
+
+		transfers, err := m.getTaskTransfers(context.Background(), "task-1")
+		require.NoError(t, err)
+		require.Len(t, transfers.Transfers, 4)
+		require.Equal(t, 2, client.TransferCalls)
+		require.Empty(t, m.deferredPages)
+
+		// The task is processed with the transfers that were fetched.
+		seedGlobusUploads(m, 1)
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Equal(t, 4, client.TransferCalls)
+		require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+		require.Equal(t, float64(2), metrics.counter("transfer_page_limit_exceeded", Labels{"endpoint": testEndpointID, "policy": "process_partial"}))
+	})
+}
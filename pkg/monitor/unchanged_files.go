@@ -0,0 +1,147 @@
+package monitor
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/gomcdb/mcmodel"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+	"gorm.io/gorm"
+)
+
+// ProjectFileStore looks up the files already in a project. FindProjectFile returns the current
+// version of the file at path, which starts with a slash, and gorm.ErrRecordNotFound when there
+// isn't one.
+type ProjectFileStore interface {
+	FindProjectFile(projectID int, path string) (*mcmodel.File, error)
+}
+
+type dbProjectFileStore struct {
+	db *gorm.DB
+
+	// foldCase matches paths whatever their case, see WithCaseInsensitivePaths.
+	foldCase bool
+}
+
+func newDBProjectFileStore(db *gorm.DB) *dbProjectFileStore {
+	return &dbProjectFileStore{db: db}
+}
+
+func (s *dbProjectFileStore) FindProjectFile(projectID int, path string) (*mcmodel.File, error) {
+	var dir mcmodel.File
+	err := s.db.Where("project_id = ?", projectID).
+		Where(s.equals("path"), filepath.Dir(path)).
+		Where("mime_type = ?", "directory").
+		First(&dir).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var file mcmodel.File
+	err = s.db.Where("project_id = ?", projectID).
+		Where("directory_id = ?", dir.ID).
+		Where(s.equals("name"), filepath.Base(path)).
+		Where("current = ?", true).
+		First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// equals returns the SQL condition for column being equal to a value, whatever the case of either
+// when foldCase is set.
+func (s *dbProjectFileStore) equals(column string) string {
+	if s.foldCase {
+		return "LOWER(" + column + ") = LOWER(?)"
+	}
+
+	return column + " = ?"
+}
+
+// fileChecksum returns the hex encoded MD5 checksum of the object in store at key, which is how
+// Materials Commons stores checksums.
+func fileChecksum(store objectstore.ObjectStore, key string) (string, error) {
+	f, err := store.Get(context.Background(), key, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// isUnchangedFile returns true if the uploaded file at local is identical to the current version
+// of path in the project, with the same size and checksum. Anything that can't be checked counts
+// as changed, so the file is loaded as it would have been without the check.
+func (m *GlobusTaskMonitor) isUnchangedFile(projectID int, path, local string) bool {
+	existing, err := m.projectFiles.FindProjectFile(projectID, path)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false
+	case err != nil:
+		m.logger.Errorf("Unable to look up %s in project %d: %s", path, projectID, err)
+		return false
+	case existing.Checksum == "":
+		return false
+	}
+
+	info, err := m.objectStore.Stat(context.Background(), local)
+	if err != nil || uint64(info.Size) != existing.Size {
+		return false
+	}
+
+	checksum, err := fileChecksum(m.objectStore, local)
+	if err != nil {
+		m.logger.Errorf("Unable to checksum %s: %s", local, err)
+		return false
+	}
+
+	return checksum == existing.Checksum
+}
+
+// skipUnchangedFiles removes the files of an upload that are already in the project, unchanged,
+// from the upload directory so the file loader doesn't load them again, and returns the files that
+// will be loaded. It does nothing unless WithSkipUnchangedFiles was given.
+func (m *GlobusTaskMonitor) skipUnchangedFiles(id string, task globus.Task, upload *GlobusUpload, files []UploadFile, skipped *skippedFiles) []UploadFile {
+	if !m.config.SkipUnchangedFiles {
+		return files
+	}
+
+	var loaded []UploadFile
+	unchanged := 0
+	for _, file := range files {
+		local := filepath.Join(upload.Path, file.Source.String())
+		if !m.isUnchangedFile(upload.ProjectID, "/"+file.Path.String(), local) {
+			loaded = append(loaded, file)
+			continue
+		}
+
+		if err := m.objectStore.Delete(context.Background(), local); err != nil {
+			// The file is still there, so the file loader will load it.
+			m.logger.Errorf("Unable to remove unchanged file %s from globus upload %s: %s", file.Source, id, err)
+			loaded = append(loaded, file)
+			continue
+		}
+
+		unchanged++
+		m.skipFile(skipped, uploadSkippedFile(id, task, upload, file, "unchanged"))
+	}
+
+	if unchanged != 0 {
+		m.logger.Infof("Skipped %d unchanged files in globus upload %s (task %s)", unchanged, id, task.TaskID)
+	}
+
+	return loaded
+}
@@ -0,0 +1,130 @@
+package monitor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+	"github.com/stretchr/testify/require"
+)
+
+// writeUploadDir creates an upload directory holding files, keyed by their path in the upload.
+func writeUploadDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "unchanged-upload")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	for path, contents := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(contents), 0644))
+	}
+
+	return dir
+}
+
+// loadUploadDir does what the file loader would, adding the files in dir to the project.
+func loadUploadDir(t *testing.T, store *fakeProjectFileStore, projectID int, dir string) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		checksum, err := fileChecksum(objectstore.NewLocalStore(""), path)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(dir, path)
+		store.add(projectID, "/"+rel, uint64(info.Size()), checksum)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestSkipUnchangedFiles(t *testing.T) {
+	files := map[string]string{"d1/a.txt": "a", "b.txt": "b"}
+	client := NewFakeGlobusClient()
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithSkipUnchangedFiles(), WithMetrics(metrics))
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	projectFiles := m.projectFiles.(*fakeProjectFileStore)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+	// The first upload of the directory is loaded as usual.
+	dir1 := writeUploadDir(t, files)
+	uploads.add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir1})
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/a.txt", "/__globus_uploads/1/b.txt")
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	loadUploadDir(t, projectFiles, 1, dir1)
+
+	// Uploading the same directory again doesn't create a file load.
+	dir2 := writeUploadDir(t, files)
+	uploads.add(&GlobusUpload{ID: 2, ProjectID: 1, OwnerID: 1, Path: dir2})
+	client.AddUpload("task-2", "/__globus_uploads/2/d1/a.txt", "/__globus_uploads/2/b.txt")
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.True(t, m.isFinished("2"))
+	require.Equal(t, 2.0, metrics.counter("files_skipped", Labels{"endpoint": testEndpointID, "reason": "unchanged"}))
+	require.Equal(t, 1.0, metrics.counter("tasks_skipped", Labels{"endpoint": testEndpointID, "reason": "unchanged"}))
+
+	// With one file changed and one added, only those two are left to load.
+	dir3 := writeUploadDir(t, map[string]string{"d1/a.txt": "a", "b.txt": "changed", "c.txt": "c"})
+	uploads.add(&GlobusUpload{ID: 3, ProjectID: 1, OwnerID: 1, Path: dir3})
+	client.AddUpload("task-3", "/__globus_uploads/3/d1/a.txt", "/__globus_uploads/3/b.txt", "/__globus_uploads/3/c.txt")
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 2)
+	require.Equal(t, 3, fileLoads.fileLoads[1].GlobusUploadID)
+	require.NoFileExists(t, filepath.Join(dir3, "d1/a.txt"))
+	require.FileExists(t, filepath.Join(dir3, "b.txt"))
+	require.FileExists(t, filepath.Join(dir3, "c.txt"))
+}
+
+func TestUnchangedFilesAreLoadedByDefault(t *testing.T) {
+	files := map[string]string{"a.txt": "a"}
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client)
+	dir := writeUploadDir(t, files)
+	loadUploadDir(t, m.projectFiles.(*fakeProjectFileStore), 1, dir)
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt")
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+	require.FileExists(t, filepath.Join(dir, "a.txt"))
+}
+
+func TestSkipUnchangedFilesInObjectStore(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewMemoryStore()
+	_, err := store.Put(ctx, "/uploads/1/same.txt", strings.NewReader("same"))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, "/uploads/1/new.txt", strings.NewReader("new"))
+	require.NoError(t, err)
+
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/same.txt", "/__globus_uploads/1/new.txt")
+	m := newTestMonitor(client, WithObjectStore(store), WithSkipUnchangedFiles())
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: "/uploads/1"})
+	checksum, err := fileChecksum(store, "/uploads/1/same.txt")
+	require.NoError(t, err)
+	m.projectFiles.(*fakeProjectFileStore).add(1, "/same.txt", 4, checksum)
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(ctx).TasksProcessed)
+	require.Equal(t, []string{"/uploads/1/new.txt"}, store.Keys())
+}
+
+func TestCaseInsensitiveProjectFileLookups(t *testing.T) {
+	store := NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID).projectFiles.(*dbProjectFileStore)
+	require.False(t, store.foldCase)
+	require.Equal(t, "name = ?", store.equals("name"))
+
+	m := NewGlobusTaskMonitor(NewFakeGlobusClient(), nil, testEndpointID, WithCaseInsensitivePaths(true))
+	store = m.projectFiles.(*dbProjectFileStore)
+	require.True(t, store.foldCase)
+	require.Equal(t, "LOWER(name) = LOWER(?)", store.equals("name"))
+}
@@ -0,0 +1,45 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	globus "github.com/materials-commons/goglobus"
+)
+
+// ACLClient is implemented by a GlobusClient that can delete the ACL rules on an endpoint. The client
+// returned by NewGlobusClient implements it.
+type ACLClient interface {
+	DeleteEndpointACLRule(endpointID, accessID string) (globus.DeleteEndpointACLRuleResult, error)
+}
+
+var _ ACLClient = (*globusClient)(nil)
+
+// deleteUploadACL removes the ACL rule that lets files be written to an upload's directory, so nothing
+// more can be added to it once it is being loaded. It only does so when WithDeleteUploadACLs was used
+// and the client is an ACLClient. A failure leaves the directory writable, so it is counted and sent
+// to the ErrorSink as acl_delete_failed for someone to follow up on. It returns false on a failure,
+// and whether that stops the upload from being finished depends on the ProcessingOrder.
+func (m *GlobusTaskMonitor) deleteUploadACL(id string, task globus.Task, upload *GlobusUpload) bool {
+	if !m.config.DeleteUploadACLs || upload.GlobusAclID == "" {
+		return true
+	}
+
+	client, ok := m.client.(ACLClient)
+	if !ok {
+		m.logger.Warnf("Globus client can't delete ACLs, leaving ACL %s for globus upload %s", upload.GlobusAclID, id)
+		return true
+	}
+
+	if _, err := client.DeleteEndpointACLRule(m.endpointID, upload.GlobusAclID); err != nil {
+		m.incCounter("acl_delete_failed", nil)
+		m.notifyError(ErrorEvent{
+			Kind:   "acl_delete_failed",
+			Err:    fmt.Errorf("unable to delete ACL %s for globus upload %s: %w", upload.GlobusAclID, id, m.client.ExtractError(err)),
+			Fields: log.Fields{"acl_id": upload.GlobusAclID, "globus_upload_id": id, "task_id": task.TaskID},
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadACLIsDeleted(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client, WithDeleteUploadACLs())
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, GlobusAclID: "acl-1"})
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, []string{"acl-1"}, client.DeletedACLs)
+}
+
+func TestUploadACLIsKeptByDefault(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client)
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, GlobusAclID: "acl-1"})
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Empty(t, client.DeletedACLs)
+}
+
+func TestFailedACLDeletionIsReported(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	client.ACLErr = errors.New("request failed")
+	client.GlobusError = &GlobusError{StatusCode: 404, Code: "AccessRuleNotFound", Message: "access rule not found"}
+	metrics := newMemoryMetrics()
+	sink := &fakeErrorSink{}
+	m := newTestMonitor(client, WithDeleteUploadACLs(), WithMetrics(metrics), WithErrorSink(sink))
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, GlobusAclID: "acl-1"})
+
+	// The upload is still loaded.
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, m.fileLoads.(*fakeFileLoadStore).fileLoads, 1)
+	require.True(t, m.finishedGlobusTasks["1"])
+
+	require.Equal(t, 1.0, metrics.counter("acl_delete_failed", Labels{"endpoint": testEndpointID}))
+	require.Len(t, sink.events, 1)
+	event := sink.events[0]
+	require.Equal(t, "acl_delete_failed", event.Kind)
+	require.Equal(t, testEndpointID, event.Fields["endpoint"])
+	require.Equal(t, "acl-1", event.Fields["acl_id"])
+	require.Equal(t, "1", event.Fields["globus_upload_id"])
+
+	var globusErr *GlobusError
+	require.True(t, errors.As(event.Err, &globusErr))
+	require.Equal(t, "AccessRuleNotFound", globusErr.Code)
+}
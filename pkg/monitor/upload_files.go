@@ -0,0 +1,196 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// DuplicateNamePolicy controls what happens when two files in the same upload end up with the same
+// path in the project once their paths are normalized. The same file transferred more than once is
+// only one file in the upload directory, so it isn't a duplicate.
+type DuplicateNamePolicy int
+
+const (
+	// DuplicateNameError rejects the upload.
+	DuplicateNameError DuplicateNamePolicy = iota
+
+	// DuplicateNameRename keeps the first file under its name and gives each later one a numbered
+	// name, so name.txt becomes name-1.txt, name-2.txt and so on. The later files are moved to their
+	// new names in the upload directory before the file load is added.
+	DuplicateNameRename
+
+	// DuplicateNameOverwrite keeps only the last file with the name. The earlier files are removed
+	// from the upload directory, and the last one is moved to the name, before the file load is added.
+	DuplicateNameOverwrite
+)
+
+// UploadFile is a file from an upload. Source is the path of the file in the upload directory, as it
+// was uploaded, and Path is where it goes in the project. Path is Source normalized by the
+// PathNormalizer, and renamed when the DuplicateNamePolicy gives it a new name. Files are looked up
+// in the upload directory by Source, and compared with each other and the project by Path.
+// Replaces holds the Sources of the earlier files with the same Path that DuplicateNameOverwrite
+// dropped in favour of this one.
+type UploadFile struct {
+	Source   mcpath.RelPath
+	Path     mcpath.RelPath
+	Replaces []mcpath.RelPath
+}
+
+// ErrDuplicateName is returned by resolveUploadFiles when two files have the same path and the
+// policy is DuplicateNameError.
+type ErrDuplicateName struct {
+	Path mcpath.RelPath
+}
+
+func (e *ErrDuplicateName) Error() string {
+	return fmt.Sprintf("more than one file uploaded to %s", e.Path)
+}
+
+// resolveUploadFiles turns the transfers for an upload into the files to load, applying the policy
+// to any files whose paths collide. Destination paths have the format
+// /__globus_uploads/<id>/...rest of path... and the rest of the path is the file's Source. Once
+// normalize has been applied it is the file's Path. When foldCase is true paths that only differ in
+// case collide, as they do on case insensitive storage, and the file keeps the case of the first
+// path uploaded.
+func resolveUploadFiles(transfers []globus.Transfer, policy DuplicateNamePolicy, normalize mcpath.PathNormalizer, foldCase bool) ([]UploadFile, error) {
+	// The same file can be transferred more than once, and is still only one file.
+	var uploaded []string
+	sources := make(map[mcpath.RelPath]bool)
+	for _, transfer := range transfers {
+		pieces := strings.SplitN(transfer.DestinationPath, "/", 4)
+		if len(pieces) < 4 || sources[mcpath.NewRelPath(pieces[3])] {
+			continue
+		}
+
+		sources[mcpath.NewRelPath(pieces[3])] = true
+		uploaded = append(uploaded, pieces[3])
+	}
+
+	var files []UploadFile
+	seen := make(map[mcpath.RelPath]int)
+	key := func(path mcpath.RelPath) mcpath.RelPath {
+		if foldCase {
+			return mcpath.RelPath(strings.ToLower(path.String()))
+		}
+
+		return path
+	}
+
+	for _, p := range uploaded {
+		source := mcpath.NewRelPath(p)
+		path := mcpath.NewRelPath(normalize(p))
+		if path.IsRoot() {
+			continue
+		}
+
+		index, exists := seen[key(path)]
+		switch {
+		case !exists:
+			seen[key(path)] = len(files)
+			files = append(files, UploadFile{Source: source, Path: path})
+		case policy == DuplicateNameOverwrite:
+			replaced := files[index]
+			files[index] = UploadFile{
+				Source:   source,
+				Path:     replaced.Path,
+				Replaces: append(replaced.Replaces, replaced.Source),
+			}
+		case policy == DuplicateNameRename:
+			// A file is never renamed to the name another file was uploaded with, so moving it there
+			// can't overwrite that file.
+			renamed := uniqueName(path, func(p mcpath.RelPath) bool {
+				_, exists := seen[key(p)]
+				return exists || sources[p]
+			})
+			seen[key(renamed)] = len(files)
+			files = append(files, UploadFile{Source: source, Path: renamed})
+		default:
+			return nil, &ErrDuplicateName{Path: path}
+		}
+	}
+
+	return files, nil
+}
+
+// uniqueName returns the first numbered version of path that isn't taken.
+func uniqueName(path mcpath.RelPath, taken func(mcpath.RelPath) bool) mcpath.RelPath {
+	ext := filepath.Ext(path.String())
+	base := strings.TrimSuffix(path.String(), ext)
+	for i := 1; ; i++ {
+		renamed := mcpath.RelPath(fmt.Sprintf("%s-%d%s", base, i, ext))
+		if !taken(renamed) {
+			return renamed
+		}
+	}
+}
+
+// removeReplacedFiles removes the files DuplicateNameOverwrite dropped from the upload directory, so
+// the file loader doesn't load them. A file that is already gone was removed by an earlier pass that
+// was retried, and one that is now at the Path of a file was put there by that pass.
+func (m *GlobusTaskMonitor) removeReplacedFiles(ctx context.Context, upload *GlobusUpload, files []UploadFile) error {
+	paths := make(map[mcpath.RelPath]bool)
+	for _, file := range files {
+		paths[file.Path] = true
+	}
+
+	for _, file := range files {
+		for _, replaced := range file.Replaces {
+			if paths[replaced] {
+				continue
+			}
+
+			err := m.objectStore.Delete(ctx, filepath.Join(upload.Path, replaced.String()))
+			if err != nil && !errors.Is(err, objectstore.ErrNotFound) {
+				return fmt.Errorf("unable to remove %s, replaced by %s: %w", replaced, file.Source, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// placeUploadFiles moves each file of an upload whose Path isn't its Source to Path in the upload
+// directory, so the file loader adds it to the project under the path it was given rather than the
+// one it was uploaded to. A file whose Source is already gone was moved by an earlier pass that was
+// retried.
+func (m *GlobusTaskMonitor) placeUploadFiles(ctx context.Context, upload *GlobusUpload, files []UploadFile) error {
+	for _, file := range files {
+		if file.Source == file.Path {
+			continue
+		}
+
+		from, to := filepath.Join(upload.Path, file.Source.String()), filepath.Join(upload.Path, file.Path.String())
+		switch err := moveObject(ctx, m.objectStore, from, to); {
+		case errors.Is(err, objectstore.ErrNotFound):
+		case err != nil:
+			return fmt.Errorf("unable to move %s to %s: %w", file.Source, file.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// moveObject moves the object at from to to in store, replacing anything already at to.
+func moveObject(ctx context.Context, store objectstore.ObjectStore, from, to string) error {
+	r, err := store.Get(ctx, from, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Put(ctx, to, r)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(ctx, from)
+}
@@ -0,0 +1,222 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/mcpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveUploadFiles(t *testing.T) {
+	transfers := []globus.Transfer{
+		{DestinationPath: "/__globus_uploads/1/d1/file.txt"},
+		{DestinationPath: "/__globus_uploads/1/other.txt"},
+		{DestinationPath: "/__globus_uploads/1/d1\\file.txt"},
+		{DestinationPath: "/__globus_uploads/1/d1//file.txt"},
+		{DestinationPath: "/__globus_uploads/1/d1\\\\file.txt"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   DuplicateNamePolicy
+		expected []UploadFile
+	}{
+		{
+			name:   "rename",
+			policy: DuplicateNameRename,
+			expected: []UploadFile{
+				{Source: "d1/file.txt", Path: "d1/file.txt"},
+				{Source: "other.txt", Path: "other.txt"},
+				{Source: "d1\\file.txt", Path: "d1/file-1.txt"},
+				{Source: "d1\\\\file.txt", Path: "d1/file-2.txt"},
+			},
+		},
+		{
+			name:   "overwrite",
+			policy: DuplicateNameOverwrite,
+			expected: []UploadFile{
+				{Source: "d1\\\\file.txt", Path: "d1/file.txt", Replaces: []mcpath.RelPath{"d1/file.txt", "d1\\file.txt"}},
+				{Source: "other.txt", Path: "other.txt"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := resolveUploadFiles(transfers, test.policy, mcpath.CanonicalNormalizer, false)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, files)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		_, err := resolveUploadFiles(transfers, DuplicateNameError, mcpath.CanonicalNormalizer, false)
+		var dupErr *ErrDuplicateName
+		require.True(t, errors.As(err, &dupErr))
+		require.Equal(t, mcpath.RelPath("d1/file.txt"), dupErr.Path)
+	})
+
+	// The same file transferred more than once isn't a duplicate.
+	files, err := resolveUploadFiles(transfers[:4], DuplicateNameError, mcpath.IdentityNormalizer, false)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+}
+
+func TestDuplicateNamesRejectUpload(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/file.txt", "/__globus_uploads/1/d1\\file.txt")
+	sink := &fakeErrorSink{}
+	processed := newFakeProcessedUploadStore()
+	m := newTestMonitor(client, WithErrorSink(sink), WithPathNormalizer(mcpath.CanonicalNormalizer))
+	m.processedUploads = processed
+	seedGlobusUploads(m, 1)
+
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+
+	// The upload is reported once and isn't recorded as processed, so a restarted monitor with a
+	// different policy can pick it up.
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "duplicate_file_name", sink.events[0].Kind)
+	require.Empty(t, processed.uploadIDs())
+}
+
+func TestResolveUploadFilesNormalizesPaths(t *testing.T) {
+	transfers := []globus.Transfer{
+		{DestinationPath: "/__globus_uploads/1/d1\\re\u0301sume\u0301.txt"},
+		{DestinationPath: "/__globus_uploads/1/d1/r\u00e9sum\u00e9.txt"},
+	}
+
+	// Without normalizing, the two paths look like different files.
+	files, err := resolveUploadFiles(transfers, DuplicateNameError, mcpath.IdentityNormalizer, false)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	files, err = resolveUploadFiles(transfers, DuplicateNameOverwrite, mcpath.CanonicalNormalizer, false)
+	require.NoError(t, err)
+	require.Equal(t, []UploadFile{{
+		Source:   "d1/r\u00e9sum\u00e9.txt",
+		Path:     "d1/r\u00e9sum\u00e9.txt",
+		Replaces: []mcpath.RelPath{"d1\\re\u0301sume\u0301.txt"},
+	}}, files)
+}
+
+func TestResolveUploadFilesFoldsCase(t *testing.T) {
+	transfers := []globus.Transfer{
+		{DestinationPath: "/__globus_uploads/1/Dir/File.txt"},
+		{DestinationPath: "/__globus_uploads/1/dir/file.txt"},
+		{DestinationPath: "/__globus_uploads/1/dir/file-1.txt"},
+	}
+
+	// Case sensitive, the paths are different files.
+	files, err := resolveUploadFiles(transfers, DuplicateNameError, mcpath.IdentityNormalizer, false)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	// Case insensitive, they are the same file.
+	_, err = resolveUploadFiles(transfers, DuplicateNameError, mcpath.IdentityNormalizer, true)
+	require.Equal(t, &ErrDuplicateName{Path: "dir/file.txt"}, err)
+
+	files, err = resolveUploadFiles(transfers, DuplicateNameOverwrite, mcpath.IdentityNormalizer, true)
+	require.NoError(t, err)
+	require.Equal(t, []UploadFile{
+		{Source: "dir/file.txt", Path: "Dir/File.txt", Replaces: []mcpath.RelPath{"Dir/File.txt"}},
+		{Source: "dir/file-1.txt", Path: "dir/file-1.txt"},
+	}, files)
+
+	// Renaming skips names taken in any case.
+	files, err = resolveUploadFiles(transfers[:2], DuplicateNameRename, mcpath.IdentityNormalizer, true)
+	require.NoError(t, err)
+	require.Equal(t, []UploadFile{
+		{Source: "Dir/File.txt", Path: "Dir/File.txt"},
+		{Source: "dir/file.txt", Path: "dir/file-1.txt"},
+	}, files)
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	for _, caseInsensitive := range []bool{false, true} {
+		client := NewFakeGlobusClient()
+		client.AddUpload("task-1", "/__globus_uploads/1/Dir/File.txt", "/__globus_uploads/1/dir/file.txt")
+		sink := &fakeErrorSink{}
+		m := newTestMonitor(client, WithCaseInsensitivePaths(caseInsensitive), WithErrorSink(sink))
+		seedGlobusUploads(m, 1)
+
+		result := m.retrieveAndProcessUploads(context.Background())
+		if caseInsensitive {
+			require.Equal(t, 0, result.TasksProcessed)
+			require.Len(t, sink.events, 1)
+			require.Equal(t, "duplicate_file_name", sink.events[0].Kind)
+		} else {
+			require.Equal(t, 1, result.TasksProcessed)
+			require.Empty(t, sink.events)
+		}
+	}
+}
+
+func TestNormalizedUploadFiles(t *testing.T) {
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithPathNormalizer(mcpath.CanonicalNormalizer), WithExcludeGlobs([]string{".DS_Store"}))
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+	// The files are on disk under the names they were uploaded with.
+	dir := writeUploadDir(t, map[string]string{"d1\\.DS_Store": "x", "d1\\re\u0301sume\u0301.txt": "cv"})
+	uploads.add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	client.AddUpload("task-1", "/__globus_uploads/1/d1\\.DS_Store", "/__globus_uploads/1/d1\\re\u0301sume\u0301.txt")
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+
+	// The excluded file is found by the name it was uploaded with, and the one that is loaded is
+	// moved to its normalized path.
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "d1", entries[0].Name())
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "d1", "r\u00e9sum\u00e9.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "cv", string(contents))
+}
+
+func TestDuplicateNamesInUploadDirectory(t *testing.T) {
+	tests := []struct {
+		policy   DuplicateNamePolicy
+		expected map[string]string
+	}{
+		{policy: DuplicateNameRename, expected: map[string]string{"d1/file.txt": "first", "d1/file-1.txt": "second"}},
+		{policy: DuplicateNameOverwrite, expected: map[string]string{"d1/file.txt": "second"}},
+	}
+
+	for _, test := range tests {
+		client := NewFakeGlobusClient()
+		m := newTestMonitor(client, WithPathNormalizer(mcpath.CanonicalNormalizer), WithDuplicateNamePolicy(test.policy))
+		uploads := m.globusUploads.(*fakeGlobusUploadStore)
+
+		dir := writeUploadDir(t, map[string]string{"d1/file.txt": "first", "d1\\file.txt": "second"})
+		uploads.add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+		client.AddUpload("task-1", "/__globus_uploads/1/d1/file.txt", "/__globus_uploads/1/d1\\file.txt")
+
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+
+		// The file loader finds the files under the names the policy gave them.
+		files := make(map[string]string)
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+
+			contents, err := ioutil.ReadFile(path)
+			rel, _ := filepath.Rel(dir, path)
+			files[rel] = string(contents)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, test.expected, files)
+	}
+}
@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectUploadSummary is a read model of the uploads processed for a project. It is kept up to date
+// as uploads are processed (see WithProjectUploadSummaries), so the totals can be shown without
+// adding up the file loads each time they are asked for.
+type ProjectUploadSummary struct {
+	ProjectID    int       `json:"project_id" gorm:"primaryKey"`
+	Uploads      int       `json:"uploads"`
+	FileCount    int       `json:"file_count"`
+	TotalBytes   int64     `json:"total_bytes"`
+	LastUploadAt time.Time `json:"last_upload_at"`
+}
+
+func (ProjectUploadSummary) TableName() string {
+	return "project_upload_summaries"
+}
+
+// UploadSummaryStore maintains the ProjectUploadSummary entries.
+type UploadSummaryStore interface {
+	// AddUpload adds an upload of files files totalling bytes, processed at uploadedAt, to the
+	// summary for projectID, creating the summary if the project doesn't have one yet.
+	AddUpload(projectID, files int, bytes int64, uploadedAt time.Time) error
+}
+
+type dbUploadSummaryStore struct {
+	db *gorm.DB
+}
+
+func newDBUploadSummaryStore(db *gorm.DB) *dbUploadSummaryStore {
+	return &dbUploadSummaryStore{db: db}
+}
+
+func (s *dbUploadSummaryStore) AddUpload(projectID, files int, bytes int64, uploadedAt time.Time) error {
+	// A single upsert, so two monitors adding to the same project can't lose an update.
+	return s.db.Exec(`
+		INSERT INTO project_upload_summaries (project_id, uploads, file_count, total_bytes, last_upload_at)
+		VALUES (?, 1, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			uploads = uploads + 1,
+			file_count = file_count + VALUES(file_count),
+			total_bytes = total_bytes + VALUES(total_bytes),
+			last_upload_at = GREATEST(last_upload_at, VALUES(last_upload_at))`,
+		projectID, files, bytes, uploadedAt).Error
+}
+
+// updateUploadSummary adds a processed upload to its project's summary when summaries are turned on.
+// The summary is only a read model, so failing to update it is logged rather than holding up the
+// upload.
+func (m *GlobusTaskMonitor) updateUploadSummary(projectID, files int, bytes int64) {
+	if !m.config.ProjectUploadSummaries {
+		return
+	}
+
+	if err := m.uploadSummaries.AddUpload(projectID, files, bytes, m.now()); err != nil {
+		m.logger.Errorf("Unable to update the upload summary for project %d: %s", projectID, err)
+	}
+}
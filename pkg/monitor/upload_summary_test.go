@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUploadSummaryStore is an in memory UploadSummaryStore.
+type fakeUploadSummaryStore struct {
+	mu        sync.Mutex
+	summaries map[int]ProjectUploadSummary
+}
+
+func newFakeUploadSummaryStore() *fakeUploadSummaryStore {
+	return &fakeUploadSummaryStore{summaries: make(map[int]ProjectUploadSummary)}
+}
+
+func (s *fakeUploadSummaryStore) AddUpload(projectID, files int, bytes int64, uploadedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summary := s.summaries[projectID]
+	summary.ProjectID = projectID
+	summary.Uploads++
+	summary.FileCount += files
+	summary.TotalBytes += bytes
+	if uploadedAt.After(summary.LastUploadAt) {
+		summary.LastUploadAt = uploadedAt
+	}
+	s.summaries[projectID] = summary
+	return nil
+}
+
+func TestProjectUploadSummaries(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt", "/__globus_uploads/1/b.txt")
+	client.AddUpload("task-3", "/__globus_uploads/3/d.txt")
+	client.Tasks[0].BytesTransferred = 100
+	client.Tasks[1].BytesTransferred = 7
+
+	summaries := newFakeUploadSummaryStore()
+	m := newTestMonitor(client, WithProjectUploadSummaries())
+	m.uploadSummaries = summaries
+	m.now = func() time.Time { return now }
+	uploads := m.globusUploads.(*fakeGlobusUploadStore)
+	uploads.add(&GlobusUpload{ID: 1, OwnerID: 1, ProjectID: 10})
+	uploads.add(&GlobusUpload{ID: 2, OwnerID: 1, ProjectID: 10})
+	uploads.add(&GlobusUpload{ID: 3, OwnerID: 1, ProjectID: 20})
+
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, map[int]ProjectUploadSummary{
+		10: {ProjectID: 10, Uploads: 1, FileCount: 2, TotalBytes: 100, LastUploadAt: now},
+		20: {ProjectID: 20, Uploads: 1, FileCount: 1, TotalBytes: 7, LastUploadAt: now},
+	}, summaries.summaries)
+
+	// Upload 2 completes later and is added to what project 10 already has.
+	later := now.Add(time.Hour)
+	m.now = func() time.Time { return later }
+	client.AddUpload("task-2", "/__globus_uploads/2/c.txt")
+	client.Tasks[2].BytesTransferred = 50
+
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, ProjectUploadSummary{ProjectID: 10, Uploads: 2, FileCount: 3, TotalBytes: 150, LastUploadAt: later}, summaries.summaries[10])
+	require.Equal(t, 1, summaries.summaries[20].Uploads)
+}
+
+func TestProjectUploadSummariesOffByDefault(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/a.txt")
+	summaries := newFakeUploadSummaryStore()
+	m := newTestMonitor(client)
+	m.uploadSummaries = summaries
+	seedGlobusUploads(m, 1)
+
+	m.retrieveAndProcessUploads(context.Background())
+	require.Empty(t, summaries.summaries)
+}
@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// VerifyEndpoint checks that the endpoint exists and that our credentials can see its tasks, so a
+// misconfigured endpoint id is caught at startup rather than showing up as passes that never find
+// anything. It asks Globus for a single task, which is the cheapest call that fails for an endpoint
+// we can't use. An endpoint id that isn't a UUID is rejected with ErrInvalidEndpointID without
+// asking Globus.
+func (m *GlobusTaskMonitor) VerifyEndpoint(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := validateEndpointID(m.endpointID); err != nil {
+		return err
+	}
+
+	_, err := m.client.GetEndpointTaskList(m.endpointID, map[string]string{"limit": "1"})
+	if err == nil {
+		return nil
+	}
+
+	globusErr := m.client.ExtractError(err)
+	switch {
+	case globusErr.IsNotFound():
+		return fmt.Errorf("globus endpoint %s not found: %w", m.endpointID, globusErr)
+	case globusErr.IsAuthError():
+		return fmt.Errorf("globus endpoint %s is not accessible with the configured credentials: %w", m.endpointID, globusErr)
+	default:
+		return fmt.Errorf("unable to verify globus endpoint %s: %w", m.endpointID, globusErr)
+	}
+}
+
+// verifyOnStart verifies the endpoint before the monitor starts. Globus being briefly unavailable
+// shouldn't stop the monitor from starting, so transient errors are only logged.
+func (m *GlobusTaskMonitor) verifyOnStart(ctx context.Context) error {
+	err := m.VerifyEndpoint(ctx)
+	if err == nil {
+		return nil
+	}
+
+	var globusErr *GlobusError
+	if errors.As(err, &globusErr) && globusErr.IsTransient() {
+		m.logger.Warnf("Unable to verify globus endpoint %s, starting anyway: %s", m.endpointID, err)
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		globusError *GlobusError
+		expected    string
+	}{
+		{name: "not found", globusError: &GlobusError{StatusCode: http.StatusNotFound, Code: "EndpointNotFound"}, expected: "globus endpoint " + testEndpointID + " not found"},
+		{name: "not accessible", globusError: &GlobusError{StatusCode: http.StatusForbidden, Code: "PermissionDenied"}, expected: "not accessible"},
+		{name: "other", globusError: &GlobusError{StatusCode: http.StatusBadRequest, Code: "BadRequest"}, expected: "unable to verify"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			client.TaskListErr = errors.New("request failed")
+			client.GlobusError = test.globusError
+			m := newTestMonitor(client)
+
+			err := m.VerifyEndpoint(context.Background())
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.expected)
+
+			var globusErr *GlobusError
+			require.True(t, errors.As(err, &globusErr))
+			require.Equal(t, test.globusError, globusErr)
+		})
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		client := NewFakeGlobusClient()
+		m := newTestMonitor(client)
+		require.NoError(t, m.VerifyEndpoint(context.Background()))
+		require.Equal(t, "1", client.TaskListFilters[0]["limit"])
+	})
+}
+
+func TestRunFailsForMissingEndpoint(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TaskListErr = errors.New("request failed")
+	client.GlobusError = &GlobusError{StatusCode: http.StatusNotFound, Code: "EndpointNotFound"}
+	m := newTestMonitor(client, WithStopAfterIdlePasses(1))
+
+	err := m.Run(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+
+	// Only the verification was made, no passes were run.
+	require.Equal(t, 1, client.TaskListCalls)
+}
+
+func TestRunStartsDespiteTransientVerifyError(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TaskListErr = errors.New("request failed")
+	client.GlobusError = &GlobusError{StatusCode: http.StatusServiceUnavailable}
+	m := newTestMonitor(client, WithStopAfterIdlePasses(1))
+
+	require.NoError(t, m.Run(context.Background()))
+	require.Equal(t, 2, client.TaskListCalls)
+}
+
+func TestRunStartsDespiteNetworkError(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.TaskListErr = errors.New("dial tcp: connection refused")
+	m := newTestMonitor(client, WithStopAfterIdlePasses(1))
+
+	require.NoError(t, m.Run(context.Background()))
+}
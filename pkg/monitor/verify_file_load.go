@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/apex/log"
+	globus "github.com/materials-commons/goglobus"
+	"gorm.io/gorm"
+)
+
+// verifyFileLoadCreated reads the file load for an upload back by its globus upload id, and returns
+// false when it can't be found, so the upload is left unfinished, its globus_uploads entry is kept,
+// and it is retried. An insert that reports success without the row being written would otherwise
+// delete the upload with nothing left to load its files. A retried upload that already has a file
+// load uses it rather than adding another, see addFileLoad. It returns true without checking when
+// SkipVerifyFileLoad is set, or the FileLoadStore isn't a FileLoadFinder.
+func (m *GlobusTaskMonitor) verifyFileLoadCreated(ctx context.Context, id string, task globus.Task, fileLoad *FileLoad) bool {
+	finder, ok := m.fileLoads.(FileLoadFinder)
+	if m.config.SkipVerifyFileLoad || !ok {
+		return true
+	}
+
+	var found *FileLoad
+	err := m.withDBRetry(ctx, "verify_file_load", func() (err error) {
+		found, err = finder.FindFileLoad(ctx, fileLoad.GlobusUploadID)
+		return err
+	})
+
+	fields := log.Fields{"globus_upload_id": id, "task_id": task.TaskID, "file_load_id": fileLoad.ID}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		m.notifyError(ErrorEvent{
+			Kind:   "file_load_not_created",
+			Err:    fmt.Errorf("file load (id: %d) for globus upload %s was not found after it was created", fileLoad.ID, id),
+			Fields: fields,
+		})
+		return false
+	case err != nil:
+		m.notifyError(ErrorEvent{
+			Kind:   "file_load_not_verified",
+			Err:    fmt.Errorf("unable to verify file load for globus upload %s: %w", id, err),
+			Fields: fields,
+		})
+		return false
+	}
+
+	fileLoad.ID = found.ID
+	return true
+}
@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadKeptWhenFileLoadNotCreated(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client)
+	seedGlobusUploads(m, 1)
+	fileLoads := m.fileLoads.(*fakeFileLoadStore)
+	fileLoads.noop = true
+	sink := &fakeErrorSink{}
+	m.errorSink = sink
+
+	// The insert reports success but nothing is written, so the upload is kept for the next pass.
+	require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.False(t, m.isFinished("1"))
+	_, err := m.globusUploads.GetGlobusUpload("1")
+	require.NoError(t, err)
+	require.Len(t, sink.events, 1)
+	require.Equal(t, "file_load_not_created", sink.events[0].Kind)
+
+	fileLoads.noop = false
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Len(t, fileLoads.fileLoads, 1)
+	require.True(t, m.isFinished("1"))
+
+	// Once the file load is found the upload is deleted.
+	require.False(t, uploadExists(m, "1"))
+}
+
+func TestVerifyFileLoadCreatedOff(t *testing.T) {
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	m := newTestMonitor(client, WithVerifyFileLoadCreated(false))
+	seedGlobusUploads(m, 1)
+	m.fileLoads.(*fakeFileLoadStore).noop = true
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.True(t, m.isFinished("1"))
+}
+
+func TestFileLoadNotAddedTwiceAfterVerifyFails(t *testing.T) {
+	for _, order := range []ProcessingOrder{ProcessingOrderACLFirst, ProcessingOrderLoadFirst} {
+		client := NewFakeGlobusClient()
+		client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+		m := newTestMonitor(client, WithProcessingOrder(order))
+		seedGlobusUploads(m, 1)
+		fileLoads := m.fileLoads.(*fakeFileLoadStore)
+
+		// The file load is added, but reading it back fails, so the upload is retried.
+		fileLoads.findErrs = []error{nil, errors.New("connection lost")}
+		require.Equal(t, 0, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Len(t, fileLoads.fileLoads, 1)
+		require.False(t, m.isFinished("1"))
+
+		// The retry uses the file load that is already there.
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Len(t, fileLoads.fileLoads, 1)
+		require.True(t, m.isFinished("1"))
+	}
+}
@@ -0,0 +1,25 @@
+package monitor
+
+// Version, Commit and BuildTime identify the build of the monitor. They are set at build time with
+// ldflags, for example:
+//
+//	go build -ldflags "-X github.com/materials-commons/mcbridgefs/pkg/monitor.Version=v1.2.0"
+//
+// and are "dev" for builds that don't set them.
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildTime = "dev"
+)
+
+// BuildInfo identifies the build of the monitor that is running.
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildTime string
+}
+
+// GetBuildInfo returns the build info set with ldflags.
+func GetBuildInfo() BuildInfo {
+	return BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime}
+}
@@ -0,0 +1,85 @@
+package monitor
+
+import (
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+)
+
+// defaultWatermarkLagWarning is how far the watermark can fall behind before the monitor warns about it.
+const defaultWatermarkLagWarning = time.Hour
+
+// advanceWatermark moves lastProcessedTime up to t. Every task that completed before t must have been
+// processed, so t is either the time a pass that handled every task it was given started, or the
+// earliest completion time of the tasks still to be handled, see advanceWatermarkToPending. The
+// watermark is kept in UTC.
+func (m *GlobusTaskMonitor) advanceWatermark(t time.Time) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	m.watermarkKnown = true
+	if t.After(m.lastProcessedTime) {
+		m.lastProcessedTime = t.UTC()
+	}
+}
+
+// completionFilterStart returns the earliest completion time a pass starting at passStart asks Globus
+// for tasks from. It is the start of the lookback window, or with WithFilterFromWatermark the
+// watermark when that is later, so Globus doesn't send the tasks that are already known to have been
+// processed. The watermark the monitor is created with is only when it started, so it isn't used
+// until a pass or ImportState has set it. Processed uploads are still skipped either way.
+func (m *GlobusTaskMonitor) completionFilterStart(passStart time.Time) time.Time {
+	start := m.compensateClockSkew(passStart).Add(-m.config.TaskLookback)
+	if !m.config.FilterFromWatermark {
+		return start
+	}
+
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	if m.watermarkKnown && m.lastProcessedTime.After(start) {
+		return m.lastProcessedTime
+	}
+
+	return start
+}
+
+// advanceWatermarkToPending moves the watermark up to the earliest completion time of the pending
+// tasks, the ones from the current pass that haven't been handled yet, or to passStart when there
+// are none. Every task that completed before then has been handled. The watermark isn't moved when
+// a pending task's completion time can't be parsed, as there is no telling how far back it goes.
+func (m *GlobusTaskMonitor) advanceWatermarkToPending(passStart time.Time, pending []globus.Task) {
+	watermark := passStart
+	for _, task := range pending {
+		completed, ok := parseGlobusTime(task.CompletionTime)
+		if !ok {
+			return
+		}
+
+		if completed.Before(watermark) {
+			watermark = completed
+		}
+	}
+
+	m.advanceWatermark(watermark)
+}
+
+// reportWatermarkLag sets the watermark_lag_seconds gauge to how far lastProcessedTime is behind now,
+// and warns when that is more than the configured threshold. A growing lag means the monitor is
+// falling behind, usually because calls to Globus are failing. Until a pass or ImportState has set
+// the watermark it is only when the monitor started, which says nothing about the backlog, so the
+// gauge isn't set.
+func (m *GlobusTaskMonitor) reportWatermarkLag() {
+	m.statusMu.Lock()
+	known, lastProcessed := m.watermarkKnown, m.lastProcessedTime
+	m.statusMu.Unlock()
+
+	if !known {
+		return
+	}
+
+	lag := m.now().Sub(lastProcessed)
+	m.setGauge("watermark_lag_seconds", lag.Seconds(), nil)
+	if m.config.WatermarkLagWarning > 0 && lag > m.config.WatermarkLagWarning {
+		m.logger.Warnf("Globus task monitor for endpoint %s is %s behind (last processed %s)",
+			m.endpointID, lag.Round(time.Second), lastProcessed.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	globus "github.com/materials-commons/goglobus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarkLag(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/file.txt")
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+	seedGlobusUploads(m, 1)
+	m.now = func() time.Time { return now }
+	m.lastProcessedTime = now.Add(-time.Hour)
+	labels := Labels{"endpoint": testEndpointID}
+
+	// A successful pass brings the watermark up to when the pass started.
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, now, m.lastProcessedTime)
+	require.Equal(t, 0.0, metrics.gauge("watermark_lag_seconds", labels))
+
+	// Passes that fail leave the watermark where it is, so the lag grows with the clock.
+	client.TaskListErr = errors.New("globus down")
+	now = now.Add(5 * time.Minute)
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 300.0, metrics.gauge("watermark_lag_seconds", labels))
+
+	// So does a pass where some transfers couldn't be fetched.
+	client.TaskListErr = nil
+	client.TransfersErr = errors.New("globus down")
+	now = now.Add(5 * time.Minute)
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, 600.0, metrics.gauge("watermark_lag_seconds", labels))
+
+	// Once Globus recovers the monitor catches up.
+	client.TransfersErr = nil
+	now = now.Add(time.Minute)
+	m.retrieveAndProcessUploads(context.Background())
+	require.Equal(t, now, m.lastProcessedTime)
+	require.Equal(t, 0.0, metrics.gauge("watermark_lag_seconds", labels))
+}
+
+// watermarkRecordingFileLoadStore records the watermark when each file load is added. Adding the file
+// load for crashAt cancels the pass and fails, as does everything after it, like the monitor stopping
+// part way through a page.
+type watermarkRecordingFileLoadStore struct {
+	fakeFileLoadStore
+	m          *GlobusTaskMonitor
+	crashAt    int
+	cancel     context.CancelFunc
+	watermarks map[int]time.Time
+}
+
+func (s *watermarkRecordingFileLoadStore) AddFileLoad(ctx context.Context, fileLoad *FileLoad) error {
+	s.watermarks[fileLoad.GlobusUploadID] = s.m.lastProcessedTime
+	if fileLoad.GlobusUploadID == s.crashAt {
+		s.cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.fakeFileLoadStore.AddFileLoad(ctx, fileLoad)
+}
+
+func TestWatermarkAdvancesByPage(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithTaskPageSize(2))
+	m.now = func() time.Time { return now }
+	m.lastProcessedTime = now.Add(-48 * time.Hour)
+
+	completed := make(map[int]time.Time)
+	for i := 1; i <= 6; i++ {
+		client.AddUpload(fmt.Sprintf("task-%d", i), fmt.Sprintf("/__globus_uploads/%d/file.txt", i))
+		completed[i] = time.Date(2021, 1, 1, 0, 0, i, 0, time.UTC)
+		client.Tasks[i-1].CompletionTime = completed[i].Format("2006-01-02T15:04:05")
+		seedGlobusUploads(m, i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &watermarkRecordingFileLoadStore{m: m, crashAt: 4, cancel: cancel, watermarks: make(map[int]time.Time)}
+	m.fileLoads = store
+
+	result := m.retrieveAndProcessUploads(ctx)
+	require.Equal(t, 3, result.TasksProcessed)
+
+	// Wherever the monitor stopped, it would start again from before the task it was working on.
+	for id, watermark := range store.watermarks {
+		require.False(t, watermark.After(completed[id]), "upload %d", id)
+	}
+
+	// The first page moved the watermark up to the first task of the second page, and it stays at
+	// the task that was being processed when the pass stopped.
+	require.Equal(t, completed[3], store.watermarks[3])
+	require.Equal(t, completed[4], m.lastProcessedTime)
+
+	// The next pass picks up where it left off and catches up.
+	require.Equal(t, 3, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+	require.Equal(t, now, m.lastProcessedTime)
+}
+
+func TestFilterFromWatermark(t *testing.T) {
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		opts      []Option
+		watermark time.Time
+		expected  string
+	}{
+		{name: "off", watermark: now.Add(-24 * time.Hour), expected: "2021-03-08"},
+		{name: "watermark in the window", opts: []Option{WithFilterFromWatermark()}, watermark: now.Add(-24 * time.Hour), expected: "2021-03-14"},
+		{name: "watermark before the window", opts: []Option{WithFilterFromWatermark()}, watermark: now.Add(-30 * 24 * time.Hour), expected: "2021-03-08"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewFakeGlobusClient()
+			m := newTestMonitor(client, test.opts...)
+			m.now = func() time.Time { return now }
+			require.NoError(t, m.ImportState([]byte(`{"version": 1, "endpoint_id": "`+testEndpointID+`", "watermark": "`+test.watermark.Format(time.RFC3339)+`"}`)))
+
+			m.retrieveAndProcessUploads(context.Background())
+			require.Equal(t, test.expected, client.TaskListFilters[0]["filter_completion_time"])
+		})
+	}
+}
+
+func TestFilterFromWatermarkWaitsForAKnownWatermark(t *testing.T) {
+	// A new monitor's watermark is when it was created, which says nothing about what has been
+	// processed, so the first pass asks for the whole lookback window and later ones from the watermark.
+	now := time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)
+	client := NewFakeGlobusClient()
+	m := newTestMonitor(client, WithFilterFromWatermark())
+	m.now = func() time.Time { return now }
+	m.lastProcessedTime = now
+
+	m.retrieveAndProcessUploads(context.Background())
+	now = now.Add(time.Hour)
+	m.retrieveAndProcessUploads(context.Background())
+
+	require.Equal(t, "2021-03-08", client.TaskListFilters[0]["filter_completion_time"])
+	require.Equal(t, "2021-03-15", client.TaskListFilters[1]["filter_completion_time"])
+}
+
+func TestWatermarkIsKeptInUTC(t *testing.T) {
+	passStart := time.Date(2021, 3, 15, 16, 0, 0, 0, time.UTC)
+	m := newTestMonitor(NewFakeGlobusClient())
+	require.Equal(t, time.UTC, m.lastProcessedTime.Location())
+
+	// 10:00 at -05:00 is 15:00 UTC, after a watermark of 14:30 UTC, so the watermark moves up to it
+	// even though 10:00 reads as earlier.
+	m.lastProcessedTime = time.Date(2021, 3, 15, 14, 30, 0, 0, time.UTC)
+	pending := []globus.Task{{TaskID: "task-1", CompletionTime: "2021-03-15T10:00:00-05:00"}}
+	m.advanceWatermarkToPending(passStart, pending)
+	require.Equal(t, time.Date(2021, 3, 15, 15, 0, 0, 0, time.UTC), m.lastProcessedTime)
+
+	// And it is before a watermark of 15:30 UTC, so the watermark stays where it is.
+	m.lastProcessedTime = time.Date(2021, 3, 15, 15, 30, 0, 0, time.UTC)
+	m.advanceWatermarkToPending(passStart, pending)
+	require.Equal(t, time.Date(2021, 3, 15, 15, 30, 0, 0, time.UTC), m.lastProcessedTime)
+
+	// A watermark given in another zone is stored in UTC.
+	m.advanceWatermark(time.Date(2021, 3, 15, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60)))
+	require.Equal(t, time.Date(2021, 3, 15, 17, 0, 0, 0, time.UTC), m.lastProcessedTime)
+}
+
+func TestWatermarkLagWaitsForAKnownWatermark(t *testing.T) {
+	client := NewFakeGlobusClient()
+	metrics := newMemoryMetrics()
+	m := newTestMonitor(client, WithMetrics(metrics))
+	key := metricKey("watermark_lag_seconds", Labels{"endpoint": testEndpointID})
+
+	// The watermark of a new monitor is when it started, so a failed first pass reports no lag.
+	client.TaskListErr = errors.New("globus down")
+	m.retrieveAndProcessUploads(context.Background())
+	require.NotContains(t, metrics.gauges, key)
+
+	client.TaskListErr = nil
+	m.retrieveAndProcessUploads(context.Background())
+	require.Contains(t, metrics.gauges, key)
+}
@@ -0,0 +1,84 @@
+package monitor
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/apex/log"
+	globus "github.com/materials-commons/goglobus"
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+)
+
+// ZeroByteFilePolicy controls what happens to empty files in an upload. Empty files are valid, but
+// can also be what is left behind by an upload that was interrupted.
+type ZeroByteFilePolicy int
+
+const (
+	// ZeroByteFileLoad loads empty files like any other.
+	ZeroByteFileLoad ZeroByteFilePolicy = iota
+
+	// ZeroByteFileSkip removes empty files from the upload directory before it is loaded, so they
+	// never reach the project.
+	ZeroByteFileSkip
+
+	// ZeroByteFileFlag loads empty files, but reports the upload to the ErrorSink so they can be
+	// checked.
+	ZeroByteFileFlag
+)
+
+// zeroByteFiles splits files into those that are empty and the rest. Globus doesn't report the size
+// of each transferred file, so the sizes come from the objects in store under dir, the directory the
+// upload was written to. A file that can't be found there is assumed not to be empty.
+func zeroByteFiles(store objectstore.ObjectStore, dir string, files []UploadFile) (empty, rest []UploadFile) {
+	for _, file := range files {
+		info, err := store.Stat(context.Background(), filepath.Join(dir, file.Source.String()))
+		if err == nil && info.Size == 0 {
+			empty = append(empty, file)
+		} else {
+			rest = append(rest, file)
+		}
+	}
+
+	return empty, rest
+}
+
+// applyZeroByteFilePolicy applies the configured ZeroByteFilePolicy to the files of an upload, and
+// returns the files that will be loaded.
+func (m *GlobusTaskMonitor) applyZeroByteFilePolicy(id string, task globus.Task, upload *GlobusUpload, files []UploadFile, skipped *skippedFiles) []UploadFile {
+	if m.config.ZeroByteFilePolicy == ZeroByteFileLoad {
+		return files
+	}
+
+	empty, rest := zeroByteFiles(m.objectStore, upload.Path, files)
+	if len(empty) == 0 {
+		return files
+	}
+
+	var paths []string
+	for _, file := range empty {
+		paths = append(paths, file.Path.String())
+	}
+
+	if m.config.ZeroByteFilePolicy == ZeroByteFileFlag {
+		m.notifyError(ErrorEvent{
+			Kind:   "zero_byte_files",
+			Fields: log.Fields{"globus_upload_id": id, "task_id": task.TaskID, "paths": paths},
+		})
+		return files
+	}
+
+	loaded := rest
+	for _, file := range empty {
+		if err := m.objectStore.Delete(context.Background(), filepath.Join(upload.Path, file.Source.String())); err != nil {
+			// The file is still there, so the file loader will load it.
+			m.logger.Errorf("Unable to remove empty file %s from globus upload %s: %s", file.Source, id, err)
+			loaded = append(loaded, file)
+			continue
+		}
+
+		m.skipFile(skipped, uploadSkippedFile(id, task, upload, file, "zero_byte"))
+	}
+
+	m.logger.Infof("Skipped %d empty files in globus upload %s", len(empty), id)
+	return loaded
+}
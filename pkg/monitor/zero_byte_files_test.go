@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/materials-commons/mcbridgefs/pkg/objectstore"
+	"github.com/stretchr/testify/require"
+)
+
+// setupZeroByteUpload creates an upload directory with two empty files and one that isn't, and a
+// monitor with a task for it.
+func setupZeroByteUpload(t *testing.T, opts ...Option) (*GlobusTaskMonitor, string) {
+	dir, err := ioutil.TempDir("", "zero-byte-upload")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "d1"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d1/empty.txt"), nil, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d1/data.txt"), []byte("data"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0644))
+
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/d1/empty.txt", "/__globus_uploads/1/d1/data.txt", "/__globus_uploads/1/empty.txt")
+	m := newTestMonitor(client, opts...)
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: dir})
+	return m, dir
+}
+
+// publishedFiles returns the number of files in the one UploadEvent published.
+func publishedFiles(t *testing.T, publisher *memoryPublisher) int {
+	require.Len(t, publisher.messages[UploadEventTopic], 1)
+	var event UploadEvent
+	require.NoError(t, json.Unmarshal(publisher.messages[UploadEventTopic][0], &event))
+	return event.Files
+}
+
+func TestZeroByteFilePolicy(t *testing.T) {
+	t.Run("load", func(t *testing.T) {
+		publisher := newMemoryPublisher()
+		sink := &fakeErrorSink{}
+		m, dir := setupZeroByteUpload(t, WithPublisher(publisher), WithErrorSink(sink))
+
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Equal(t, 3, publishedFiles(t, publisher))
+		require.Empty(t, sink.events)
+		require.FileExists(t, filepath.Join(dir, "empty.txt"))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		publisher := newMemoryPublisher()
+		metrics := newMemoryMetrics()
+		m, dir := setupZeroByteUpload(t, WithPublisher(publisher), WithMetrics(metrics), WithZeroByteFilePolicy(ZeroByteFileSkip))
+
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Equal(t, 1, publishedFiles(t, publisher))
+		require.Equal(t, 2.0, metrics.counter("files_skipped", Labels{"endpoint": testEndpointID, "reason": "zero_byte"}))
+
+		// The empty files are removed so the file loader doesn't load them.
+		require.NoFileExists(t, filepath.Join(dir, "empty.txt"))
+		require.NoFileExists(t, filepath.Join(dir, "d1/empty.txt"))
+		require.FileExists(t, filepath.Join(dir, "d1/data.txt"))
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		publisher := newMemoryPublisher()
+		sink := &fakeErrorSink{}
+		m, dir := setupZeroByteUpload(t, WithPublisher(publisher), WithErrorSink(sink), WithZeroByteFilePolicy(ZeroByteFileFlag))
+
+		require.Equal(t, 1, m.retrieveAndProcessUploads(context.Background()).TasksProcessed)
+		require.Equal(t, 3, publishedFiles(t, publisher))
+		require.Len(t, sink.events, 1)
+		require.Equal(t, "zero_byte_files", sink.events[0].Kind)
+		require.Equal(t, []string{"d1/empty.txt", "empty.txt"}, sink.events[0].Fields["paths"])
+		require.FileExists(t, filepath.Join(dir, "empty.txt"))
+	})
+}
+
+func TestZeroByteFilesMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zero-byte-upload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Files that aren't in the directory can't be shown to be empty, so they are kept.
+	files := []UploadFile{{Source: "missing.txt", Path: "missing.txt"}}
+	empty, rest := zeroByteFiles(objectstore.NewLocalStore(""), dir, files)
+	require.Empty(t, empty)
+	require.Equal(t, files, rest)
+}
+
+func TestZeroByteFilesInObjectStore(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewMemoryStore()
+	_, err := store.Put(ctx, "/uploads/1/empty.txt", strings.NewReader(""))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, "/uploads/1/data.txt", strings.NewReader("data"))
+	require.NoError(t, err)
+
+	client := NewFakeGlobusClient()
+	client.AddUpload("task-1", "/__globus_uploads/1/empty.txt", "/__globus_uploads/1/data.txt")
+	publisher := newMemoryPublisher()
+	m := newTestMonitor(client, WithObjectStore(store), WithPublisher(publisher), WithZeroByteFilePolicy(ZeroByteFileSkip))
+	m.globusUploads.(*fakeGlobusUploadStore).add(&GlobusUpload{ID: 1, ProjectID: 1, OwnerID: 1, Path: "/uploads/1"})
+
+	require.Equal(t, 1, m.retrieveAndProcessUploads(ctx).TasksProcessed)
+	require.Equal(t, 1, publishedFiles(t, publisher))
+	require.Equal(t, []string{"/uploads/1/data.txt"}, store.Keys())
+}
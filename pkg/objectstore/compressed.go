@@ -0,0 +1,85 @@
+package objectstore
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+)
+
+// CompressedGetter is implemented by stores that can send the bytes of an object gzip compressed, so
+// large reads of compressible files take less time to come over a slow link.
+type CompressedGetter interface {
+	// GetCompressed returns the bytes Get returns for the same arguments, compressed with gzip. The
+	// caller closes the reader.
+	GetCompressed(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// ReadRange reads the bytes of key starting at offset into buf, and returns how many were read, which
+// is fewer than len(buf) at the end of the object. With compressed set, and a store that is a
+// CompressedGetter, the bytes are fetched compressed. When that fails for any reason other than the
+// object not being there, such as the store sending bytes that can't be decompressed, the bytes are
+// fetched again with Get.
+func ReadRange(ctx context.Context, store ObjectStore, key string, buf []byte, offset int64, compressed bool) (int, error) {
+	if getter, ok := store.(CompressedGetter); ok && compressed {
+		n, err := readRange(ctx, getter.GetCompressed, key, buf, offset, true)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return n, err
+		}
+	}
+
+	return readRange(ctx, store.Get, key, buf, offset, false)
+}
+
+type getFunc func(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+// readRange fills buf with what get returns, decompressing it when gzipped is set. Unlike
+// io.ReadFull it doesn't turn a reader that stops part way through with io.ErrUnexpectedEOF into a
+// short read, so a truncated compressed stream is an error.
+func readRange(ctx context.Context, get getFunc, key string, buf []byte, offset int64, gzipped bool) (int, error) {
+	r, err := get(ctx, key, offset, int64(len(buf)))
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var src io.Reader = r
+	if gzipped {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, err
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	n := 0
+	for n < len(buf) && err == nil {
+		var read int
+		read, err = src.Read(buf[n:])
+		n += read
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+// compress returns a reader of r's contents compressed with gzip, which closes r once it has been
+// read or is closed itself.
+func compress(r io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer r.Close()
+		zw, _ := gzip.NewWriterLevel(pw, gzip.BestSpeed)
+		_, err := io.Copy(zw, r)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr
+}
@@ -0,0 +1,86 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRange(t *testing.T) {
+	ctx := context.Background()
+	contents := []byte(strings.Repeat("sample,temperature,pressure\n", 1000))
+	for name, store := range testStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Put(ctx, "data.csv", bytes.NewReader(contents))
+			require.NoError(t, err)
+
+			for _, compressed := range []bool{false, true} {
+				buf := make([]byte, 4096)
+				n, err := ReadRange(ctx, store, "data.csv", buf, 1000, compressed)
+				require.NoError(t, err)
+				require.Equal(t, contents[1000:1000+4096], buf[:n], "compressed %t", compressed)
+
+				// A range that runs past the end of the object returns what is there.
+				n, err = ReadRange(ctx, store, "data.csv", buf, int64(len(contents)-10), compressed)
+				require.NoError(t, err)
+				require.Equal(t, contents[len(contents)-10:], buf[:n], "compressed %t", compressed)
+
+				_, err = ReadRange(ctx, store, "missing.csv", buf, 0, compressed)
+				require.True(t, errors.Is(err, ErrNotFound), "compressed %t", compressed)
+			}
+		})
+	}
+}
+
+// brokenCompressionStore sends compressed bytes that can't be decompressed, or err when it is set.
+type brokenCompressionStore struct {
+	*MemoryStore
+	err   error
+	calls int
+}
+
+func (s *brokenCompressionStore) GetCompressed(context.Context, string, int64, int64) (io.ReadCloser, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return ioutil.NopCloser(strings.NewReader("not gzip")), nil
+}
+
+func TestReadRangeFallsBackToGet(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "bad data"},
+		{name: "error", err: errors.New("compression not supported")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &brokenCompressionStore{MemoryStore: NewMemoryStore(), err: test.err}
+			_, err := store.Put(ctx, "data.csv", strings.NewReader("0123456789"))
+			require.NoError(t, err)
+
+			buf := make([]byte, 4)
+			n, err := ReadRange(ctx, store, "data.csv", buf, 3, true)
+			require.NoError(t, err)
+			require.Equal(t, "3456", string(buf[:n]))
+			require.Equal(t, 1, store.calls)
+		})
+	}
+
+	// A missing object isn't asked for again.
+	store := &brokenCompressionStore{MemoryStore: NewMemoryStore(), err: ErrNotFound}
+	_, err := ReadRange(ctx, store, "missing.csv", make([]byte, 4), 0, true)
+	require.True(t, errors.Is(err, ErrNotFound))
+}
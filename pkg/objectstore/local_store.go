@@ -0,0 +1,108 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore stores each object as a file under Root, with the key as the file's path below it. With
+// an empty Root the keys are paths on the local file system.
+type LocalStore struct {
+	Root string
+}
+
+var _ ObjectStore = (*LocalStore)(nil)
+var _ LocalFiler = (*LocalStore)(nil)
+var _ CompressedGetter = (*LocalStore)(nil)
+
+// NewLocalStore returns a LocalStore for the objects under root.
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{Root: root}
+}
+
+// LocalPath returns the path of the file holding key.
+func (s *LocalStore) LocalPath(key string) string {
+	return filepath.Join(s.Root, key)
+}
+
+// Get returns a reader for the file's contents from offset.
+func (s *LocalStore) Get(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.LocalPath(key))
+	if err != nil {
+		return nil, notFound(key, err)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	if length < 0 {
+		return f, nil
+	}
+
+	return readCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+// GetCompressed returns what Get does compressed with gzip. The file is read before it is compressed,
+// so nothing is saved for a local Root, but reads asking for compression behave the same with a
+// LocalStore as with a store that compresses on the far side.
+func (s *LocalStore) GetCompressed(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	r, err := s.Get(ctx, key, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return compress(r), nil
+}
+
+// Put writes r to the file, creating the directories above it when they don't exist. The file is
+// truncated and written in place, so anything that already has it open sees the new contents.
+func (s *LocalStore) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	path := s.LocalPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return n, err
+}
+
+// Stat describes the file. Only regular files are objects.
+func (s *LocalStore) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	info, err := os.Stat(s.LocalPath(key))
+	switch {
+	case err != nil:
+		return ObjectInfo{}, notFound(key, err)
+	case !info.Mode().IsRegular():
+		return ObjectInfo{}, fmt.Errorf("%w: %s isn't a file", ErrNotFound, key)
+	}
+
+	return ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes the file.
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	return notFound(key, os.Remove(s.LocalPath(key)))
+}
+
+// notFound turns a missing file error into ErrNotFound, leaving other errors as they are.
+func notFound(key string, err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return err
+}
@@ -0,0 +1,103 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps objects in memory. It is meant for tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+	now     func() time.Time
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+var _ ObjectStore = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject), now: time.Now}
+}
+
+// Get returns a reader over a copy of the object's contents, so later Puts don't change what it reads.
+func (s *MemoryStore) Get(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	data := obj.data
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(append([]byte(nil), data...))), nil
+}
+
+// Put reads r and stores it under key.
+func (s *MemoryStore) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryObject{data: data, modTime: s.now()}
+	return int64(len(data)), nil
+}
+
+// Stat describes the object.
+func (s *MemoryStore) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	return ObjectInfo{Key: key, Size: int64(len(obj.data)), ModTime: obj.modTime}, nil
+}
+
+// Delete removes the object.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[key]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	delete(s.objects, key)
+	return nil
+}
+
+// Keys returns the keys of the objects in the store, sorted.
+func (s *MemoryStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,54 @@
+// Package objectstore holds the contents of files as objects named by keys. The file system and the
+// Globus task monitor go through an ObjectStore rather than the local file system, so where the
+// contents live can be changed, and so the code reading and writing them can be tested against a
+// MemoryStore. Writes through the file system still need a local file to write to: with a
+// LocalFiler that is the object's own file, and with any other store it is a copy that is put back
+// into the store when the file is released.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when there is no object with the key asked for.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo describes an object.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// ObjectStore stores objects by key.
+type ObjectStore interface {
+	// Get returns the length bytes of key starting at offset. A negative length reads to the end of
+	// the object. The caller closes the reader.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Put replaces the contents of key with everything read from r, creating it if need be, and
+	// returns the number of bytes written.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+
+	// Stat describes key.
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalFiler is implemented by stores that keep each object in a local file, which can be opened
+// and changed in place.
+type LocalFiler interface {
+	// LocalPath returns the path of the file holding key.
+	LocalPath(key string) string
+}
+
+// readCloser closes c once r has been read.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,64 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testStores returns a LocalStore under a temporary directory and a MemoryStore, which should behave
+// the same.
+func testStores(t *testing.T) map[string]ObjectStore {
+	dir, err := ioutil.TempDir("", "objectstore")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return map[string]ObjectStore{"local": NewLocalStore(dir), "memory": NewMemoryStore()}
+}
+
+func readObject(t *testing.T, store ObjectStore, key string, offset, length int64) string {
+	r, err := store.Get(context.Background(), key, offset, length)
+	require.NoError(t, err)
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestObjectStores(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			n, err := store.Put(ctx, "ab/cd/object", strings.NewReader("0123456789"))
+			require.NoError(t, err)
+			require.Equal(t, int64(10), n)
+
+			require.Equal(t, "0123456789", readObject(t, store, "ab/cd/object", 0, -1))
+			require.Equal(t, "345", readObject(t, store, "ab/cd/object", 3, 3))
+			require.Equal(t, "89", readObject(t, store, "ab/cd/object", 8, 10))
+
+			info, err := store.Stat(ctx, "ab/cd/object")
+			require.NoError(t, err)
+			require.Equal(t, int64(10), info.Size)
+
+			// Put replaces the whole object.
+			_, err = store.Put(ctx, "ab/cd/object", strings.NewReader("new"))
+			require.NoError(t, err)
+			require.Equal(t, "new", readObject(t, store, "ab/cd/object", 0, -1))
+
+			require.NoError(t, store.Delete(ctx, "ab/cd/object"))
+			_, err = store.Stat(ctx, "ab/cd/object")
+			require.True(t, errors.Is(err, ErrNotFound))
+			_, err = store.Get(ctx, "ab/cd/object", 0, -1)
+			require.True(t, errors.Is(err, ErrNotFound))
+			require.True(t, errors.Is(store.Delete(ctx, "ab/cd/object"), ErrNotFound))
+		})
+	}
+}